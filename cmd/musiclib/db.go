@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/config"
+	"github.com/alserok/music_lib/internal/db/postgres"
+	"github.com/alserok/music_lib/internal/db/sqlite"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/jmoiron/sqlx"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrate manages the schema migrations, the embedded migrations are used unless -dir is set
+//
+//	musiclib migrate status
+//	musiclib migrate down-to 20241204151210
+func runMigrate(args []string) error {
+	name, args, err := subcommand(args, "up", "up-to", "down", "down-to", "status")
+	if err != nil {
+		return err
+	}
+
+	usage := ""
+	if name == "up-to" || name == "down-to" {
+		usage = "<version>"
+	}
+
+	fs := newFlagSet("migrate "+name, usage)
+	dir := fs.String("dir", "", "migrations directory, the embedded migrations if empty")
+	if err = fs.Parse(args); err != nil {
+		return err
+	}
+
+	var version int64
+	if usage != "" {
+		if fs.NArg() != 1 {
+			return fmt.Errorf("version is required")
+		}
+		if version, err = strconv.ParseInt(fs.Arg(0), 10, 64); err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", fs.Arg(0))
+		}
+	}
+
+	// the connection must not apply migrations by itself
+	conn, schema, err := mustOpenSchema()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	switch name {
+	case "up":
+		return schema.up(conn, *dir)
+	case "up-to":
+		return schema.upTo(conn, version, *dir)
+	case "down":
+		return schema.down(conn, *dir)
+	case "down-to":
+		return schema.downTo(conn, version, *dir)
+	}
+
+	migrations, err := schema.status(conn, *dir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tMIGRATION")
+	for _, migration := range migrations {
+		appliedAt := "pending"
+		if migration.Applied {
+			appliedAt = migration.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, appliedAt, migration.Name)
+	}
+
+	return w.Flush()
+}
+
+func runReindex(args []string) error {
+	fs := newFlagSet("reindex", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conn, schema, err := mustOpenSchema()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err = schema.reindex(ctx, conn); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "indexes were rebuilt")
+	return nil
+}
+
+// schema manages the database of a driver
+type schema struct {
+	up      func(conn *sqlx.DB, dir ...string) error
+	upTo    func(conn *sqlx.DB, version int64, dir ...string) error
+	down    func(conn *sqlx.DB, dir ...string) error
+	downTo  func(conn *sqlx.DB, version int64, dir ...string) error
+	status  func(conn *sqlx.DB, dir ...string) ([]models.Migration, error)
+	reindex func(ctx context.Context, conn *sqlx.DB) error
+}
+
+// mustOpenSchema connects to the configured database without applying migrations
+func mustOpenSchema() (*sqlx.DB, schema, error) {
+	cfg := config.MustLoad()
+
+	switch cfg.DB.Driver {
+	case config.DriverPostgres:
+		return postgres.MustOpen(cfg.DB.DSN()), schema{
+			up:      postgres.MigrateUp,
+			upTo:    postgres.MigrateUpTo,
+			down:    postgres.MigrateDown,
+			downTo:  postgres.MigrateDownTo,
+			status:  postgres.MigrationStatus,
+			reindex: postgres.Reindex,
+		}, nil
+	case config.DriverSQLite:
+		return sqlite.MustOpen(cfg.DB.SQLitePath), schema{
+			up:      sqlite.MigrateUp,
+			upTo:    sqlite.MigrateUpTo,
+			down:    sqlite.MigrateDown,
+			downTo:  sqlite.MigrateDownTo,
+			status:  sqlite.MigrationStatus,
+			reindex: sqlite.Reindex,
+		}, nil
+	}
+
+	return nil, schema{}, fmt.Errorf("the %s driver has no schema to manage", cfg.DB.Driver)
+}
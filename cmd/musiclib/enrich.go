@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"os"
+)
+
+// runEnrich fills the missing release date, text and link of songs from the song data API and prints a result
+// per song
+//
+//	musiclib enrich -pending
+//	musiclib enrich 4e3a5c1e-... 9b2f0d7a-...
+func runEnrich(args []string) error {
+	fs := newFlagSet("enrich", "[song IDs]")
+	pending := fs.Bool("pending", false, "enrich all songs that miss the release date, text or link")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *pending == (fs.NArg() > 0) {
+		return fmt.Errorf("either song IDs or -pending is required")
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	var (
+		results []models.BulkResult
+		err     error
+	)
+	if *pending {
+		results, err = lib.srvc.EnrichPendingSongs(lib.ctx)
+	} else {
+		results, err = lib.srvc.EnrichSongs(lib.ctx, fs.Args())
+	}
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		SongID string `json:"songID"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	failed := 0
+	report := make([]result, len(results))
+	for i, res := range results {
+		report[i].SongID = res.SongID
+		if res.Err != nil {
+			report[i].Error = res.Err.Error()
+			failed++
+		}
+	}
+
+	if err = writeJSON(report); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "total: %d failed: %d\n", len(results), failed)
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/alserok/music_lib/internal/exporter"
+	"github.com/alserok/music_lib/internal/service/models"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runExport writes the songs matching the filter to a file or stdout
+//
+//	musiclib export -format jsonl -group Muse -o muse.jsonl
+func runExport(args []string) error {
+	fs := newFlagSet("export", "")
+	var (
+		format = fs.String("format", "", "csv or jsonl, guessed by the output extension if empty, csv by default")
+		fields = fs.String("fields", "", "comma separated fields to export, all fields if empty")
+		out    = fs.String("o", "", "file to write the songs to, stdout if empty")
+	)
+	// the zero limit exports all songs
+	filter := songFilterFlags(fs, 0)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	songFilter, err := filter()
+	if err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = exporter.FormatCSV
+		if strings.EqualFold(filepath.Ext(*out), ".jsonl") {
+			*format = exporter.FormatJSONL
+		}
+	}
+
+	selected, err := exporter.ParseFields(*fields)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		w = f
+	}
+
+	buf := bufio.NewWriter(w)
+
+	writer, err := exporter.NewWriter(buf, *format, selected)
+	if err != nil {
+		return err
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	count := 0
+	err = lib.srvc.ExportSongs(lib.ctx, songFilter, func(song models.Song) error {
+		count++
+		return writer.Write(song)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported: %d\n", count)
+
+	return nil
+}
+
+// songFilterFlags defines the song filter flags, the returned func builds the filter after parsing
+func songFilterFlags(fs *flag.FlagSet, limit int) func() (models.SongFilter, error) {
+	var (
+		filter      models.SongFilter
+		releaseDate string
+	)
+	fs.StringVar(&filter.Group, "group", "", "group name")
+	fs.StringVar(&filter.Song, "song", "", "song name")
+	fs.StringVar(&filter.Text, "text", "", "text fragment")
+	fs.StringVar(&filter.Link, "link", "", "song link")
+	fs.StringVar(&releaseDate, "release-date", "", "release date like 2006-07-16")
+	fs.BoolVar(&filter.IncludeDeleted, "include-deleted", false, "include songs in the trash")
+	fs.IntVar(&filter.Lim, "limit", limit, "max number of songs")
+	fs.IntVar(&filter.Off, "offset", 0, "number of songs to skip")
+
+	return func() (models.SongFilter, error) {
+		if releaseDate != "" {
+			date, err := time.Parse(time.DateOnly, releaseDate)
+			if err != nil {
+				return models.SongFilter{}, fmt.Errorf("failed to parse release date: %w", err)
+			}
+			filter.ReleaseDate = &date
+		}
+
+		return filter, nil
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/importer"
+	"github.com/alserok/music_lib/internal/service/models"
+	"io"
+	"os"
+)
+
+// runImport imports a CSV or JSON Lines catalogue or a directory of audio files and prints the report as JSON
+//
+//	musiclib import -file catalogue.csv -columns group=Artist,song=Title -enrich
+//	musiclib import -dir ~/Music
+func runImport(args []string) error {
+	fs := newFlagSet("import", "")
+	var (
+		path      = fs.String("file", "", "CSV or JSON Lines file to import")
+		dir       = fs.String("dir", "", "directory of MP3, FLAC, Ogg and M4A files to import by their tags")
+		format    = fs.String("format", "", "csv or jsonl, guessed by the file extension if empty")
+		delimiter = fs.String("delimiter", "", "CSV delimiter, ',' by default, 'tab' for TSV")
+		encoding  = fs.String("encoding", "", "file encoding like windows-1251, UTF-8 by default")
+		columns   = fs.String("columns", "", "CSV column mapping like group=Artist,song=Title")
+		enrich    = fs.Bool("enrich", false, "request missing song data from the song data API")
+		out       = fs.String("report", "", "file to write the report to, stdout if empty")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var (
+		rows []models.ImportRow
+		err  error
+	)
+	switch {
+	case *path != "" && *dir != "":
+		return fmt.Errorf("file and dir are mutually exclusive")
+	case *dir != "":
+		rows, err = importer.ReadDir(*dir)
+	case *path != "":
+		rows, err = readImportFile(*path, *format, *delimiter, *encoding, *columns)
+	default:
+		return fmt.Errorf("file or dir is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	results, err := lib.srvc.ImportSongs(lib.ctx, rows, *enrich)
+	if err != nil {
+		return err
+	}
+
+	report := importer.NewReport(results, func(err error) string {
+		return err.Error()
+	})
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		w = f
+	}
+
+	if err = writeIndentedJSON(w, report); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "total: %d created: %d skipped: %d failed: %d\n", report.Total, report.Created, report.Skipped, report.Failed)
+
+	return nil
+}
+
+func readImportFile(path, format, delimiter, encoding, columns string) ([]models.ImportRow, error) {
+	opts := importer.Options{Format: format, Encoding: encoding}
+	if opts.Format == "" {
+		opts.Format = importer.FormatFromName(path)
+	}
+
+	var err error
+	if opts.Delimiter, err = importer.ParseDelimiter(delimiter); err != nil {
+		return nil, err
+	}
+	if opts.Columns, err = importer.ParseColumns(columns); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return importer.Read(file, opts)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/api"
+	"github.com/alserok/music_lib/internal/app"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/config"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+)
+
+// Manages the music library from the command line, the configuration is read from the env like for the server
+//
+//	go run ./cmd/musiclib serve
+//	go run ./cmd/musiclib migrate status
+//	go run ./cmd/musiclib songs list -group Muse
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage(os.Stdout)
+		return
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	err := cmd.run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %s\n", name, err.Error())
+		os.Exit(1)
+	}
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "serve", usage: "start the HTTP server", run: runServe},
+	{name: "migrate", usage: "up | up-to | down | down-to | status, manage database migrations", run: runMigrate},
+	{name: "import", usage: "import a CSV or JSON Lines catalogue or a directory of audio files", run: runImport},
+	{name: "export", usage: "export songs as CSV or JSON Lines", run: runExport},
+	{name: "enrich", usage: "request missing song data for the given song IDs or all songs with --pending", run: runEnrich},
+	{name: "songs", usage: "list | get | delete songs", run: runSongs},
+	{name: "users", usage: "role, grant a role to a user", run: runUsers},
+	{name: "reindex", usage: "rebuild database indexes and refresh statistics", run: runReindex},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: musiclib <command> [flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "musiclib <command> -h" for the command flags`)
+}
+
+// newFlagSet returns the flag set of the (sub)command, the errors are returned by Parse instead of exiting
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet("musiclib "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), strings.TrimSpace("usage: musiclib "+name+" [flags] "+args))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runServe(args []string) error {
+	fs := newFlagSet("serve", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app.MustStart(config.MustLoad())
+	return nil
+}
+
+// library is the service and the repository the commands work with
+type library struct {
+	ctx  context.Context
+	srvc service.Service
+
+	stop      context.CancelFunc
+	closeRepo func()
+}
+
+// mustOpenLibrary connects to the database applying migrations like the server does, the context is canceled
+// on interrupt
+func mustOpenLibrary() *library {
+	cfg := config.MustLoad()
+	log := logger.NewSlog(cfg.Env)
+
+	repo, closeRepo := app.MustOpenRepository(cfg)
+	srvc := service.New(repo, &service.Clients{SongDataAPIClient: api.NewSongDataClient(cfg.Clients.SongDataAPIAddr)})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx = logger.WrapLogger(ctx, log)
+	ctx = logger.WrapIdentifier(ctx)
+	ctx = actor.WrapActor(ctx, cliActor())
+	// the command line is used by the ones who run the library, so it is not restricted
+	ctx = auth.WrapRole(ctx, auth.RoleAdmin)
+
+	return &library{ctx: ctx, srvc: srvc, stop: stop, closeRepo: closeRepo}
+}
+
+func (l *library) close() {
+	l.stop()
+	l.closeRepo()
+}
+
+// cliActor records changes made from the command line under the OS user name
+func cliActor() string {
+	u, err := user.Current()
+	if err != nil || u.Username == "" {
+		return "cli"
+	}
+	return "cli:" + u.Username
+}
+
+// writeJSON prints v as indented JSON to stdout
+func writeJSON(v any) error {
+	return writeIndentedJSON(os.Stdout, v)
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// subcommand splits the args into the subcommand and its args
+func subcommand(args []string, names ...string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("expected one of: %s", strings.Join(names, ", "))
+	}
+
+	for _, name := range names {
+		if args[0] == name {
+			return name, args[1:], nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("unknown subcommand %q, expected one of: %s", args[0], strings.Join(names, ", "))
+}
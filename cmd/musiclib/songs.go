@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+)
+
+func runSongs(args []string) error {
+	name, args, err := subcommand(args, "list", "get", "delete")
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "list":
+		return runSongsList(args)
+	case "get":
+		return runSongsGet(args)
+	default:
+		return runSongsDelete(args)
+	}
+}
+
+// runSongsList prints the songs matching the filter as JSON
+//
+//	musiclib songs list -group Muse -limit 20
+func runSongsList(args []string) error {
+	fs := newFlagSet("songs list", "")
+	filter := songFilterFlags(fs, 10)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	songFilter, err := filter()
+	if err != nil {
+		return err
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	songs, err := lib.srvc.GetSongs(lib.ctx, songFilter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(songs)
+}
+
+func runSongsGet(args []string) error {
+	fs := newFlagSet("songs get", "<song ID>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("song ID is required")
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	song, err := lib.srvc.GetSong(lib.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(song)
+}
+
+// runSongsDelete moves the song to the trash, the current version is used unless -version is set
+func runSongsDelete(args []string) error {
+	fs := newFlagSet("songs delete", "<song ID>")
+	version := fs.Int("version", 0, "expected song version, the current one if 0")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("song ID is required")
+	}
+	songID := fs.Arg(0)
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	if *version == 0 {
+		song, err := lib.srvc.GetSong(lib.ctx, songID)
+		if err != nil {
+			return err
+		}
+		*version = song.Version
+	}
+
+	if err := lib.srvc.DeleteSong(lib.ctx, songID, *version); err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted %s at version %d\n", songID, *version)
+	return nil
+}
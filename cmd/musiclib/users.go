@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+)
+
+func runUsers(args []string) error {
+	_, args, err := subcommand(args, "role")
+	if err != nil {
+		return err
+	}
+
+	return runUsersRole(args)
+}
+
+// runUsersRole grants the role to the user, it is how the first admin is made
+//
+//	musiclib users role alice admin
+func runUsersRole(args []string) error {
+	fs := newFlagSet("users role", "<name> <viewer | editor | admin>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("user name and role are required")
+	}
+
+	lib := mustOpenLibrary()
+	defer lib.close()
+
+	user, err := lib.srvc.SetUserRole(lib.ctx, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is %s\n", user.Name, user.Role)
+	return nil
+}
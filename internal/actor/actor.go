@@ -0,0 +1,26 @@
+package actor
+
+import "context"
+
+type ContextActor string
+
+const ctxActorKey ContextActor = "ctx_actor"
+
+const (
+	Anonymous = "anonymous"
+)
+
+// WrapActor stores the name of the one who performs the request, it is recorded in song revisions
+func WrapActor(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxActorKey, name)
+}
+
+// ExtractActor returns the request actor or Anonymous if it was not set
+func ExtractActor(ctx context.Context) string {
+	name, ok := ctx.Value(ctxActorKey).(string)
+	if !ok || name == "" {
+		return Anonymous
+	}
+
+	return name
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/alserok/music_lib/internal/service/models"
 	"github.com/alserok/music_lib/internal/utils"
 	"net/http"
-	"net/http/httptest"
 	"time"
 )
 
@@ -19,7 +18,7 @@ type SongDataAPIClient interface {
 func NewSongDataClient(addr string) *songDataClient {
 	return &songDataClient{
 		addr: addr,
-		cl:   http.DefaultClient,
+		cl:   &http.Client{Timeout: 1 * time.Second},
 	}
 }
 
@@ -36,14 +35,16 @@ type songDataClient struct {
 func (s *songDataClient) GetSongData(ctx context.Context, group string, song string) (models.SongData, error) {
 	logger.ExtractLogger(ctx).Debug("SongDataAPI sending request", logger.WithArg("id", logger.ExtractIdentifier(ctx)))
 
-	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s%s", s.addr, pathInfo), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s", s.addr, pathInfo), nil)
+	if err != nil {
+		return models.SongData{}, utils.NewError(err.Error(), utils.Internal)
+	}
 
 	query := req.URL.Query()
 	query.Set("group", group)
 	query.Set("song", song)
 	req.URL.RawQuery = query.Encode()
 
-	s.cl.Timeout = 1 * time.Second
 	res, err := s.cl.Do(req)
 	if err != nil {
 		return models.SongData{}, utils.NewError(err.Error(), utils.Internal)
@@ -1,12 +1,20 @@
 package app
 
 import (
+	"context"
 	"github.com/alserok/music_lib/internal/api"
+	"github.com/alserok/music_lib/internal/auth"
 	"github.com/alserok/music_lib/internal/config"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/db/memory"
 	"github.com/alserok/music_lib/internal/db/postgres"
+	"github.com/alserok/music_lib/internal/db/sqlite"
+	"github.com/alserok/music_lib/internal/jobs"
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/server"
 	"github.com/alserok/music_lib/internal/service"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/time/rate"
 
 	_ "github.com/alserok/music_lib/docs"
 )
@@ -16,17 +24,111 @@ func MustStart(cfg *config.Config) {
 	log.Info("starting server")
 	defer log.Info("server was stopped")
 
-	conn := postgres.MustConnect(cfg.DB.DSN())
-	defer func() {
-		_ = conn.Close()
-	}()
+	repo, closeRepo := MustOpenRepository(cfg)
+	defer closeRepo()
 
 	songDataClient := api.NewSongDataClient(cfg.Clients.SongDataAPIAddr)
 
-	repo := postgres.NewRepository(conn)
-	srvc := service.New(repo, &service.Clients{SongDataAPIClient: songDataClient})
-	srvr := server.New(server.HTTP, srvc, log)
+	clients := &service.Clients{SongDataAPIClient: songDataClient}
+	if l := cfg.Clients.SongDataAPIRateLimit; l.Requests > 0 {
+		clients.SongDataAPILimiter = rate.NewLimiter(rate.Limit(float64(l.Requests)/l.Period.Seconds()), l.Requests)
+	}
+
+	srvc := service.New(repo, clients)
+
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	opts := server.Options{
+		RateLimits: server.RateLimits{
+			Read:  server.RateLimit(cfg.RateLimits.Read),
+			Write: server.RateLimit(cfg.RateLimits.Write),
+			Auth:  server.RateLimit(cfg.RateLimits.Auth),
+		},
+		TrustedProxies: cfg.TrustedProxies,
+		Cache: server.CachePolicies{
+			Songs:        server.CachePolicy(cfg.Cache.Songs),
+			SongText:     server.CachePolicy(cfg.Cache.SongText),
+			Song:         server.CachePolicy(cfg.Cache.Song),
+			SongSnapshot: server.CachePolicy(cfg.Cache.SongSnapshot),
+		},
+	}
+	if cfg.JWT.Enabled() {
+		opts.JWT = MustNewJWTVerifier(cfg)
+		if cfg.JWT.JWKSFile != "" {
+			go jobs.RunKeySetReload(jobsCtx, log, opts.JWT, cfg.JWT.JWKSFile, cfg.JWT.ReloadInterval)
+		}
+	}
+
+	srvr := server.New(server.HTTP, srvc, log, opts)
+
+	go jobs.RunTrashPurge(jobsCtx, log, srvc, cfg.Trash.PurgeInterval, cfg.Trash.Retention)
+	go jobs.RunSimilarityRebuild(jobsCtx, log, srvc, cfg.Recommendations.SimilarRebuildInterval)
+	go jobs.RunRecommendationTraining(jobsCtx, log, srvc, cfg.Recommendations.TrainInterval)
 
 	log.Info("server is running", logger.WithArg("port", cfg.Port))
 	srvr.MustServe(cfg.Port)
 }
+
+// MustNewJWTVerifier returns the verifier of the configured JWT keys, the file takes precedence over the inline set
+func MustNewJWTVerifier(cfg *config.Config) *auth.JWTVerifier {
+	var (
+		keys *auth.KeySet
+		err  error
+	)
+	if cfg.JWT.JWKSFile != "" {
+		keys, err = auth.LoadKeySet(cfg.JWT.JWKSFile)
+	} else {
+		keys, err = auth.ParseKeySet([]byte(cfg.JWT.JWKS))
+	}
+	if err != nil {
+		panic("failed to load JWT keys: " + err.Error())
+	}
+
+	return auth.NewJWTVerifier(keys, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.Leeway)
+}
+
+// MustOpenRepository opens the repository of the configured driver, databases apply pending migrations unless
+// auto migration is disabled. The returned func releases the repository
+func MustOpenRepository(cfg *config.Config) (db.Repository, func()) {
+	var conn *sqlx.DB
+
+	switch cfg.DB.Driver {
+	case config.DriverMemory:
+		return memory.NewRepository(), func() {}
+	case config.DriverSQLite:
+		if cfg.DB.AutoMigrate {
+			conn = sqlite.MustConnect(cfg.DB.SQLitePath)
+		} else {
+			conn = sqlite.MustOpen(cfg.DB.SQLitePath)
+		}
+
+		return sqlite.NewRepository(conn), func() {
+			_ = conn.Close()
+		}
+	}
+
+	if cfg.DB.AutoMigrate {
+		conn = postgres.MustConnect(cfg.DB.DSN())
+	} else {
+		conn = postgres.MustOpen(cfg.DB.DSN())
+	}
+
+	if len(cfg.DB.Replicas) == 0 {
+		return postgres.NewRepository(conn), func() {
+			_ = conn.Close()
+		}
+	}
+
+	replicas := postgres.MustOpenReplicas(cfg.DB.Replicas)
+	replicas.Check(context.Background())
+
+	checksCtx, cancelChecks := context.WithCancel(context.Background())
+	go replicas.RunHealthChecks(checksCtx, cfg.DB.ReplicaCheckInterval)
+
+	return postgres.NewReplicatedRepository(conn, replicas), func() {
+		cancelChecks()
+		_ = replicas.Close()
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"slices"
+	"strings"
+)
+
+const (
+	// ScopeRead reads the library
+	ScopeRead = "read"
+	// ScopeWrite creates and edits songs like an editor, it includes ScopeRead
+	ScopeWrite = "write"
+	// ScopeAdmin has the rights of an admin, it includes all other scopes
+	ScopeAdmin = "admin"
+	// ScopeExport exports the library and playlists
+	ScopeExport = "export"
+
+	// apiKeyPrefix tells API keys apart from other secrets, for example in leaked logs
+	apiKeyPrefix = "mlk_"
+	// apiKeyPrefixLen is the length of the key start kept to tell keys apart in listings
+	apiKeyPrefixLen = len(apiKeyPrefix) + 6
+)
+
+var scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin, ScopeExport}
+
+// ValidScope reports whether the scope is one of the known scopes
+func ValidScope(scope string) bool {
+	return slices.Contains(scopes, scope)
+}
+
+// NewAPIKey returns a random API key, its hash and its prefix, only the hash and the prefix are stored
+func NewAPIKey() (key string, hash string, prefix string, err error) {
+	token, _, err := NewToken()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key = apiKeyPrefix + token
+
+	return key, HashToken(key), key[:apiKeyPrefixLen], nil
+}
+
+// IsAPIKey reports whether the secret looks like an API key
+func IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix)
+}
+
+type ContextAPIKey string
+
+const ctxAPIKeyKey ContextAPIKey = "ctx_api_key"
+
+// WrapAPIKey stores the API key the request is authenticated with
+func WrapAPIKey(ctx context.Context, key models.APIKey) context.Context {
+	return context.WithValue(ctx, ctxAPIKeyKey, key)
+}
+
+// ExtractAPIKey returns the API key of the request, false if the request has none
+func ExtractAPIKey(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(ctxAPIKeyKey).(models.APIKey)
+	return key, ok
+}
+
+// HasScope reports whether the scopes include the scope
+func HasScope(granted []string, scope string) bool {
+	switch {
+	case slices.Contains(granted, ScopeAdmin):
+		return true
+	case scope == ScopeRead:
+		return slices.Contains(granted, ScopeRead) || slices.Contains(granted, ScopeWrite)
+	default:
+		return slices.Contains(granted, scope)
+	}
+}
+
+// RequireScope returns a Forbidden error for requests authenticated by an API key or a JWT without the scope,
+// other requests are checked by their role only
+func RequireScope(ctx context.Context, scope string) error {
+	granted, ok := extractScopes(ctx)
+	if !ok || HasScope(granted, scope) {
+		return nil
+	}
+
+	return utils.NewError("the "+scope+" scope is required", utils.Forbidden)
+}
+
+// extractScopes returns the scopes of the API key or of the JWT claims, false if the request has neither. JWTs
+// without the scope claim are limited by their roles only
+func extractScopes(ctx context.Context) ([]string, bool) {
+	if key, ok := ExtractAPIKey(ctx); ok {
+		return key.Scopes, true
+	}
+
+	if claims, ok := ExtractClaims(ctx); ok && claims.Scope != "" {
+		return strings.Fields(claims.Scope), true
+	}
+
+	return nil, false
+}
+
+// ScopesRole returns the role the scopes of an API key or a JWT grant, scopes without write or admin are viewers
+func ScopesRole(granted []string) string {
+	switch {
+	case HasScope(granted, ScopeAdmin):
+		return RoleAdmin
+	case HasScope(granted, ScopeWrite):
+		return RoleEditor
+	default:
+		return RoleViewer
+	}
+}
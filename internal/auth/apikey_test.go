@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"strings"
+)
+
+func (suite *AuthSuite) TestNewAPIKey() {
+	key, hash, prefix, err := NewAPIKey()
+	suite.Require().NoError(err)
+	suite.Require().True(IsAPIKey(key))
+	suite.Require().True(strings.HasPrefix(key, prefix))
+	suite.Require().Less(len(prefix), len(key)/2)
+	suite.Require().Equal(HashToken(key), hash)
+
+	other, _, _, err := NewAPIKey()
+	suite.Require().NoError(err)
+	suite.Require().NotEqual(key, other)
+}
+
+func (suite *AuthSuite) TestAPIKeyScopes() {
+	suite.Require().True(HasScope([]string{ScopeWrite}, ScopeRead))
+	suite.Require().False(HasScope([]string{ScopeWrite}, ScopeExport))
+	suite.Require().True(HasScope([]string{ScopeAdmin}, ScopeExport))
+	suite.Require().False(HasScope([]string{ScopeExport}, ScopeRead))
+
+	ctx := context.Background()
+	suite.Require().NoError(RequireScope(ctx, ScopeExport))
+
+	// keys have the role of their scopes as long as their owner has it
+	admin := WrapUser(ctx, models.User{Role: RoleAdmin})
+	suite.Require().Equal(RoleEditor, ExtractRole(WrapAPIKey(admin, models.APIKey{Scopes: []string{ScopeWrite}})))
+	suite.Require().Equal(RoleViewer, ExtractRole(WrapAPIKey(admin, models.APIKey{Scopes: []string{ScopeExport}})))
+
+	viewer := WrapAPIKey(WrapUser(ctx, models.User{Role: RoleViewer}), models.APIKey{Scopes: []string{ScopeAdmin}})
+	suite.Require().Equal(RoleViewer, ExtractRole(viewer))
+	suite.Require().NoError(RequireScope(viewer, ScopeExport))
+
+	reader := WrapAPIKey(admin, models.APIKey{Scopes: []string{ScopeRead}})
+	suite.Require().NoError(RequireScope(reader, ScopeRead))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(RequireScope(reader, ScopeExport)))
+}
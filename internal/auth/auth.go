@@ -0,0 +1,22 @@
+// Package auth keeps the authenticated user of a request and the credentials primitives of the accounts
+package auth
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/service/models"
+)
+
+type ContextUser string
+
+const ctxUserKey ContextUser = "ctx_user"
+
+// WrapUser stores the authenticated user of the request
+func WrapUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, ctxUserKey, user)
+}
+
+// ExtractUser returns the authenticated user of the request, false for anonymous requests
+func ExtractUser(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(ctxUserKey).(models.User)
+	return user, ok
+}
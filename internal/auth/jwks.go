@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// KeySet are the keys JWTs are verified with. Several keys are active at once while they rotate: new tokens are
+// signed with the new key and tokens signed with the old one stay valid till it is removed from the set
+type KeySet struct {
+	keys []verificationKey
+}
+
+type verificationKey struct {
+	kid string
+	alg string
+	key any
+}
+
+// jwk is a key of a JSON Web Key Set, see RFC 7517 and RFC 8037 for the Ed25519 keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// K is the secret of the symmetric keys
+	K string `json:"k"`
+	// N and E are the modulus and the exponent of the RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// Crv and X are the curve and the public key of the OKP keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// LoadKeySet reads the JSON Web Key Set file
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key set: %w", err)
+	}
+
+	return ParseKeySet(data)
+}
+
+// ParseKeySet parses the JSON Web Key Set of the HS256, RS256 and EdDSA keys. Keys meant for encryption are
+// skipped, a set without keys to verify signatures with is an error
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse key set: %w", err)
+	}
+
+	keys := &KeySet{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %d %q: %w", i, k.Kid, err)
+		}
+		keys.keys = append(keys.keys, key)
+	}
+
+	if len(keys.keys) == 0 {
+		return nil, errors.New("key set has no signature keys")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) parse() (verificationKey, error) {
+	key := verificationKey{kid: k.Kid, alg: k.Alg}
+
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return verificationKey{}, errors.New("invalid secret")
+		}
+		key.key = secret
+		key.alg = keyAlg(k.Alg, AlgHS256)
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return verificationKey{}, errors.New("invalid modulus")
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return verificationKey{}, errors.New("invalid exponent")
+		}
+
+		key.key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		key.alg = keyAlg(k.Alg, AlgRS256)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return verificationKey{}, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return verificationKey{}, errors.New("invalid public key")
+		}
+		key.key = ed25519.PublicKey(x)
+		key.alg = keyAlg(k.Alg, AlgEdDSA)
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	return key, nil
+}
+
+// keyAlg returns the algorithm of the key, keys without one are used with the only algorithm of their type
+// supported here. Other algorithms of the type are left unset, so no token matches them
+func keyAlg(alg, def string) string {
+	if alg == "" || alg == def {
+		return def
+	}
+	return ""
+}
+
+// lookup returns the keys of the algorithm, only the key of the ID if the token names one
+func (s *KeySet) lookup(kid, alg string) []any {
+	var keys []any
+	for _, key := range s.keys {
+		if key.alg != alg || (kid != "" && key.kid != kid) {
+			continue
+		}
+		keys = append(keys, key.key)
+	}
+
+	return keys
+}
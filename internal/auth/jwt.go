@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Claims are the claims of a verified JWT
+type Claims struct {
+	jwt.RegisteredClaims
+
+	// Scope are the space separated scopes granted to the token
+	Scope string `json:"scope,omitempty"`
+	// Roles are the roles of the service, the highest one is used
+	Roles []string `json:"roles,omitempty"`
+}
+
+type ContextClaims string
+
+const ctxClaimsKey ContextClaims = "ctx_claims"
+
+// WrapClaims stores the claims of the JWT the request is authenticated with
+func WrapClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, ctxClaimsKey, claims)
+}
+
+// ExtractClaims returns the claims of the request JWT, false if the request has none
+func ExtractClaims(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(ctxClaimsKey).(Claims)
+	return claims, ok
+}
+
+// JWTVerifier verifies the signature, the time and the audience claims of JWTs
+type JWTVerifier struct {
+	keys atomic.Pointer[KeySet]
+
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier returns the verifier of the tokens signed with the keys. The tokens must have the issuer and
+// the audience if they are set and must expire, leeway is the allowed clock skew
+func NewJWTVerifier(keys *KeySet, issuer, audience string, leeway time.Duration) *JWTVerifier {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	v := &JWTVerifier{parser: jwt.NewParser(opts...)}
+	v.keys.Store(keys)
+
+	return v
+}
+
+// SetKeys replaces the keys, tokens being verified keep the previous ones
+func (v *JWTVerifier) SetKeys(keys *KeySet) {
+	v.keys.Store(keys)
+}
+
+// Verify returns the claims of the token if it is valid
+func (v *JWTVerifier) Verify(token string) (Claims, error) {
+	keys := v.keys.Load()
+
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		found := keys.lookup(kid, t.Method.Alg())
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no %s key %q", t.Method.Alg(), kid)
+		}
+
+		set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, len(found))}
+		for i, key := range found {
+			set.Keys[i] = key
+		}
+
+		return set, nil
+	})
+	if err != nil {
+		return Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// IsJWT reports whether the token looks like a JWT rather than a session token
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"time"
+)
+
+func (suite *AuthSuite) TestVerifyAlgorithms() {
+	secret := []byte("secret of at least 32 bytes long")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	suite.Require().NoError(err)
+
+	keys, err := ParseKeySet([]byte(fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hs", "k": %q},
+		{"kty": "RSA", "kid": "rs", "alg": "RS256", "use": "sig", "n": %q, "e": %q},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "", "e": ""}
+	]}`,
+		b64(secret),
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(edPub),
+	)))
+	suite.Require().NoError(err)
+
+	verifier := NewJWTVerifier(keys, "issuer", "music_lib", 0)
+
+	tests := []struct {
+		name   string
+		method jwt.SigningMethod
+		kid    string
+		key    any
+	}{
+		{name: "HS256", method: jwt.SigningMethodHS256, kid: "hs", key: secret},
+		{name: "RS256", method: jwt.SigningMethodRS256, kid: "rs", key: rsaKey},
+		{name: "EdDSA", method: jwt.SigningMethodEdDSA, kid: "ed", key: edKey},
+		{name: "without kid", method: jwt.SigningMethodRS256, key: rsaKey},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			token := suite.sign(tc.method, tc.kid, tc.key, validClaims())
+
+			claims, err := verifier.Verify(token)
+			suite.Require().NoError(err)
+			suite.Require().Equal("service", claims.Subject)
+			suite.Require().Equal("songs:read", claims.Scope)
+		})
+	}
+
+	// the kid must name a key of the algorithm
+	_, err = verifier.Verify(suite.sign(jwt.SigningMethodHS256, "rs", secret, validClaims()))
+	suite.Require().Error(err)
+
+	// tokens signed with unsupported algorithms are rejected even with a known key
+	_, err = verifier.Verify(suite.sign(jwt.SigningMethodHS512, "hs", secret, validClaims()))
+	suite.Require().Error(err)
+}
+
+func (suite *AuthSuite) TestVerifyRotation() {
+	keys, err := ParseKeySet([]byte(fmt.Sprintf(`{"keys": [{"kty": "oct", "kid": "old", "k": %q}, {"kty": "oct", "kid": "new", "k": %q}]}`,
+		b64([]byte("old secret")), b64([]byte("new secret")))))
+	suite.Require().NoError(err)
+
+	verifier := NewJWTVerifier(keys, "", "", 0)
+
+	oldToken := suite.sign(jwt.SigningMethodHS256, "old", []byte("old secret"), validClaims())
+	newToken := suite.sign(jwt.SigningMethodHS256, "new", []byte("new secret"), validClaims())
+	unnamed := suite.sign(jwt.SigningMethodHS256, "", []byte("new secret"), validClaims())
+
+	for _, token := range []string{oldToken, newToken, unnamed} {
+		_, err = verifier.Verify(token)
+		suite.Require().NoError(err)
+	}
+
+	// the old key is retired
+	keys, err = ParseKeySet([]byte(fmt.Sprintf(`{"keys": [{"kty": "oct", "kid": "new", "k": %q}]}`, b64([]byte("new secret")))))
+	suite.Require().NoError(err)
+	verifier.SetKeys(keys)
+
+	_, err = verifier.Verify(oldToken)
+	suite.Require().Error(err)
+	_, err = verifier.Verify(newToken)
+	suite.Require().NoError(err)
+}
+
+func (suite *AuthSuite) TestVerifyClaims() {
+	secret := []byte("secret")
+	keys, err := ParseKeySet([]byte(fmt.Sprintf(`{"keys": [{"kty": "oct", "k": %q}]}`, b64(secret))))
+	suite.Require().NoError(err)
+
+	verifier := NewJWTVerifier(keys, "issuer", "music_lib", time.Minute)
+
+	tests := []struct {
+		name  string
+		edit  func(claims *Claims)
+		valid bool
+	}{
+		{name: "valid", edit: func(claims *Claims) {}, valid: true},
+		{name: "expired", edit: func(claims *Claims) { claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour)) }},
+		{name: "expired within leeway", edit: func(claims *Claims) {
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+		}, valid: true},
+		{name: "without expiration", edit: func(claims *Claims) { claims.ExpiresAt = nil }},
+		{name: "not yet valid", edit: func(claims *Claims) { claims.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) }},
+		{name: "other issuer", edit: func(claims *Claims) { claims.Issuer = "other" }},
+		{name: "other audience", edit: func(claims *Claims) { claims.Audience = jwt.ClaimStrings{"other"} }},
+		{name: "one of audiences", edit: func(claims *Claims) {
+			claims.Audience = jwt.ClaimStrings{"other", "music_lib"}
+		}, valid: true},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			claims := validClaims()
+			tc.edit(&claims)
+
+			_, err := verifier.Verify(suite.sign(jwt.SigningMethodHS256, "", secret, claims))
+			if tc.valid {
+				suite.Require().NoError(err)
+			} else {
+				suite.Require().Error(err)
+			}
+		})
+	}
+}
+
+func (suite *AuthSuite) TestParseInvalidKeySet() {
+	for _, set := range []string{
+		`not json`,
+		`{"keys": []}`,
+		`{"keys": [{"kty": "EC", "crv": "P-256"}]}`,
+		`{"keys": [{"kty": "oct", "k": ""}]}`,
+		`{"keys": [{"kty": "OKP", "crv": "X25519", "x": "AAAA"}]}`,
+		`{"keys": [{"kty": "RSA", "n": "AQAB"}]}`,
+	} {
+		_, err := ParseKeySet([]byte(set))
+		suite.Require().Error(err, set)
+	}
+}
+
+func (suite *AuthSuite) TestRequireScopeClaims() {
+	ctx := context.Background()
+
+	// tokens with scopes are limited by them, the scopes cap the roles
+	reader := WrapClaims(ctx, Claims{Scope: ScopeRead + " " + ScopeExport, Roles: []string{RoleAdmin}})
+	suite.Require().NoError(RequireScope(reader, ScopeRead))
+	suite.Require().NoError(RequireScope(reader, ScopeExport))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(RequireScope(reader, ScopeWrite)))
+	suite.Require().Equal(RoleViewer, ExtractRole(reader))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(RequireRole(reader, RoleAdmin)))
+
+	// scopes do not raise the roles
+	editor := WrapClaims(ctx, Claims{Scope: ScopeAdmin, Roles: []string{RoleEditor}})
+	suite.Require().Equal(RoleEditor, ExtractRole(editor))
+	suite.Require().Empty(ExtractRole(WrapClaims(ctx, Claims{Scope: ScopeAdmin})))
+
+	// tokens without scopes are limited by their roles only
+	unscoped := WrapClaims(ctx, Claims{Roles: []string{RoleAdmin}})
+	suite.Require().NoError(RequireScope(unscoped, ScopeRead))
+	suite.Require().NoError(RequireScope(unscoped, ScopeExport))
+	suite.Require().Equal(RoleAdmin, ExtractRole(unscoped))
+}
+
+func (suite *AuthSuite) sign(method jwt.SigningMethod, kid string, key any, claims Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	suite.Require().NoError(err)
+	suite.Require().True(IsJWT(signed))
+
+	return signed
+}
+
+func validClaims() Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "service",
+			Issuer:    "issuer",
+			Audience:  jwt.ClaimStrings{"music_lib"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		Scope: "songs:read",
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, new(AuthSuite))
+}
+
+type AuthSuite struct {
+	suite.Suite
+}
+
+func (suite *AuthSuite) TestHashPassword() {
+	hash, err := HashPassword("password")
+	suite.Require().NoError(err)
+	suite.Require().True(strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$"))
+
+	ok, err := VerifyPassword("password", hash)
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+
+	ok, err = VerifyPassword("Password", hash)
+	suite.Require().NoError(err)
+	suite.Require().False(ok)
+
+	// hashes are salted
+	other, err := HashPassword("password")
+	suite.Require().NoError(err)
+	suite.Require().NotEqual(hash, other)
+}
+
+func (suite *AuthSuite) TestVerifyInvalidHash() {
+	for _, hash := range []string{
+		"",
+		"password",
+		"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		"$argon2id$v=18$m=65536,t=3,p=4$c2FsdA$a2V5",
+		"$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$",
+	} {
+		_, err := VerifyPassword("password", hash)
+		suite.Require().Error(err, hash)
+	}
+}
+
+func (suite *AuthSuite) TestNewToken() {
+	token, hash, err := NewToken()
+	suite.Require().NoError(err)
+	suite.Require().Len(token, 43)
+	suite.Require().Equal(HashToken(token), hash)
+
+	other, _, err := NewToken()
+	suite.Require().NoError(err)
+	suite.Require().NotEqual(token, other)
+}
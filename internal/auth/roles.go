@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+const (
+	// RoleViewer reads the library, it is the role of new accounts
+	RoleViewer = "viewer"
+	// RoleEditor creates and edits songs
+	RoleEditor = "editor"
+	// RoleAdmin deletes and purges songs, manages migrations and the roles of users
+	RoleAdmin = "admin"
+)
+
+// roleRanks order the roles, a role has the rights of all lower ones
+var roleRanks = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ValidRole reports whether the role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+type ContextRole string
+
+const ctxRoleKey ContextRole = "ctx_role"
+
+// WrapRole grants the role to the context whoever performs the request, jobs and the command line use it
+func WrapRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, ctxRoleKey, role)
+}
+
+// ExtractRole returns the role granted to the context, the role of the API key scopes, of the user or the highest
+// role of the JWT claims capped by their scopes. Anonymous requests have no role
+func ExtractRole(ctx context.Context) string {
+	if role, ok := ctx.Value(ctxRoleKey).(string); ok {
+		return role
+	}
+
+	user, isUser := ExtractUser(ctx)
+
+	// a key has the rights of its scopes as long as its owner still has them
+	if key, ok := ExtractAPIKey(ctx); ok {
+		role := ScopesRole(key.Scopes)
+		if isUser && !HasRole(user.Role, role) {
+			return user.Role
+		}
+		return role
+	}
+
+	if isUser {
+		return user.Role
+	}
+
+	var role string
+	if claims, ok := ExtractClaims(ctx); ok {
+		for _, r := range claims.Roles {
+			if roleRanks[r] > roleRanks[role] {
+				role = r
+			}
+		}
+
+		// the scopes of a token cap its roles like the scopes of a key cap the role of its owner
+		if scopes, ok := extractScopes(ctx); ok && HasRole(role, ScopesRole(scopes)) {
+			return ScopesRole(scopes)
+		}
+	}
+
+	return role
+}
+
+// RequireRole returns an Unauthorized error for anonymous requests and a Forbidden error for requests which role
+// is lower than the required one
+func RequireRole(ctx context.Context, role string) error {
+	current := ExtractRole(ctx)
+	if current == "" {
+		return utils.NewError("authentication required", utils.Unauthorized)
+	}
+
+	if !HasRole(current, role) {
+		return utils.NewError("the "+role+" role is required", utils.Forbidden)
+	}
+
+	return nil
+}
+
+// HasRole reports whether the current role has the rights of the role
+func HasRole(current, role string) bool {
+	return roleRanks[current] >= roleRanks[role]
+}
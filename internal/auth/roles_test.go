@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+func (suite *AuthSuite) TestRequireRole() {
+	ctx := context.Background()
+
+	suite.Require().Equal(utils.Unauthorized, utils.ErrorCode(RequireRole(ctx, RoleViewer)))
+
+	editor := WrapUser(ctx, models.User{Role: RoleEditor})
+	suite.Require().NoError(RequireRole(editor, RoleViewer))
+	suite.Require().NoError(RequireRole(editor, RoleEditor))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(RequireRole(editor, RoleAdmin)))
+
+	// services get the highest of their roles, unknown roles grant nothing
+	service := WrapClaims(ctx, Claims{Roles: []string{"owner", RoleAdmin, RoleViewer}})
+	suite.Require().Equal(RoleAdmin, ExtractRole(service))
+	suite.Require().Equal("", ExtractRole(WrapClaims(ctx, Claims{Roles: []string{"owner"}})))
+
+	// granted roles take precedence
+	suite.Require().NoError(RequireRole(WrapRole(ctx, RoleAdmin), RoleAdmin))
+	suite.Require().Equal(RoleViewer, ExtractRole(WrapRole(editor, RoleViewer)))
+}
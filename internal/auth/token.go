@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// tokenLen is the number of random bytes of a token
+const tokenLen = 32
+
+// NewToken returns a random bearer token and its hash, only the hash is stored
+func NewToken() (token string, hash string, err error) {
+	b := make([]byte, tokenLen)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash a token is stored with. Tokens are random, so a fast hash is enough
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -11,13 +15,119 @@ type Config struct {
 	Port string
 	Env  string
 
-	DB Postgres
+	// TrustedProxies are the networks of the proxies which X-Forwarded-For headers tell the client addresses
+	TrustedProxies []*net.IPNet
+
+	DB DB
 
 	Clients Clients
+
+	Trash Trash
+
+	Recommendations Recommendations
+
+	JWT JWT
+
+	RateLimits RateLimits
+
+	Cache Cache
+}
+
+// Cache are the Cache-Control policies of the read routes, the responses are revalidated with their ETags
+type Cache struct {
+	// Songs is the policy of the song lists, they change with every edit of any song
+	Songs CachePolicy
+	// SongText is the policy of the song texts split into verses
+	SongText CachePolicy
+	// Song is the policy of the songs with their stats, they change with every favorite, rating and play
+	Song CachePolicy
+	// SongSnapshot is the policy of the songs as of a revision or a time
+	SongSnapshot CachePolicy
+}
+
+// CachePolicy lets clients keep the responses for MaxAge, Private keeps them out of shared caches and NoCache
+// makes clients revalidate them on every request
+type CachePolicy struct {
+	MaxAge  time.Duration
+	Private bool
+	NoCache bool
+}
+
+// RateLimits are the budgets of every client in the groups of routes, a zero budget disables the limit
+type RateLimits struct {
+	// Read limits reading and exporting the library
+	Read RateLimit
+	// Write limits creating, editing and deleting songs, new songs are enriched by the song data API
+	Write RateLimit
+	// Auth limits registrations, logins and API key management, passwords are slow to hash on purpose
+	Auth RateLimit
+}
+
+// RateLimit allows Requests per Period, they can be spent at once
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// JWT configures the authentication of other services by bearer JWTs, it is disabled if no keys are set
+type JWT struct {
+	// JWKSFile is the JSON Web Key Set file, it is reloaded every ReloadInterval to pick up rotated keys
+	JWKSFile       string
+	ReloadInterval time.Duration
+	// JWKS is the JSON Web Key Set itself, it is used if no file is set
+	JWKS string
+
+	// Issuer and Audience are required in the tokens if they are set
+	Issuer   string
+	Audience string
+	// Leeway is the allowed clock skew of the token times
+	Leeway time.Duration
+}
+
+// Enabled reports whether the keys are configured
+func (j *JWT) Enabled() bool {
+	return j.JWKSFile != "" || j.JWKS != ""
+}
+
+type Trash struct {
+	// Retention is how long deleted songs stay in the trash before they are purged
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+type Recommendations struct {
+	// SimilarRebuildInterval is how often the index of similar songs is built from the whole library to catch up
+	// with the changes made by other instances
+	SimilarRebuildInterval time.Duration
+	// TrainInterval is how often the recommendations are trained on the plays and favorites of all users
+	TrainInterval time.Duration
 }
 
 type Clients struct {
 	SongDataAPIAddr string
+	// SongDataAPIRateLimit is the budget of the requests to the song data API of all clients together, a zero budget
+	// disables the limit
+	SongDataAPIRateLimit RateLimit
+}
+
+const (
+	DriverPostgres = "postgres"
+	// DriverSQLite keeps the library in a single file, it needs no database server
+	DriverSQLite = "sqlite"
+	// DriverMemory keeps the library in the process memory, it is lost on restart
+	DriverMemory = "memory"
+)
+
+type DB struct {
+	Driver string
+
+	// AutoMigrate applies pending migrations on startup
+	AutoMigrate bool
+
+	Postgres
+
+	// SQLitePath is the database file of the sqlite driver
+	SQLitePath string
 }
 
 type Postgres struct {
@@ -26,6 +136,11 @@ type Postgres struct {
 	User string
 	Pass string
 	Name string
+
+	// Replicas are the DSNs of read replicas, the read-only queries are spread over the healthy ones
+	Replicas []string
+	// ReplicaCheckInterval is how often the health of the replicas is checked
+	ReplicaCheckInterval time.Duration
 }
 
 func (p *Postgres) DSN() string {
@@ -37,14 +152,190 @@ func MustLoad() *Config {
 
 	cfg.Port = os.Getenv("PORT")
 	cfg.Env = os.Getenv("ENV")
+	cfg.TrustedProxies = mustParseNetworks("TRUSTED_PROXIES")
 
+	cfg.DB.Driver = mustParseDriver("DB_DRIVER")
 	cfg.DB.Host = os.Getenv("DB_HOST")
 	cfg.DB.Port = os.Getenv("DB_PORT")
 	cfg.DB.User = os.Getenv("DB_USER")
 	cfg.DB.Pass = os.Getenv("DB_PASS")
 	cfg.DB.Name = os.Getenv("DB_NAME")
+	cfg.DB.Replicas = parseList("DB_REPLICA_DSNS")
+	cfg.DB.ReplicaCheckInterval = mustParseInterval("DB_REPLICA_CHECK_INTERVAL", 5*time.Second)
+	cfg.DB.AutoMigrate = mustParseBool("DB_AUTO_MIGRATE", true)
+	cfg.DB.SQLitePath = os.Getenv("DB_SQLITE_PATH")
+	if cfg.DB.SQLitePath == "" {
+		cfg.DB.SQLitePath = "music_lib.db"
+	}
 
 	cfg.Clients.SongDataAPIAddr = os.Getenv("SONG_DATA_API_ADDR")
+	cfg.Clients.SongDataAPIRateLimit = mustParseRateLimit("SONG_DATA_API_RATE_LIMIT", RateLimit{Requests: 10, Period: time.Second})
+
+	cfg.JWT.JWKSFile = os.Getenv("JWT_JWKS_FILE")
+	cfg.JWT.ReloadInterval = mustParseInterval("JWT_JWKS_RELOAD_INTERVAL", time.Minute)
+	cfg.JWT.JWKS = os.Getenv("JWT_JWKS")
+	cfg.JWT.Issuer = os.Getenv("JWT_ISSUER")
+	cfg.JWT.Audience = os.Getenv("JWT_AUDIENCE")
+	cfg.JWT.Leeway = mustParseDuration("JWT_LEEWAY", 30*time.Second)
+
+	cfg.RateLimits.Read = mustParseRateLimit("RATE_LIMIT_READ", RateLimit{Requests: 600, Period: time.Minute})
+	cfg.RateLimits.Write = mustParseRateLimit("RATE_LIMIT_WRITE", RateLimit{Requests: 60, Period: time.Minute})
+	cfg.RateLimits.Auth = mustParseRateLimit("RATE_LIMIT_AUTH", RateLimit{Requests: 10, Period: time.Minute})
+
+	cfg.Cache.Songs = mustParseCachePolicy("CACHE_SONGS", CachePolicy{NoCache: true})
+	cfg.Cache.SongText = mustParseCachePolicy("CACHE_SONG_TEXT", CachePolicy{MaxAge: time.Minute})
+	cfg.Cache.Song = mustParseCachePolicy("CACHE_SONG", CachePolicy{NoCache: true})
+	cfg.Cache.SongSnapshot = mustParseCachePolicy("CACHE_SONG_SNAPSHOT", CachePolicy{MaxAge: time.Minute})
+
+	cfg.Trash.Retention = mustParseInterval("TRASH_RETENTION", 30*24*time.Hour)
+	cfg.Trash.PurgeInterval = mustParseInterval("TRASH_PURGE_INTERVAL", time.Hour)
+
+	cfg.Recommendations.SimilarRebuildInterval = mustParseInterval("SIMILAR_REBUILD_INTERVAL", 6*time.Hour)
+	cfg.Recommendations.TrainInterval = mustParseInterval("RECOMMENDATIONS_TRAIN_INTERVAL", time.Hour)
 
 	return &cfg
 }
+
+// mustParseDuration reads a duration like "720h" from the env, def is used if the variable is empty
+func mustParseDuration(key string, def time.Duration) time.Duration {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s: %s", key, err.Error()))
+	}
+
+	return d
+}
+
+// mustParseInterval reads the interval of a job or a retention like "1h" from the env, it must be positive and def
+// is used if the variable is empty
+func mustParseInterval(key string, def time.Duration) time.Duration {
+	d := mustParseDuration(key, def)
+	if d <= 0 {
+		panic(fmt.Sprintf("invalid %s: the interval must be positive", key))
+	}
+
+	return d
+}
+
+// mustParseBool reads a boolean like "true" or "0" from the env, def is used if the variable is empty
+func mustParseBool(key string, def bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s: %s", key, err.Error()))
+	}
+
+	return b
+}
+
+// mustParseRateLimit reads a budget like "60/1m" from the env, "0" disables the limit and def is used if the
+// variable is empty
+func mustParseRateLimit(key string, def RateLimit) RateLimit {
+	val := os.Getenv(key)
+	switch val {
+	case "":
+		return def
+	case "0":
+		return RateLimit{}
+	}
+
+	requests, period, ok := strings.Cut(val, "/")
+	if !ok {
+		panic(fmt.Sprintf("invalid %s: expected <requests>/<period>", key))
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		panic(fmt.Sprintf("invalid %s: invalid number of requests %q", key, requests))
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		panic(fmt.Sprintf("invalid %s: invalid period %q", key, period))
+	}
+
+	return RateLimit{Requests: n, Period: d}
+}
+
+// mustParseCachePolicy reads a policy like "private,30s" or "no-cache" from the env, the max age is a duration and
+// def is used if the variable is empty
+func mustParseCachePolicy(key string, def CachePolicy) CachePolicy {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+
+	var policy CachePolicy
+	for _, directive := range strings.Split(val, ",") {
+		switch directive = strings.TrimSpace(directive); directive {
+		case "private":
+			policy.Private = true
+		case "no-cache":
+			policy.NoCache = true
+		default:
+			d, err := time.ParseDuration(directive)
+			if err != nil || d < 0 {
+				panic(fmt.Sprintf("invalid %s: invalid directive %q", key, directive))
+			}
+			policy.MaxAge = d
+		}
+	}
+
+	return policy
+}
+
+// parseList reads a comma separated list from the env, empty items are skipped
+func parseList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// mustParseNetworks reads a comma separated list of networks like "10.0.0.0/8" from the env, addresses are networks
+// of a single address
+func mustParseNetworks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, item := range parseList(key) {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			panic(fmt.Sprintf("invalid %s: %s", key, err.Error()))
+		}
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// mustParseDriver reads the database driver from the env, postgres is used if the variable is empty
+func mustParseDriver(key string) string {
+	switch val := os.Getenv(key); val {
+	case "":
+		return DriverPostgres
+	case DriverPostgres, DriverSQLite, DriverMemory:
+		return val
+	default:
+		panic(fmt.Sprintf("invalid %s: unknown driver %q", key, val))
+	}
+}
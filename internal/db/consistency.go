@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type ContextConsistency string
+
+const ctxConsistencyKey ContextConsistency = "ctx_consistency"
+
+// WrapReadYourWrites makes the reads of the context go to the primary database after its first write, so a request
+// sees its own changes whatever the replication lag is
+func WrapReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxConsistencyKey, new(atomic.Bool))
+}
+
+// WrapPrimary makes all reads of the context go to the primary database
+func WrapPrimary(ctx context.Context) context.Context {
+	pinned := new(atomic.Bool)
+	pinned.Store(true)
+
+	return context.WithValue(ctx, ctxConsistencyKey, pinned)
+}
+
+// MarkWrite is called by repositories after a write, it pins the context wrapped with WrapReadYourWrites to the primary
+func MarkWrite(ctx context.Context) {
+	if pinned, ok := ctx.Value(ctxConsistencyKey).(*atomic.Bool); ok {
+		pinned.Store(true)
+	}
+}
+
+// ReadsPrimary reports whether the reads of the context must go to the primary database
+func ReadsPrimary(ctx context.Context) bool {
+	pinned, ok := ctx.Value(ctxConsistencyKey).(*atomic.Bool)
+	return ok && pinned.Load()
+}
@@ -0,0 +1,317 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ratingEntry struct {
+	rating  int
+	ratedAt time.Time
+}
+
+type playEntry struct {
+	userID string
+	play   models.Play
+}
+
+// songStats are the counters of the song_stats table of the postgres repository
+type songStats struct {
+	favorites int64
+	ratings   int64
+	ratingSum int64
+	plays     int64
+}
+
+func (s songStats) toModel() *models.SongStats {
+	stats := &models.SongStats{Favorites: s.favorites, Ratings: s.ratings, Plays: s.plays}
+	if s.ratings != 0 {
+		stats.AverageRating = float64(s.ratingSum) / float64(s.ratings)
+	}
+
+	return stats
+}
+
+func (r *repository) SetFavorite(ctx context.Context, userID string, songID string, favorite bool) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received SetFavorite",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID, songID = canonicalID(userID), canonicalID(songID)
+	if _, ok := r.users[userID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	_, favorited := r.favorites[userID][songID]
+	switch {
+	case !favorite && favorited:
+		delete(r.favorites[userID], songID)
+		r.addStats(songID, songStats{favorites: -1})
+	case favorite:
+		if !r.live(songID) {
+			return utils.NewError("song not found", utils.NotFound)
+		}
+		if favorited {
+			break
+		}
+
+		if r.favorites[userID] == nil {
+			r.favorites[userID] = make(map[string]time.Time)
+		}
+		r.favorites[userID][songID] = timestamp(time.Now().UTC())
+		r.addStats(songID, songStats{favorites: 1})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed SetFavorite",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetFavorites(ctx context.Context, userID string, lim, off int) ([]models.Favorite, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(lim, off); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	favorites := make([]models.Favorite, 0)
+	for songID, favoritedAt := range r.favorites[canonicalID(userID)] {
+		if r.live(songID) {
+			favorites = append(favorites, models.Favorite{Song: cloneSong(r.songs[songID].song), FavoritedAt: favoritedAt})
+		}
+	}
+
+	slices.SortFunc(favorites, func(a, b models.Favorite) int {
+		return cmp.Or(b.FavoritedAt.Compare(a.FavoritedAt), strings.Compare(a.Song.SongID, b.Song.SongID))
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return page(favorites, lim, off), nil
+}
+
+func (r *repository) RateSong(ctx context.Context, userID string, songID string, rating int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID, songID = canonicalID(userID), canonicalID(songID)
+	if _, ok := r.users[userID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	old := r.ratings[userID][songID]
+	switch {
+	case rating == 0 && old.rating != 0:
+		delete(r.ratings[userID], songID)
+		r.addStats(songID, songStats{ratings: -1, ratingSum: -int64(old.rating)})
+	case rating != 0:
+		if !r.live(songID) {
+			return utils.NewError("song not found", utils.NotFound)
+		}
+		if rating < models.MinRating || rating > models.MaxRating {
+			return utils.NewError(`new row for relation "ratings" violates check constraint "ratings_rating_check"`,
+				utils.Internal)
+		}
+
+		if r.ratings[userID] == nil {
+			r.ratings[userID] = make(map[string]ratingEntry)
+		}
+		r.ratings[userID][songID] = ratingEntry{rating: rating, ratedAt: timestamp(time.Now().UTC())}
+
+		delta := songStats{ratingSum: int64(rating - old.rating)}
+		if old.rating == 0 {
+			delta.ratings = 1
+		}
+		r.addStats(songID, delta)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetRatings(ctx context.Context, userID string, lim, off int) ([]models.Rating, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(lim, off); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ratings := make([]models.Rating, 0)
+	for songID, entry := range r.ratings[canonicalID(userID)] {
+		if r.live(songID) {
+			ratings = append(ratings, models.Rating{
+				Song:    cloneSong(r.songs[songID].song),
+				Rating:  entry.rating,
+				RatedAt: entry.ratedAt,
+			})
+		}
+	}
+
+	slices.SortFunc(ratings, func(a, b models.Rating) int {
+		return cmp.Or(b.RatedAt.Compare(a.RatedAt), strings.Compare(a.Song.SongID, b.Song.SongID))
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return page(ratings, lim, off), nil
+}
+
+func (r *repository) AddPlay(ctx context.Context, userID string, play models.Play) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID = canonicalID(userID)
+	if _, ok := r.users[userID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	songID := canonicalID(play.Song.SongID)
+	if !r.live(songID) {
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	play.PlayID = canonicalID(play.PlayID)
+	if play.PlayID == "" || slices.ContainsFunc(r.plays, func(entry playEntry) bool {
+		return entry.play.PlayID == play.PlayID
+	}) {
+		return utils.NewError("invalid play ID", utils.Internal)
+	}
+	if play.Duration < 0 {
+		return utils.NewError(`new row for relation "plays" violates check constraint "plays_duration_check"`,
+			utils.Internal)
+	}
+
+	play.Song = models.Song{SongID: songID}
+	play.PlayedAt = timestamp(play.PlayedAt)
+	r.plays = append(r.plays, playEntry{userID: userID, play: play})
+	r.addStats(songID, songStats{plays: 1})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlays(ctx context.Context, userID string, lim, off int) ([]models.Play, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(lim, off); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID = canonicalID(userID)
+
+	plays := make([]models.Play, 0)
+	for _, entry := range r.plays {
+		if entry.userID == userID && r.live(entry.play.Song.SongID) {
+			play := entry.play
+			play.Song = cloneSong(r.songs[play.Song.SongID].song)
+			plays = append(plays, play)
+		}
+	}
+
+	slices.SortFunc(plays, func(a, b models.Play) int {
+		return cmp.Or(b.PlayedAt.Compare(a.PlayedAt), strings.Compare(a.PlayID, b.PlayID))
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return page(plays, lim, off), nil
+}
+
+// live reports whether the song is in the library and not in the trash
+func (r *repository) live(songID string) bool {
+	entry, ok := r.songs[songID]
+	return ok && entry.song.DeletedAt == nil
+}
+
+func (r *repository) addStats(songID string, delta songStats) {
+	stats := r.stats[songID]
+	stats.favorites += delta.favorites
+	stats.ratings += delta.ratings
+	stats.ratingSum += delta.ratingSum
+	stats.plays += delta.plays
+	r.stats[songID] = stats
+}
+
+// removeActivity removes the favorites, ratings, plays and neighbors of the purged song like the cascades of
+// postgres
+func (r *repository) removeActivity(songID string) {
+	for _, favorites := range r.favorites {
+		delete(favorites, songID)
+	}
+	for _, ratings := range r.ratings {
+		delete(ratings, songID)
+	}
+	r.plays = slices.DeleteFunc(r.plays, func(entry playEntry) bool {
+		return entry.play.Song.SongID == songID
+	})
+	delete(r.stats, songID)
+	r.removeNeighbors(songID)
+}
+
+// page applies OFFSET and LIMIT to the list
+func page[T any](list []T, lim, off int) []T {
+	if off >= len(list) {
+		return list[:0]
+	}
+	list = list[off:]
+
+	return list[:min(lim, len(list))]
+}
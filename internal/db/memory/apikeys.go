@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+type apiKeyEntry struct {
+	key  models.APIKey
+	hash string
+}
+
+func (r *repository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key.OwnerID = canonicalID(key.OwnerID)
+	if _, ok := r.users[key.OwnerID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	key.KeyID = canonicalID(key.KeyID)
+	if _, ok := r.apiKeys[key.KeyID]; ok || key.KeyID == "" {
+		return utils.NewError("invalid API key ID", utils.Internal)
+	}
+
+	for _, entry := range r.apiKeys {
+		if entry.hash == keyHash {
+			return utils.NewError("duplicate API key", utils.Internal)
+		}
+	}
+
+	key.Scopes = slices.Clone(key.Scopes)
+	key.CreatedAt, key.LastUsedAt, key.RevokedAt = timestamp(time.Now().UTC()), nil, nil
+	r.apiKeys[key.KeyID] = apiKeyEntry{key: key, hash: keyHash}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetAPIKey(ctx context.Context, keyID string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.apiKeys[canonicalID(keyID)]
+	if !ok {
+		return models.APIKey{}, utils.NewError("API key not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return cloneAPIKey(entry.key), nil
+}
+
+func (r *repository) GetAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID = canonicalID(ownerID)
+
+	var entries []apiKeyEntry
+	for _, entry := range r.apiKeys {
+		if entry.key.OwnerID == ownerID {
+			entries = append(entries, entry)
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b apiKeyEntry) int {
+		if c := a.key.CreatedAt.Compare(b.key.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.key.KeyID, b.key.KeyID)
+	})
+
+	keys := make([]models.APIKey, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, cloneAPIKey(entry.key))
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return keys, nil
+}
+
+func (r *repository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received UseAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, entry := range r.apiKeys {
+		if entry.hash != keyHash || entry.key.RevokedAt != nil {
+			continue
+		}
+
+		now := timestamp(time.Now().UTC())
+		if entry.key.LastUsedAt == nil || entry.key.LastUsedAt.Before(now.Add(-db.LastUseInterval)) {
+			entry.key.LastUsedAt = &now
+			r.apiKeys[id] = entry
+		}
+
+		logger.ExtractLogger(ctx).
+			Debug("repo passed UseAPIKey",
+				logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+			)
+
+		return cloneAPIKey(entry.key), nil
+	}
+
+	return models.APIKey{}, utils.NewError("API key not found", utils.NotFound)
+}
+
+func (r *repository) RevokeAPIKey(ctx context.Context, keyID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keyID = canonicalID(keyID)
+	entry, ok := r.apiKeys[keyID]
+	if !ok {
+		return utils.NewError("API key not found", utils.NotFound)
+	}
+
+	if entry.key.RevokedAt == nil {
+		now := timestamp(time.Now().UTC())
+		entry.key.RevokedAt = &now
+		r.apiKeys[keyID] = entry
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func cloneAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	if key.LastUsedAt != nil {
+		usedAt := *key.LastUsedAt
+		key.LastUsedAt = &usedAt
+	}
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		key.RevokedAt = &revokedAt
+	}
+	return key
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"time"
+)
+
+func (r *repository) CreateSongs(ctx context.Context, songs []models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+	for i := range songs {
+		songs[i].Version = 1
+		songs[i].UpdatedAt = t.now
+
+		if err := t.create(ctx, songs[i]); err != nil {
+			return err
+		}
+	}
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs := r.applyBulk(len(songs), atomic, func(t *tx, i int) error {
+		return t.edit(ctx, songs[i])
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+func (r *repository) DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs := r.applyBulk(len(songs), atomic, func(t *tx, i int) error {
+		return t.delete(ctx, songs[i].SongID, songs[i].Version)
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+// applyBulk runs apply for n items under a single lock. In the atomic mode it stops at the first failure
+// and discards everything, otherwise every item gets its own tx so a failure discards only that item
+func (r *repository) applyBulk(n int, atomic bool, apply func(t *tx, i int) error) []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := timestamp(time.Now().UTC())
+	t := r.beginAt(now)
+
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		if atomic {
+			if errs[i] = apply(t, i); errs[i] != nil {
+				return errs
+			}
+			continue
+		}
+
+		item := r.beginAt(now)
+		if errs[i] = apply(item, i); errs[i] == nil {
+			item.commit()
+		}
+	}
+
+	t.commit()
+
+	return errs
+}
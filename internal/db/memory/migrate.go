@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+)
+
+// the memory repository has no schema, so there are no migrations to list or apply
+
+func (r *repository) GetMigrations(ctx context.Context) ([]models.Migration, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetMigrations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return []models.Migration{}, nil
+}
+
+func (r *repository) MigrateUp(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MigrateUp",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) MigrateDown(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MigrateDown",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
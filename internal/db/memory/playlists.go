@@ -0,0 +1,495 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+type playlistEntry struct {
+	playlist models.Playlist
+	// smart is the smart playlist policy as JSON like the smart column of the postgres repository, so the rules are
+	// never shared with callers
+	smart []byte
+	// collaborators are the times the users were added at by their IDs
+	collaborators map[string]time.Time
+	songs         []playlistSongEntry
+}
+
+type playlistSongEntry struct {
+	entryID  string
+	songID   string
+	position string
+	addedBy  string
+	addedAt  time.Time
+}
+
+func (r *repository) CreatePlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	playlist.OwnerID = canonicalID(playlist.OwnerID)
+	if _, ok := r.users[playlist.OwnerID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	playlist.PlaylistID = canonicalID(playlist.PlaylistID)
+	if _, ok := r.playlists[playlist.PlaylistID]; ok || playlist.PlaylistID == "" {
+		return utils.NewError("invalid playlist ID", utils.Internal)
+	}
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	now := timestamp(time.Now().UTC())
+	playlist.Collaborators, playlist.Songs, playlist.Smart = nil, nil, nil
+	playlist.CreatedAt, playlist.UpdatedAt = now, now
+	r.playlists[playlist.PlaylistID] = &playlistEntry{
+		playlist:      playlist,
+		smart:         smart,
+		collaborators: make(map[string]time.Time),
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return models.Playlist{}, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	playlist, err := entry.model()
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	playlist.Collaborators = make([]models.PlaylistCollaborator, 0, len(entry.collaborators))
+	for userID, addedAt := range entry.collaborators {
+		playlist.Collaborators = append(playlist.Collaborators, models.PlaylistCollaborator{
+			UserID:  userID,
+			Name:    r.users[userID].Name,
+			AddedAt: addedAt,
+		})
+	}
+
+	slices.SortFunc(playlist.Collaborators, func(a, b models.PlaylistCollaborator) int {
+		if c := a.AddedAt.Compare(b.AddedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlist, nil
+}
+
+func (r *repository) GetPlaylists(ctx context.Context, filter models.PlaylistFilter) ([]models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	memberID := canonicalID(filter.MemberID)
+
+	var playlists []models.Playlist
+	for _, entry := range r.playlists {
+		if filter.MemberID == "" {
+			if entry.playlist.Visibility != models.PlaylistPublic {
+				continue
+			}
+		} else if _, ok := entry.collaborators[memberID]; !ok && entry.playlist.OwnerID != memberID {
+			continue
+		}
+
+		playlist, err := entry.model()
+		if err != nil {
+			return nil, err
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	slices.SortFunc(playlists, func(a, b models.Playlist) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.PlaylistID, b.PlaylistID)
+	})
+
+	if filter.Off >= len(playlists) {
+		return []models.Playlist{}, nil
+	}
+	playlists = playlists[filter.Off:]
+	playlists = playlists[:min(filter.Lim, len(playlists))]
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlists, nil
+}
+
+func (r *repository) EditPlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.playlists[canonicalID(playlist.PlaylistID)]
+	if !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	entry.smart = smart
+	entry.playlist.Name, entry.playlist.Description, entry.playlist.Visibility = playlist.Name, playlist.Description,
+		playlist.Visibility
+	entry.playlist.UpdatedAt = timestamp(time.Now().UTC())
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeletePlaylist(ctx context.Context, playlistID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	playlistID = canonicalID(playlistID)
+	if _, ok := r.playlists[playlistID]; !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+	delete(r.playlists, playlistID)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) AddPlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	userID = canonicalID(userID)
+	if _, ok = r.users[userID]; !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	if _, ok = entry.collaborators[userID]; !ok {
+		entry.collaborators[userID] = timestamp(time.Now().UTC())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return utils.NewError("collaborator not found", utils.NotFound)
+	}
+
+	userID = canonicalID(userID)
+	if _, ok = entry.collaborators[userID]; !ok {
+		return utils.NewError("collaborator not found", utils.NotFound)
+	}
+	delete(entry.collaborators, userID)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylistSongs(ctx context.Context, playlistID string) ([]models.PlaylistSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return nil, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	songs := make([]models.PlaylistSong, 0, len(entry.songs))
+	for _, song := range entry.songs {
+		songs = append(songs, models.PlaylistSong{
+			EntryID:  song.entryID,
+			Position: song.position,
+			Song:     cloneSong(r.songs[song.songID].song),
+			AddedBy:  song.addedBy,
+			AddedAt:  song.addedAt,
+		})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) AddPlaylistSong(ctx context.Context, playlistID string, entry models.PlaylistSong) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	playlist, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	songID := canonicalID(entry.Song.SongID)
+	if song, ok := r.songs[songID]; !ok || song.song.DeletedAt != nil {
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	entryID := canonicalID(entry.EntryID)
+	if entryID == "" || slices.ContainsFunc(playlist.songs, func(song playlistSongEntry) bool {
+		return song.entryID == entryID
+	}) {
+		return utils.NewError("invalid playlist entry ID", utils.Internal)
+	}
+
+	if playlist.taken(entry.Position) {
+		return utils.NewError("position is taken", utils.Conflict)
+	}
+
+	addedBy := canonicalID(entry.AddedBy)
+	if _, ok := r.users[addedBy]; !ok {
+		addedBy = ""
+	}
+
+	now := timestamp(time.Now().UTC())
+	playlist.songs = append(playlist.songs, playlistSongEntry{
+		entryID:  entryID,
+		songID:   songID,
+		position: entry.Position,
+		addedBy:  addedBy,
+		addedAt:  now,
+	})
+	playlist.sort()
+	playlist.playlist.UpdatedAt = now
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) MovePlaylistSong(ctx context.Context, playlistID string, entryID string, position string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	playlist, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	i := playlist.find(canonicalID(entryID))
+	if i < 0 {
+		return utils.NewError("playlist entry not found", utils.NotFound)
+	}
+
+	if playlist.songs[i].position != position {
+		if playlist.taken(position) {
+			return utils.NewError("position is taken", utils.Conflict)
+		}
+
+		playlist.songs[i].position = position
+		playlist.sort()
+	}
+	playlist.playlist.UpdatedAt = timestamp(time.Now().UTC())
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	playlist, ok := r.playlists[canonicalID(playlistID)]
+	if !ok {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	i := playlist.find(canonicalID(entryID))
+	if i < 0 {
+		return utils.NewError("playlist entry not found", utils.NotFound)
+	}
+
+	playlist.songs = slices.Delete(playlist.songs, i, i+1)
+	playlist.playlist.UpdatedAt = timestamp(time.Now().UTC())
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+// removeFromPlaylists removes the song from all playlists and updates them, the repository lock must be held
+func (r *repository) removeFromPlaylists(songID string, now time.Time) {
+	for _, playlist := range r.playlists {
+		songs := slices.DeleteFunc(playlist.songs, func(song playlistSongEntry) bool {
+			return song.songID == songID
+		})
+		if len(songs) != len(playlist.songs) {
+			playlist.songs, playlist.playlist.UpdatedAt = songs, now
+		}
+	}
+}
+
+// find returns the index of the entry or -1
+// model returns the playlist without its collaborators and songs
+func (p *playlistEntry) model() (models.Playlist, error) {
+	playlist := p.playlist
+	if p.smart != nil {
+		playlist.Smart = new(models.SmartPlaylist)
+		if err := json.Unmarshal(p.smart, playlist.Smart); err != nil {
+			return models.Playlist{}, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	return playlist, nil
+}
+
+// smartJSON returns the smart playlist policy as JSON, nil for regular playlists
+func smartJSON(smart *models.SmartPlaylist) ([]byte, error) {
+	if smart == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(smart)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return b, nil
+}
+
+func (p *playlistEntry) find(entryID string) int {
+	return slices.IndexFunc(p.songs, func(song playlistSongEntry) bool {
+		return song.entryID == entryID
+	})
+}
+
+func (p *playlistEntry) taken(position string) bool {
+	return slices.ContainsFunc(p.songs, func(song playlistSongEntry) bool {
+		return song.position == position
+	})
+}
+
+// sort orders the songs by their positions byte by byte, like the "C" collation of the postgres column
+func (p *playlistEntry) sort() {
+	slices.SortFunc(p.songs, func(a, b playlistSongEntry) int {
+		return strings.Compare(a.position, b.position)
+	})
+}
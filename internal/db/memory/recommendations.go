@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"slices"
+	"strings"
+)
+
+func (r *repository) StreamInteractions(ctx context.Context, fn func(interaction models.Interaction) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// the interactions are collected first, so fn sees a single snapshot and may change the repository
+	r.mu.RLock()
+	interactions := make(map[[2]string]models.Interaction)
+	for _, entry := range r.plays {
+		if !r.live(entry.play.Song.SongID) {
+			continue
+		}
+
+		key := [2]string{entry.userID, entry.play.Song.SongID}
+		interaction := interactions[key]
+		interaction.UserID, interaction.SongID = key[0], key[1]
+		interaction.Plays++
+		if entry.play.PlayedAt.After(interaction.LastAt) {
+			interaction.LastAt = entry.play.PlayedAt
+		}
+		interactions[key] = interaction
+	}
+	for userID, favorites := range r.favorites {
+		for songID, favoritedAt := range favorites {
+			if !r.live(songID) {
+				continue
+			}
+
+			key := [2]string{userID, songID}
+			interaction := interactions[key]
+			interaction.UserID, interaction.SongID = key[0], key[1]
+			interaction.Favorite = true
+			if favoritedAt.After(interaction.LastAt) {
+				interaction.LastAt = favoritedAt
+			}
+			interactions[key] = interaction
+		}
+	}
+	r.mu.RUnlock()
+
+	sorted := make([]models.Interaction, 0, len(interactions))
+	for _, interaction := range interactions {
+		sorted = append(sorted, interaction)
+	}
+	slices.SortFunc(sorted, func(a, b models.Interaction) int {
+		return cmp.Or(strings.Compare(a.UserID, b.UserID), b.LastAt.Compare(a.LastAt), strings.Compare(a.SongID, b.SongID))
+	})
+
+	for _, interaction := range sorted {
+		if err := ctx.Err(); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if err := fn(interaction); err != nil {
+			return err
+		}
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) ReplaceSongNeighbors(ctx context.Context, neighbors []models.SongNeighbor) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := make(map[string][]models.SongNeighbor)
+	for _, neighbor := range neighbors {
+		neighbor.SongID, neighbor.NeighborID = canonicalID(neighbor.SongID), canonicalID(neighbor.NeighborID)
+
+		// songs purged since the training are skipped
+		_, songOK := r.songs[neighbor.SongID]
+		_, neighborOK := r.songs[neighbor.NeighborID]
+		if !songOK || !neighborOK {
+			continue
+		}
+
+		replaced[neighbor.SongID] = append(replaced[neighbor.SongID], neighbor)
+	}
+	r.neighbors = replaced
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetRecommendations(ctx context.Context, userID string, lim int) ([]models.Recommendation, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(lim, 0); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID = canonicalID(userID)
+
+	heard := make(map[string]struct{})
+	for _, entry := range r.plays {
+		if entry.userID == userID {
+			heard[entry.play.Song.SongID] = struct{}{}
+		}
+	}
+	for songID := range r.favorites[userID] {
+		heard[songID] = struct{}{}
+	}
+
+	scores := make(map[string]float64)
+	for songID := range heard {
+		for _, neighbor := range r.neighbors[songID] {
+			scores[neighbor.NeighborID] += neighbor.Score
+		}
+	}
+
+	recommendations := make([]models.Recommendation, 0)
+	for songID, entry := range r.songs {
+		if _, ok := heard[songID]; ok || entry.song.DeletedAt != nil {
+			continue
+		}
+
+		song := cloneSong(entry.song)
+		song.Stats = r.stats[songID].toModel()
+		recommendations = append(recommendations, models.Recommendation{Song: song, Score: scores[songID]})
+	}
+
+	slices.SortFunc(recommendations, func(a, b models.Recommendation) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(b.Song.Stats.Plays, a.Song.Stats.Plays),
+			strings.Compare(a.Song.SongID, b.Song.SongID))
+	})
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return page(recommendations, lim, 0), nil
+}
+
+// removeNeighbors removes the neighbors of the purged song and the song from the neighbors of other songs
+func (r *repository) removeNeighbors(songID string) {
+	delete(r.neighbors, songID)
+	for id, neighbors := range r.neighbors {
+		r.neighbors[id] = slices.DeleteFunc(neighbors, func(neighbor models.SongNeighbor) bool {
+			return neighbor.NeighborID == songID
+		})
+	}
+}
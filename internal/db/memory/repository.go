@@ -0,0 +1,499 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRepository returns an empty library kept in the process memory. It follows the postgres repository
+// semantics, including the errors, and is meant for tests and demos
+func NewRepository() *repository {
+	return &repository{
+		songs:     make(map[string]songEntry),
+		revisions: make(map[string][]models.SongRevision),
+		users:     make(map[string]models.User),
+		sessions:  make(map[string]sessionEntry),
+		apiKeys:   make(map[string]apiKeyEntry),
+		playlists: make(map[string]*playlistEntry),
+		favorites: make(map[string]map[string]time.Time),
+		ratings:   make(map[string]map[string]ratingEntry),
+		stats:     make(map[string]songStats),
+		neighbors: make(map[string][]models.SongNeighbor),
+	}
+}
+
+type repository struct {
+	mu sync.RWMutex
+
+	songs map[string]songEntry
+	// revisions of every song in the order of versions
+	revisions map[string][]models.SongRevision
+
+	// seq orders the songs by creation, like the rows of a table without ORDER BY
+	seq int64
+
+	users map[string]models.User
+	// sessions by token hashes
+	sessions map[string]sessionEntry
+	// apiKeys by key IDs
+	apiKeys map[string]apiKeyEntry
+	// playlists by playlist IDs
+	playlists map[string]*playlistEntry
+
+	// favorites are the times songs were favorited at by user IDs and song IDs
+	favorites map[string]map[string]time.Time
+	// ratings by user IDs and song IDs
+	ratings map[string]map[string]ratingEntry
+	// plays in the order they were recorded
+	plays []playEntry
+	// stats by song IDs
+	stats map[string]songStats
+	// neighbors of the recommendations by song IDs
+	neighbors map[string][]models.SongNeighbor
+}
+
+type songEntry struct {
+	song models.Song
+	seq  int64
+	// stats are set for the songs of filterSongs
+	stats *models.SongStats
+}
+
+func (r *repository) CreateSong(ctx context.Context, song models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+	if err := t.create(ctx, song); err != nil {
+		return err
+	}
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) EditSong(ctx context.Context, song models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+	if err := t.edit(ctx, song); err != nil {
+		return err
+	}
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeleteSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+	if err := t.delete(ctx, songID, version); err != nil {
+		return err
+	}
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetSong(ctx context.Context, songID string) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.songs[canonicalID(songID)]
+	if !ok || entry.song.DeletedAt != nil {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	song := cloneSong(entry.song)
+	song.Stats = r.stats[song.SongID].toModel()
+
+	return song, nil
+}
+
+func (r *repository) GetSongsByID(ctx context.Context, songIDs []string) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	songs := make([]models.Song, 0, len(songIDs))
+	seen := make(map[string]struct{}, len(songIDs))
+	for _, songID := range songIDs {
+		entry, ok := r.songs[canonicalID(songID)]
+		if !ok || entry.song.DeletedAt != nil {
+			continue
+		}
+		if _, ok = seen[entry.song.SongID]; ok {
+			continue
+		}
+		seen[entry.song.SongID] = struct{}{}
+
+		song := cloneSong(entry.song)
+		song.Stats = r.stats[song.SongID].toModel()
+		songs = append(songs, song)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) GetSongText(ctx context.Context, songID string) (string, time.Time, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongText",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.songs[canonicalID(songID)]
+	if !ok || entry.song.DeletedAt != nil {
+		return "", time.Time{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongText",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return entry.song.Data.Text, entry.song.UpdatedAt, nil
+}
+
+func (r *repository) GetSongs(ctx context.Context, filter models.SongFilter) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if filter.SongID != "" && canonicalID(filter.SongID) == "" {
+		return []models.Song{}, nil
+	}
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return nil, err
+	}
+
+	popularity, ok := songOrders[filter.Sort]
+	if !ok {
+		return nil, utils.NewError("unknown sort: "+filter.Sort, utils.Internal)
+	}
+
+	r.mu.RLock()
+	entries := r.filterSongs(filter)
+	if filter.Rules != nil {
+		now := time.Now().UTC()
+		entries = slices.DeleteFunc(entries, func(entry songEntry) bool {
+			return !r.matchRules(*filter.Rules, entry, now)
+		})
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b songEntry) int {
+		if popularity != nil {
+			return cmp.Or(popularity(b.stats, a.stats), strings.Compare(a.song.SongID, b.song.SongID))
+		}
+		return int(a.seq - b.seq)
+	})
+
+	songs := make([]models.Song, 0, filter.Lim)
+	for _, entry := range paginate(entries, filter.Lim, filter.Off, false) {
+		entry.song.Stats = entry.stats
+		songs = append(songs, entry.song)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) StreamSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if filter.SongID != "" && canonicalID(filter.SongID) == "" {
+		return nil
+	}
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return err
+	}
+
+	// the songs are copied, so fn sees a single snapshot of the library and may change it
+	r.mu.RLock()
+	entries := r.filterSongs(filter)
+	r.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b songEntry) int {
+		return strings.Compare(a.song.SongID, b.song.SongID)
+	})
+
+	for _, entry := range paginate(entries, filter.Lim, filter.Off, true) {
+		if err := ctx.Err(); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if err := fn(entry.song); err != nil {
+			return err
+		}
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) FindSongs(ctx context.Context, songs []models.NewSong) ([]models.NewSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	live := make(map[models.NewSong]bool, len(r.songs))
+	for _, entry := range r.songs {
+		if entry.song.DeletedAt == nil {
+			live[models.NewSong{Group: entry.song.Group, Song: entry.song.Song}] = true
+		}
+	}
+
+	var found []models.NewSong
+	for _, song := range songs {
+		if live[song] {
+			found = append(found, song)
+			// found songs are distinct
+			delete(live, song)
+		}
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return found, nil
+}
+
+// songOrders compare the stats of songs for the sorts of models.SongFilter, nil keeps the order of the library
+var songOrders = map[string]func(a, b *models.SongStats) int{
+	"": nil,
+	models.SortPlays: func(a, b *models.SongStats) int {
+		return cmp.Compare(a.Plays, b.Plays)
+	},
+	models.SortFavorites: func(a, b *models.SongStats) int {
+		return cmp.Compare(a.Favorites, b.Favorites)
+	},
+	models.SortRating: func(a, b *models.SongStats) int {
+		return cmp.Or(cmp.Compare(a.AverageRating, b.AverageRating), cmp.Compare(a.Ratings, b.Ratings))
+	},
+}
+
+// filterSongs returns copies of the songs matching the filter with their stats, the pagination is not applied
+func (r *repository) filterSongs(filter models.SongFilter) []songEntry {
+	songID := canonicalID(filter.SongID)
+
+	var entries []songEntry
+	for _, entry := range r.songs {
+		song, stats := entry.song, r.stats[entry.song.SongID].toModel()
+		switch {
+		case filter.SongID != "" && song.SongID != songID,
+			filter.Group != "" && !contains(song.Group, filter.Group),
+			filter.Song != "" && !contains(song.Song, filter.Song),
+			filter.ReleaseDate != nil && !song.Data.ReleaseDate.Equal(timestamp(*filter.ReleaseDate)),
+			filter.Text != "" && !contains(song.Data.Text, filter.Text),
+			filter.Link != "" && song.Data.Link != filter.Link,
+			song.DeletedAt != nil && !filter.IncludeDeleted,
+			stats.Plays < filter.MinPlays,
+			stats.AverageRating < filter.MinRating:
+			continue
+		}
+
+		entries = append(entries, songEntry{song: cloneSong(song), seq: entry.seq, stats: stats})
+	}
+
+	return entries
+}
+
+// paginate applies OFFSET and LIMIT, the zero limit selects nothing unless zeroIsAll is set
+func paginate(entries []songEntry, lim, off int, zeroIsAll bool) []songEntry {
+	if off >= len(entries) {
+		return nil
+	}
+	entries = entries[off:]
+
+	if lim == 0 && zeroIsAll {
+		return entries
+	}
+	return entries[:min(lim, len(entries))]
+}
+
+func validatePagination(lim, off int) error {
+	if lim < 0 {
+		return utils.NewError("LIMIT must not be negative", utils.Internal)
+	}
+	if off < 0 {
+		return utils.NewError("OFFSET must not be negative", utils.Internal)
+	}
+	return nil
+}
+
+// contains matches like the LIKE '%' || substr || '%' filters of the postgres repository, % and _ of substr
+// are wildcards and \ escapes them
+func contains(s, substr string) bool {
+	return like([]rune(s), []rune("%"+substr+"%"))
+}
+
+// like matches with the two pointer algorithm in O(len(s) * len(pattern)), on a mismatch the last % takes one
+// more rune of s and the rest of the pattern is matched again
+func like(s, pattern []rune) bool {
+	tokens := likeTokens(pattern)
+
+	var (
+		si, ti int
+		// star is the token after the last %, -1 if there was none, mark is where the rest of s is matched from
+		star, mark = -1, 0
+	)
+	for si < len(s) {
+		switch {
+		case ti < len(tokens) && tokens[ti].anySeq:
+			ti++
+			star, mark = ti, si
+		case ti < len(tokens) && (tokens[ti].anyOne || tokens[ti].r == s[si]):
+			si, ti = si+1, ti+1
+		case star >= 0:
+			mark++
+			si, ti = mark, star
+		default:
+			return false
+		}
+	}
+
+	for ti < len(tokens) && tokens[ti].anySeq {
+		ti++
+	}
+
+	return ti == len(tokens)
+}
+
+// likeToken is a rune, a _ matching any rune or a % matching any runes of a LIKE pattern
+type likeToken struct {
+	r      rune
+	anyOne bool
+	anySeq bool
+}
+
+// likeTokens resolves the escapes of the pattern, consecutive % are collapsed as they match the same
+func likeTokens(pattern []rune) []likeToken {
+	tokens := make([]likeToken, 0, len(pattern))
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '%':
+			if len(tokens) == 0 || !tokens[len(tokens)-1].anySeq {
+				tokens = append(tokens, likeToken{anySeq: true})
+			}
+		case '_':
+			tokens = append(tokens, likeToken{anyOne: true})
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			tokens = append(tokens, likeToken{r: pattern[i]})
+		default:
+			tokens = append(tokens, likeToken{r: pattern[i]})
+		}
+	}
+
+	return tokens
+}
+
+// canonicalID returns the song ID in the form postgres returns uuid values, IDs that are not UUIDs can not
+// belong to any song and are returned empty
+func canonicalID(songID string) string {
+	id, err := uuid.Parse(songID)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// timestamp converts the time like a postgres timestamp column does: the wall clock is kept without the zone
+// and the precision is microseconds. The changes are made at the UTC wall clock like on a postgres server in UTC
+func timestamp(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+}
+
+func cloneSong(song models.Song) models.Song {
+	if song.DeletedAt != nil {
+		deletedAt := *song.DeletedAt
+		song.DeletedAt = &deletedAt
+	}
+	return song
+}
@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/db/repotest"
+	"github.com/stretchr/testify/suite"
+	"testing"
+)
+
+func TestConformanceSuite(t *testing.T) {
+	suite.Run(t, &repotest.Suite{
+		NewRepository: func(s *suite.Suite) (db.Repository, func()) {
+			return NewRepository(), func() {}
+		},
+	})
+}
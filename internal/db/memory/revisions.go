@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+func (r *repository) RestoreSong(ctx context.Context, songID string, revision int, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+
+	before, err := t.lock(songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	target, err := t.revision(before.SongID, revision)
+	if err != nil {
+		return err
+	}
+	if target.After == nil {
+		return utils.NewError("revision deletes the song and can not be restored", utils.BadRequest)
+	}
+
+	song := *target.After
+	song.SongID = before.SongID
+	t.update(&song, before)
+
+	t.addRevision(ctx, models.RevisionRestore, &before, &song, revision)
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if canonicalID(songID) == "" {
+		return []models.SongRevision{}, nil
+	}
+
+	if err := validatePagination(lim, off); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.revisions[canonicalID(songID)]
+
+	revisions := make([]models.SongRevision, 0, lim)
+	// the revisions are stored in the order of versions and returned from the newest one
+	for i := len(stored) - 1 - off; i >= 0 && len(revisions) < lim; i-- {
+		revisions = append(revisions, cloneRevision(stored[i]))
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revisions, nil
+}
+
+func (r *repository) GetSongRevision(ctx context.Context, songID string, version int) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revision, err := r.begin().revision(canonicalID(songID), version)
+	if err != nil {
+		return models.SongRevision{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return cloneRevision(revision), nil
+}
+
+func (r *repository) GetSongRevisionAt(ctx context.Context, songID string, at time.Time) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisionAt",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	at = timestamp(at)
+
+	stored := r.revisions[canonicalID(songID)]
+	for i := len(stored) - 1; i >= 0; i-- {
+		if !stored[i].CreatedAt.After(at) {
+			logger.ExtractLogger(ctx).
+				Debug("repo passed GetSongRevisionAt",
+					logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+				)
+
+			return cloneRevision(stored[i]), nil
+		}
+	}
+
+	return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+}
+
+func cloneRevision(revision models.SongRevision) models.SongRevision {
+	if revision.Before != nil {
+		before := cloneSong(*revision.Before)
+		revision.Before = &before
+	}
+	if revision.After != nil {
+		after := cloneSong(*revision.After)
+		revision.After = &after
+	}
+	return revision
+}
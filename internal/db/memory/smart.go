@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"time"
+)
+
+// matchRules reports whether the song of the entry matches the rules of a smart playlist, the entry is one of
+// filterSongs. The read lock has to be held
+func (r *repository) matchRules(rules models.SmartRules, entry songEntry, now time.Time) bool {
+	if len(rules.Conditions) == 0 && len(rules.Groups) == 0 {
+		return true
+	}
+
+	matchAny := rules.Match == models.MatchAny
+	for _, condition := range rules.Conditions {
+		if r.matchCondition(condition, entry, now) == matchAny {
+			return matchAny
+		}
+	}
+	for _, group := range rules.Groups {
+		if r.matchRules(group, entry, now) == matchAny {
+			return matchAny
+		}
+	}
+
+	return !matchAny
+}
+
+func (r *repository) matchCondition(condition models.SmartCondition, entry songEntry, now time.Time) bool {
+	song, stats := entry.song, entry.stats
+
+	switch {
+	case condition.Group != "" && !contains(song.Group, condition.Group),
+		condition.Song != "" && !contains(song.Song, condition.Song),
+		condition.Text != "" && !contains(song.Data.Text, condition.Text),
+		condition.Link != "" && song.Data.Link != condition.Link,
+		condition.ReleasedFrom != nil && song.Data.ReleaseDate.Before(timestamp(*condition.ReleasedFrom)),
+		condition.ReleasedTo != nil && song.Data.ReleaseDate.After(timestamp(*condition.ReleasedTo)),
+		stats.Plays < condition.MinPlays,
+		condition.MaxPlays != nil && stats.Plays > *condition.MaxPlays,
+		stats.Favorites < condition.MinFavorites,
+		stats.AverageRating < condition.MinRating:
+		return false
+	}
+
+	if condition.AddedWithinDays != 0 {
+		// songs are added by their first revision
+		revisions := r.revisions[song.SongID]
+		since := now.Add(-time.Duration(condition.AddedWithinDays) * 24 * time.Hour)
+		if len(revisions) == 0 || revisions[0].CreatedAt.Before(since) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"time"
+)
+
+func (r *repository) RestoreDeletedSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+
+	song, err := t.lock(songID, version, true)
+	if err != nil {
+		return err
+	}
+
+	song.Version, song.UpdatedAt, song.DeletedAt = song.Version+1, t.now, nil
+	t.put(song)
+
+	t.addRevision(ctx, models.RevisionUndelete, nil, &song, 0)
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := r.begin()
+
+	deadline := t.now.Add(-retention)
+	for songID, entry := range r.songs {
+		// the revisions of the songs are removed with them
+		if entry.song.DeletedAt != nil && entry.song.DeletedAt.Before(deadline) {
+			t.remove(songID)
+		}
+	}
+
+	t.commit()
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return int64(len(t.order)), nil
+}
@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+// tx stages the changes of a write and applies them to the repository on commit, so a failed write leaves
+// the library untouched. The repository lock must be held for the whole life of the tx
+type tx struct {
+	r *repository
+
+	// now is the time of all changes of the tx, like now() in a postgres transaction
+	now time.Time
+
+	// songs are the changed songs, nil marks removed ones
+	songs map[string]*models.Song
+	// order keeps the changed song IDs in the order of the first change
+	order     []string
+	revisions []models.SongRevision
+}
+
+func (r *repository) begin() *tx {
+	return r.beginAt(timestamp(time.Now().UTC()))
+}
+
+func (r *repository) beginAt(now time.Time) *tx {
+	return &tx{
+		r:     r,
+		now:   now,
+		songs: make(map[string]*models.Song),
+	}
+}
+
+func (t *tx) commit() {
+	for _, songID := range t.order {
+		song := t.songs[songID]
+		if song == nil || song.DeletedAt != nil {
+			// songs in the trash are removed from the playlists for good, like purged ones
+			t.r.removeFromPlaylists(songID, t.now)
+		}
+		if song == nil {
+			delete(t.r.songs, songID)
+			delete(t.r.revisions, songID)
+			t.r.removeActivity(songID)
+			continue
+		}
+
+		entry, ok := t.r.songs[songID]
+		if !ok {
+			t.r.seq++
+			entry.seq = t.r.seq
+		}
+		entry.song = *song
+		t.r.songs[songID] = entry
+	}
+
+	for _, revision := range t.revisions {
+		t.r.revisions[revision.SongID] = append(t.r.revisions[revision.SongID], revision)
+	}
+}
+
+// get returns the song as the tx sees it
+func (t *tx) get(songID string) (models.Song, bool) {
+	if song, ok := t.songs[songID]; ok {
+		if song == nil {
+			return models.Song{}, false
+		}
+		return cloneSong(*song), true
+	}
+
+	entry, ok := t.r.songs[songID]
+	return cloneSong(entry.song), ok
+}
+
+func (t *tx) put(song models.Song) {
+	if _, ok := t.songs[song.SongID]; !ok {
+		t.order = append(t.order, song.SongID)
+	}
+	t.songs[song.SongID] = &song
+}
+
+func (t *tx) remove(songID string) {
+	if _, ok := t.songs[songID]; !ok {
+		t.order = append(t.order, songID)
+	}
+	t.songs[songID] = nil
+}
+
+// lock checks the version of the song like lockSong of the postgres repository, 0 matches any version.
+// deleted selects whether the song is looked up in the trash or among the live songs
+func (t *tx) lock(songID string, version int, deleted bool) (models.Song, error) {
+	song, ok := t.get(canonicalID(songID))
+	if !ok || (song.DeletedAt != nil) != deleted {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	if version != 0 && version != song.Version {
+		return models.Song{}, utils.NewError(
+			fmt.Sprintf("song version mismatch: expected: %d current: %d", version, song.Version), utils.PreconditionFailed)
+	}
+
+	return song, nil
+}
+
+func (t *tx) create(ctx context.Context, song models.Song) error {
+	songID := canonicalID(song.SongID)
+	if songID == "" {
+		return utils.NewError(fmt.Sprintf("invalid input syntax for type uuid: %q", song.SongID), utils.Internal)
+	}
+	if _, ok := t.get(songID); ok {
+		return utils.NewError(`duplicate key value violates unique constraint "songs_pkey"`, utils.Internal)
+	}
+
+	song.SongID = songID
+	song.Data.ReleaseDate = timestamp(song.Data.ReleaseDate)
+	song.Version, song.UpdatedAt, song.DeletedAt, song.Stats = 1, t.now, nil, nil
+	t.put(song)
+
+	t.addRevision(ctx, models.RevisionCreate, nil, &song, 0)
+
+	return nil
+}
+
+func (t *tx) edit(ctx context.Context, song models.Song) error {
+	before, err := t.lock(song.SongID, song.Version, false)
+	if err != nil {
+		return err
+	}
+
+	song.SongID = before.SongID
+	t.update(&song, before)
+
+	t.addRevision(ctx, models.RevisionEdit, &before, &song, 0)
+
+	return nil
+}
+
+// delete moves the song to the trash, it stays there till it is restored or purged
+func (t *tx) delete(ctx context.Context, songID string, version int) error {
+	before, err := t.lock(songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	song, deletedAt := before, t.now
+	song.Version, song.UpdatedAt, song.DeletedAt = before.Version+1, t.now, &deletedAt
+	t.put(song)
+
+	t.addRevision(ctx, models.RevisionDelete, &before, nil, 0)
+
+	return nil
+}
+
+// update overwrites the song data and bumps its version, the new version is set to the song
+func (t *tx) update(song *models.Song, before models.Song) {
+	song.Data.ReleaseDate = timestamp(song.Data.ReleaseDate)
+	song.Version, song.UpdatedAt, song.DeletedAt, song.Stats = before.Version+1, t.now, before.DeletedAt, nil
+	t.put(*song)
+}
+
+// revision returns the revision of the song with the version
+func (t *tx) revision(songID string, version int) (models.SongRevision, error) {
+	for _, revision := range t.r.revisions[songID] {
+		if revision.Version == version {
+			return revision, nil
+		}
+	}
+	for _, revision := range t.revisions {
+		if revision.SongID == songID && revision.Version == version {
+			return revision, nil
+		}
+	}
+
+	return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+}
+
+// addRevision records the change, the revision takes the version of the after state or the next one for deletes
+func (t *tx) addRevision(ctx context.Context, action string, before, after *models.Song, restoredFrom int) {
+	revision := models.SongRevision{
+		Action:       action,
+		Author:       actor.ExtractActor(ctx),
+		CreatedAt:    t.now,
+		RestoredFrom: restoredFrom,
+	}
+
+	if before != nil {
+		snapshot := cloneSong(*before)
+		revision.SongID, revision.Version, revision.Before = before.SongID, before.Version+1, &snapshot
+	}
+	if after != nil {
+		snapshot := cloneSong(*after)
+		revision.SongID, revision.Version, revision.After = after.SongID, after.Version, &snapshot
+	}
+
+	t.revisions = append(t.revisions, revision)
+}
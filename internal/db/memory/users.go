@@ -0,0 +1,231 @@
+package memory
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+// validRole reports whether the role passes the check constraint of the role column
+func validRole(role string) bool {
+	return role == "viewer" || role == "editor" || role == "admin"
+}
+
+type sessionEntry struct {
+	userID    string
+	expiresAt time.Time
+}
+
+func (r *repository) CreateUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.users {
+		if other.Name == user.Name {
+			return utils.NewError("user name is taken", utils.Conflict)
+		}
+	}
+
+	user.UserID = canonicalID(user.UserID)
+	if _, ok := r.users[user.UserID]; ok || user.UserID == "" {
+		return utils.NewError("invalid user ID", utils.Internal)
+	}
+
+	if !validRole(user.Role) {
+		return utils.NewError("invalid role: "+user.Role, utils.Internal)
+	}
+
+	now := timestamp(time.Now().UTC())
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.users[user.UserID] = user
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetUser(ctx context.Context, userID string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[canonicalID(userID)]
+	if !ok {
+		return models.User{}, utils.NewError("user not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUserByName",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Name == name {
+			logger.ExtractLogger(ctx).
+				Debug("repo passed GetUserByName",
+					logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+				)
+
+			return user, nil
+		}
+	}
+
+	return models.User{}, utils.NewError("user not found", utils.NotFound)
+}
+
+func (r *repository) EditUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[canonicalID(user.UserID)]
+	if !ok {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	if !validRole(user.Role) {
+		return utils.NewError("invalid role: "+user.Role, utils.Internal)
+	}
+
+	stored.DisplayName, stored.Email, stored.Role, stored.PasswordHash = user.DisplayName, user.Email, user.Role, user.PasswordHash
+	stored.UpdatedAt = timestamp(time.Now().UTC())
+	r.users[stored.UserID] = stored
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) CreateSession(ctx context.Context, tokenHash string, userID string, ttl time.Duration) (time.Time, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID = canonicalID(userID)
+	if _, ok := r.users[userID]; !ok {
+		return time.Time{}, utils.NewError("user not found", utils.NotFound)
+	}
+
+	now := timestamp(time.Now().UTC())
+	for hash, session := range r.sessions {
+		if session.userID == userID && !session.expiresAt.After(now) {
+			delete(r.sessions, hash)
+		}
+	}
+
+	if _, ok := r.sessions[tokenHash]; ok {
+		return time.Time{}, utils.NewError("duplicate session token", utils.Internal)
+	}
+
+	expiresAt := timestamp(now.Add(ttl))
+	r.sessions[tokenHash] = sessionEntry{userID: userID, expiresAt: expiresAt}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return expiresAt, nil
+}
+
+func (r *repository) GetSessionUser(ctx context.Context, tokenHash string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[tokenHash]
+	if !ok || !session.expiresAt.After(time.Now().UTC()) {
+		return models.User{}, utils.NewError("session not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return r.users[session.userID], nil
+}
+
+func (r *repository) DeleteSession(ctx context.Context, tokenHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, tokenHash)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeleteUserSessions(ctx context.Context, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userID = canonicalID(userID)
+	for hash, session := range r.sessions {
+		if session.userID == userID {
+			delete(r.sessions, hash)
+		}
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
@@ -23,4 +23,6 @@ CREATE INDEX songs_song_index ON songs (song);
 
 -- +goose Down
 -- +goose StatementBegin
+DROP TABLE group_songs;
+DROP TABLE songs;
 -- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE song_revisions
+(
+    song_id       text         NOT NULL,
+    version       INTEGER      NOT NULL,
+    action        VARCHAR(16)  NOT NULL,
+    author        VARCHAR(255) NOT NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT now(),
+    before        JSONB,
+    after         JSONB,
+    restored_from INTEGER,
+    PRIMARY KEY (song_id, version)
+);
+
+CREATE INDEX song_revisions_created_at_index ON song_revisions (song_id, created_at);
+
+-- existing songs start their history with the current state
+INSERT INTO song_revisions (song_id, version, action, author, created_at, after)
+SELECT songs.id,
+       songs.version,
+       'create',
+       'anonymous',
+       songs.updated_at,
+       jsonb_build_object(
+               'songID', songs.id,
+               'group', group_songs.group_name,
+               'song', songs.song,
+               'data', jsonb_build_object(
+                       'releaseDate', coalesce(to_char(songs.release_date, 'YYYY-MM-DD"T"HH24:MI:SS"Z"'), '0001-01-01T00:00:00Z'),
+                       'text', coalesce(songs.text, ''),
+                       'link', coalesce(songs.link, '')
+                       ),
+               'version', songs.version,
+               'updatedAt', to_char(songs.updated_at, 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+       )
+FROM songs
+         INNER JOIN group_songs ON songs.id = group_songs.song_id;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE song_revisions;
+-- +goose StatementEnd
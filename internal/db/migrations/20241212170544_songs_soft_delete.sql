@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE songs
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX songs_deleted_at_index ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX songs_deleted_at_index;
+
+ALTER TABLE songs
+    DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE group_songs
+    DROP CONSTRAINT group_songs_song_id_fkey;
+
+-- rows left by deletes that did not remove the group of the song
+DELETE
+FROM group_songs
+WHERE song_id IS NULL
+   OR song_id NOT IN (SELECT id FROM songs);
+
+DELETE
+FROM group_songs duplicate USING group_songs kept
+WHERE duplicate.song_id = kept.song_id
+  AND duplicate.ctid > kept.ctid;
+
+-- history of songs deleted before the trash was introduced, they can not be restored anyway
+DELETE
+FROM song_revisions
+WHERE song_id NOT IN (SELECT id FROM songs);
+
+ALTER TABLE songs
+    ALTER COLUMN id TYPE uuid USING id::uuid;
+
+ALTER TABLE group_songs
+    ALTER COLUMN song_id TYPE uuid USING song_id::uuid,
+    ALTER COLUMN song_id SET NOT NULL,
+    ADD PRIMARY KEY (song_id),
+    ADD CONSTRAINT group_songs_song_id_fkey FOREIGN KEY (song_id) REFERENCES songs (id) ON DELETE CASCADE;
+
+DROP INDEX group_songs_song_index;
+
+ALTER TABLE song_revisions
+    ALTER COLUMN song_id TYPE uuid USING song_id::uuid,
+    ADD CONSTRAINT song_revisions_song_id_fkey FOREIGN KEY (song_id) REFERENCES songs (id) ON DELETE CASCADE,
+    ADD CONSTRAINT song_revisions_version_check CHECK (version > 0);
+
+ALTER TABLE songs
+    ADD CONSTRAINT songs_version_check CHECK (version > 0);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE songs
+    DROP CONSTRAINT songs_version_check;
+
+ALTER TABLE song_revisions
+    DROP CONSTRAINT song_revisions_version_check,
+    DROP CONSTRAINT song_revisions_song_id_fkey,
+    ALTER COLUMN song_id TYPE text;
+
+CREATE INDEX group_songs_song_index ON group_songs (song_id);
+
+ALTER TABLE group_songs
+    DROP CONSTRAINT group_songs_song_id_fkey,
+    DROP CONSTRAINT group_songs_pkey,
+    ALTER COLUMN song_id DROP NOT NULL,
+    ALTER COLUMN song_id TYPE text;
+
+ALTER TABLE songs
+    ALTER COLUMN id TYPE text;
+
+ALTER TABLE group_songs
+    ADD CONSTRAINT group_songs_song_id_fkey FOREIGN KEY (song_id) REFERENCES songs (id);
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users
+(
+    id            uuid PRIMARY KEY,
+    name          VARCHAR(64)  NOT NULL UNIQUE,
+    display_name  VARCHAR(255) NOT NULL DEFAULT '',
+    email         VARCHAR(255) NOT NULL DEFAULT '',
+    password_hash TEXT         NOT NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMP    NOT NULL DEFAULT now()
+);
+
+CREATE TABLE sessions
+(
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id    uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_user_id_index ON sessions (user_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sessions;
+DROP TABLE users;
+-- +goose StatementEnd
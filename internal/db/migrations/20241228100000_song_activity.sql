@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE favorites
+(
+    user_id    uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    song_id    uuid      NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+CREATE INDEX favorites_user_id_created_at_index ON favorites (user_id, created_at);
+CREATE INDEX favorites_song_id_index ON favorites (song_id);
+
+CREATE TABLE ratings
+(
+    user_id  uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    song_id  uuid      NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    rating   SMALLINT  NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    rated_at TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, song_id)
+);
+
+CREATE INDEX ratings_user_id_rated_at_index ON ratings (user_id, rated_at);
+CREATE INDEX ratings_song_id_index ON ratings (song_id);
+
+CREATE TABLE plays
+(
+    id        uuid PRIMARY KEY,
+    user_id   uuid      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    song_id   uuid      NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    played_at TIMESTAMP NOT NULL,
+    -- seconds listened
+    duration  INTEGER   NOT NULL CHECK (duration >= 0)
+);
+
+CREATE INDEX plays_user_id_played_at_index ON plays (user_id, played_at);
+CREATE INDEX plays_song_id_index ON plays (song_id);
+
+-- song_stats counts the rows of the tables above by songs, so songs are sorted by popularity without
+-- aggregating the plays. Songs without favorites, ratings and plays have no row
+CREATE TABLE song_stats
+(
+    song_id    uuid PRIMARY KEY REFERENCES songs (id) ON DELETE CASCADE,
+    favorites  BIGINT NOT NULL DEFAULT 0,
+    ratings    BIGINT NOT NULL DEFAULT 0,
+    rating_sum BIGINT NOT NULL DEFAULT 0,
+    plays      BIGINT NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE song_stats;
+DROP TABLE plays;
+DROP TABLE ratings;
+DROP TABLE favorites;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- smart playlists keep their rules instead of songs, the rules are evaluated on every read
+ALTER TABLE playlists
+    ADD COLUMN smart JSONB;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE playlists
+    DROP COLUMN smart;
+-- +goose StatementEnd
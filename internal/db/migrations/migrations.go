@@ -0,0 +1,103 @@
+// Package migrations embeds the SQL migrations, so binaries apply them from any working directory
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/pressly/goose"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FS are the migrations of the postgres schema
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// SQLiteFS are the migrations of the sqlite schema
+var SQLiteFS, _ = fs.Sub(sqliteFS, "sqlite")
+
+// mu serializes migrations of the process, goose keeps its dialect globally and does not lock the database
+var mu sync.Mutex
+
+// Run runs fn with the migrations directory, which is dir if it is set or the migrations of fsys otherwise.
+// goose reads migrations from disk only, so the embedded ones are copied to a temporary directory
+func Run(dialect string, fsys fs.FS, dir string, fn func(path string) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := goose.SetDialect(dialect); err != nil {
+		return fmt.Errorf("failed to set dialect: %w", err)
+	}
+
+	if dir != "" {
+		return fn(dir)
+	}
+
+	path, err := os.MkdirTemp("", "music_lib_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(path)
+	}()
+
+	if err = os.CopyFS(path, fsys); err != nil {
+		return fmt.Errorf("failed to copy migrations: %w", err)
+	}
+
+	return fn(path)
+}
+
+// Status returns all migrations of the directory in the order of versions, it must be called by fn of Run
+func Status(conn *sql.DB, path string) ([]models.Migration, error) {
+	collected, err := goose.CollectMigrations(path, 0, goose.MaxVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// the version table is created on a pristine database
+	if _, err = goose.EnsureDBVersion(conn); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(fmt.Sprintf(`SELECT version_id, tstamp, is_applied FROM %s ORDER BY id`, goose.TableName()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// the last record of a version is its current state
+	records := make(map[int64]goose.MigrationRecord)
+	for rows.Next() {
+		var record goose.MigrationRecord
+		if err = rows.Scan(&record.VersionID, &record.TStamp, &record.IsApplied); err != nil {
+			return nil, err
+		}
+		records[record.VersionID] = record
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]models.Migration, len(collected))
+	for i, migration := range collected {
+		status[i] = models.Migration{Version: migration.Version, Name: filepath.Base(migration.Source)}
+
+		if record := records[migration.Version]; record.IsApplied {
+			appliedAt := record.TStamp
+			status[i].Applied, status[i].AppliedAt = true, &appliedAt
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE songs
+(
+    id           TEXT PRIMARY KEY,
+    song         TEXT      NOT NULL,
+    release_date TIMESTAMP,
+    text         TEXT      NOT NULL DEFAULT '',
+    link         TEXT      NOT NULL DEFAULT '',
+    version      INTEGER   NOT NULL DEFAULT 1 CHECK (version > 0),
+    updated_at   TIMESTAMP NOT NULL,
+    deleted_at   TIMESTAMP
+);
+
+CREATE INDEX songs_song_index ON songs (song);
+CREATE INDEX songs_deleted_at_index ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE group_songs
+(
+    song_id    TEXT PRIMARY KEY REFERENCES songs (id) ON DELETE CASCADE,
+    group_name TEXT NOT NULL
+);
+
+CREATE INDEX group_songs_group_index ON group_songs (group_name);
+
+CREATE TABLE song_revisions
+(
+    song_id       TEXT      NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    version       INTEGER   NOT NULL CHECK (version > 0),
+    action        TEXT      NOT NULL,
+    author        TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    before        TEXT,
+    after         TEXT,
+    restored_from INTEGER,
+    PRIMARY KEY (song_id, version)
+);
+
+CREATE INDEX song_revisions_created_at_index ON song_revisions (song_id, created_at);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE song_revisions;
+DROP TABLE group_songs;
+DROP TABLE songs;
+-- +goose StatementEnd
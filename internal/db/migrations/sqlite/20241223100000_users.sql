@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users
+(
+    id            TEXT PRIMARY KEY,
+    name          TEXT      NOT NULL UNIQUE,
+    display_name  TEXT      NOT NULL DEFAULT '',
+    email         TEXT      NOT NULL DEFAULT '',
+    password_hash TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    updated_at    TIMESTAMP NOT NULL
+);
+
+CREATE TABLE sessions
+(
+    token_hash TEXT PRIMARY KEY,
+    user_id    TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sessions_user_id_index ON sessions (user_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sessions;
+DROP TABLE users;
+-- +goose StatementEnd
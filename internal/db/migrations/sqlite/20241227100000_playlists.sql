@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE playlists
+(
+    id          TEXT PRIMARY KEY,
+    owner_id    TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name        TEXT      NOT NULL,
+    description TEXT      NOT NULL DEFAULT '',
+    visibility  TEXT      NOT NULL DEFAULT 'private' CHECK (visibility IN ('private', 'unlisted', 'public')),
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX playlists_owner_id_index ON playlists (owner_id);
+CREATE INDEX playlists_public_index ON playlists (updated_at) WHERE visibility = 'public';
+
+CREATE TABLE playlist_collaborators
+(
+    playlist_id TEXT      NOT NULL REFERENCES playlists (id) ON DELETE CASCADE,
+    user_id     TEXT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    added_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (playlist_id, user_id)
+);
+
+CREATE INDEX playlist_collaborators_user_id_index ON playlist_collaborators (user_id);
+
+-- positions are compared byte by byte, so inserting or moving a song never renumbers the others
+CREATE TABLE playlist_songs
+(
+    id          TEXT PRIMARY KEY,
+    playlist_id TEXT      NOT NULL REFERENCES playlists (id) ON DELETE CASCADE,
+    song_id     TEXT      NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    position    TEXT      NOT NULL,
+    added_by    TEXT REFERENCES users (id) ON DELETE SET NULL,
+    added_at    TIMESTAMP NOT NULL,
+    UNIQUE (playlist_id, position)
+);
+
+CREATE INDEX playlist_songs_song_id_index ON playlist_songs (song_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE playlist_songs;
+DROP TABLE playlist_collaborators;
+DROP TABLE playlists;
+-- +goose StatementEnd
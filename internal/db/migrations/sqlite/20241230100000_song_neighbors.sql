@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- song_neighbors is the trained model of the recommendations, it is replaced by every training
+CREATE TABLE song_neighbors
+(
+    song_id     TEXT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    neighbor_id TEXT NOT NULL REFERENCES songs (id) ON DELETE CASCADE,
+    score       REAL NOT NULL,
+    PRIMARY KEY (song_id, neighbor_id)
+);
+
+CREATE INDEX song_neighbors_neighbor_id_index ON song_neighbors (neighbor_id);
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE song_neighbors;
+-- +goose StatementEnd
@@ -0,0 +1,401 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+// activitySongColumns select the songs of favorites, ratings and plays joined with songs and group_songs
+const activitySongColumns = `songs.id,
+				group_songs.group_name,
+				songs.song,
+				songs.release_date,
+				songs.text,
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at`
+
+func (r *repository) SetFavorite(ctx context.Context, userID string, songID string, favorite bool) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received SetFavorite",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+	if !validID(songID) {
+		if !favorite {
+			return nil
+		}
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	err := r.updateActivity(ctx, func(tx *sqlx.Tx) error {
+		if !favorite {
+			res, err := tx.ExecContext(ctx, `DELETE FROM favorites WHERE user_id = $1 AND song_id = $2`, userID, songID)
+			if err != nil {
+				return utils.NewError(err.Error(), utils.Internal)
+			}
+
+			return addStats(ctx, tx, songID, res, songStatsDelta{favorites: -1})
+		}
+
+		if err := shareSong(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		q := `INSERT INTO favorites (user_id, song_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+		res, err := tx.ExecContext(ctx, q, userID, songID)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return utils.NewError("user not found", utils.NotFound)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return addStats(ctx, tx, songID, res, songStatsDelta{favorites: 1})
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed SetFavorite",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetFavorites(ctx context.Context, userID string, lim, off int) ([]models.Favorite, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return []models.Favorite{}, nil
+	}
+
+	q := `SELECT favorites.created_at AS favorited_at, ` + activitySongColumns + `
+			FROM favorites
+			JOIN songs ON songs.id = favorites.song_id
+			JOIN group_songs ON songs.id = group_songs.song_id
+			WHERE favorites.user_id = $1 AND songs.deleted_at IS NULL
+			ORDER BY favorites.created_at DESC, songs.id LIMIT $2 OFFSET $3`
+
+	var rows []favoriteRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, userID, lim, off)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	favorites := make([]models.Favorite, 0, len(rows))
+	for _, row := range rows {
+		favorites = append(favorites, models.Favorite{Song: row.songRow.toModel(), FavoritedAt: row.FavoritedAt})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return favorites, nil
+}
+
+func (r *repository) RateSong(ctx context.Context, userID string, songID string, rating int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+	if !validID(songID) {
+		if rating == 0 {
+			return nil
+		}
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	err := r.updateActivity(ctx, func(tx *sqlx.Tx) error {
+		if rating == 0 {
+			var old int64
+			q := `DELETE FROM ratings WHERE user_id = $1 AND song_id = $2 RETURNING rating`
+			if err := tx.QueryRowxContext(ctx, q, userID, songID).Scan(&old); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil
+				}
+				return utils.NewError(err.Error(), utils.Internal)
+			}
+
+			return updateStats(ctx, tx, songID, songStatsDelta{ratings: -1, ratingSum: -old})
+		}
+
+		if err := shareSong(ctx, tx, songID); err != nil {
+			return err
+		}
+
+		// a concurrent first rating of the user waits for the insert, then the rating is read locked like any other
+		q := `INSERT INTO ratings (user_id, song_id, rating) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+
+		res, err := tx.ExecContext(ctx, q, userID, songID, rating)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return utils.NewError("user not found", utils.NotFound)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+		if n == 1 {
+			return updateStats(ctx, tx, songID, songStatsDelta{ratings: 1, ratingSum: int64(rating)})
+		}
+
+		var old int64
+		q = `SELECT rating FROM ratings WHERE user_id = $1 AND song_id = $2 FOR UPDATE`
+		if err = tx.QueryRowxContext(ctx, q, userID, songID).Scan(&old); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		q = `UPDATE ratings SET rating = $3, rated_at = now() WHERE user_id = $1 AND song_id = $2`
+		if _, err = tx.ExecContext(ctx, q, userID, songID, rating); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return updateStats(ctx, tx, songID, songStatsDelta{ratingSum: int64(rating) - old})
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetRatings(ctx context.Context, userID string, lim, off int) ([]models.Rating, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return []models.Rating{}, nil
+	}
+
+	q := `SELECT ratings.rating, ratings.rated_at, ` + activitySongColumns + `
+			FROM ratings
+			JOIN songs ON songs.id = ratings.song_id
+			JOIN group_songs ON songs.id = group_songs.song_id
+			WHERE ratings.user_id = $1 AND songs.deleted_at IS NULL
+			ORDER BY ratings.rated_at DESC, songs.id LIMIT $2 OFFSET $3`
+
+	var rows []ratingRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, userID, lim, off)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	ratings := make([]models.Rating, 0, len(rows))
+	for _, row := range rows {
+		ratings = append(ratings, models.Rating{Song: row.songRow.toModel(), Rating: row.Rating, RatedAt: row.RatedAt})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return ratings, nil
+}
+
+func (r *repository) AddPlay(ctx context.Context, userID string, play models.Play) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+	if !validID(play.Song.SongID) {
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	err := r.updateActivity(ctx, func(tx *sqlx.Tx) error {
+		if err := shareSong(ctx, tx, play.Song.SongID); err != nil {
+			return err
+		}
+
+		q := `INSERT INTO plays (id, user_id, song_id, played_at, duration) VALUES ($1, $2, $3, $4, $5)`
+
+		if _, err := tx.ExecContext(ctx, q, play.PlayID, userID, play.Song.SongID, play.PlayedAt, play.Duration); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return utils.NewError("user not found", utils.NotFound)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return updateStats(ctx, tx, play.Song.SongID, songStatsDelta{plays: 1})
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlays(ctx context.Context, userID string, lim, off int) ([]models.Play, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return []models.Play{}, nil
+	}
+
+	q := `SELECT plays.id AS play_id, plays.played_at, plays.duration, ` + activitySongColumns + `
+			FROM plays
+			JOIN songs ON songs.id = plays.song_id
+			JOIN group_songs ON songs.id = group_songs.song_id
+			WHERE plays.user_id = $1 AND songs.deleted_at IS NULL
+			ORDER BY plays.played_at DESC, plays.id LIMIT $2 OFFSET $3`
+
+	var rows []playRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, userID, lim, off)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	plays := make([]models.Play, 0, len(rows))
+	for _, row := range rows {
+		plays = append(plays, models.Play{
+			PlayID:   row.PlayID,
+			Song:     row.songRow.toModel(),
+			PlayedAt: row.PlayedAt,
+			Duration: row.Duration,
+		})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return plays, nil
+}
+
+// updateActivity runs fn in a transaction
+func (r *repository) updateActivity(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	return nil
+}
+
+// songStatsDelta are the changes of the counters of song_stats
+type songStatsDelta struct {
+	favorites int64
+	ratings   int64
+	ratingSum int64
+	plays     int64
+}
+
+// addStats updates the stats of the song if the statement changed a row
+func addStats(ctx context.Context, tx *sqlx.Tx, songID string, res sql.Result, delta songStatsDelta) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	return updateStats(ctx, tx, songID, delta)
+}
+
+// updateStats adds the delta to the stats of the song, the row of the song is created by the first change
+func updateStats(ctx context.Context, tx *sqlx.Tx, songID string, delta songStatsDelta) error {
+	q := `INSERT INTO song_stats (song_id, favorites, ratings, rating_sum, plays) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (song_id) DO UPDATE SET
+				favorites = song_stats.favorites + EXCLUDED.favorites,
+				ratings = song_stats.ratings + EXCLUDED.ratings,
+				rating_sum = song_stats.rating_sum + EXCLUDED.rating_sum,
+				plays = song_stats.plays + EXCLUDED.plays`
+
+	_, err := tx.ExecContext(ctx, q, songID, delta.favorites, delta.ratings, delta.ratingSum, delta.plays)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+type favoriteRow struct {
+	FavoritedAt time.Time `db:"favorited_at"`
+	songRow
+}
+
+type ratingRow struct {
+	Rating  int       `db:"rating"`
+	RatedAt time.Time `db:"rated_at"`
+	songRow
+}
+
+type playRow struct {
+	PlayID   string    `db:"play_id"`
+	PlayedAt time.Time `db:"played_at"`
+	Duration int       `db:"duration"`
+	songRow
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/lib/pq"
+	"time"
+)
+
+// keys are read from the primary like accounts, a key must work right after it is issued
+
+const selectAPIKeys = `SELECT id, owner_id, name, prefix, scopes, created_at, last_used_at, revoked_at FROM api_keys`
+
+func (r *repository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(key.OwnerID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	q := `INSERT INTO api_keys (id, owner_id, name, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, q, key.KeyID, key.OwnerID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return utils.NewError("user not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetAPIKey(ctx context.Context, keyID string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(keyID) {
+		return models.APIKey{}, utils.NewError("API key not found", utils.NotFound)
+	}
+
+	key, err := r.getAPIKey(ctx, selectAPIKeys+` WHERE id = $1`, keyID)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return key, nil
+}
+
+func (r *repository) GetAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(ownerID) {
+		return nil, nil
+	}
+
+	var rows []apiKeyRow
+	if err := r.db.SelectContext(ctx, &rows, selectAPIKeys+` WHERE owner_id = $1 ORDER BY created_at, id`, ownerID); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	keys := make([]models.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return keys, nil
+}
+
+func (r *repository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received UseAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	key, err := r.getAPIKey(ctx, selectAPIKeys+` WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	q := `UPDATE api_keys SET last_used_at = now()
+	  WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - make_interval(secs => $2))
+	  RETURNING last_used_at`
+
+	var usedAt time.Time
+	if err = r.db.QueryRowxContext(ctx, q, key.KeyID, db.LastUseInterval.Seconds()).Scan(&usedAt); err == nil {
+		key.LastUsedAt = &usedAt
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed UseAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return key, nil
+}
+
+func (r *repository) RevokeAPIKey(ctx context.Context, keyID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(keyID) {
+		return utils.NewError("API key not found", utils.NotFound)
+	}
+
+	q := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`
+
+	res, err := r.db.ExecContext(ctx, q, keyID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	} else if n == 0 {
+		return utils.NewError("API key not found", utils.NotFound)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) getAPIKey(ctx context.Context, q string, args ...any) (models.APIKey, error) {
+	var row apiKeyRow
+	if err := r.db.QueryRowxContext(ctx, q, args...).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, utils.NewError("API key not found", utils.NotFound)
+		}
+		return models.APIKey{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return row.toModel(), nil
+}
+
+type apiKeyRow struct {
+	models.APIKey
+	Scopes pq.StringArray `db:"scopes"`
+}
+
+func (r apiKeyRow) toModel() models.APIKey {
+	key := r.APIKey
+	key.Scopes = r.Scopes
+	return key
+}
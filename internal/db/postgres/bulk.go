@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"time"
+)
+
+const (
+	// maxQueryParams is the limit of bind parameters in a single postgres statement
+	maxQueryParams = 65535
+)
+
+func (r *repository) CreateSongs(ctx context.Context, songs []models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now().UTC()
+
+	songRows := make([][]any, 0, len(songs))
+	groupRows := make([][]any, 0, len(songs))
+	revisionRows := make([][]any, 0, len(songs))
+	for i := range songs {
+		songs[i].Version = 1
+		songs[i].UpdatedAt = now
+
+		song := songs[i]
+		songRows = append(songRows, []any{song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link, song.Version, song.UpdatedAt})
+		groupRows = append(groupRows, []any{song.SongID, song.Group})
+
+		revision, err := revisionValues(ctx, models.RevisionCreate, nil, &song, 0)
+		if err != nil {
+			return err
+		}
+		revisionRows = append(revisionRows, revision)
+	}
+
+	if err = insertRows(ctx, tx, "songs", []string{"id", "song", "release_date", "text", "link", "version", "updated_at"}, songRows); err != nil {
+		return err
+	}
+	if err = insertRows(ctx, tx, "group_songs", []string{"song_id", "group_name"}, groupRows); err != nil {
+		return err
+	}
+	if err = insertRows(ctx, tx, "song_revisions", revisionColumns, revisionRows); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs, err := r.applyBulk(ctx, len(songs), atomic, func(tx *sqlx.Tx, i int) error {
+		return editSong(ctx, tx, songs[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+func (r *repository) DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs, err := r.applyBulk(ctx, len(songs), atomic, func(tx *sqlx.Tx, i int) error {
+		return deleteSong(ctx, tx, songs[i].SongID, songs[i].Version)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+// applyBulk runs apply for n items in a single transaction. In the atomic mode it stops at the first failure
+// and rolls everything back, otherwise every item is wrapped into a savepoint so a failure discards only that item
+func (r *repository) applyBulk(ctx context.Context, n int, atomic bool, apply func(tx *sqlx.Tx, i int) error) ([]error, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		if atomic {
+			if errs[i] = apply(tx, i); errs[i] != nil {
+				return errs, nil
+			}
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if errs[i] = apply(tx, i); errs[i] != nil {
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, utils.NewError(err.Error(), utils.Internal)
+			}
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	return errs, nil
+}
+
+// insertRows inserts rows with multi-row INSERT statements split to fit the bind parameters limit
+func insertRows(ctx context.Context, tx *sqlx.Tx, table string, columns []string, rows [][]any) error {
+	chunk := maxQueryParams / len(columns)
+
+	for start := 0; start < len(rows); start += chunk {
+		end := min(start+chunk, len(rows))
+
+		var (
+			q    strings.Builder
+			args = make([]any, 0, (end-start)*len(columns))
+		)
+		q.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", ")))
+
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				q.WriteString(", ")
+			}
+
+			placeholders := make([]string, len(row))
+			for j := range row {
+				args = append(args, row[j])
+				placeholders[j] = fmt.Sprintf("$%d", len(args))
+			}
+			q.WriteString("(" + strings.Join(placeholders, ", ") + ")")
+		}
+
+		if _, err := tx.ExecContext(ctx, q.String(), args...); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	return nil
+}
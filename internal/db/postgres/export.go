@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// exportBatchSize is the number of rows fetched from the export cursor at once
+	exportBatchSize = 500
+)
+
+func (r *repository) StreamSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if filter.SongID != "" && !validID(filter.SongID) {
+		return nil
+	}
+
+	// the export fails over to the primary only until the first song is passed to fn, so no song is passed twice
+	var (
+		streamed bool
+		fnErr    error
+	)
+	stream := func(song models.Song) error {
+		streamed = true
+		fnErr = fn(song)
+		return fnErr
+	}
+
+	conn, replica := r.reader(ctx)
+	err := streamSongs(ctx, conn, filter, stream)
+	if !streamed && r.failover(ctx, replica, err) {
+		err = streamSongs(ctx, r.db, filter, stream)
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+// streamSongs passes the songs of the filter to fn through a cursor, errors are returned unwrapped
+func streamSongs(ctx context.Context, conn *sqlx.DB, filter models.SongFilter, fn func(song models.Song) error) error {
+	// cursors live only inside a transaction, the export sees a single snapshot of the library
+	tx, err := conn.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// LIMIT NULL returns all rows, so the zero limit exports everything
+	q := `DECLARE songs_export NO SCROLL CURSOR FOR ` + selectFilteredSongs + `
+      ORDER BY songs.id OFFSET $7 LIMIT NULLIF($8, 0)`
+
+	if _, err = tx.ExecContext(ctx, q, filterArgs(filter)...); err != nil {
+		return err
+	}
+
+	for {
+		var rows []songStatsRow
+		if err = tx.SelectContext(ctx, &rows, fmt.Sprintf(`FETCH %d FROM songs_export`, exportBatchSize)); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err = fn(row.songRow.toModel()); err != nil {
+				return err
+			}
+		}
+
+		if len(rows) < exportBatchSize {
+			break
+		}
+	}
+
+	return nil
+}
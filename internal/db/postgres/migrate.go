@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/db/migrations"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose"
+)
+
+// MigrateUp applies all pending migrations
+func MigrateUp(conn *sqlx.DB, dir ...string) error {
+	return migrate(dir, func(path string) error {
+		return goose.Up(conn.DB, path)
+	})
+}
+
+// MigrateUpTo applies the pending migrations up to and including the version
+func MigrateUpTo(conn *sqlx.DB, version int64, dir ...string) error {
+	return migrate(dir, func(path string) error {
+		return goose.UpTo(conn.DB, path, version)
+	})
+}
+
+// MigrateDown rolls back the last applied migration
+func MigrateDown(conn *sqlx.DB, dir ...string) error {
+	return migrate(dir, func(path string) error {
+		return goose.Down(conn.DB, path)
+	})
+}
+
+// MigrateDownTo rolls back the migrations newer than the version, 0 rolls back all of them
+func MigrateDownTo(conn *sqlx.DB, version int64, dir ...string) error {
+	return migrate(dir, func(path string) error {
+		return goose.DownTo(conn.DB, path, version)
+	})
+}
+
+// MigrationStatus returns all migrations in the order of versions
+func MigrationStatus(conn *sqlx.DB, dir ...string) ([]models.Migration, error) {
+	var status []models.Migration
+
+	err := migrate(dir, func(path string) (err error) {
+		status, err = migrations.Status(conn.DB, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// migrate runs fn with the migrations directory, which is dir if it is set or the embedded migrations otherwise
+func migrate(dir []string, fn func(path string) error) error {
+	var path string
+	if len(dir) > 0 {
+		path = dir[0]
+	}
+
+	return migrations.Run("postgres", migrations.FS, path, fn)
+}
+
+func (r *repository) GetMigrations(ctx context.Context) ([]models.Migration, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetMigrations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	status, err := MigrationStatus(r.db)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetMigrations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return status, nil
+}
+
+func (r *repository) MigrateUp(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MigrateUp",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	var err error
+	if version == 0 {
+		err = MigrateUp(r.db)
+	} else {
+		err = MigrateUpTo(r.db, version)
+	}
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed MigrateUp",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) MigrateDown(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MigrateDown",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := MigrateDownTo(r.db, version); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed MigrateDown",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
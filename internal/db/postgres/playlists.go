@@ -0,0 +1,577 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const selectPlaylists = `SELECT id, owner_id, name, description, visibility, created_at, updated_at, smart FROM playlists`
+
+func (r *repository) CreatePlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlist.OwnerID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO playlists (id, owner_id, name, description, visibility, smart) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = r.db.ExecContext(ctx, q, playlist.PlaylistID, playlist.OwnerID, playlist.Name, playlist.Description,
+		playlist.Visibility, smart)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return utils.NewError("user not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) {
+		return models.Playlist{}, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	var playlist models.Playlist
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		var row playlistRow
+		if err := conn.QueryRowxContext(ctx, selectPlaylists+` WHERE id = $1`, playlistID).StructScan(&row); err != nil {
+			return err
+		}
+
+		var err error
+		if playlist, err = row.toModel(); err != nil {
+			return err
+		}
+
+		q := `SELECT playlist_collaborators.user_id, users.name, playlist_collaborators.added_at
+		  FROM playlist_collaborators
+		  JOIN users ON users.id = playlist_collaborators.user_id
+		  WHERE playlist_collaborators.playlist_id = $1
+		  ORDER BY playlist_collaborators.added_at, users.name`
+
+		playlist.Collaborators = []models.PlaylistCollaborator{}
+		return conn.SelectContext(ctx, &playlist.Collaborators, q, playlistID)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Playlist{}, utils.NewError("playlist not found", utils.NotFound)
+		}
+		return models.Playlist{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlist, nil
+}
+
+func (r *repository) GetPlaylists(ctx context.Context, filter models.PlaylistFilter) ([]models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := selectPlaylists + ` WHERE visibility = 'public'`
+	args := []any{filter.Lim, filter.Off}
+
+	if filter.MemberID != "" {
+		if !validID(filter.MemberID) {
+			return []models.Playlist{}, nil
+		}
+
+		q = selectPlaylists + ` WHERE owner_id = $3 OR
+		  id IN (SELECT playlist_id FROM playlist_collaborators WHERE user_id = $3)`
+		args = append(args, filter.MemberID)
+	}
+
+	q += ` ORDER BY updated_at DESC, id LIMIT $1 OFFSET $2`
+
+	var rows []playlistRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, args...)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	playlists := make([]models.Playlist, 0, len(rows))
+	for _, row := range rows {
+		playlist, err := row.toModel()
+		if err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlists, nil
+}
+
+func (r *repository) EditPlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlist.PlaylistID) {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE playlists SET name = $1, description = $2, visibility = $3, smart = $4, updated_at = now() WHERE id = $5`
+
+	res, err := r.db.ExecContext(ctx, q, playlist.Name, playlist.Description, playlist.Visibility, smart,
+		playlist.PlaylistID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "playlist not found"); err != nil {
+		return err
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeletePlaylist(ctx context.Context, playlistID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	// the songs and the collaborators of the playlist are removed by the cascade
+	res, err := r.db.ExecContext(ctx, `DELETE FROM playlists WHERE id = $1`, playlistID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "playlist not found"); err != nil {
+		return err
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) AddPlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+	if !validID(userID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	q := `INSERT INTO playlist_collaborators (playlist_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	if _, err := r.db.ExecContext(ctx, q, playlistID, userID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			if pqErr.Constraint == "playlist_collaborators_user_id_fkey" {
+				return utils.NewError("user not found", utils.NotFound)
+			}
+			return utils.NewError("playlist not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) || !validID(userID) {
+		return utils.NewError("collaborator not found", utils.NotFound)
+	}
+
+	q := `DELETE FROM playlist_collaborators WHERE playlist_id = $1 AND user_id = $2`
+
+	res, err := r.db.ExecContext(ctx, q, playlistID, userID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "collaborator not found"); err != nil {
+		return err
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylistSongs(ctx context.Context, playlistID string) ([]models.PlaylistSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) {
+		return nil, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	q := `SELECT
+				playlist_songs.id AS entry_id,
+				playlist_songs.position,
+				COALESCE(playlist_songs.added_by::text, '') AS added_by,
+				playlist_songs.added_at,
+				songs.id,
+				group_songs.group_name,
+				songs.song,
+				songs.release_date,
+				songs.text,
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at
+			FROM playlist_songs
+			JOIN songs ON songs.id = playlist_songs.song_id
+			JOIN group_songs ON songs.id = group_songs.song_id
+			WHERE playlist_songs.playlist_id = $1
+			ORDER BY playlist_songs.position`
+
+	var rows []playlistSongRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		var exists bool
+		if err := conn.QueryRowxContext(ctx, `SELECT EXISTS (SELECT 1 FROM playlists WHERE id = $1)`, playlistID).
+			Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+
+		return conn.SelectContext(ctx, &rows, q, playlistID)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.NewError("playlist not found", utils.NotFound)
+		}
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	entries := make([]models.PlaylistSong, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return entries, nil
+}
+
+func (r *repository) AddPlaylistSong(ctx context.Context, playlistID string, entry models.PlaylistSong) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+	if !validID(entry.Song.SongID) {
+		return utils.NewError("song not found", utils.NotFound)
+	}
+
+	var addedBy *string
+	if validID(entry.AddedBy) {
+		addedBy = &entry.AddedBy
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the song is locked before the playlist like deleteSong does, so it can not be moved to the trash before
+	// the entry is added
+	if err = shareSong(ctx, tx, entry.Song.SongID); err != nil {
+		return err
+	}
+
+	if err = touchPlaylist(ctx, tx, playlistID); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO playlist_songs (id, playlist_id, song_id, position, added_by) VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err = tx.ExecContext(ctx, q, entry.EntryID, playlistID, entry.Song.SongID, entry.Position, addedBy); err != nil {
+		if isUniqueViolation(err) {
+			return utils.NewError("position is taken", utils.Conflict)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) MovePlaylistSong(ctx context.Context, playlistID string, entryID string, position string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) || !validID(entryID) {
+		return utils.NewError("playlist entry not found", utils.NotFound)
+	}
+
+	err := r.updatePlaylist(ctx, playlistID, func(tx *sqlx.Tx) error {
+		q := `UPDATE playlist_songs SET position = $1 WHERE id = $2 AND playlist_id = $3`
+
+		res, err := tx.ExecContext(ctx, q, position, entryID, playlistID)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return utils.NewError("position is taken", utils.Conflict)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return requireRow(res, "playlist entry not found")
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(playlistID) || !validID(entryID) {
+		return utils.NewError("playlist entry not found", utils.NotFound)
+	}
+
+	err := r.updatePlaylist(ctx, playlistID, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM playlist_songs WHERE id = $1 AND playlist_id = $2`, entryID, playlistID)
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return requireRow(res, "playlist entry not found")
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+// updatePlaylist runs fn in a transaction after updating the playlist
+func (r *repository) updatePlaylist(ctx context.Context, playlistID string, fn func(tx *sqlx.Tx) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = touchPlaylist(ctx, tx, playlistID); err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	return nil
+}
+
+// touchPlaylist updates the playlist, which also locks it against concurrent changes of its songs
+func touchPlaylist(ctx context.Context, tx *sqlx.Tx, playlistID string) error {
+	res, err := tx.ExecContext(ctx, `UPDATE playlists SET updated_at = now() WHERE id = $1`, playlistID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return requireRow(res, "playlist not found")
+}
+
+// removeFromPlaylists removes the song from all playlists and updates them
+func removeFromPlaylists(ctx context.Context, tx *sqlx.Tx, songID string) error {
+	q := `UPDATE playlists SET updated_at = now() WHERE id IN (SELECT playlist_id FROM playlist_songs WHERE song_id = $1)`
+
+	if _, err := tx.ExecContext(ctx, q, songID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM playlist_songs WHERE song_id = $1`, songID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+// requireRow returns a NotFound error with the message if the statement changed no rows
+func requireRow(res sql.Result, msg string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	if n == 0 {
+		return utils.NewError(msg, utils.NotFound)
+	}
+
+	return nil
+}
+
+// playlistRow is a playlist selected with selectPlaylists, SmartJSON holds the smart playlist policy as JSON
+type playlistRow struct {
+	models.Playlist
+	SmartJSON []byte `db:"smart"`
+}
+
+func (r playlistRow) toModel() (models.Playlist, error) {
+	playlist := r.Playlist
+	if r.SmartJSON != nil {
+		playlist.Smart = new(models.SmartPlaylist)
+		if err := json.Unmarshal(r.SmartJSON, playlist.Smart); err != nil {
+			return models.Playlist{}, err
+		}
+	}
+
+	return playlist, nil
+}
+
+// smartJSON returns the value of the smart column, NULL for regular playlists
+func smartJSON(smart *models.SmartPlaylist) (sql.NullString, error) {
+	if smart == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(smart)
+	if err != nil {
+		return sql.NullString{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+type playlistSongRow struct {
+	EntryID  string    `db:"entry_id"`
+	Position string    `db:"position"`
+	AddedBy  string    `db:"added_by"`
+	AddedAt  time.Time `db:"added_at"`
+	songRow
+}
+
+func (r playlistSongRow) toModel() models.PlaylistSong {
+	return models.PlaylistSong{
+		EntryID:  r.EntryID,
+		Position: r.Position,
+		Song:     r.songRow.toModel(),
+		AddedBy:  r.AddedBy,
+		AddedAt:  r.AddedAt,
+	}
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func (r *repository) StreamInteractions(ctx context.Context, fn func(interaction models.Interaction) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// the training fails over to the primary only until the first interaction is passed to fn, like the export
+	var (
+		streamed bool
+		fnErr    error
+	)
+	stream := func(interaction models.Interaction) error {
+		streamed = true
+		fnErr = fn(interaction)
+		return fnErr
+	}
+
+	conn, replica := r.reader(ctx)
+	err := streamInteractions(ctx, conn, stream)
+	if !streamed && r.failover(ctx, replica, err) {
+		err = streamInteractions(ctx, r.db, stream)
+	}
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+// streamInteractions passes the plays and favorites summed up by users and songs to fn, errors are returned unwrapped
+func streamInteractions(ctx context.Context, conn *sqlx.DB, fn func(interaction models.Interaction) error) error {
+	q := `SELECT
+				interactions.user_id,
+				interactions.song_id,
+				sum(interactions.plays) AS plays,
+				bool_or(interactions.favorite) AS favorite,
+				max(interactions.at) AS last_at
+			FROM (
+				SELECT user_id, song_id, 1 AS plays, FALSE AS favorite, played_at AS at FROM plays
+				UNION ALL
+				SELECT user_id, song_id, 0 AS plays, TRUE AS favorite, created_at AS at FROM favorites
+			) AS interactions
+			JOIN songs ON songs.id = interactions.song_id
+			WHERE songs.deleted_at IS NULL
+			GROUP BY interactions.user_id, interactions.song_id
+			ORDER BY interactions.user_id, last_at DESC, interactions.song_id`
+
+	rows, err := conn.QueryxContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var interaction models.Interaction
+		if err = rows.StructScan(&interaction); err != nil {
+			return err
+		}
+
+		if err = fn(interaction); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *repository) ReplaceSongNeighbors(ctx context.Context, neighbors []models.SongNeighbor) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	songIDs := make([]string, 0, len(neighbors))
+	neighborIDs := make([]string, 0, len(neighbors))
+	scores := make([]float64, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		if !validID(neighbor.SongID) || !validID(neighbor.NeighborID) {
+			continue
+		}
+
+		songIDs = append(songIDs, neighbor.SongID)
+		neighborIDs = append(neighborIDs, neighbor.NeighborID)
+		scores = append(scores, neighbor.Score)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM song_neighbors`); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	// songs purged since the training are skipped
+	q := `INSERT INTO song_neighbors (song_id, neighbor_id, score)
+			SELECT neighbors.song_id, neighbors.neighbor_id, neighbors.score
+			FROM unnest($1::uuid[], $2::uuid[], $3::float8[]) AS neighbors(song_id, neighbor_id, score)
+			WHERE EXISTS (SELECT 1 FROM songs WHERE songs.id = neighbors.song_id) AND
+				EXISTS (SELECT 1 FROM songs WHERE songs.id = neighbors.neighbor_id)`
+
+	if _, err = tx.ExecContext(ctx, q, pq.Array(songIDs), pq.Array(neighborIDs), pq.Array(scores)); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetRecommendations(ctx context.Context, userID string, lim int) ([]models.Recommendation, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// users that are not in the database have heard nothing
+	if !validID(userID) {
+		userID = ""
+	}
+
+	q := `WITH heard AS (
+				SELECT song_id FROM plays WHERE user_id = NULLIF($1::text, '')::uuid
+				UNION
+				SELECT song_id FROM favorites WHERE user_id = NULLIF($1::text, '')::uuid
+			), scores AS (
+				SELECT song_neighbors.neighbor_id AS song_id, sum(song_neighbors.score) AS score
+				FROM song_neighbors JOIN heard ON heard.song_id = song_neighbors.song_id
+				GROUP BY song_neighbors.neighbor_id
+			)
+			SELECT
+				COALESCE(scores.score, 0) AS score,
+				songs.id,
+				group_songs.group_name,
+				songs.song,
+				songs.release_date,
+				songs.text,
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at,
+				` + statsColumns + `
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			LEFT JOIN song_stats ON songs.id = song_stats.song_id
+			LEFT JOIN scores ON songs.id = scores.song_id
+			WHERE songs.deleted_at IS NULL AND songs.id NOT IN (SELECT song_id FROM heard)
+			ORDER BY score DESC, plays DESC, songs.id LIMIT $2`
+
+	var rows []recommendationRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, userID, lim)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	recommendations := make([]models.Recommendation, 0, len(rows))
+	for _, row := range rows {
+		recommendations = append(recommendations, models.Recommendation{Song: row.songStatsRow.toModel(), Score: row.Score})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return recommendations, nil
+}
+
+type recommendationRow struct {
+	Score float64 `db:"score"`
+	songStatsRow
+}
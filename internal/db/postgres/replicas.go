@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// replicaCheckTimeout limits a single health check of a replica
+	replicaCheckTimeout = 2 * time.Second
+)
+
+// Replicas routes the reads of the repository to healthy replicas in turn. The reads go to the primary when no
+// replica is healthy or the context requires the primary, see db.ReadsPrimary
+type Replicas struct {
+	conns   []*sqlx.DB
+	healthy []atomic.Bool
+
+	next atomic.Uint64
+}
+
+// NewReplicas returns the replicas of the connections, all of them are healthy till the first check
+func NewReplicas(conns ...*sqlx.DB) *Replicas {
+	r := &Replicas{
+		conns:   conns,
+		healthy: make([]atomic.Bool, len(conns)),
+	}
+	for i := range r.healthy {
+		r.healthy[i].Store(true)
+	}
+
+	return r
+}
+
+// MustOpenReplicas opens the replicas without connecting to them, an unavailable replica is only marked unhealthy
+// by checks
+func MustOpenReplicas(dsns []string) *Replicas {
+	conns := make([]*sqlx.DB, len(dsns))
+	for i, dsn := range dsns {
+		conn, err := sqlx.Open("postgres", dsn)
+		if err != nil {
+			panic("failed to open replica: " + dsn)
+		}
+		conns[i] = conn
+	}
+
+	return NewReplicas(conns...)
+}
+
+// Check pings the replicas and marks the failed ones unhealthy till the next successful check
+func (r *Replicas) Check(ctx context.Context) {
+	for i, conn := range r.conns {
+		checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+		r.healthy[i].Store(conn.PingContext(checkCtx) == nil)
+		cancel()
+	}
+}
+
+// RunHealthChecks checks the replicas every interval till the context is done
+func (r *Replicas) RunHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx)
+		}
+	}
+}
+
+// Close closes the connections of the replicas
+func (r *Replicas) Close() error {
+	var errs []error
+	for _, conn := range r.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// pick returns the next healthy replica, false if there is none
+func (r *Replicas) pick() (int, bool) {
+	n := uint64(len(r.conns))
+	if n == 0 {
+		return 0, false
+	}
+
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		idx := int((start + i) % n)
+		if r.healthy[idx].Load() {
+			return idx, true
+		}
+	}
+
+	return 0, false
+}
+
+// read runs the query on a replica or the primary. A replica that fails to serve the query because of its
+// connection is marked unhealthy and the query is retried on the primary
+func (r *repository) read(ctx context.Context, query func(conn *sqlx.DB) error) error {
+	conn, replica := r.reader(ctx)
+
+	err := query(conn)
+	if r.failover(ctx, replica, err) {
+		return query(r.db)
+	}
+
+	return err
+}
+
+// reader returns the connection for the reads of the context and the index of the replica, -1 for the primary
+func (r *repository) reader(ctx context.Context) (*sqlx.DB, int) {
+	if r.replicas == nil || db.ReadsPrimary(ctx) {
+		return r.db, -1
+	}
+
+	idx, ok := r.replicas.pick()
+	if !ok {
+		return r.db, -1
+	}
+
+	return r.replicas.conns[idx], idx
+}
+
+// failover reports whether the read failed because of the replica connection and has to be retried on the primary,
+// the replica is marked unhealthy then
+func (r *repository) failover(ctx context.Context, replica int, err error) bool {
+	if replica < 0 || err == nil || !isConnError(err) || ctx.Err() != nil {
+		return false
+	}
+
+	r.replicas.healthy[replica].Store(false)
+
+	return true
+}
+
+// isConnError reports whether the error is caused by the database connection rather than the query
+func isConnError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection exceptions, shutdowns and replicas that can not serve queries yet
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	return false
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/db"
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/service/models"
 	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"time"
 )
 
@@ -15,8 +19,15 @@ func NewRepository(db *sqlx.DB) *repository {
 	return &repository{db: db}
 }
 
+// NewReplicatedRepository returns the repository that writes to the primary and reads from the replicas
+func NewReplicatedRepository(db *sqlx.DB, replicas *Replicas) *repository {
+	return &repository{db: db, replicas: replicas}
+}
+
 type repository struct {
 	db *sqlx.DB
+	// replicas serve the reads if set
+	replicas *Replicas
 }
 
 func (r *repository) CreateSong(ctx context.Context, song models.Song) error {
@@ -33,12 +44,14 @@ func (r *repository) CreateSong(ctx context.Context, song models.Song) error {
 		_ = tx.Rollback()
 	}()
 
-	q := `INSERT INTO songs (id, song, release_date, text, link) VALUES ($1, $2, $3, $4, $5)`
+	q := `INSERT INTO songs (id, song, release_date, text, link) VALUES ($1, $2, $3, $4, $5) RETURNING version, updated_at`
 
-	_, err = tx.ExecContext(ctx, q, song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link)
+	err = tx.QueryRowxContext(ctx, q, song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link).
+		Scan(&song.Version, &song.UpdatedAt)
 	if err != nil {
 		return utils.NewError(err.Error(), utils.Internal)
 	}
+	song.UpdatedAt = song.UpdatedAt.UTC()
 
 	q = `INSERT INTO group_songs (song_id, group_name) VALUES ($1, $2)`
 
@@ -47,9 +60,14 @@ func (r *repository) CreateSong(ctx context.Context, song models.Song) error {
 		return utils.NewError(err.Error(), utils.Internal)
 	}
 
+	if err = insertRevision(ctx, tx, models.RevisionCreate, nil, &song, 0); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return utils.NewError(err.Error(), utils.Internal)
 	}
+	db.MarkWrite(ctx)
 
 	logger.ExtractLogger(ctx).
 		Debug("repo passed CreateSong",
@@ -73,23 +91,14 @@ func (r *repository) EditSong(ctx context.Context, song models.Song) error {
 		_ = tx.Rollback()
 	}()
 
-	q := `UPDATE songs SET song = $2, release_date = $3, text = $4, link = $5 WHERE id = $1`
-
-	_, err = tx.ExecContext(ctx, q, song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link)
-	if err != nil {
-		return utils.NewError(err.Error(), utils.Internal)
-	}
-
-	q = `UPDATE group_songs SET group_name = $2 WHERE song_id = $1`
-
-	_, err = tx.ExecContext(ctx, q, song.SongID, song.Group)
-	if err != nil {
-		return utils.NewError(err.Error(), utils.Internal)
+	if err = editSong(ctx, tx, song); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
 		return utils.NewError(err.Error(), utils.Internal)
 	}
+	db.MarkWrite(ctx)
 
 	logger.ExtractLogger(ctx).
 		Debug("repo passed EditSong",
@@ -99,7 +108,7 @@ func (r *repository) EditSong(ctx context.Context, song models.Song) error {
 	return nil
 }
 
-func (r *repository) DeleteSong(ctx context.Context, songID string) error {
+func (r *repository) DeleteSong(ctx context.Context, songID string, version int) error {
 	logger.ExtractLogger(ctx).
 		Debug("repo received DeleteSong",
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
@@ -113,46 +122,91 @@ func (r *repository) DeleteSong(ctx context.Context, songID string) error {
 		_ = tx.Rollback()
 	}()
 
-	q := `DELETE FROM group_songs WHERE song_id = $1`
+	if err = deleteSong(ctx, tx, songID, version); err != nil {
+		return err
+	}
 
-	_, err = r.db.ExecContext(ctx, q, songID)
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return utils.NewError(err.Error(), utils.Internal)
 	}
+	db.MarkWrite(ctx)
 
-	q = `DELETE FROM songs WHERE id = $1`
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
 
-	_, err = tx.ExecContext(ctx, q, songID)
-	if err != nil {
-		return utils.NewError(err.Error(), utils.Internal)
+	return nil
+}
+
+func (r *repository) GetSong(ctx context.Context, songID string) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT 
+				songs.id, 
+				group_songs.group_name, 
+				songs.song, 
+				songs.release_date, 
+				songs.text, 
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at,
+				` + statsColumns + `
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			LEFT JOIN song_stats ON songs.id = song_stats.song_id
+			WHERE songs.id = $1 AND songs.deleted_at IS NULL LIMIT 1`
+
+	if !validID(songID) {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
 	}
 
-	if err = tx.Commit(); err != nil {
-		return utils.NewError(err.Error(), utils.Internal)
+	var row songStatsRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		return conn.QueryRowxContext(ctx, q, songID).StructScan(&row)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Song{}, utils.NewError("song not found", utils.NotFound)
+		}
+		return models.Song{}, utils.NewError(err.Error(), utils.Internal)
 	}
 
 	logger.ExtractLogger(ctx).
-		Debug("repo passed DeleteSong",
+		Debug("repo passed GetSong",
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
-	return nil
+	return row.toModel(), nil
 }
 
-func (r *repository) GetSongText(ctx context.Context, songID string) (string, error) {
+func (r *repository) GetSongText(ctx context.Context, songID string) (string, time.Time, error) {
 	logger.ExtractLogger(ctx).
 		Debug("repo received GetSongText",
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
-	q := `SELECT text FROM songs WHERE id = $1 LIMIT 1`
+	q := `SELECT text, updated_at FROM songs WHERE id = $1 AND deleted_at IS NULL LIMIT 1`
+
+	if !validID(songID) {
+		return "", time.Time{}, utils.NewError("song not found", utils.NotFound)
+	}
 
-	var text string
-	if err := r.db.QueryRowxContext(ctx, q, songID).Scan(&text); err != nil {
+	var (
+		text      string
+		updatedAt time.Time
+	)
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		return conn.QueryRowxContext(ctx, q, songID).Scan(&text, &updatedAt)
+	})
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", utils.NewError("song not found", utils.NotFound)
+			return "", time.Time{}, utils.NewError("song not found", utils.NotFound)
 		}
-		return "", utils.NewError(err.Error(), utils.Internal)
+		return "", time.Time{}, utils.NewError(err.Error(), utils.Internal)
 	}
 
 	logger.ExtractLogger(ctx).
@@ -160,7 +214,7 @@ func (r *repository) GetSongText(ctx context.Context, songID string) (string, er
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
-	return text, nil
+	return text, updatedAt, nil
 }
 
 func (r *repository) GetSongs(ctx context.Context, filter models.SongFilter) ([]models.Song, error) {
@@ -169,59 +223,362 @@ func (r *repository) GetSongs(ctx context.Context, filter models.SongFilter) ([]
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
+	order, ok := songOrders[filter.Sort]
+	if !ok {
+		return nil, utils.NewError("unknown sort: "+filter.Sort, utils.Internal)
+	}
+
+	args := filterArgs(filter)
+	rules := "TRUE"
+	if filter.Rules != nil {
+		rules, args = rulesCondition(*filter.Rules, args)
+	}
+
+	q := selectFilteredSongs + ` AND
+          ` + rules + `
+      ` + order + ` OFFSET $7 LIMIT $8`
+
+	if filter.SongID != "" && !validID(filter.SongID) {
+		return []models.Song{}, nil
+	}
+
+	var rows []songStatsRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, args...)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	songs := make([]models.Song, 0, len(rows))
+	for _, row := range rows {
+		songs = append(songs, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) FindSongs(ctx context.Context, songs []models.NewSong) ([]models.NewSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	groups := make([]string, len(songs))
+	names := make([]string, len(songs))
+	for i, song := range songs {
+		groups[i], names[i] = song.Group, song.Song
+	}
+
+	q := `SELECT DISTINCT group_songs.group_name, songs.song
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			INNER JOIN unnest($1::text[], $2::text[]) AS found(group_name, song) 
+				ON found.group_name = group_songs.group_name AND found.song = songs.song
+			WHERE songs.deleted_at IS NULL`
+
+	var found []models.NewSong
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows, err := conn.QueryxContext(ctx, q, pq.Array(groups), pq.Array(names))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		found = nil
+		for rows.Next() {
+			var song models.NewSong
+			if err = rows.Scan(&song.Group, &song.Song); err != nil {
+				return err
+			}
+
+			found = append(found, song)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return found, nil
+}
+
+// statsColumns select the stats of the songs joined with song_stats, songs without a row have zero stats
+const statsColumns = `COALESCE(song_stats.favorites, 0) AS favorites,
+				COALESCE(song_stats.ratings, 0) AS ratings,
+				COALESCE(song_stats.rating_sum::float8 / NULLIF(song_stats.ratings, 0), 0) AS average_rating,
+				COALESCE(song_stats.plays, 0) AS plays`
+
+func (r *repository) GetSongsByID(ctx context.Context, songIDs []string) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
 	q := `SELECT 
-				group_songs.song_id as id, 
+				songs.id, 
 				group_songs.group_name, 
 				songs.song, 
 				songs.release_date, 
 				songs.text, 
-				songs.link 
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at,
+				` + statsColumns + `
 			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			LEFT JOIN song_stats ON songs.id = song_stats.song_id
+			WHERE songs.id = ANY($1::uuid[]) AND songs.deleted_at IS NULL`
+
+	valid := make([]string, 0, len(songIDs))
+	for _, songID := range songIDs {
+		if validID(songID) {
+			valid = append(valid, songID)
+		}
+	}
+
+	var rows []songStatsRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, pq.Array(valid))
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	songs := make([]models.Song, 0, len(rows))
+	for _, row := range rows {
+		songs = append(songs, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+// selectFilteredSongs selects the songs matching models.SongFilter with their stats, $7 and $8 are left
+// for the pagination
+const selectFilteredSongs = `SELECT 
+			group_songs.song_id as id, 
+			group_songs.group_name, 
+			songs.song, 
+			songs.release_date, 
+			songs.text, 
+			songs.link,
+			songs.version,
+			songs.updated_at,
+			songs.deleted_at,
+			` + statsColumns + `
+		FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+		LEFT JOIN song_stats ON songs.id = song_stats.song_id
       WHERE 
-          (group_songs.song_id = $1 OR $1 = '') AND
+          ($1::text = '' OR group_songs.song_id = NULLIF($1::text, '')::uuid) AND
           (group_songs.group_name LIKE '%' || $2 || '%' OR $2 = '') AND
           (songs.song LIKE '%' || $3 || '%' OR $3 = '') AND
           (songs.release_date = $4 OR $4 IS NULL) AND
           (songs.text LIKE '%' || $5 || '%' OR $5 = '') AND
-          (songs.link = $6 OR $6 = '')
-      OFFSET $7 LIMIT $8`
+          (songs.link = $6 OR $6 = '') AND
+          (songs.deleted_at IS NULL OR $9) AND
+          COALESCE(song_stats.plays, 0) >= $10 AND
+          COALESCE(song_stats.rating_sum::float8 / NULLIF(song_stats.ratings, 0), 0) >= $11`
+
+// songOrders are the ORDER BY clauses of the sorts of models.SongFilter
+var songOrders = map[string]string{
+	"":                   "",
+	models.SortPlays:     "ORDER BY plays DESC, id",
+	models.SortFavorites: "ORDER BY favorites DESC, id",
+	models.SortRating:    "ORDER BY average_rating DESC, ratings DESC, id",
+}
 
-	rows, err := r.db.QueryxContext(ctx, q, filter.SongID, filter.Group, filter.Song, filter.ReleaseDate, filter.Text, filter.Link, filter.Off, filter.Lim)
-	if err != nil {
-		return nil, utils.NewError(err.Error(), utils.Internal)
+func filterArgs(filter models.SongFilter) []any {
+	return []any{filter.SongID, filter.Group, filter.Song, filter.ReleaseDate, filter.Text, filter.Link, filter.Off, filter.Lim,
+		filter.IncludeDeleted, filter.MinPlays, filter.MinRating}
+}
+
+// lockSong locks the song row till the end of the transaction and checks its version, 0 matches any version.
+// deleted selects whether the song is looked up in the trash or among the live songs
+func lockSong(ctx context.Context, tx *sqlx.Tx, songID string, version int, deleted bool) (models.Song, error) {
+	if !validID(songID) {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
 	}
 
-	songs := make([]models.Song, 0, filter.Lim)
-	for rows.Next() {
-		var fullSongData struct {
-			SongID      string    `json:"songID" db:"id"`
-			Group       string    `json:"group" db:"group_name"`
-			Song        string    `json:"song"`
-			ReleaseDate time.Time `json:"releaseDate" db:"release_date"`
-			Text        string    `json:"text"`
-			Link        string    `json:"link"`
+	q := `SELECT 
+				songs.id, 
+				group_songs.group_name, 
+				songs.song, 
+				songs.release_date, 
+				songs.text, 
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			WHERE songs.id = $1 AND (songs.deleted_at IS NOT NULL) = $2 FOR UPDATE OF songs`
+
+	var row songRow
+	if err := tx.QueryRowxContext(ctx, q, songID, deleted).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Song{}, utils.NewError("song not found", utils.NotFound)
 		}
-		if err = rows.StructScan(&fullSongData); err != nil {
-			// may not return an error and continue with the other songs
-			return nil, utils.NewError(err.Error(), utils.Internal)
+		return models.Song{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if version != 0 && version != row.Version {
+		return models.Song{}, utils.NewError(
+			fmt.Sprintf("song version mismatch: expected: %d current: %d", version, row.Version), utils.PreconditionFailed)
+	}
+
+	return row.toModel(), nil
+}
+
+// shareSong locks the live song against changes till the end of the transaction, songs in the trash are not found
+func shareSong(ctx context.Context, tx *sqlx.Tx, songID string) error {
+	var deleted bool
+	q := `SELECT deleted_at IS NOT NULL FROM songs WHERE id = $1 FOR SHARE`
+	if err := tx.QueryRowxContext(ctx, q, songID).Scan(&deleted); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.NewError("song not found", utils.NotFound)
 		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	if deleted {
+		return utils.NewError("song not found", utils.NotFound)
+	}
 
-		songs = append(songs, models.Song{
-			SongID: fullSongData.SongID,
-			Group:  fullSongData.Group,
-			Song:   fullSongData.Song,
-			Data: models.SongData{
-				ReleaseDate: fullSongData.ReleaseDate,
-				Text:        fullSongData.Text,
-				Link:        fullSongData.Link,
-			},
-		})
+	return nil
+}
+
+func editSong(ctx context.Context, tx *sqlx.Tx, song models.Song) error {
+	before, err := lockSong(ctx, tx, song.SongID, song.Version, false)
+	if err != nil {
+		return err
 	}
 
-	logger.ExtractLogger(ctx).
-		Debug("repo passed GetSongs",
-			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
-		)
+	if err = updateSong(ctx, tx, &song); err != nil {
+		return err
+	}
 
-	return songs, nil
+	return insertRevision(ctx, tx, models.RevisionEdit, &before, &song, 0)
+}
+
+// deleteSong moves the song to the trash, it stays there till it is restored or purged. The song is removed from
+// the playlists for good, restoring it does not bring it back to them
+func deleteSong(ctx context.Context, tx *sqlx.Tx, songID string, version int) error {
+	before, err := lockSong(ctx, tx, songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE songs SET deleted_at = now(), version = version + 1, updated_at = now() WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, q, songID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = removeFromPlaylists(ctx, tx, songID); err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, models.RevisionDelete, &before, nil, 0)
+}
+
+// updateSong overwrites the song data and bumps its version, the new version is set to the song
+func updateSong(ctx context.Context, tx *sqlx.Tx, song *models.Song) error {
+	q := `UPDATE songs SET song = $2, release_date = $3, text = $4, link = $5, version = version + 1, updated_at = now() 
+             WHERE id = $1 RETURNING version, updated_at`
+
+	err := tx.QueryRowxContext(ctx, q, song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link).
+		Scan(&song.Version, &song.UpdatedAt)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	song.UpdatedAt = song.UpdatedAt.UTC()
+
+	q = `UPDATE group_songs SET group_name = $2 WHERE song_id = $1`
+
+	_, err = tx.ExecContext(ctx, q, song.SongID, song.Group)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+// validID reports if the ID is a UUID. Other IDs can not belong to any row, postgres fails to compare them
+// with the uuid columns instead of finding nothing
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+type songRow struct {
+	SongID      string       `db:"id"`
+	Group       string       `db:"group_name"`
+	Song        string       `db:"song"`
+	ReleaseDate time.Time    `db:"release_date"`
+	Text        string       `db:"text"`
+	Link        string       `db:"link"`
+	Version     int          `db:"version"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+	DeletedAt   sql.NullTime `db:"deleted_at"`
+}
+
+func (s songRow) toModel() models.Song {
+	song := models.Song{
+		SongID: s.SongID,
+		Group:  s.Group,
+		Song:   s.Song,
+		Data: models.SongData{
+			ReleaseDate: s.ReleaseDate,
+			Text:        s.Text,
+			Link:        s.Link,
+		},
+		Version:   s.Version,
+		UpdatedAt: s.UpdatedAt.UTC(),
+	}
+
+	if s.DeletedAt.Valid {
+		deletedAt := s.DeletedAt.Time.UTC()
+		song.DeletedAt = &deletedAt
+	}
+
+	return song
+}
+
+// songStatsRow is a song selected with statsColumns
+type songStatsRow struct {
+	songRow
+	Favorites     int64   `db:"favorites"`
+	Ratings       int64   `db:"ratings"`
+	AverageRating float64 `db:"average_rating"`
+	Plays         int64   `db:"plays"`
+}
+
+func (s songStatsRow) toModel() models.Song {
+	song := s.songRow.toModel()
+	song.Stats = &models.SongStats{
+		Favorites:     s.Favorites,
+		Ratings:       s.Ratings,
+		AverageRating: s.AverageRating,
+		Plays:         s.Plays,
+	}
+
+	return song
 }
@@ -2,10 +2,16 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/actor"
 	"github.com/alserok/music_lib/internal/config"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/db/repotest"
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/mocks"
 	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
 	"github.com/docker/go-connections/nat"
 	"github.com/golang/mock/gomock"
 	"github.com/jmoiron/sqlx"
@@ -17,10 +23,33 @@ import (
 	"time"
 )
 
+// song IDs are UUIDs in the schema
+const (
+	songID1 = "00000000-0000-0000-0000-000000000001"
+	songID2 = "00000000-0000-0000-0000-000000000002"
+	songID3 = "00000000-0000-0000-0000-000000000003"
+)
+
+func testSongID(i int) string {
+	return fmt.Sprintf("10000000-0000-0000-0000-%012d", i)
+}
+
 func TestRepositorySuite(t *testing.T) {
 	suite.Run(t, new(RepositorySuite))
 }
 
+func TestConformanceSuite(t *testing.T) {
+	suite.Run(t, &repotest.Suite{
+		NewRepository: func(s *suite.Suite) (db.Repository, func()) {
+			conn, container := newPostgresDB(s)
+			return NewRepository(conn), func() {
+				_ = conn.Close()
+				_ = container.Terminate(context.Background())
+			}
+		},
+	})
+}
+
 type RepositorySuite struct {
 	suite.Suite
 
@@ -49,7 +78,7 @@ func (suite *RepositorySuite) TeardownTest() {
 func (suite *RepositorySuite) TestGetSongs() {
 	songs := []models.Song{
 		{
-			SongID: "id1",
+			SongID: songID1,
 			Song:   "song1",
 			Group:  "group1",
 			Data: models.SongData{
@@ -59,7 +88,7 @@ func (suite *RepositorySuite) TestGetSongs() {
 			},
 		},
 		{
-			SongID: "id2",
+			SongID: songID2,
 			Song:   "song2",
 			Group:  "group2",
 			Data: models.SongData{
@@ -112,7 +141,7 @@ func (suite *RepositorySuite) TestGetSongs() {
 	suite.Require().Len(res, 1)
 	suite.Require().Equal(songs[0].Song, res[0].Song)
 
-	res, err = suite.repo.GetSongs(ctx, models.SongFilter{SongID: "id1", Lim: 1})
+	res, err = suite.repo.GetSongs(ctx, models.SongFilter{SongID: songID1, Lim: 1})
 	suite.Require().NoError(err)
 	suite.Require().Len(res, 1)
 	suite.Require().Equal(songs[0].Song, res[0].Song)
@@ -138,7 +167,7 @@ func (suite *RepositorySuite) TestGetSongs() {
 	suite.Require().Len(res, 1)
 	suite.Require().Equal(songs[1].Song, res[0].Song)
 
-	res, err = suite.repo.GetSongs(ctx, models.SongFilter{SongID: "id2", Lim: 1})
+	res, err = suite.repo.GetSongs(ctx, models.SongFilter{SongID: songID2, Lim: 1})
 	suite.Require().NoError(err)
 	suite.Require().Len(res, 1)
 	suite.Require().Equal(songs[1].Song, res[0].Song)
@@ -151,7 +180,7 @@ func (suite *RepositorySuite) TestGetSongs() {
 
 func (suite *RepositorySuite) TestGetSongText() {
 	song := models.Song{
-		SongID: "id1",
+		SongID: songID1,
 		Song:   "song1",
 		Group:  "group1",
 		Data: models.SongData{
@@ -176,14 +205,15 @@ func (suite *RepositorySuite) TestGetSongText() {
 	ctx := logger.WrapLogger(context.Background(), suite.logger)
 	ctx = logger.WrapIdentifier(ctx)
 
-	res, err := suite.repo.GetSongText(ctx, song.SongID)
+	res, updatedAt, err := suite.repo.GetSongText(ctx, song.SongID)
 	suite.Require().NoError(err)
 	suite.Require().Equal(song.Data.Text, res)
+	suite.Require().False(updatedAt.IsZero())
 }
 
 func (suite *RepositorySuite) TestCreateSong() {
 	song := models.Song{
-		SongID: "id1",
+		SongID: songID1,
 		Song:   "song1",
 		Group:  "group1",
 		Data: models.SongData{
@@ -230,7 +260,7 @@ func (suite *RepositorySuite) TestCreateSong() {
 
 func (suite *RepositorySuite) TestEditSong() {
 	song := models.Song{
-		SongID: "id1",
+		SongID: songID1,
 		Song:   "song1",
 		Group:  "group1",
 		Data: models.SongData{
@@ -289,7 +319,7 @@ func (suite *RepositorySuite) TestEditSong() {
 
 func (suite *RepositorySuite) TestDeleteSong() {
 	song := models.Song{
-		SongID: "id1",
+		SongID: songID1,
 		Song:   "song1",
 		Group:  "group1",
 		Data: models.SongData{
@@ -314,8 +344,40 @@ func (suite *RepositorySuite) TestDeleteSong() {
 	ctx := logger.WrapLogger(context.Background(), suite.logger)
 	ctx = logger.WrapIdentifier(ctx)
 
-	err = suite.repo.DeleteSong(ctx, song.SongID)
+	err = suite.repo.DeleteSong(ctx, song.SongID, 0)
+	suite.Require().NoError(err)
+
+	// deleted song is hidden but stays in the trash
+	songs, err := suite.repo.GetSongs(ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Empty(songs)
+
+	songs, err = suite.repo.GetSongs(ctx, models.SongFilter{Lim: 10, IncludeDeleted: true})
+	suite.Require().NoError(err)
+	suite.Require().Len(songs, 1)
+	suite.Require().NotNil(songs[0].DeletedAt)
+
+	_, err = suite.repo.GetSong(ctx, song.SongID)
+	suite.Require().Error(err)
+
+	// restored song is visible again
+	suite.Require().NoError(suite.repo.RestoreDeletedSong(ctx, song.SongID, 0))
+
+	restored, err := suite.repo.GetSong(ctx, song.SongID)
+	suite.Require().NoError(err)
+	suite.Require().Nil(restored.DeletedAt)
+	suite.Require().Equal(3, restored.Version)
+
+	// songs are purged only after the retention period
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, song.SongID, 0))
+
+	purged, err := suite.repo.PurgeDeletedSongs(ctx, time.Hour)
 	suite.Require().NoError(err)
+	suite.Require().Equal(int64(0), purged)
+
+	purged, err = suite.repo.PurgeDeletedSongs(ctx, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(int64(1), purged)
 
 	var res int64
 	suite.Require().NoError(suite.conn.QueryRowx(`SELECT count(*) FROM songs`).Scan(&res))
@@ -323,6 +385,402 @@ func (suite *RepositorySuite) TestDeleteSong() {
 
 	suite.Require().NoError(suite.conn.QueryRowx(`SELECT count(*) FROM group_songs`).Scan(&res))
 	suite.Require().Equal(int64(0), res)
+
+	suite.Require().NoError(suite.conn.QueryRowx(`SELECT count(*) FROM song_revisions`).Scan(&res))
+	suite.Require().Equal(int64(0), res)
+}
+
+func (suite *RepositorySuite) TestEditSongVersionMismatch() {
+	song := models.Song{
+		SongID: songID1,
+		Song:   "song1",
+		Group:  "group1",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC),
+			Text:        "song text 1\n\nsong text 2",
+			Link:        "link1",
+		},
+	}
+	_, err := suite.conn.Exec(`INSERT INTO songs (id, song, release_date, text, link) VALUES ($1, $2, $3, $4, $5)`,
+		song.SongID, song.Song, song.Data.ReleaseDate, song.Data.Text, song.Data.Link,
+	)
+	suite.Require().NoError(err)
+	_, err = suite.conn.Exec(`INSERT INTO group_songs (song_id, group_name) VALUES ($1, $2)`,
+		song.SongID, song.Group,
+	)
+	suite.Require().NoError(err)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	// first edit with the actual version bumps it
+	song.Version = 1
+	song.Song = "edited song title"
+	suite.Require().NoError(suite.repo.EditSong(ctx, song))
+
+	res, err := suite.repo.GetSong(ctx, song.SongID)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, res.Version)
+	suite.Require().Equal(song.Song, res.Song)
+
+	// second edit with the stale version is rejected
+	song.Song = "stale song title"
+	suite.Require().Error(suite.repo.EditSong(ctx, song))
+	suite.Require().Error(suite.repo.DeleteSong(ctx, song.SongID, 1))
+
+	res, err = suite.repo.GetSong(ctx, song.SongID)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, res.Version)
+	suite.Require().Equal("edited song title", res.Song)
+
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, song.SongID, 2))
+}
+
+func (suite *RepositorySuite) TestSongRevisions() {
+	song := models.Song{
+		SongID: songID1,
+		Song:   "song1",
+		Group:  "group1",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC),
+			Text:        "song text 1",
+			Link:        "link1",
+		},
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+	ctx = actor.WrapActor(ctx, "author")
+
+	suite.Require().NoError(suite.repo.CreateSong(ctx, song))
+
+	edited := song
+	edited.Song = "edited song title"
+	suite.Require().NoError(suite.repo.EditSong(ctx, edited))
+
+	// restore to the first revision creates the third one
+	suite.Require().NoError(suite.repo.RestoreSong(ctx, song.SongID, 1, 2))
+
+	current, err := suite.repo.GetSong(ctx, song.SongID)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, current.Version)
+	suite.Require().Equal(song.Song, current.Song)
+
+	revisions, err := suite.repo.GetSongRevisions(ctx, song.SongID, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 3)
+	suite.Require().Equal(models.RevisionRestore, revisions[0].Action)
+	suite.Require().Equal(1, revisions[0].RestoredFrom)
+	suite.Require().Equal(edited.Song, revisions[0].Before.Song)
+	suite.Require().Equal(song.Song, revisions[0].After.Song)
+	suite.Require().Equal(models.RevisionEdit, revisions[1].Action)
+	suite.Require().Equal(models.RevisionCreate, revisions[2].Action)
+	suite.Require().Nil(revisions[2].Before)
+	suite.Require().Equal("author", revisions[2].Author)
+
+	revision, err := suite.repo.GetSongRevision(ctx, song.SongID, 2)
+	suite.Require().NoError(err)
+	suite.Require().Equal(edited.Song, revision.After.Song)
+
+	revision, err = suite.repo.GetSongRevisionAt(ctx, song.SongID, time.Now().Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, revision.Version)
+
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, song.SongID, 3))
+
+	revision, err = suite.repo.GetSongRevision(ctx, song.SongID, 4)
+	suite.Require().NoError(err)
+	suite.Require().Equal(models.RevisionDelete, revision.Action)
+	suite.Require().Nil(revision.After)
+}
+
+func (suite *RepositorySuite) TestBulk() {
+	songs := []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1", Data: models.SongData{Text: "text1"}},
+		{SongID: songID2, Song: "song2", Group: "group2", Data: models.SongData{Text: "text2"}},
+		{SongID: songID3, Song: "song3", Group: "group3", Data: models.SongData{Text: "text3"}},
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	suite.Require().NoError(suite.repo.CreateSongs(ctx, songs))
+
+	res, err := suite.repo.GetSongs(ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(res, len(songs))
+
+	revisions, err := suite.repo.GetSongRevisions(ctx, songID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 1)
+
+	// best-effort edit skips the stale version only
+	edits := []models.Song{songs[0], songs[1]}
+	edits[0].Song, edits[0].Version = "edited1", 1
+	edits[1].Song, edits[1].Version = "edited2", 5
+
+	errs, err := suite.repo.EditSongs(ctx, edits, false)
+	suite.Require().NoError(err)
+	suite.Require().NoError(errs[0])
+	suite.Require().Error(errs[1])
+
+	song, err := suite.repo.GetSong(ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal("edited1", song.Song)
+
+	// atomic delete is rolled back by the missing song
+	errs, err = suite.repo.DeleteSongs(ctx, []models.SongVersion{{SongID: songID2}, {SongID: "missing"}}, true)
+	suite.Require().NoError(err)
+	suite.Require().NoError(errs[0])
+	suite.Require().Error(errs[1])
+
+	_, err = suite.repo.GetSong(ctx, songID2)
+	suite.Require().NoError(err)
+
+	errs, err = suite.repo.DeleteSongs(ctx, []models.SongVersion{{SongID: songID2}, {SongID: songID3}}, true)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]error{nil, nil}, errs)
+
+	res, err = suite.repo.GetSongs(ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(res, 1)
+}
+
+func (suite *RepositorySuite) TestFindSongs() {
+	songs := []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1"},
+		{SongID: songID2, Song: "song2", Group: "group2"},
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	suite.Require().NoError(suite.repo.CreateSongs(ctx, songs))
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, songID2, 0))
+
+	found, err := suite.repo.FindSongs(ctx, []models.NewSong{
+		{Group: "group1", Song: "song1"},
+		{Group: "group1", Song: "song2"},
+		{Group: "group2", Song: "song2"},
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]models.NewSong{{Group: "group1", Song: "song1"}}, found)
+}
+
+func (suite *RepositorySuite) TestStreamSongs() {
+	songs := make([]models.Song, exportBatchSize+10)
+	for i := range songs {
+		songs[i] = models.Song{SongID: testSongID(i), Song: fmt.Sprintf("song%d", i), Group: "group"}
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	suite.Require().NoError(suite.repo.CreateSongs(ctx, songs))
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, testSongID(0), 0))
+
+	var ids []string
+	err := suite.repo.StreamSongs(ctx, models.SongFilter{Group: "group"}, func(song models.Song) error {
+		ids = append(ids, song.SongID)
+		return nil
+	})
+	suite.Require().NoError(err)
+	suite.Require().Len(ids, len(songs)-1)
+	suite.Require().Equal(testSongID(1), ids[0])
+	suite.Require().Equal(songs[len(songs)-1].SongID, ids[len(ids)-1])
+
+	ids = nil
+	err = suite.repo.StreamSongs(ctx, models.SongFilter{Lim: 2, Off: 1, IncludeDeleted: true}, func(song models.Song) error {
+		ids = append(ids, song.SongID)
+		return nil
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{testSongID(1), testSongID(2)}, ids)
+
+	stop := errors.New("stop")
+	err = suite.repo.StreamSongs(ctx, models.SongFilter{}, func(song models.Song) error {
+		return stop
+	})
+	suite.Require().ErrorIs(err, stop)
+}
+
+func (suite *RepositorySuite) TestAtomicityUnderFailure() {
+	songs := []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1"},
+		{SongID: songID2, Song: "song2", Group: "group2"},
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	suite.Require().NoError(suite.repo.CreateSongs(ctx, songs))
+
+	// the revision is written last, so its failure comes after the song was already updated in the transaction
+	_, err := suite.conn.Exec(`CREATE FUNCTION fail_revision() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'revision failure';
+		END $$ LANGUAGE plpgsql`)
+	suite.Require().NoError(err)
+	_, err = suite.conn.Exec(`CREATE TRIGGER fail_revision BEFORE INSERT ON song_revisions 
+		FOR EACH ROW WHEN (NEW.song_id = '` + songID2 + `') EXECUTE FUNCTION fail_revision()`)
+	suite.Require().NoError(err)
+
+	requireUnchanged := func(songID string, want models.Song) {
+		song, err := suite.repo.GetSong(ctx, songID)
+		suite.Require().NoError(err)
+		suite.Require().Equal(want.Song, song.Song)
+		suite.Require().Equal(want.Group, song.Group)
+		suite.Require().Equal(1, song.Version)
+
+		revisions, err := suite.repo.GetSongRevisions(ctx, songID, 10, 0)
+		suite.Require().NoError(err)
+		suite.Require().Len(revisions, 1)
+	}
+
+	edited := songs[1]
+	edited.Song, edited.Group = "edited", "edited"
+	suite.Require().Error(suite.repo.EditSong(ctx, edited))
+	requireUnchanged(songID2, songs[1])
+
+	suite.Require().Error(suite.repo.DeleteSong(ctx, songID2, 0))
+	requireUnchanged(songID2, songs[1])
+
+	// the failure of the last item rolls back the applied first one
+	first := songs[0]
+	first.Song = "edited"
+	errs, err := suite.repo.EditSongs(ctx, []models.Song{first, edited}, true)
+	suite.Require().NoError(err)
+	suite.Require().NoError(errs[0])
+	suite.Require().Error(errs[1])
+	requireUnchanged(songID1, songs[0])
+
+	errs, err = suite.repo.DeleteSongs(ctx, []models.SongVersion{{SongID: songID1}, {SongID: songID2}}, true)
+	suite.Require().NoError(err)
+	suite.Require().Error(errs[1])
+	requireUnchanged(songID1, songs[0])
+
+	// best-effort batches keep the items before and after the failed one
+	errs, err = suite.repo.EditSongs(ctx, []models.Song{edited, first}, false)
+	suite.Require().NoError(err)
+	suite.Require().Error(errs[0])
+	suite.Require().NoError(errs[1])
+	requireUnchanged(songID2, songs[1])
+
+	song, err := suite.repo.GetSong(ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal("edited", song.Song)
+	suite.Require().Equal(2, song.Version)
+}
+
+func (suite *RepositorySuite) TestConstraints() {
+	songs := []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1"},
+		{SongID: songID2, Song: "song2", Group: "group2"},
+	}
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	suite.Require().NoError(suite.repo.CreateSongs(ctx, songs))
+
+	// a song belongs to a single group
+	_, err := suite.conn.Exec(`INSERT INTO group_songs (song_id, group_name) VALUES ($1, $2)`, songID1, "group2")
+	suite.Require().Error(err)
+
+	_, err = suite.conn.Exec(`INSERT INTO group_songs (song_id, group_name) VALUES ($1, $2)`, songID3, "group3")
+	suite.Require().Error(err)
+
+	count := func(table, column, songID string) int {
+		var n int
+		suite.Require().NoError(suite.conn.Get(&n, `SELECT count(*) FROM `+table+` WHERE `+column+` = $1`, songID))
+		return n
+	}
+
+	// removing a song removes its group and history
+	_, err = suite.conn.Exec(`DELETE FROM songs WHERE id = $1`, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Zero(count("group_songs", "song_id", songID1))
+	suite.Require().Zero(count("song_revisions", "song_id", songID1))
+
+	suite.Require().NoError(suite.repo.DeleteSong(ctx, songID2, 0))
+
+	purged, err := suite.repo.PurgeDeletedSongs(ctx, 0)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(1, purged)
+	suite.Require().Zero(count("songs", "id", songID2))
+	suite.Require().Zero(count("group_songs", "song_id", songID2))
+	suite.Require().Zero(count("song_revisions", "song_id", songID2))
+
+	// IDs that are not UUIDs can not match any song
+	_, err = suite.repo.GetSong(ctx, "id")
+	suite.Require().True(utils.IsNotFound(err))
+
+	res, err := suite.repo.GetSongs(ctx, models.SongFilter{SongID: "id", Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Empty(res)
+}
+
+func (suite *RepositorySuite) TestMigrations() {
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	migrations, err := suite.repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(migrations)
+	for _, migration := range migrations {
+		suite.Require().True(migration.Applied, migration.Name)
+	}
+
+	// every down section must undo its up section, so the schema can be rebuilt from scratch
+	suite.Require().NoError(suite.repo.MigrateDown(ctx, 0))
+
+	migrations, err = suite.repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	for _, migration := range migrations {
+		suite.Require().False(migration.Applied, migration.Name)
+	}
+
+	suite.Require().NoError(suite.repo.MigrateUp(ctx, migrations[0].Version))
+
+	migrations, err = suite.repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	suite.Require().True(migrations[0].Applied)
+	suite.Require().False(migrations[1].Applied)
+
+	suite.Require().NoError(suite.repo.MigrateUp(ctx, 0))
+	suite.Require().NoError(suite.repo.CreateSong(ctx, models.Song{SongID: songID1, Song: "song", Group: "group"}))
 }
 
 func newPostgresDB(s *suite.Suite) (*sqlx.DB, *postgres.PostgresContainer) {
@@ -354,7 +812,7 @@ func newPostgresDB(s *suite.Suite) (*sqlx.DB, *postgres.PostgresContainer) {
 	s.Require().NoError(err)
 	cfg.Port = port.Port()
 
-	conn := MustConnect(cfg.DSN(), "../migrations")
+	conn := MustConnect(cfg.DSN())
 
 	return conn, postgresContainer
 }
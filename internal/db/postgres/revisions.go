@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (r *repository) RestoreSong(ctx context.Context, songID string, revision int, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	before, err := lockSong(ctx, tx, songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	q := `SELECT song_id, version, action, author, created_at, before, after, restored_from
+			FROM song_revisions WHERE song_id = $1 AND version = $2`
+
+	target, err := getRevision(ctx, tx, q, songID, revision)
+	if err != nil {
+		return err
+	}
+	if target.After == nil {
+		return utils.NewError("revision deletes the song and can not be restored", utils.BadRequest)
+	}
+
+	song := *target.After
+	song.SongID = songID
+	if err = updateSong(ctx, tx, &song); err != nil {
+		return err
+	}
+
+	if err = insertRevision(ctx, tx, models.RevisionRestore, &before, &song, revision); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT song_id, version, action, author, created_at, before, after, restored_from
+			FROM song_revisions WHERE song_id = $1
+			ORDER BY version DESC OFFSET $2 LIMIT $3`
+
+	if !validID(songID) {
+		return []models.SongRevision{}, nil
+	}
+
+	var rows []revisionRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		rows = rows[:0]
+		return conn.SelectContext(ctx, &rows, q, songID, off, lim)
+	})
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	revisions := make([]models.SongRevision, 0, len(rows))
+	for _, row := range rows {
+		revision, err := row.toModel()
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revisions, nil
+}
+
+func (r *repository) GetSongRevision(ctx context.Context, songID string, version int) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT song_id, version, action, author, created_at, before, after, restored_from
+			FROM song_revisions WHERE song_id = $1 AND version = $2`
+
+	if !validID(songID) {
+		return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+	}
+
+	var row revisionRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		return conn.QueryRowxContext(ctx, q, songID, version).StructScan(&row)
+	})
+
+	revision, err := scannedRevision(row, err)
+	if err != nil {
+		return models.SongRevision{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revision, nil
+}
+
+func (r *repository) GetSongRevisionAt(ctx context.Context, songID string, at time.Time) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisionAt",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT song_id, version, action, author, created_at, before, after, restored_from
+			FROM song_revisions WHERE song_id = $1 AND created_at <= $2
+			ORDER BY version DESC LIMIT 1`
+
+	if !validID(songID) {
+		return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+	}
+
+	var row revisionRow
+	err := r.read(ctx, func(conn *sqlx.DB) error {
+		return conn.QueryRowxContext(ctx, q, songID, at).StructScan(&row)
+	})
+
+	revision, err := scannedRevision(row, err)
+	if err != nil {
+		return models.SongRevision{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevisionAt",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revision, nil
+}
+
+// getRevision runs the revision query, the song ID must be the first argument
+func getRevision(ctx context.Context, db sqlx.QueryerContext, q string, songID string, args ...any) (models.SongRevision, error) {
+	if !validID(songID) {
+		return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+	}
+
+	var row revisionRow
+	err := db.QueryRowxContext(ctx, q, append([]any{songID}, args...)...).StructScan(&row)
+
+	return scannedRevision(row, err)
+}
+
+// scannedRevision converts the row scanned with the error err
+func scannedRevision(row revisionRow, err error) (models.SongRevision, error) {
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+		}
+		return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return row.toModel()
+}
+
+// insertRevision records the change, the revision takes the version of the after state or the next one for deletes
+func insertRevision(ctx context.Context, tx *sqlx.Tx, action string, before, after *models.Song, restoredFrom int) error {
+	values, err := revisionValues(ctx, action, before, after, restoredFrom)
+	if err != nil {
+		return err
+	}
+
+	q := `INSERT INTO song_revisions (song_id, version, action, author, before, after, restored_from)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	if _, err = tx.ExecContext(ctx, q, values...); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+var revisionColumns = []string{"song_id", "version", "action", "author", "before", "after", "restored_from"}
+
+// revisionValues returns the revision row in the order of revisionColumns
+func revisionValues(ctx context.Context, action string, before, after *models.Song, restoredFrom int) ([]any, error) {
+	var (
+		songID                string
+		version               int
+		beforeJSON, afterJSON any
+		restoredFromValue     sql.NullInt64
+	)
+
+	// nil snapshots stay untyped nils, nil slices would be sent as empty strings which are not valid JSONB
+	if before != nil {
+		songID, version = before.SongID, before.Version+1
+		b, err := json.Marshal(before)
+		if err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+		beforeJSON = b
+	}
+	if after != nil {
+		// the stats sent with the song are not part of its versions
+		snapshot := *after
+		snapshot.Stats = nil
+		songID, version = after.SongID, after.Version
+		b, err := json.Marshal(snapshot)
+		if err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+		afterJSON = b
+	}
+	if restoredFrom != 0 {
+		restoredFromValue = sql.NullInt64{Int64: int64(restoredFrom), Valid: true}
+	}
+
+	return []any{songID, version, action, actor.ExtractActor(ctx), beforeJSON, afterJSON, restoredFromValue}, nil
+}
+
+type revisionRow struct {
+	SongID       string        `db:"song_id"`
+	Version      int           `db:"version"`
+	Action       string        `db:"action"`
+	Author       string        `db:"author"`
+	CreatedAt    time.Time     `db:"created_at"`
+	Before       []byte        `db:"before"`
+	After        []byte        `db:"after"`
+	RestoredFrom sql.NullInt64 `db:"restored_from"`
+}
+
+func (r revisionRow) toModel() (models.SongRevision, error) {
+	revision := models.SongRevision{
+		SongID:       r.SongID,
+		Version:      r.Version,
+		Action:       r.Action,
+		Author:       r.Author,
+		CreatedAt:    r.CreatedAt.UTC(),
+		RestoredFrom: int(r.RestoredFrom.Int64),
+	}
+
+	if r.Before != nil {
+		revision.Before = new(models.Song)
+		if err := json.Unmarshal(r.Before, revision.Before); err != nil {
+			return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+	if r.After != nil {
+		revision.After = new(models.Song)
+		if err := json.Unmarshal(r.After, revision.After); err != nil {
+			return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	return revision, nil
+}
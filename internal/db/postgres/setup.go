@@ -1,13 +1,24 @@
 package postgres
 
 import (
+	"context"
+	"fmt"
 	"github.com/jmoiron/sqlx"
-	"github.com/pressly/goose"
 
 	_ "github.com/lib/pq"
 )
 
+// MustConnect connects to the database and applies pending migrations, the embedded ones unless dir is set
 func MustConnect(dsn string, dir ...string) *sqlx.DB {
+	conn := MustOpen(dsn)
+
+	mustMigrate(conn, dir...)
+
+	return conn
+}
+
+// MustOpen connects to the database without applying migrations
+func MustOpen(dsn string) *sqlx.DB {
 	conn, err := sqlx.Connect("postgres", dsn)
 	if err != nil {
 		panic("failed to connect to database: " + dsn)
@@ -17,27 +28,30 @@ func MustConnect(dsn string, dir ...string) *sqlx.DB {
 		panic("failed to ping database: " + dsn)
 	}
 
-	mustMigrate(conn, dir...)
-
 	return conn
 }
 
-const (
-	migrationsDir = "./internal/db/migrations"
-)
-
 func mustMigrate(conn *sqlx.DB, dir ...string) {
-	if err := goose.SetDialect("postgres"); err != nil {
-		panic("failed to set dialect: " + err.Error())
+	if err := MigrateUp(conn, dir...); err != nil {
+		panic("failed to migrate: " + err.Error())
 	}
+}
 
-	if len(dir) == 0 {
-		if err := goose.Up(conn.DB, migrationsDir); err != nil {
-			panic("failed to migrate: " + err.Error())
+// reindexTables are rebuilt by Reindex
+var reindexTables = []string{"songs", "group_songs", "song_revisions", "users", "sessions", "api_keys", "playlists",
+	"playlist_collaborators", "playlist_songs", "favorites", "ratings", "plays", "song_stats", "song_neighbors"}
+
+// Reindex rebuilds the indexes of the library tables and refreshes the planner statistics
+func Reindex(ctx context.Context, conn *sqlx.DB) error {
+	for _, table := range reindexTables {
+		if _, err := conn.ExecContext(ctx, "REINDEX TABLE "+table); err != nil {
+			return fmt.Errorf("failed to reindex %s: %w", table, err)
 		}
-	} else {
-		if err := goose.Up(conn.DB, dir[0]); err != nil {
-			panic("failed to migrate: " + err.Error())
+
+		if _, err := conn.ExecContext(ctx, "ANALYZE "+table); err != nil {
+			return fmt.Errorf("failed to analyze %s: %w", table, err)
 		}
 	}
+
+	return nil
 }
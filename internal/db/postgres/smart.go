@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"strconv"
+	"strings"
+)
+
+// rulesCondition returns the condition selecting the songs matching the rules of a smart playlist from the songs
+// joined with group_songs and song_stats. The values of the rules are appended to args, the placeholders of the
+// condition follow the ones of args
+func rulesCondition(rules models.SmartRules, args []any) (string, []any) {
+	conds := make([]string, 0, len(rules.Conditions)+len(rules.Groups))
+	for _, condition := range rules.Conditions {
+		var cond string
+		cond, args = smartCondition(condition, args)
+		conds = append(conds, cond)
+	}
+	for _, group := range rules.Groups {
+		var cond string
+		cond, args = rulesCondition(group, args)
+		conds = append(conds, cond)
+	}
+
+	if len(conds) == 0 {
+		return "TRUE", args
+	}
+	if rules.Match == models.MatchAny {
+		return "(" + strings.Join(conds, " OR ") + ")", args
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
+
+func smartCondition(condition models.SmartCondition, args []any) (string, []any) {
+	arg := func(val any) string {
+		args = append(args, val)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var conds []string
+	if condition.Group != "" {
+		conds = append(conds, `group_songs.group_name LIKE '%' || `+arg(condition.Group)+` || '%'`)
+	}
+	if condition.Song != "" {
+		conds = append(conds, `songs.song LIKE '%' || `+arg(condition.Song)+` || '%'`)
+	}
+	if condition.Text != "" {
+		conds = append(conds, `songs.text LIKE '%' || `+arg(condition.Text)+` || '%'`)
+	}
+	if condition.Link != "" {
+		conds = append(conds, `songs.link = `+arg(condition.Link))
+	}
+	if condition.ReleasedFrom != nil {
+		conds = append(conds, `songs.release_date >= `+arg(*condition.ReleasedFrom))
+	}
+	if condition.ReleasedTo != nil {
+		conds = append(conds, `songs.release_date <= `+arg(*condition.ReleasedTo))
+	}
+	if condition.MinPlays != 0 {
+		conds = append(conds, `COALESCE(song_stats.plays, 0) >= `+arg(condition.MinPlays))
+	}
+	if condition.MaxPlays != nil {
+		conds = append(conds, `COALESCE(song_stats.plays, 0) <= `+arg(*condition.MaxPlays))
+	}
+	if condition.MinFavorites != 0 {
+		conds = append(conds, `COALESCE(song_stats.favorites, 0) >= `+arg(condition.MinFavorites))
+	}
+	if condition.MinRating != 0 {
+		conds = append(conds,
+			`COALESCE(song_stats.rating_sum::float8 / NULLIF(song_stats.ratings, 0), 0) >= `+arg(condition.MinRating))
+	}
+	if condition.AddedWithinDays != 0 {
+		// songs are added by their first revision
+		conds = append(conds, `(SELECT min(created_at) FROM song_revisions WHERE song_revisions.song_id = songs.id) >=
+			now() - make_interval(days => `+arg(condition.AddedWithinDays)+`::int)`)
+	}
+
+	if len(conds) == 0 {
+		return "TRUE", args
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args
+}
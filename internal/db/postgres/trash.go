@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/lib/pq"
+	"time"
+)
+
+func (r *repository) RestoreDeletedSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	song, err := lockSong(ctx, tx, songID, version, true)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE songs SET deleted_at = NULL, version = version + 1, updated_at = now() WHERE id = $1 RETURNING version, updated_at`
+
+	if err = tx.QueryRowxContext(ctx, q, songID).Scan(&song.Version, &song.UpdatedAt); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	song.UpdatedAt = song.UpdatedAt.UTC()
+	song.DeletedAt = nil
+
+	if err = insertRevision(ctx, tx, models.RevisionUndelete, nil, &song, 0); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// locked rows can not be restored from the trash while they are purged
+	q := `SELECT id FROM songs WHERE deleted_at < now() - make_interval(secs => $1) FOR UPDATE`
+
+	var ids []string
+	if err = tx.SelectContext(ctx, &ids, q, retention.Seconds()); err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// the groups and revisions of the songs are removed by the cascade
+	q = `DELETE FROM songs WHERE id = ANY($1)`
+
+	if _, err = tx.ExecContext(ctx, q, pq.Array(ids)); err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return int64(len(ids)), nil
+}
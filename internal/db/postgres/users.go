@@ -0,0 +1,253 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/lib/pq"
+	"time"
+)
+
+// accounts are always read from the primary, a replica lagging behind a login must not reject its session
+
+const selectUsers = `SELECT id, name, display_name, email, role, password_hash, created_at, updated_at FROM users`
+
+func (r *repository) CreateUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `INSERT INTO users (id, name, display_name, email, role, password_hash) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, q, user.UserID, user.Name, user.DisplayName, user.Email, user.Role, user.PasswordHash)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return utils.NewError("user name is taken", utils.Conflict)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetUser(ctx context.Context, userID string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return models.User{}, utils.NewError("user not found", utils.NotFound)
+	}
+
+	user, err := r.getUser(ctx, selectUsers+` WHERE id = $1`, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUserByName",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := r.getUser(ctx, selectUsers+` WHERE name = $1`, name)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetUserByName",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) EditUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(user.UserID) {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	q := `UPDATE users SET display_name = $1, email = $2, role = $3, password_hash = $4, updated_at = now() WHERE id = $5`
+
+	res, err := r.db.ExecContext(ctx, q, user.DisplayName, user.Email, user.Role, user.PasswordHash, user.UserID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	} else if n == 0 {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) CreateSession(ctx context.Context, tokenHash string, userID string, ttl time.Duration) (time.Time, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return time.Time{}, utils.NewError("user not found", utils.NotFound)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	q := `DELETE FROM sessions WHERE user_id = $1 AND expires_at <= now()`
+
+	if _, err = tx.ExecContext(ctx, q, userID); err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q = `INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, now() + make_interval(secs => $3))
+	  RETURNING expires_at`
+
+	var expiresAt time.Time
+	if err = tx.QueryRowxContext(ctx, q, tokenHash, userID, ttl.Seconds()).Scan(&expiresAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return time.Time{}, utils.NewError("user not found", utils.NotFound)
+		}
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return expiresAt, nil
+}
+
+func (r *repository) GetSessionUser(ctx context.Context, tokenHash string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT users.id, users.name, users.display_name, users.email, users.role, users.password_hash, users.created_at,
+       users.updated_at
+	  FROM sessions
+	  JOIN users ON users.id = sessions.user_id
+	  WHERE sessions.token_hash = $1 AND sessions.expires_at > now()`
+
+	user, err := r.getUser(ctx, q, tokenHash)
+	if err != nil {
+		if utils.IsNotFound(err) {
+			return models.User{}, utils.NewError("session not found", utils.NotFound)
+		}
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) DeleteSession(ctx context.Context, tokenHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeleteUserSessions(ctx context.Context, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !validID(userID) {
+		return nil
+	}
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	db.MarkWrite(ctx)
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) getUser(ctx context.Context, q string, args ...any) (models.User, error) {
+	var user models.User
+	if err := r.db.QueryRowxContext(ctx, q, args...).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, utils.NewError("user not found", utils.NotFound)
+		}
+		return models.User{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return user, nil
+}
+
+// isUniqueViolation reports whether the error is a violation of a unique constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -3,12 +3,129 @@ package db
 import (
 	"context"
 	"github.com/alserok/music_lib/internal/service/models"
+	"time"
 )
 
+// LastUseInterval is how often the use of an API key is recorded, so busy clients do not write on every request
+const LastUseInterval = time.Minute
+
 type Repository interface {
 	CreateSong(ctx context.Context, song models.Song) error
 	EditSong(ctx context.Context, song models.Song) error
-	DeleteSong(ctx context.Context, songID string) error
-	GetSongText(ctx context.Context, songID string) (string, error)
+	// DeleteSong moves the song to the trash and removes it from the playlists
+	DeleteSong(ctx context.Context, songID string, version int) error
+	// GetSong returns the song with its stats
+	GetSong(ctx context.Context, songID string) (models.Song, error)
+	GetSongText(ctx context.Context, songID string) (text string, updatedAt time.Time, err error)
+	// GetSongsByID returns the songs of the IDs with their stats in no particular order, songs in the trash and
+	// unknown IDs are left out
+	GetSongsByID(ctx context.Context, songIDs []string) ([]models.Song, error)
+	// GetSongs returns the songs matching the filter with their stats
 	GetSongs(ctx context.Context, filter models.SongFilter) ([]models.Song, error)
+	// StreamSongs calls fn for every song matching the filter in the order of song IDs without loading all of them
+	// into memory, the zero limit matches all songs and the sort and the rules are ignored. The stats of the songs
+	// are not set. An error of fn stops the stream and is returned as is
+	StreamSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error
+	// FindSongs returns the songs of the list that are already in the library, songs in the trash are not included
+	FindSongs(ctx context.Context, songs []models.NewSong) ([]models.NewSong, error)
+
+	// RestoreSong reverts the song to the state of the revision, the restore is recorded as a new revision
+	RestoreSong(ctx context.Context, songID string, revision int, version int) error
+	GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error)
+	GetSongRevision(ctx context.Context, songID string, version int) (models.SongRevision, error)
+	// GetSongRevisionAt returns the last revision created before or at the given time
+	GetSongRevisionAt(ctx context.Context, songID string, at time.Time) (models.SongRevision, error)
+
+	// RestoreDeletedSong moves the song out of the trash
+	RestoreDeletedSong(ctx context.Context, songID string, version int) error
+	// PurgeDeletedSongs permanently removes songs that are in the trash longer than retention
+	PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error)
+
+	// CreateSongs inserts all songs in a single transaction using multi-row inserts
+	CreateSongs(ctx context.Context, songs []models.Song) error
+	// EditSongs applies the edits in a single transaction and returns an error per song. If atomic is set
+	// the first failure rolls back the whole batch, otherwise only the failed edits are skipped
+	EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]error, error)
+	// DeleteSongs works like EditSongs for moving songs to the trash
+	DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]error, error)
+
+	// GetMigrations returns the migrations of the schema in the order of versions
+	GetMigrations(ctx context.Context) ([]models.Migration, error)
+	// MigrateUp applies the pending migrations up to and including the version, 0 applies all of them
+	MigrateUp(ctx context.Context, version int64) error
+	// MigrateDown rolls back the migrations newer than the version, 0 rolls back all of them
+	MigrateDown(ctx context.Context, version int64) error
+
+	// CreateUser stores the account, a taken name is a Conflict error
+	CreateUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, userID string) (models.User, error)
+	GetUserByName(ctx context.Context, name string) (models.User, error)
+	// EditUser updates the profile and the password hash of the user
+	EditUser(ctx context.Context, user models.User) error
+
+	// CreateSession stores the session of the token hash valid for ttl and removes the expired sessions of the user
+	CreateSession(ctx context.Context, tokenHash string, userID string, ttl time.Duration) (expiresAt time.Time, err error)
+	// GetSessionUser returns the user of the session, expired sessions are not found
+	GetSessionUser(ctx context.Context, tokenHash string) (models.User, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteUserSessions(ctx context.Context, userID string) error
+
+	// CreateAPIKey stores the key of the owner by the hash of its secret
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error
+	GetAPIKey(ctx context.Context, keyID string) (models.APIKey, error)
+	// GetAPIKeys returns the keys of the owner including the revoked ones in the order of creation
+	GetAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error)
+	// UseAPIKey returns the active key of the hash and records its use, revoked keys are not found
+	UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+	// RevokeAPIKey revokes the key for good, revoking a revoked key keeps its revocation time
+	RevokeAPIKey(ctx context.Context, keyID string) error
+
+	CreatePlaylist(ctx context.Context, playlist models.Playlist) error
+	// GetPlaylist returns the playlist with its collaborators, the songs are returned by GetPlaylistSongs
+	GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error)
+	// GetPlaylists returns the playlists matching the filter without collaborators and songs, the most recently
+	// updated first
+	GetPlaylists(ctx context.Context, filter models.PlaylistFilter) ([]models.Playlist, error)
+	// EditPlaylist updates the name, the description, the visibility and the smart playlist policy of the playlist
+	EditPlaylist(ctx context.Context, playlist models.Playlist) error
+	DeletePlaylist(ctx context.Context, playlistID string) error
+	// AddPlaylistCollaborator lets the user edit the songs of the playlist, adding a collaborator again changes
+	// nothing
+	AddPlaylistCollaborator(ctx context.Context, playlistID string, userID string) error
+	RemovePlaylistCollaborator(ctx context.Context, playlistID string, userID string) error
+
+	// GetPlaylistSongs returns the entries of the playlist in the order of positions
+	GetPlaylistSongs(ctx context.Context, playlistID string) ([]models.PlaylistSong, error)
+	// AddPlaylistSong adds the song of the entry at its position, songs in the trash are not found and a taken
+	// position is a Conflict error. Changes of the entries update the playlist
+	AddPlaylistSong(ctx context.Context, playlistID string, entry models.PlaylistSong) error
+	// MovePlaylistSong gives the entry a new position, a taken position is a Conflict error
+	MovePlaylistSong(ctx context.Context, playlistID string, entryID string, position string) error
+	RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error
+
+	// SetFavorite adds the song to the favorites of the user or removes it from them, songs in the trash can not
+	// be added. Adding a favorite again changes nothing
+	SetFavorite(ctx context.Context, userID string, songID string, favorite bool) error
+	// GetFavorites returns the favorites of the user, the most recent first. Songs in the trash are left out
+	GetFavorites(ctx context.Context, userID string, lim, off int) ([]models.Favorite, error)
+	// RateSong sets the rating of the song by the user, the zero rating removes it. Songs in the trash can not
+	// be rated
+	RateSong(ctx context.Context, userID string, songID string, rating int) error
+	// GetRatings returns the ratings of the user, the most recent first. Songs in the trash are left out
+	GetRatings(ctx context.Context, userID string, lim, off int) ([]models.Rating, error)
+	// AddPlay records the play of the song by the user, songs in the trash are not found
+	AddPlay(ctx context.Context, userID string, play models.Play) error
+	// GetPlays returns the listening history of the user, the most recent plays first. Plays of songs in the trash
+	// are left out
+	GetPlays(ctx context.Context, userID string, lim, off int) ([]models.Play, error)
+
+	// StreamInteractions calls fn for every user and song with plays or a favorite ordered by users, the latest
+	// songs of a user first. Songs in the trash are left out
+	StreamInteractions(ctx context.Context, fn func(interaction models.Interaction) error) error
+	// ReplaceSongNeighbors replaces all song neighbors with the given ones, the neighbors of purged songs are
+	// removed with them
+	ReplaceSongNeighbors(ctx context.Context, neighbors []models.SongNeighbor) error
+	// GetRecommendations returns the songs the user neither played nor favorited, scored by the sum of the
+	// neighbor scores of the songs the user did. The songs with no score follow by plays
+	GetRecommendations(ctx context.Context, userID string, lim int) ([]models.Recommendation, error)
 }
@@ -0,0 +1,271 @@
+package repotest
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+const (
+	playID1 = "60000000-0000-0000-0000-000000000001"
+	playID2 = "60000000-0000-0000-0000-000000000002"
+	playID3 = "60000000-0000-0000-0000-000000000003"
+)
+
+func (suite *Suite) TestFavorites() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2, songID3)
+
+	// favorites are added once
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID1, true))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID1, true))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID2, true))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID1, true))
+
+	suite.requireCode(utils.NotFound, suite.repo.SetFavorite(suite.ctx, userID1, missingID, true))
+	suite.requireCode(utils.NotFound, suite.repo.SetFavorite(suite.ctx, userID1, "missing", true))
+	suite.requireCode(utils.NotFound, suite.repo.SetFavorite(suite.ctx, missingID, songID1, true))
+
+	favorites, err := suite.repo.GetFavorites(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{songID1, songID2}, favoriteSongIDs(favorites))
+	suite.Require().False(favorites[0].FavoritedAt.IsZero())
+	suite.Require().Nil(favorites[0].Song.Stats)
+
+	favorites, err = suite.repo.GetFavorites(suite.ctx, userID1, 1, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(favorites, 1)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(2, song.Stats.Favorites)
+
+	// removing a favorite twice or one that was never added changes nothing
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID1, false))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID1, false))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID3, false))
+
+	song, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(1, song.Stats.Favorites)
+
+	// songs in the trash are left out till they are restored and can not be added
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+	suite.requireCode(utils.NotFound, suite.repo.SetFavorite(suite.ctx, userID2, songID2, true))
+
+	favorites, err = suite.repo.GetFavorites(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1}, favoriteSongIDs(favorites))
+
+	suite.Require().NoError(suite.repo.RestoreDeletedSong(suite.ctx, songID2, 0))
+
+	favorites, err = suite.repo.GetFavorites(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{songID1, songID2}, favoriteSongIDs(favorites))
+
+	favorites, err = suite.repo.GetFavorites(suite.ctx, "missing", 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Empty(favorites)
+}
+
+func (suite *Suite) TestRatings() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(models.SongStats{}, *song.Stats)
+
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID1, 5))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID2, songID1, 2))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID2, 3))
+
+	song, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(2, song.Stats.Ratings)
+	suite.Require().InDelta(3.5, song.Stats.AverageRating, 1e-9)
+
+	// rating again replaces the rating
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID2, songID1, 4))
+
+	song, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(2, song.Stats.Ratings)
+	suite.Require().InDelta(4.5, song.Stats.AverageRating, 1e-9)
+
+	ratings, err := suite.repo.GetRatings(suite.ctx, userID2, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(ratings, 1)
+	suite.Require().Equal(songID1, ratings[0].Song.SongID)
+	suite.Require().Equal(4, ratings[0].Rating)
+	suite.Require().False(ratings[0].RatedAt.IsZero())
+
+	ratings, err = suite.repo.GetRatings(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(ratings, 2)
+
+	suite.requireCode(utils.Internal, suite.repo.RateSong(suite.ctx, userID1, songID1, 6))
+	suite.requireCode(utils.NotFound, suite.repo.RateSong(suite.ctx, userID1, missingID, 3))
+	suite.requireCode(utils.NotFound, suite.repo.RateSong(suite.ctx, missingID, songID1, 3))
+
+	// the zero rating removes the rating, removing it again changes nothing
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID1, 0))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID1, 0))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, missingID, 0))
+
+	song, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(1, song.Stats.Ratings)
+	suite.Require().InDelta(4, song.Stats.AverageRating, 1e-9)
+
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID2, songID1, 0))
+
+	song, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Zero(song.Stats.Ratings)
+	suite.Require().Zero(song.Stats.AverageRating)
+
+	// songs in the trash can not be rated
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+	suite.requireCode(utils.NotFound, suite.repo.RateSong(suite.ctx, userID1, songID2, 1))
+
+	ratings, err = suite.repo.GetRatings(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Empty(ratings)
+}
+
+func (suite *Suite) TestPlays() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2)
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	plays := []struct {
+		userID string
+		play   models.Play
+	}{
+		{userID1, models.Play{PlayID: playID1, Song: models.Song{SongID: songID1}, PlayedAt: start, Duration: 180}},
+		{userID1, models.Play{PlayID: playID2, Song: models.Song{SongID: songID2}, PlayedAt: start.Add(time.Hour), Duration: 30}},
+		{userID2, models.Play{PlayID: playID3, Song: models.Song{SongID: songID1}, PlayedAt: start, Duration: 200}},
+	}
+	for _, p := range plays {
+		suite.Require().NoError(suite.repo.AddPlay(suite.ctx, p.userID, p.play))
+	}
+
+	suite.requireCode(utils.NotFound, suite.repo.AddPlay(suite.ctx, userID1, models.Play{
+		PlayID: missingID, Song: models.Song{SongID: missingID}, PlayedAt: start,
+	}))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlay(suite.ctx, missingID, models.Play{
+		PlayID: missingID, Song: models.Song{SongID: songID1}, PlayedAt: start,
+	}))
+
+	// the most recent plays go first
+	history, err := suite.repo.GetPlays(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 2)
+	suite.Require().Equal(playID2, history[0].PlayID)
+	suite.Require().Equal(songID2, history[0].Song.SongID)
+	suite.Require().Equal("song2", history[0].Song.Song)
+	suite.Require().True(start.Add(time.Hour).Equal(history[0].PlayedAt))
+	suite.Require().Equal(30, history[0].Duration)
+	suite.Require().Equal(playID1, history[1].PlayID)
+
+	history, err = suite.repo.GetPlays(suite.ctx, userID1, 1, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(history, 1)
+	suite.Require().Equal(playID1, history[0].PlayID)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(2, song.Stats.Plays)
+
+	// plays of songs in the trash are left out, purged songs take their plays with them
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlay(suite.ctx, userID1, models.Play{
+		PlayID: missingID, Song: models.Song{SongID: songID1}, PlayedAt: start,
+	}))
+
+	history, err = suite.repo.GetPlays(suite.ctx, userID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{playID2}, playIDs(history))
+
+	_, err = suite.repo.PurgeDeletedSongs(suite.ctx, -time.Minute)
+	suite.Require().NoError(err)
+
+	history, err = suite.repo.GetPlays(suite.ctx, userID2, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Empty(history)
+}
+
+func (suite *Suite) TestGetSongsPopularity() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2, songID3)
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	for i, songID := range []string{songID2, songID2, songID3} {
+		suite.Require().NoError(suite.repo.AddPlay(suite.ctx, userID1, models.Play{
+			PlayID: []string{playID1, playID2, playID3}[i], Song: models.Song{SongID: songID}, PlayedAt: start,
+		}))
+	}
+
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID1, 4))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID2, songID1, 4))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID3, 4))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID2, 2))
+
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID3, true))
+
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Sort: models.SortPlays})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID2, songID3, songID1}, songIDs(songs))
+	suite.Require().EqualValues(2, songs[0].Stats.Plays)
+	suite.Require().InDelta(2, songs[0].Stats.AverageRating, 1e-9)
+
+	// equal average ratings are ordered by the number of ratings
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Sort: models.SortRating})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1, songID3, songID2}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 1, Off: 1, Sort: models.SortFavorites})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, MinPlays: 1, Sort: models.SortPlays})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID2, songID3}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, MinRating: 3.5, Sort: models.SortPlays})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID3, songID1}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, MinPlays: 1, MinRating: 3})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID3}, songIDs(songs))
+
+	_, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Sort: "loudness"})
+	suite.requireCode(utils.Internal, err)
+
+	// the stats are not part of the song versions
+	song, err := suite.repo.GetSong(suite.ctx, songID3)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.repo.EditSong(suite.ctx, song))
+
+	revision, err := suite.repo.GetSongRevision(suite.ctx, songID3, 2)
+	suite.Require().NoError(err)
+	suite.Require().Nil(revision.After.Stats)
+}
+
+func favoriteSongIDs(favorites []models.Favorite) []string {
+	ids := make([]string, len(favorites))
+	for i, favorite := range favorites {
+		ids[i] = favorite.Song.SongID
+	}
+	return ids
+}
+
+func playIDs(plays []models.Play) []string {
+	ids := make([]string, len(plays))
+	for i, play := range plays {
+		ids[i] = play.PlayID
+	}
+	return ids
+}
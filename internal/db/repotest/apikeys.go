@@ -0,0 +1,94 @@
+package repotest
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+const (
+	keyID1 = "30000000-0000-0000-0000-000000000001"
+	keyID2 = "30000000-0000-0000-0000-000000000002"
+	keyID3 = "30000000-0000-0000-0000-000000000003"
+)
+
+func (suite *Suite) TestAPIKeys() {
+	suite.createUsers(userID1, userID2)
+
+	key := models.APIKey{
+		KeyID:   keyID1,
+		OwnerID: userID1,
+		Name:    "importer",
+		Prefix:  "mlk_abcdef",
+		Scopes:  []string{"read", "write"},
+	}
+	suite.Require().NoError(suite.repo.CreateAPIKey(suite.ctx, key, "hash1"))
+	suite.Require().NoError(suite.repo.CreateAPIKey(suite.ctx, models.APIKey{
+		KeyID: keyID2, OwnerID: userID1, Name: "exporter", Prefix: "mlk_ghijkl", Scopes: []string{"export"},
+	}, "hash2"))
+	suite.Require().NoError(suite.repo.CreateAPIKey(suite.ctx, models.APIKey{
+		KeyID: keyID3, OwnerID: userID2, Name: "other", Prefix: "mlk_mnopqr", Scopes: []string{"admin"},
+	}, "hash3"))
+
+	res, err := suite.repo.GetAPIKey(suite.ctx, keyID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(key.Name, res.Name)
+	suite.Require().Equal(key.Prefix, res.Prefix)
+	suite.Require().Equal(key.Scopes, res.Scopes)
+	suite.Require().Equal(userID1, res.OwnerID)
+	suite.Require().False(res.CreatedAt.IsZero())
+	suite.Require().Nil(res.LastUsedAt)
+	suite.Require().Nil(res.RevokedAt)
+
+	keys, err := suite.repo.GetAPIKeys(suite.ctx, userID1)
+	suite.Require().NoError(err)
+	suite.Require().Len(keys, 2)
+	for _, k := range keys {
+		suite.Require().Equal(userID1, k.OwnerID)
+	}
+
+	// the use of a key is recorded
+	used, err := suite.repo.UseAPIKey(suite.ctx, "hash1")
+	suite.Require().NoError(err)
+	suite.Require().Equal(keyID1, used.KeyID)
+	suite.Require().NotNil(used.LastUsedAt)
+
+	res, err = suite.repo.GetAPIKey(suite.ctx, keyID1)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(res.LastUsedAt)
+	suite.Require().Equal(*used.LastUsedAt, *res.LastUsedAt)
+
+	_, err = suite.repo.UseAPIKey(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	// revoked keys are listed but can not be used
+	suite.Require().NoError(suite.repo.RevokeAPIKey(suite.ctx, keyID1))
+	_, err = suite.repo.UseAPIKey(suite.ctx, "hash1")
+	suite.requireCode(utils.NotFound, err)
+
+	revoked, err := suite.repo.GetAPIKey(suite.ctx, keyID1)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(revoked.RevokedAt)
+
+	suite.Require().NoError(suite.repo.RevokeAPIKey(suite.ctx, keyID1))
+	again, err := suite.repo.GetAPIKey(suite.ctx, keyID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(*revoked.RevokedAt, *again.RevokedAt)
+
+	// keys of other owners stay active
+	_, err = suite.repo.UseAPIKey(suite.ctx, "hash3")
+	suite.Require().NoError(err)
+
+	suite.requireCode(utils.NotFound, suite.repo.RevokeAPIKey(suite.ctx, missingID))
+	suite.requireCode(utils.NotFound, suite.repo.RevokeAPIKey(suite.ctx, "missing"))
+
+	_, err = suite.repo.GetAPIKey(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	keys, err = suite.repo.GetAPIKeys(suite.ctx, missingID)
+	suite.Require().NoError(err)
+	suite.Require().Empty(keys)
+
+	suite.requireCode(utils.NotFound, suite.repo.CreateAPIKey(suite.ctx, models.APIKey{
+		KeyID: missingID, OwnerID: missingID, Name: "orphan", Prefix: "mlk_stuvwx", Scopes: []string{"read"},
+	}, "hash4"))
+}
@@ -0,0 +1,228 @@
+package repotest
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+const (
+	playlistID1 = "40000000-0000-0000-0000-000000000001"
+	playlistID2 = "40000000-0000-0000-0000-000000000002"
+	playlistID3 = "40000000-0000-0000-0000-000000000003"
+
+	entryID1 = "50000000-0000-0000-0000-000000000001"
+	entryID2 = "50000000-0000-0000-0000-000000000002"
+	entryID3 = "50000000-0000-0000-0000-000000000003"
+)
+
+func (suite *Suite) TestPlaylists() {
+	suite.createUsers(userID1, userID2)
+
+	playlist := models.Playlist{
+		PlaylistID:  playlistID1,
+		OwnerID:     userID1,
+		Name:        "road trip",
+		Description: "songs for the road",
+		Visibility:  models.PlaylistPrivate,
+	}
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, playlist))
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID2, OwnerID: userID2, Name: "public", Visibility: models.PlaylistPublic,
+	}))
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID3, OwnerID: userID2, Name: "unlisted", Visibility: models.PlaylistUnlisted,
+	}))
+	suite.requireCode(utils.NotFound, suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: missingID, OwnerID: missingID, Name: "orphan", Visibility: models.PlaylistPrivate,
+	}))
+
+	res, err := suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(playlist.Name, res.Name)
+	suite.Require().Equal(playlist.Description, res.Description)
+	suite.Require().Equal(playlist.Visibility, res.Visibility)
+	suite.Require().Equal(userID1, res.OwnerID)
+	suite.Require().Empty(res.Collaborators)
+	suite.Require().False(res.CreatedAt.IsZero())
+
+	_, err = suite.repo.GetPlaylist(suite.ctx, missingID)
+	suite.requireCode(utils.NotFound, err)
+	_, err = suite.repo.GetPlaylist(suite.ctx, "not a uuid")
+	suite.requireCode(utils.NotFound, err)
+
+	// collaborators are added once
+	suite.Require().NoError(suite.repo.AddPlaylistCollaborator(suite.ctx, playlistID1, userID2))
+	suite.Require().NoError(suite.repo.AddPlaylistCollaborator(suite.ctx, playlistID1, userID2))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlaylistCollaborator(suite.ctx, playlistID1, missingID))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlaylistCollaborator(suite.ctx, missingID, userID2))
+
+	res, err = suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Len(res.Collaborators, 1)
+	suite.Require().Equal(userID2, res.Collaborators[0].UserID)
+	suite.Require().Equal("user2", res.Collaborators[0].Name)
+
+	// members see the playlists they own or collaborate on, everyone sees the public ones
+	playlists, err := suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{MemberID: userID2, Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{playlistID1, playlistID2, playlistID3}, playlistIDs(playlists))
+
+	playlists, err = suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{MemberID: userID1, Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{playlistID1}, playlistIDs(playlists))
+
+	playlists, err = suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{playlistID2}, playlistIDs(playlists))
+
+	playlists, err = suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{MemberID: userID2, Lim: 2, Off: 2})
+	suite.Require().NoError(err)
+	suite.Require().Len(playlists, 1)
+
+	suite.Require().NoError(suite.repo.RemovePlaylistCollaborator(suite.ctx, playlistID1, userID2))
+	suite.requireCode(utils.NotFound, suite.repo.RemovePlaylistCollaborator(suite.ctx, playlistID1, userID2))
+
+	playlists, err = suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{MemberID: userID2, Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{playlistID2, playlistID3}, playlistIDs(playlists))
+
+	playlist.Name, playlist.Visibility = "renamed", models.PlaylistPublic
+	suite.Require().NoError(suite.repo.EditPlaylist(suite.ctx, playlist))
+	suite.requireCode(utils.NotFound, suite.repo.EditPlaylist(suite.ctx, models.Playlist{PlaylistID: missingID, Name: "x",
+		Visibility: models.PlaylistPrivate}))
+
+	res, err = suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal("renamed", res.Name)
+	suite.Require().Equal(models.PlaylistPublic, res.Visibility)
+
+	suite.Require().NoError(suite.repo.DeletePlaylist(suite.ctx, playlistID1))
+	suite.requireCode(utils.NotFound, suite.repo.DeletePlaylist(suite.ctx, playlistID1))
+
+	_, err = suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestPlaylistSongs() {
+	suite.createUsers(userID1)
+	suite.createSongs(songID1, songID2)
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID1, OwnerID: userID1, Name: "mix", Visibility: models.PlaylistPrivate,
+	}))
+
+	suite.Require().NoError(suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: entryID1, Position: "a0", Song: models.Song{SongID: songID1}, AddedBy: userID1,
+	}))
+	suite.Require().NoError(suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: entryID2, Position: "a1", Song: models.Song{SongID: songID2},
+	}))
+	// the same song can be added again
+	suite.Require().NoError(suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: entryID3, Position: "Zz", Song: models.Song{SongID: songID1},
+	}))
+
+	suite.requireCode(utils.Conflict, suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: missingID, Position: "a1", Song: models.Song{SongID: songID1},
+	}))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: missingID, Position: "a2", Song: models.Song{SongID: missingID},
+	}))
+	suite.requireCode(utils.NotFound, suite.repo.AddPlaylistSong(suite.ctx, missingID, models.PlaylistSong{
+		EntryID: missingID, Position: "a2", Song: models.Song{SongID: songID1},
+	}))
+
+	entries, err := suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{entryID3, entryID1, entryID2}, entryIDs(entries))
+	suite.Require().Equal(songID1, entries[1].Song.SongID)
+	suite.Require().Equal("song1", entries[1].Song.Song)
+	suite.Require().Equal("group1", entries[1].Song.Group)
+	suite.Require().Equal(userID1, entries[1].AddedBy)
+	suite.Require().Empty(entries[2].AddedBy)
+
+	// positions are compared byte by byte, a1V is after a1
+	suite.Require().NoError(suite.repo.MovePlaylistSong(suite.ctx, playlistID1, entryID3, "a1V"))
+	suite.requireCode(utils.Conflict, suite.repo.MovePlaylistSong(suite.ctx, playlistID1, entryID1, "a1"))
+	suite.requireCode(utils.NotFound, suite.repo.MovePlaylistSong(suite.ctx, playlistID1, missingID, "a5"))
+
+	entries, err = suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{entryID1, entryID2, entryID3}, entryIDs(entries))
+
+	suite.Require().NoError(suite.repo.RemovePlaylistSong(suite.ctx, playlistID1, entryID2))
+	suite.requireCode(utils.NotFound, suite.repo.RemovePlaylistSong(suite.ctx, playlistID1, entryID2))
+
+	entries, err = suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{entryID1, entryID3}, entryIDs(entries))
+
+	_, err = suite.repo.GetPlaylistSongs(suite.ctx, missingID)
+	suite.requireCode(utils.NotFound, err)
+
+	// the entries are removed with the playlist
+	suite.Require().NoError(suite.repo.DeletePlaylist(suite.ctx, playlistID1))
+	_, err = suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestPlaylistSongsOfDeletedSongs() {
+	suite.createUsers(userID1)
+	suite.createSongs(songID1, songID2, songID3)
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID1, OwnerID: userID1, Name: "mix", Visibility: models.PlaylistPrivate,
+	}))
+
+	for i, songID := range []string{songID1, songID2, songID1} {
+		suite.Require().NoError(suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+			EntryID: []string{entryID1, entryID2, entryID3}[i], Position: []string{"a0", "a1", "a2"}[i],
+			Song: models.Song{SongID: songID},
+		}))
+	}
+
+	before, err := suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+
+	// deleted songs leave the playlists and are not brought back by restoring them
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+
+	entries, err := suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{entryID2}, entryIDs(entries))
+
+	after, err := suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().False(after.UpdatedAt.Before(before.UpdatedAt))
+
+	suite.Require().NoError(suite.repo.RestoreDeletedSong(suite.ctx, songID1, 0))
+
+	entries, err = suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{entryID2}, entryIDs(entries))
+
+	// songs in the trash can not be added
+	_, err = suite.repo.DeleteSongs(suite.ctx, []models.SongVersion{{SongID: songID2}, {SongID: songID3}}, true)
+	suite.Require().NoError(err)
+	suite.requireCode(utils.NotFound, suite.repo.AddPlaylistSong(suite.ctx, playlistID1, models.PlaylistSong{
+		EntryID: entryID1, Position: "a5", Song: models.Song{SongID: songID3},
+	}))
+
+	entries, err = suite.repo.GetPlaylistSongs(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Empty(entries)
+}
+
+func playlistIDs(playlists []models.Playlist) []string {
+	ids := make([]string, len(playlists))
+	for i, playlist := range playlists {
+		ids[i] = playlist.PlaylistID
+	}
+	return ids
+}
+
+func entryIDs(entries []models.PlaylistSong) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.EntryID
+	}
+	return ids
+}
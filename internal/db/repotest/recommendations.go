@@ -0,0 +1,137 @@
+package repotest
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"time"
+)
+
+const playID4 = "60000000-0000-0000-0000-000000000004"
+
+func (suite *Suite) TestStreamInteractions() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2, songID3)
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	plays := []struct {
+		userID string
+		play   models.Play
+	}{
+		{userID1, models.Play{PlayID: playID1, Song: models.Song{SongID: songID1}, PlayedAt: start}},
+		{userID1, models.Play{PlayID: playID2, Song: models.Song{SongID: songID1}, PlayedAt: start.Add(time.Hour)}},
+		{userID1, models.Play{PlayID: playID3, Song: models.Song{SongID: songID2}, PlayedAt: start.Add(2 * time.Hour)}},
+		{userID2, models.Play{PlayID: playID4, Song: models.Song{SongID: songID3}, PlayedAt: start}},
+	}
+	for _, p := range plays {
+		suite.Require().NoError(suite.repo.AddPlay(suite.ctx, p.userID, p.play))
+	}
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID1, true))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID2, true))
+
+	// songs in the trash are left out
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID3, 0))
+
+	var interactions []models.Interaction
+	suite.Require().NoError(suite.repo.StreamInteractions(suite.ctx, func(interaction models.Interaction) error {
+		interactions = append(interactions, interaction)
+		return nil
+	}))
+	suite.Require().Len(interactions, 3)
+
+	// the favorite is the latest interaction with the first song
+	suite.Require().Equal(userID1, interactions[0].UserID)
+	suite.Require().Equal(songID1, interactions[0].SongID)
+	suite.Require().Equal(int64(2), interactions[0].Plays)
+	suite.Require().True(interactions[0].Favorite)
+	suite.Require().True(interactions[0].LastAt.After(start.Add(time.Hour)))
+
+	suite.Require().Equal(models.Interaction{
+		UserID: userID1, SongID: songID2, Plays: 1, LastAt: start.Add(2 * time.Hour),
+	}, interactions[1])
+
+	suite.Require().Equal(userID2, interactions[2].UserID)
+	suite.Require().Equal(songID2, interactions[2].SongID)
+	suite.Require().Zero(interactions[2].Plays)
+	suite.Require().True(interactions[2].Favorite)
+}
+
+func (suite *Suite) TestRecommendations() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2, songID3)
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	suite.Require().NoError(suite.repo.AddPlay(suite.ctx, userID1, models.Play{
+		PlayID: playID1, Song: models.Song{SongID: songID1}, PlayedAt: start,
+	}))
+	suite.Require().NoError(suite.repo.AddPlay(suite.ctx, userID2, models.Play{
+		PlayID: playID2, Song: models.Song{SongID: songID3}, PlayedAt: start,
+	}))
+
+	recommended := func(userID string, lim int) ([]string, []float64) {
+		recommendations, err := suite.repo.GetRecommendations(suite.ctx, userID, lim)
+		suite.Require().NoError(err)
+
+		ids, scores := make([]string, 0), make([]float64, 0)
+		for _, recommendation := range recommendations {
+			suite.Require().NotNil(recommendation.Song.Stats)
+			ids = append(ids, recommendation.Song.SongID)
+			scores = append(scores, recommendation.Score)
+		}
+		return ids, scores
+	}
+
+	// without neighbors the songs not heard yet follow by plays
+	ids, scores := recommended(userID1, 10)
+	suite.Require().Equal([]string{songID3, songID2}, ids)
+	suite.Require().Equal([]float64{0, 0}, scores)
+
+	// users without history get the whole library
+	for _, userID := range []string{missingID, "invalid"} {
+		ids, _ = recommended(userID, 10)
+		suite.Require().Equal([]string{songID1, songID3, songID2}, ids)
+	}
+
+	// the neighbors of purged songs are skipped
+	suite.Require().NoError(suite.repo.ReplaceSongNeighbors(suite.ctx, []models.SongNeighbor{
+		{SongID: songID1, NeighborID: songID2, Score: 0.5},
+		{SongID: songID2, NeighborID: songID1, Score: 0.5},
+		{SongID: songID1, NeighborID: songID3, Score: 0.25},
+		{SongID: songID1, NeighborID: missingID, Score: 0.75},
+	}))
+
+	ids, scores = recommended(userID1, 10)
+	suite.Require().Equal([]string{songID2, songID3}, ids)
+	suite.Require().Equal([]float64{0.5, 0.25}, scores)
+
+	ids, _ = recommended(userID1, 1)
+	suite.Require().Equal([]string{songID2}, ids)
+
+	// the neighbors are replaced as a whole
+	suite.Require().NoError(suite.repo.ReplaceSongNeighbors(suite.ctx, []models.SongNeighbor{
+		{SongID: songID1, NeighborID: songID3, Score: 0.75},
+		{SongID: songID1, NeighborID: songID2, Score: 0.25},
+		{SongID: songID3, NeighborID: songID2, Score: 0.5},
+	}))
+
+	ids, scores = recommended(userID1, 10)
+	suite.Require().Equal([]string{songID3, songID2}, ids)
+	suite.Require().Equal([]float64{0.75, 0.25}, scores)
+
+	// favorites count as heard and the scores of the heard songs add up
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID3, true))
+
+	ids, scores = recommended(userID1, 10)
+	suite.Require().Equal([]string{songID2}, ids)
+	suite.Require().Equal([]float64{0.75}, scores)
+
+	// songs in the trash are not recommended
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	ids, _ = recommended(userID1, 10)
+	suite.Require().Empty(ids)
+
+	_, err := suite.repo.PurgeDeletedSongs(suite.ctx, -time.Minute)
+	suite.Require().NoError(err)
+
+	ids, _ = recommended(userID2, 10)
+	suite.Require().Equal([]string{songID1}, ids)
+}
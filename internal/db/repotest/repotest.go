@@ -0,0 +1,672 @@
+// Package repotest is the conformance suite of db.Repository implementations. Every implementation runs it
+// from its own tests, so they keep the same filtering, pagination and error semantics
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/mocks"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"sync"
+	"time"
+)
+
+// song IDs are UUIDs in the postgres schema
+const (
+	songID1 = "00000000-0000-0000-0000-000000000001"
+	songID2 = "00000000-0000-0000-0000-000000000002"
+	songID3 = "00000000-0000-0000-0000-000000000003"
+	// missingID is a valid ID no song has
+	missingID = "00000000-0000-0000-0000-0000000000ff"
+)
+
+// Suite runs the same tests against any repository, NewRepository must return an empty repository for every test
+// and a func releasing it
+type Suite struct {
+	suite.Suite
+
+	NewRepository func(s *suite.Suite) (db.Repository, func())
+
+	ctrl   *gomock.Controller
+	logger *mocks.MockLogger
+
+	repo db.Repository
+	ctx  context.Context
+}
+
+func (suite *Suite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.logger = mocks.NewMockLogger(suite.ctrl)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	suite.ctx = logger.WrapLogger(context.Background(), suite.logger)
+	suite.ctx = logger.WrapIdentifier(suite.ctx)
+	suite.ctx = actor.WrapActor(suite.ctx, "author")
+
+	repo, release := suite.NewRepository(&suite.Suite)
+	suite.T().Cleanup(release)
+	suite.repo = repo
+}
+
+func (suite *Suite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func (suite *Suite) TestCreateSong() {
+	song := models.Song{
+		SongID: songID1,
+		Song:   "song1",
+		Group:  "group1",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC),
+			Text:        "song text 1\n\nsong text 2",
+			Link:        "link1",
+		},
+	}
+
+	suite.Require().NoError(suite.repo.CreateSong(suite.ctx, song))
+
+	res, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(song.SongID, res.SongID)
+	suite.Require().Equal(song.Group, res.Group)
+	suite.Require().Equal(song.Song, res.Song)
+	suite.Require().True(song.Data.ReleaseDate.Equal(res.Data.ReleaseDate))
+	suite.Require().Equal(song.Data.Text, res.Data.Text)
+	suite.Require().Equal(song.Data.Link, res.Data.Link)
+	suite.Require().Equal(1, res.Version)
+	suite.Require().False(res.UpdatedAt.IsZero())
+	suite.Require().Nil(res.DeletedAt)
+
+	// the ID is taken even by songs in the trash
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+	suite.requireCode(utils.Internal, suite.repo.CreateSong(suite.ctx, song))
+
+	song.SongID = "not a uuid"
+	suite.requireCode(utils.Internal, suite.repo.CreateSong(suite.ctx, song))
+}
+
+func (suite *Suite) TestGetSong() {
+	suite.createSongs(songID1)
+
+	_, err := suite.repo.GetSong(suite.ctx, missingID)
+	suite.requireCode(utils.NotFound, err)
+
+	_, err = suite.repo.GetSong(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+
+	_, err = suite.repo.GetSong(suite.ctx, songID1)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestGetSongsByID() {
+	suite.createSongs(songID1, songID2, songID3)
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID3, 0))
+
+	songs, err := suite.repo.GetSongsByID(suite.ctx, []string{songID2, songID1, songID3, missingID, "missing"})
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{songID1, songID2}, songIDs(songs))
+
+	song, err := suite.repo.GetSong(suite.ctx, songID2)
+	suite.Require().NoError(err)
+	for _, found := range songs {
+		if found.SongID == songID2 {
+			suite.Require().Equal(song, found)
+		}
+	}
+
+	songs, err = suite.repo.GetSongsByID(suite.ctx, nil)
+	suite.Require().NoError(err)
+	suite.Require().Empty(songs)
+}
+
+func (suite *Suite) TestGetSongText() {
+	suite.createSongs(songID1, songID2)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+
+	text, updatedAt, err := suite.repo.GetSongText(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(song.Data.Text, text)
+	suite.Require().True(song.UpdatedAt.Equal(updatedAt))
+
+	_, _, err = suite.repo.GetSongText(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	_, _, err = suite.repo.GetSongText(suite.ctx, songID2)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestGetSongsFilter() {
+	releaseDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	suite.Require().NoError(suite.repo.CreateSongs(suite.ctx, []models.Song{
+		{SongID: songID1, Group: "Muse", Song: "Uprising", Data: models.SongData{Text: "paranoia is in bloom", Link: "link1", ReleaseDate: releaseDate}},
+		{SongID: songID2, Group: "Muse", Song: "Madness", Data: models.SongData{Text: "I can't get it", Link: "link2"}},
+		{SongID: songID3, Group: "100% Band", Song: "Hit_Song", Data: models.SongData{Text: "la la la", Link: "link3"}},
+	}))
+
+	tests := []struct {
+		name   string
+		filter models.SongFilter
+		ids    []string
+	}{
+		{name: "no filter", filter: models.SongFilter{}, ids: []string{songID1, songID2, songID3}},
+		{name: "song ID", filter: models.SongFilter{SongID: songID2}, ids: []string{songID2}},
+		{name: "invalid song ID", filter: models.SongFilter{SongID: "missing"}, ids: []string{}},
+		{name: "group substring", filter: models.SongFilter{Group: "us"}, ids: []string{songID1, songID2}},
+		{name: "group is case sensitive", filter: models.SongFilter{Group: "muse"}, ids: []string{}},
+		{name: "song substring", filter: models.SongFilter{Song: "ing"}, ids: []string{songID1}},
+		{name: "text substring", filter: models.SongFilter{Text: "la la"}, ids: []string{songID3}},
+		{name: "link is exact", filter: models.SongFilter{Link: "link"}, ids: []string{}},
+		{name: "link", filter: models.SongFilter{Link: "link2"}, ids: []string{songID2}},
+		{name: "release date", filter: models.SongFilter{ReleaseDate: &releaseDate}, ids: []string{songID1}},
+		{name: "like wildcards", filter: models.SongFilter{Song: "M_d%ss"}, ids: []string{songID2}},
+		{name: "escaped wildcard", filter: models.SongFilter{Group: `100\%`}, ids: []string{songID3}},
+		{name: "escaped underscore", filter: models.SongFilter{Song: `t\_S`}, ids: []string{songID3}},
+		{name: "several fields", filter: models.SongFilter{Group: "Muse", Text: "bloom"}, ids: []string{songID1}},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			tc.filter.Lim = 10
+
+			songs, err := suite.repo.GetSongs(suite.ctx, tc.filter)
+			suite.Require().NoError(err)
+			suite.Require().NotNil(songs)
+			suite.Require().ElementsMatch(tc.ids, songIDs(songs))
+		})
+	}
+}
+
+func (suite *Suite) TestGetSongsFilterWildcards() {
+	suite.Require().NoError(suite.repo.CreateSongs(suite.ctx, []models.Song{
+		{SongID: songID1, Group: strings.Repeat("a", 200), Song: "song", Data: models.SongData{Text: strings.Repeat("ab", 2000)}},
+	}))
+
+	// patterns of many wildcards take as long as the others
+	tests := []struct {
+		name   string
+		filter models.SongFilter
+		ids    []string
+	}{
+		{name: "percents", filter: models.SongFilter{Group: strings.Repeat("%", 30) + "x"}, ids: []string{}},
+		{name: "percents and letters", filter: models.SongFilter{Group: strings.Repeat("%a", 30) + "x"}, ids: []string{}},
+		{name: "percents and underscores", filter: models.SongFilter{Text: strings.Repeat("%_b", 30) + "c"}, ids: []string{}},
+		{name: "matching", filter: models.SongFilter{Text: strings.Repeat("%_b", 30) + "%a_"}, ids: []string{songID1}},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			tc.filter.Lim = 10
+
+			songs, err := suite.repo.GetSongs(suite.ctx, tc.filter)
+			suite.Require().NoError(err)
+			suite.Require().ElementsMatch(tc.ids, songIDs(songs))
+		})
+	}
+}
+
+func (suite *Suite) TestGetSongsPagination() {
+	suite.createSongs(songID1, songID2, songID3)
+
+	first, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 2})
+	suite.Require().NoError(err)
+	suite.Require().Len(first, 2)
+
+	rest, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 2, Off: 2})
+	suite.Require().NoError(err)
+	suite.Require().Len(rest, 1)
+	suite.Require().ElementsMatch([]string{songID1, songID2, songID3}, append(songIDs(first), songIDs(rest)...))
+
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Off: 3})
+	suite.Require().NoError(err)
+	suite.Require().Empty(songs)
+
+	// unlike the stream, the zero limit selects nothing
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{})
+	suite.Require().NoError(err)
+	suite.Require().Empty(songs)
+
+	_, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: -1})
+	suite.requireCode(utils.Internal, err)
+
+	_, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Off: -1})
+	suite.requireCode(utils.Internal, err)
+}
+
+func (suite *Suite) TestGetSongsTrash() {
+	suite.createSongs(songID1, songID2)
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{SongID: songID2, Lim: 10, IncludeDeleted: true})
+	suite.Require().NoError(err)
+	suite.Require().Len(songs, 1)
+	suite.Require().NotNil(songs[0].DeletedAt)
+	suite.Require().Equal(2, songs[0].Version)
+}
+
+func (suite *Suite) TestEditSong() {
+	suite.createSongs(songID1)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+
+	song.Song = "edited song title"
+	song.Group = "edited group title"
+	song.Data.Text = "edited song text"
+	song.Data.ReleaseDate = time.Date(2024, 2, 1, 1, 1, 1, 0, time.UTC)
+	suite.Require().NoError(suite.repo.EditSong(suite.ctx, song))
+
+	res, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(song.Song, res.Song)
+	suite.Require().Equal(song.Group, res.Group)
+	suite.Require().Equal(song.Data.Text, res.Data.Text)
+	suite.Require().True(song.Data.ReleaseDate.Equal(res.Data.ReleaseDate))
+	suite.Require().Equal(2, res.Version)
+
+	// the song was edited since version 1
+	suite.requireCode(utils.PreconditionFailed, suite.repo.EditSong(suite.ctx, song))
+
+	// the zero version matches any version
+	song.Version = 0
+	suite.Require().NoError(suite.repo.EditSong(suite.ctx, song))
+
+	song.SongID = missingID
+	suite.requireCode(utils.NotFound, suite.repo.EditSong(suite.ctx, song))
+
+	song.SongID = "missing"
+	suite.requireCode(utils.NotFound, suite.repo.EditSong(suite.ctx, song))
+}
+
+func (suite *Suite) TestConcurrentEdits() {
+	suite.createSongs(songID1)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+
+	const editors = 10
+
+	var wg sync.WaitGroup
+	errs := make([]error, editors)
+	for i := range editors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			edited := song
+			edited.Song = fmt.Sprintf("edited by %d", i)
+			errs[i] = suite.repo.EditSong(suite.ctx, edited)
+		}()
+	}
+	wg.Wait()
+
+	// every editor expects version 1, so only the first edit is applied
+	var applied int
+	for _, err = range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		suite.requireCode(utils.PreconditionFailed, err)
+	}
+	suite.Require().Equal(1, applied)
+
+	revisions, err := suite.repo.GetSongRevisions(suite.ctx, songID1, 100, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 2)
+}
+
+func (suite *Suite) TestDeleteSong() {
+	suite.createSongs(songID1)
+
+	suite.requireCode(utils.PreconditionFailed, suite.repo.DeleteSong(suite.ctx, songID1, 2))
+	suite.requireCode(utils.NotFound, suite.repo.DeleteSong(suite.ctx, missingID, 0))
+
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 1))
+
+	// songs in the trash can not be changed or deleted again
+	suite.requireCode(utils.NotFound, suite.repo.DeleteSong(suite.ctx, songID1, 0))
+	suite.requireCode(utils.NotFound, suite.repo.EditSong(suite.ctx, models.Song{SongID: songID1, Song: "song", Group: "group"}))
+
+	revision, err := suite.repo.GetSongRevision(suite.ctx, songID1, 2)
+	suite.Require().NoError(err)
+	suite.Require().Equal(models.RevisionDelete, revision.Action)
+	suite.Require().Equal(1, revision.Before.Version)
+	suite.Require().Nil(revision.After)
+}
+
+func (suite *Suite) TestRestoreDeletedSong() {
+	suite.createSongs(songID1)
+
+	suite.requireCode(utils.NotFound, suite.repo.RestoreDeletedSong(suite.ctx, songID1, 0))
+
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+
+	suite.requireCode(utils.PreconditionFailed, suite.repo.RestoreDeletedSong(suite.ctx, songID1, 1))
+	suite.Require().NoError(suite.repo.RestoreDeletedSong(suite.ctx, songID1, 2))
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, song.Version)
+	suite.Require().Nil(song.DeletedAt)
+
+	revision, err := suite.repo.GetSongRevision(suite.ctx, songID1, 3)
+	suite.Require().NoError(err)
+	suite.Require().Equal(models.RevisionUndelete, revision.Action)
+	suite.Require().Nil(revision.Before)
+	suite.Require().Equal(3, revision.After.Version)
+}
+
+func (suite *Suite) TestRestoreSong() {
+	suite.createSongs(songID1)
+
+	song, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+
+	edited := song
+	edited.Song = "edited song title"
+	suite.Require().NoError(suite.repo.EditSong(suite.ctx, edited))
+
+	// restore to the first revision creates the third one
+	suite.Require().NoError(suite.repo.RestoreSong(suite.ctx, songID1, 1, 2))
+
+	current, err := suite.repo.GetSong(suite.ctx, songID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, current.Version)
+	suite.Require().Equal(song.Song, current.Song)
+
+	revision, err := suite.repo.GetSongRevision(suite.ctx, songID1, 3)
+	suite.Require().NoError(err)
+	suite.Require().Equal(models.RevisionRestore, revision.Action)
+	suite.Require().Equal(1, revision.RestoredFrom)
+	suite.Require().Equal(edited.Song, revision.Before.Song)
+	suite.Require().Equal(song.Song, revision.After.Song)
+
+	suite.requireCode(utils.PreconditionFailed, suite.repo.RestoreSong(suite.ctx, songID1, 1, 2))
+	suite.requireCode(utils.NotFound, suite.repo.RestoreSong(suite.ctx, songID1, 10, 0))
+	suite.requireCode(utils.NotFound, suite.repo.RestoreSong(suite.ctx, missingID, 1, 0))
+
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID1, 0))
+	suite.Require().NoError(suite.repo.RestoreDeletedSong(suite.ctx, songID1, 0))
+
+	suite.requireCode(utils.BadRequest, suite.repo.RestoreSong(suite.ctx, songID1, 4, 0))
+}
+
+func (suite *Suite) TestSongRevisions() {
+	suite.createSongs(songID1)
+
+	for i := range 2 {
+		suite.Require().NoError(suite.repo.EditSong(suite.ctx, models.Song{SongID: songID1, Song: fmt.Sprintf("edit%d", i), Group: "group"}))
+	}
+
+	revisions, err := suite.repo.GetSongRevisions(suite.ctx, songID1, 2, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]int{3, 2}, revisionVersions(revisions))
+	suite.Require().Equal("author", revisions[0].Author)
+	suite.Require().Equal(models.RevisionEdit, revisions[0].Action)
+
+	revisions, err = suite.repo.GetSongRevisions(suite.ctx, songID1, 2, 2)
+	suite.Require().NoError(err)
+	suite.Require().Equal([]int{1}, revisionVersions(revisions))
+	suite.Require().Equal(models.RevisionCreate, revisions[0].Action)
+	suite.Require().Nil(revisions[0].Before)
+
+	revisions, err = suite.repo.GetSongRevisions(suite.ctx, "missing", 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(revisions)
+	suite.Require().Empty(revisions)
+
+	_, err = suite.repo.GetSongRevisions(suite.ctx, songID1, -1, 0)
+	suite.requireCode(utils.Internal, err)
+
+	_, err = suite.repo.GetSongRevision(suite.ctx, songID1, 4)
+	suite.requireCode(utils.NotFound, err)
+
+	_, err = suite.repo.GetSongRevision(suite.ctx, "missing", 1)
+	suite.requireCode(utils.NotFound, err)
+
+	revision, err := suite.repo.GetSongRevisionAt(suite.ctx, songID1, time.Now().UTC().Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, revision.Version)
+
+	revision, err = suite.repo.GetSongRevisionAt(suite.ctx, songID1, revision.CreatedAt)
+	suite.Require().NoError(err)
+	suite.Require().Equal(3, revision.Version)
+
+	_, err = suite.repo.GetSongRevisionAt(suite.ctx, songID1, time.Now().UTC().Add(-time.Hour))
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestPurgeDeletedSongs() {
+	suite.createSongs(songID1, songID2)
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	purged, err := suite.repo.PurgeDeletedSongs(suite.ctx, time.Hour)
+	suite.Require().NoError(err)
+	suite.Require().Zero(purged)
+
+	// the negative retention purges the songs deleted just now
+	purged, err = suite.repo.PurgeDeletedSongs(suite.ctx, -time.Minute)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(1, purged)
+
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, IncludeDeleted: true})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1}, songIDs(songs))
+
+	revisions, err := suite.repo.GetSongRevisions(suite.ctx, songID2, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Empty(revisions)
+
+	suite.requireCode(utils.NotFound, suite.repo.RestoreDeletedSong(suite.ctx, songID2, 0))
+}
+
+func (suite *Suite) TestCreateSongs() {
+	songs := []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1"},
+		{SongID: songID2, Song: "song2", Group: "group2"},
+	}
+
+	suite.Require().NoError(suite.repo.CreateSongs(suite.ctx, songs))
+	suite.Require().Equal(1, songs[0].Version)
+	suite.Require().False(songs[1].UpdatedAt.IsZero())
+
+	revisions, err := suite.repo.GetSongRevisions(suite.ctx, songID2, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 1)
+
+	// the duplicate fails the whole batch
+	err = suite.repo.CreateSongs(suite.ctx, []models.Song{
+		{SongID: songID3, Song: "song3", Group: "group3"},
+		{SongID: songID1, Song: "song1", Group: "group1"},
+	})
+	suite.requireCode(utils.Internal, err)
+
+	_, err = suite.repo.GetSong(suite.ctx, songID3)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) TestEditSongs() {
+	suite.createSongs(songID1, songID2, songID3)
+
+	// best-effort edit skips the stale version only
+	errs, err := suite.repo.EditSongs(suite.ctx, []models.Song{
+		{SongID: songID1, Song: "edited1", Group: "group", Version: 1},
+		{SongID: songID2, Song: "edited2", Group: "group", Version: 5},
+		{SongID: songID3, Song: "edited3", Group: "group"},
+	}, false)
+	suite.Require().NoError(err)
+	suite.Require().Len(errs, 3)
+	suite.Require().NoError(errs[0])
+	suite.requireCode(utils.PreconditionFailed, errs[1])
+	suite.Require().NoError(errs[2])
+
+	suite.requireSongTitle(songID1, "edited1")
+	suite.requireSongTitle(songID2, "song2")
+	suite.requireSongTitle(songID3, "edited3")
+
+	// atomic edit is rolled back by the missing song, the items after it are not tried
+	errs, err = suite.repo.EditSongs(suite.ctx, []models.Song{
+		{SongID: songID1, Song: "atomic1", Group: "group"},
+		{SongID: missingID, Song: "atomic2", Group: "group"},
+		{SongID: songID3, Song: "atomic3", Group: "group"},
+	}, true)
+	suite.Require().NoError(err)
+	suite.Require().NoError(errs[0])
+	suite.requireCode(utils.NotFound, errs[1])
+	suite.Require().NoError(errs[2])
+
+	suite.requireSongTitle(songID1, "edited1")
+	suite.requireSongTitle(songID3, "edited3")
+
+	revisions, err := suite.repo.GetSongRevisions(suite.ctx, songID1, 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(revisions, 2)
+}
+
+func (suite *Suite) TestDeleteSongs() {
+	suite.createSongs(songID1, songID2, songID3)
+
+	errs, err := suite.repo.DeleteSongs(suite.ctx, []models.SongVersion{{SongID: songID2}, {SongID: "missing"}}, true)
+	suite.Require().NoError(err)
+	suite.Require().NoError(errs[0])
+	suite.requireCode(utils.NotFound, errs[1])
+
+	_, err = suite.repo.GetSong(suite.ctx, songID2)
+	suite.Require().NoError(err)
+
+	errs, err = suite.repo.DeleteSongs(suite.ctx, []models.SongVersion{{SongID: songID2, Version: 2}, {SongID: songID3, Version: 1}}, false)
+	suite.Require().NoError(err)
+	suite.requireCode(utils.PreconditionFailed, errs[0])
+	suite.Require().NoError(errs[1])
+
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().ElementsMatch([]string{songID1, songID2}, songIDs(songs))
+}
+
+func (suite *Suite) TestFindSongs() {
+	suite.Require().NoError(suite.repo.CreateSongs(suite.ctx, []models.Song{
+		{SongID: songID1, Song: "song1", Group: "group1"},
+		{SongID: songID2, Song: "song2", Group: "group2"},
+	}))
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	found, err := suite.repo.FindSongs(suite.ctx, []models.NewSong{
+		{Group: "group1", Song: "song1"},
+		{Group: "group1", Song: "song1"},
+		{Group: "group1", Song: "song2"},
+		{Group: "group2", Song: "song2"},
+		{Group: "group", Song: "song"},
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]models.NewSong{{Group: "group1", Song: "song1"}}, found)
+}
+
+func (suite *Suite) TestStreamSongs() {
+	// the songs are created out of the order of IDs
+	suite.createSongs(songID3, songID1, songID2)
+	suite.Require().NoError(suite.repo.DeleteSong(suite.ctx, songID2, 0))
+
+	ids := suite.streamSongIDs(models.SongFilter{})
+	suite.Require().Equal([]string{songID1, songID3}, ids)
+
+	ids = suite.streamSongIDs(models.SongFilter{Lim: 1, Off: 1, IncludeDeleted: true})
+	suite.Require().Equal([]string{songID2}, ids)
+
+	ids = suite.streamSongIDs(models.SongFilter{SongID: "missing"})
+	suite.Require().Empty(ids)
+
+	stop := errors.New("stop")
+	err := suite.repo.StreamSongs(suite.ctx, models.SongFilter{}, func(song models.Song) error {
+		return stop
+	})
+	suite.Require().ErrorIs(err, stop)
+}
+
+func (suite *Suite) TestMigrations() {
+	migrations, err := suite.repo.GetMigrations(suite.ctx)
+	suite.Require().NoError(err)
+
+	// applying the applied migrations changes nothing
+	suite.Require().NoError(suite.repo.MigrateUp(suite.ctx, 0))
+
+	after, err := suite.repo.GetMigrations(suite.ctx)
+	suite.Require().NoError(err)
+	suite.Require().Equal(len(migrations), len(after))
+	for _, migration := range after {
+		suite.Require().True(migration.Applied)
+	}
+}
+
+// createSongs creates songs named after their position in the list
+func (suite *Suite) createSongs(ids ...string) {
+	for i, id := range ids {
+		suite.Require().NoError(suite.repo.CreateSong(suite.ctx, models.Song{
+			SongID: id,
+			Song:   fmt.Sprintf("song%d", i+1),
+			Group:  fmt.Sprintf("group%d", i+1),
+			Data:   models.SongData{Text: fmt.Sprintf("song text %d", i+1), Link: fmt.Sprintf("link%d", i+1)},
+		}))
+	}
+}
+
+func (suite *Suite) streamSongIDs(filter models.SongFilter) []string {
+	var ids []string
+	err := suite.repo.StreamSongs(suite.ctx, filter, func(song models.Song) error {
+		ids = append(ids, song.SongID)
+		return nil
+	})
+	suite.Require().NoError(err)
+
+	return ids
+}
+
+func (suite *Suite) requireSongTitle(songID, title string) {
+	song, err := suite.repo.GetSong(suite.ctx, songID)
+	suite.Require().NoError(err)
+	suite.Require().Equal(title, song.Song)
+}
+
+func (suite *Suite) requireCode(code int, err error) {
+	suite.Require().Error(err)
+	suite.Require().Equal(code, utils.ErrorCode(err), err.Error())
+}
+
+func songIDs(songs []models.Song) []string {
+	ids := make([]string, len(songs))
+	for i, song := range songs {
+		ids[i] = song.SongID
+	}
+	return ids
+}
+
+func revisionVersions(revisions []models.SongRevision) []int {
+	versions := make([]int, len(revisions))
+	for i, revision := range revisions {
+		versions[i] = revision.Version
+	}
+	return versions
+}
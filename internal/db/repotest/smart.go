@@ -0,0 +1,163 @@
+package repotest
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"time"
+)
+
+func (suite *Suite) TestSmartPlaylists() {
+	suite.createUsers(userID1)
+
+	maxPlays := int64(3)
+	smart := &models.SmartPlaylist{
+		Rules: models.SmartRules{
+			Match: models.MatchAny,
+			Conditions: []models.SmartCondition{
+				{Group: "group1", MaxPlays: &maxPlays},
+			},
+			Groups: []models.SmartRules{
+				{Match: models.MatchAll, Conditions: []models.SmartCondition{{MinRating: 4}, {AddedWithinDays: 7}}},
+			},
+		},
+		Sort:  models.SortRating,
+		Limit: 20,
+	}
+
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID1, OwnerID: userID1, Name: "smart", Visibility: models.PlaylistPublic, Smart: smart,
+	}))
+	suite.Require().NoError(suite.repo.CreatePlaylist(suite.ctx, models.Playlist{
+		PlaylistID: playlistID2, OwnerID: userID1, Name: "regular", Visibility: models.PlaylistPublic,
+	}))
+
+	res, err := suite.repo.GetPlaylist(suite.ctx, playlistID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(smart, res.Smart)
+
+	res, err = suite.repo.GetPlaylist(suite.ctx, playlistID2)
+	suite.Require().NoError(err)
+	suite.Require().Nil(res.Smart)
+
+	// the rules are replaced on edit
+	smart.Rules.Groups, smart.Limit = nil, 5
+	res.PlaylistID, res.Name, res.Smart = playlistID1, "smarter", smart
+	suite.Require().NoError(suite.repo.EditPlaylist(suite.ctx, res))
+
+	playlists, err := suite.repo.GetPlaylists(suite.ctx, models.PlaylistFilter{MemberID: userID1, Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Len(playlists, 2)
+	for _, playlist := range playlists {
+		if playlist.PlaylistID == playlistID1 {
+			suite.Require().Equal("smarter", playlist.Name)
+			suite.Require().Equal(smart, playlist.Smart)
+		} else {
+			suite.Require().Nil(playlist.Smart)
+		}
+	}
+}
+
+func (suite *Suite) TestGetSongsRules() {
+	suite.createUsers(userID1, userID2)
+	suite.createSongs(songID1, songID2, songID3)
+
+	released := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	song, err := suite.repo.GetSong(suite.ctx, songID2)
+	suite.Require().NoError(err)
+	song.Data.ReleaseDate = released
+	suite.Require().NoError(suite.repo.EditSong(suite.ctx, song))
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	for i, songID := range []string{songID1, songID1, songID3} {
+		suite.Require().NoError(suite.repo.AddPlay(suite.ctx, userID1, models.Play{
+			PlayID: []string{playID1, playID2, playID3}[i], Song: models.Song{SongID: songID}, PlayedAt: start,
+		}))
+	}
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID3, 5))
+	suite.Require().NoError(suite.repo.RateSong(suite.ctx, userID1, songID2, 2))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID1, songID3, true))
+	suite.Require().NoError(suite.repo.SetFavorite(suite.ctx, userID2, songID3, true))
+
+	never := int64(0)
+	from, to := released.AddDate(0, 0, -1), released.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name  string
+		rules models.SmartRules
+		sort  string
+		songs []string
+	}{
+		{
+			name:  "empty rules match all songs",
+			rules: models.SmartRules{Match: models.MatchAll},
+			sort:  models.SortPlays,
+			songs: []string{songID1, songID3, songID2},
+		},
+		{
+			name: "all",
+			rules: models.SmartRules{Match: models.MatchAll, Conditions: []models.SmartCondition{
+				{MinPlays: 1}, {MinRating: 4},
+			}},
+			songs: []string{songID3},
+		},
+		{
+			name: "any",
+			rules: models.SmartRules{Match: models.MatchAny, Conditions: []models.SmartCondition{
+				{Group: "group1"}, {MinFavorites: 2},
+			}},
+			sort:  models.SortFavorites,
+			songs: []string{songID3, songID1},
+		},
+		{
+			name: "fields of a condition match together",
+			rules: models.SmartRules{Match: models.MatchAny, Conditions: []models.SmartCondition{
+				{Text: "text 1", MinFavorites: 1},
+			}},
+			songs: []string{},
+		},
+		{
+			name: "never played",
+			rules: models.SmartRules{Match: models.MatchAll, Conditions: []models.SmartCondition{
+				{MaxPlays: &never},
+			}},
+			songs: []string{songID2},
+		},
+		{
+			name: "release dates",
+			rules: models.SmartRules{Match: models.MatchAll, Conditions: []models.SmartCondition{
+				{ReleasedFrom: &from, ReleasedTo: &to},
+			}},
+			songs: []string{songID2},
+		},
+		{
+			name: "nested groups",
+			rules: models.SmartRules{
+				Match:      models.MatchAll,
+				Conditions: []models.SmartCondition{{AddedWithinDays: 1}},
+				Groups: []models.SmartRules{
+					{Match: models.MatchAny, Conditions: []models.SmartCondition{{Song: "song2"}, {Link: "link3"}}},
+					{Match: models.MatchAny, Conditions: []models.SmartCondition{{MinRating: 1}, {MinPlays: 5}}},
+				},
+			},
+			sort:  models.SortRating,
+			songs: []string{songID3, songID2},
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Sort: tc.sort, Rules: &tc.rules})
+			suite.Require().NoError(err)
+			suite.Require().Equal(tc.songs, songIDs(songs))
+		})
+	}
+
+	// the rules narrow down the other fields of the filter
+	rules := models.SmartRules{Match: models.MatchAny, Conditions: []models.SmartCondition{{MinPlays: 1}}}
+	songs, err := suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 1, Sort: models.SortPlays, Rules: &rules})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID1}, songIDs(songs))
+
+	songs, err = suite.repo.GetSongs(suite.ctx, models.SongFilter{Lim: 10, Group: "group3", Rules: &rules})
+	suite.Require().NoError(err)
+	suite.Require().Equal([]string{songID3}, songIDs(songs))
+}
@@ -0,0 +1,125 @@
+package repotest
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+const (
+	userID1 = "20000000-0000-0000-0000-000000000001"
+	userID2 = "20000000-0000-0000-0000-000000000002"
+)
+
+func (suite *Suite) TestUsers() {
+	user := models.User{
+		UserID:       userID1,
+		Name:         "user1",
+		DisplayName:  "User 1",
+		Email:        "user1@example.com",
+		Role:         "viewer",
+		PasswordHash: "hash",
+	}
+	suite.Require().NoError(suite.repo.CreateUser(suite.ctx, user))
+
+	res, err := suite.repo.GetUser(suite.ctx, userID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal(user.Name, res.Name)
+	suite.Require().Equal(user.DisplayName, res.DisplayName)
+	suite.Require().Equal(user.Email, res.Email)
+	suite.Require().Equal(user.Role, res.Role)
+	suite.Require().Equal(user.PasswordHash, res.PasswordHash)
+	suite.Require().False(res.CreatedAt.IsZero())
+
+	byName, err := suite.repo.GetUserByName(suite.ctx, "user1")
+	suite.Require().NoError(err)
+	suite.Require().Equal(res, byName)
+
+	// names are unique
+	user.UserID = userID2
+	suite.requireCode(utils.Conflict, suite.repo.CreateUser(suite.ctx, user))
+
+	res.DisplayName, res.Email, res.Role, res.PasswordHash = "renamed", "", "admin", "other hash"
+	suite.Require().NoError(suite.repo.EditUser(suite.ctx, res))
+
+	edited, err := suite.repo.GetUser(suite.ctx, userID1)
+	suite.Require().NoError(err)
+	suite.Require().Equal("renamed", edited.DisplayName)
+	suite.Require().Empty(edited.Email)
+	suite.Require().Equal("admin", edited.Role)
+	suite.Require().Equal("other hash", edited.PasswordHash)
+	suite.Require().Equal("user1", edited.Name)
+	suite.Require().False(edited.UpdatedAt.Before(res.UpdatedAt))
+
+	_, err = suite.repo.GetUser(suite.ctx, userID2)
+	suite.requireCode(utils.NotFound, err)
+
+	_, err = suite.repo.GetUser(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	_, err = suite.repo.GetUserByName(suite.ctx, "user2")
+	suite.requireCode(utils.NotFound, err)
+
+	suite.requireCode(utils.NotFound, suite.repo.EditUser(suite.ctx, models.User{UserID: userID2, Role: "viewer"}))
+
+	// roles are checked by the storage
+	edited.Role = "owner"
+	suite.requireCode(utils.Internal, suite.repo.EditUser(suite.ctx, edited))
+
+	user.UserID, user.Name = userID2, "user2"
+	user.Role = ""
+	suite.requireCode(utils.Internal, suite.repo.CreateUser(suite.ctx, user))
+}
+
+func (suite *Suite) TestSessions() {
+	suite.createUsers(userID1, userID2)
+
+	expiresAt, err := suite.repo.CreateSession(suite.ctx, "hash1", userID1, time.Hour)
+	suite.Require().NoError(err)
+	suite.Require().WithinDuration(time.Now().UTC().Add(time.Hour), expiresAt, time.Minute)
+
+	_, err = suite.repo.CreateSession(suite.ctx, "hash2", userID1, time.Hour)
+	suite.Require().NoError(err)
+	_, err = suite.repo.CreateSession(suite.ctx, "hash3", userID2, time.Hour)
+	suite.Require().NoError(err)
+
+	user, err := suite.repo.GetSessionUser(suite.ctx, "hash1")
+	suite.Require().NoError(err)
+	suite.Require().Equal(userID1, user.UserID)
+
+	_, err = suite.repo.GetSessionUser(suite.ctx, "missing")
+	suite.requireCode(utils.NotFound, err)
+
+	suite.Require().NoError(suite.repo.DeleteSession(suite.ctx, "hash1"))
+	_, err = suite.repo.GetSessionUser(suite.ctx, "hash1")
+	suite.requireCode(utils.NotFound, err)
+
+	suite.Require().NoError(suite.repo.DeleteUserSessions(suite.ctx, userID1))
+	_, err = suite.repo.GetSessionUser(suite.ctx, "hash2")
+	suite.requireCode(utils.NotFound, err)
+
+	// sessions of other users are kept
+	_, err = suite.repo.GetSessionUser(suite.ctx, "hash3")
+	suite.Require().NoError(err)
+
+	// expired sessions are not found
+	_, err = suite.repo.CreateSession(suite.ctx, "expired", userID1, -time.Hour)
+	suite.Require().NoError(err)
+	_, err = suite.repo.GetSessionUser(suite.ctx, "expired")
+	suite.requireCode(utils.NotFound, err)
+
+	_, err = suite.repo.CreateSession(suite.ctx, "hash4", missingID, time.Hour)
+	suite.requireCode(utils.NotFound, err)
+}
+
+func (suite *Suite) createUsers(ids ...string) {
+	for i, id := range ids {
+		suite.Require().NoError(suite.repo.CreateUser(suite.ctx, models.User{
+			UserID:       id,
+			Name:         fmt.Sprintf("user%d", i+1),
+			Role:         "viewer",
+			PasswordHash: "hash",
+		}))
+	}
+}
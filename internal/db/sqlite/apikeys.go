@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/mattn/go-sqlite3"
+	"strings"
+	"time"
+)
+
+const selectAPIKeys = `SELECT id, owner_id, name, prefix, scopes, created_at, last_used_at, revoked_at FROM api_keys`
+
+func (r *repository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `INSERT INTO api_keys (id, owner_id, name, prefix, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, q, canonicalID(key.KeyID), canonicalID(key.OwnerID), key.Name, key.Prefix, keyHash,
+		strings.Join(key.Scopes, " "), timestamp(now()))
+	if err != nil {
+		if isConstraintViolation(err, sqlite3.ErrConstraintForeignKey) {
+			return utils.NewError("user not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetAPIKey(ctx context.Context, keyID string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	key, err := r.getAPIKey(ctx, selectAPIKeys+` WHERE id = ?`, canonicalID(keyID))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return key, nil
+}
+
+func (r *repository) GetAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	var rows []apiKeyRow
+	q := selectAPIKeys + ` WHERE owner_id = ? ORDER BY created_at, id`
+	if err := r.db.SelectContext(ctx, &rows, q, canonicalID(ownerID)); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	keys := make([]models.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return keys, nil
+}
+
+func (r *repository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received UseAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	key, err := r.getAPIKey(ctx, selectAPIKeys+` WHERE key_hash = ? AND revoked_at IS NULL`, keyHash)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	usedAt := now()
+	q := `UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+
+	res, err := r.db.ExecContext(ctx, q, timestamp(usedAt), key.KeyID, timestamp(usedAt.Add(-db.LastUseInterval)))
+	if err != nil {
+		return models.APIKey{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return models.APIKey{}, utils.NewError(err.Error(), utils.Internal)
+	} else if n > 0 {
+		key.LastUsedAt = &usedAt
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed UseAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return key, nil
+}
+
+func (r *repository) RevokeAPIKey(ctx context.Context, keyID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`
+
+	res, err := r.db.ExecContext(ctx, q, timestamp(now()), canonicalID(keyID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	} else if n == 0 {
+		return utils.NewError("API key not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) getAPIKey(ctx context.Context, q string, args ...any) (models.APIKey, error) {
+	var row apiKeyRow
+	if err := r.db.QueryRowxContext(ctx, q, args...).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, utils.NewError("API key not found", utils.NotFound)
+		}
+		return models.APIKey{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return row.toModel(), nil
+}
+
+type apiKeyRow struct {
+	KeyID      string       `db:"id"`
+	OwnerID    string       `db:"owner_id"`
+	Name       string       `db:"name"`
+	Prefix     string       `db:"prefix"`
+	Scopes     string       `db:"scopes"`
+	CreatedAt  time.Time    `db:"created_at"`
+	LastUsedAt sql.NullTime `db:"last_used_at"`
+	RevokedAt  sql.NullTime `db:"revoked_at"`
+}
+
+func (r apiKeyRow) toModel() models.APIKey {
+	key := models.APIKey{
+		KeyID:     r.KeyID,
+		OwnerID:   r.OwnerID,
+		Name:      r.Name,
+		Prefix:    r.Prefix,
+		Scopes:    strings.Fields(r.Scopes),
+		CreatedAt: r.CreatedAt,
+	}
+
+	if r.LastUsedAt.Valid {
+		key.LastUsedAt = &r.LastUsedAt.Time
+	}
+	if r.RevokedAt.Valid {
+		key.RevokedAt = &r.RevokedAt.Time
+	}
+
+	return key
+}
@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (r *repository) CreateSongs(ctx context.Context, songs []models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// sqlite has no round trips to save, so the songs are inserted one by one
+	now := now()
+	for i := range songs {
+		if err = insertSong(ctx, tx, &songs[i], now); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs, err := r.applyBulk(ctx, len(songs), atomic, func(tx *sqlx.Tx, i int, now time.Time) error {
+		return editSong(ctx, tx, songs[i], now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+func (r *repository) DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]error, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	errs, err := r.applyBulk(ctx, len(songs), atomic, func(tx *sqlx.Tx, i int, now time.Time) error {
+		return deleteSong(ctx, tx, songs[i].SongID, songs[i].Version, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return errs, nil
+}
+
+// applyBulk runs apply for n items in a single transaction. In the atomic mode it stops at the first failure
+// and rolls everything back, otherwise every item is wrapped into a savepoint so a failure discards only that item
+func (r *repository) applyBulk(ctx context.Context, n int, atomic bool, apply func(tx *sqlx.Tx, i int, now time.Time) error) ([]error, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := now()
+
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		if atomic {
+			if errs[i] = apply(tx, i, now); errs[i] != nil {
+				return errs, nil
+			}
+			continue
+		}
+
+		if _, err = tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if errs[i] = apply(tx, i, now); errs[i] != nil {
+			if _, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+				return nil, utils.NewError(err.Error(), utils.Internal)
+			}
+		}
+
+		// unlike postgres, the savepoint is left after the rollback and has to be released anyway
+		if _, err = tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+func (r *repository) StreamSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if filter.SongID != "" && canonicalID(filter.SongID) == "" {
+		return nil
+	}
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return err
+	}
+
+	// the negative limit returns all rows, so the zero limit exports everything
+	if filter.Lim == 0 {
+		filter.Lim = -1
+	}
+
+	// a single statement reads a single snapshot of the library, WAL lets fn write meanwhile
+	q := selectFilteredSongs + `
+      ORDER BY songs.id LIMIT ?8 OFFSET ?7`
+
+	rows, err := r.db.QueryxContext(ctx, q, filterArgs(filter)...)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var row songStatsRow
+		if err = rows.StructScan(&row); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if err = fn(row.songRow.toModel()); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
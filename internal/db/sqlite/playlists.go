@@ -0,0 +1,519 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const selectPlaylists = `SELECT id, owner_id, name, description, visibility, created_at, updated_at, smart FROM playlists`
+
+func (r *repository) CreatePlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	now := timestamp(now())
+	q := `INSERT INTO playlists (id, owner_id, name, description, visibility, created_at, updated_at, smart)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = r.db.ExecContext(ctx, q, canonicalID(playlist.PlaylistID), canonicalID(playlist.OwnerID), playlist.Name,
+		playlist.Description, playlist.Visibility, now, now, smart)
+	if err != nil {
+		if isConstraintViolation(err, sqlite3.ErrConstraintForeignKey) {
+			return utils.NewError("user not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	var row playlistRow
+	if err := r.db.QueryRowxContext(ctx, selectPlaylists+` WHERE id = ?`, canonicalID(playlistID)).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Playlist{}, utils.NewError("playlist not found", utils.NotFound)
+		}
+		return models.Playlist{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	playlist, err := row.toModel()
+	if err != nil {
+		return models.Playlist{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q := `SELECT playlist_collaborators.user_id, users.name, playlist_collaborators.added_at
+		FROM playlist_collaborators
+		JOIN users ON users.id = playlist_collaborators.user_id
+		WHERE playlist_collaborators.playlist_id = ?
+		ORDER BY playlist_collaborators.added_at, users.name`
+
+	playlist.Collaborators = []models.PlaylistCollaborator{}
+	if err := r.db.SelectContext(ctx, &playlist.Collaborators, q, playlist.PlaylistID); err != nil {
+		return models.Playlist{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlist, nil
+}
+
+func (r *repository) GetPlaylists(ctx context.Context, filter models.PlaylistFilter) ([]models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return nil, err
+	}
+
+	q := selectPlaylists + ` WHERE visibility = 'public'`
+	args := []any{filter.Lim, filter.Off}
+
+	if filter.MemberID != "" {
+		q = selectPlaylists + ` WHERE owner_id = ?3 OR
+			id IN (SELECT playlist_id FROM playlist_collaborators WHERE user_id = ?3)`
+		args = append(args, canonicalID(filter.MemberID))
+	}
+
+	q += ` ORDER BY updated_at DESC, id LIMIT ?1 OFFSET ?2`
+
+	var rows []playlistRow
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	playlists := make([]models.Playlist, 0, len(rows))
+	for _, row := range rows {
+		playlist, err := row.toModel()
+		if err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		playlists = append(playlists, playlist)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return playlists, nil
+}
+
+func (r *repository) EditPlaylist(ctx context.Context, playlist models.Playlist) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	smart, err := smartJSON(playlist.Smart)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE playlists SET name = ?, description = ?, visibility = ?, smart = ?, updated_at = ? WHERE id = ?`
+
+	res, err := r.db.ExecContext(ctx, q, playlist.Name, playlist.Description, playlist.Visibility, smart,
+		timestamp(now()), canonicalID(playlist.PlaylistID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "playlist not found"); err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeletePlaylist(ctx context.Context, playlistID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// the songs and the collaborators of the playlist are removed by the cascade
+	res, err := r.db.ExecContext(ctx, `DELETE FROM playlists WHERE id = ?`, canonicalID(playlistID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "playlist not found"); err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) AddPlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// sqlite does not tell the violated foreign key, so the playlist is looked up first
+	var exists bool
+	q := `SELECT EXISTS (SELECT 1 FROM playlists WHERE id = ?)`
+	if err = tx.QueryRowxContext(ctx, q, canonicalID(playlistID)).Scan(&exists); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	if !exists {
+		return utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	q = `INSERT INTO playlist_collaborators (playlist_id, user_id, added_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`
+
+	if _, err = tx.ExecContext(ctx, q, canonicalID(playlistID), canonicalID(userID), timestamp(now())); err != nil {
+		if isConstraintViolation(err, sqlite3.ErrConstraintForeignKey) {
+			return utils.NewError("user not found", utils.NotFound)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistCollaborator(ctx context.Context, playlistID string, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `DELETE FROM playlist_collaborators WHERE playlist_id = ? AND user_id = ?`
+
+	res, err := r.db.ExecContext(ctx, q, canonicalID(playlistID), canonicalID(userID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "collaborator not found"); err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetPlaylistSongs(ctx context.Context, playlistID string) ([]models.PlaylistSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	playlistID = canonicalID(playlistID)
+
+	var exists bool
+	if err := r.db.QueryRowxContext(ctx, `SELECT EXISTS (SELECT 1 FROM playlists WHERE id = ?)`, playlistID).Scan(&exists); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	if !exists {
+		return nil, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	q := `SELECT
+			playlist_songs.id AS entry_id,
+			playlist_songs.position,
+			COALESCE(playlist_songs.added_by, '') AS added_by,
+			playlist_songs.added_at,
+			songs.id,
+			group_songs.group_name,
+			songs.song,
+			songs.release_date,
+			songs.text,
+			songs.link,
+			songs.version,
+			songs.updated_at,
+			songs.deleted_at
+		FROM playlist_songs
+		JOIN songs ON songs.id = playlist_songs.song_id
+		JOIN group_songs ON songs.id = group_songs.song_id
+		WHERE playlist_songs.playlist_id = ?
+		ORDER BY playlist_songs.position`
+
+	var rows []playlistSongRow
+	if err := r.db.SelectContext(ctx, &rows, q, playlistID); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	entries := make([]models.PlaylistSong, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetPlaylistSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return entries, nil
+}
+
+func (r *repository) AddPlaylistSong(ctx context.Context, playlistID string, entry models.PlaylistSong) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	err := r.updatePlaylist(ctx, playlistID, func(tx *sqlx.Tx, now time.Time) error {
+		if _, err := lockSong(ctx, tx, entry.Song.SongID, 0, false); err != nil {
+			return err
+		}
+
+		var addedBy any
+		if id := canonicalID(entry.AddedBy); id != "" {
+			addedBy = id
+		}
+
+		q := `INSERT INTO playlist_songs (id, playlist_id, song_id, position, added_by, added_at) VALUES (?, ?, ?, ?, ?, ?)`
+
+		_, err := tx.ExecContext(ctx, q, canonicalID(entry.EntryID), canonicalID(playlistID), canonicalID(entry.Song.SongID),
+			entry.Position, addedBy, timestamp(now))
+		if err != nil {
+			if isConstraintViolation(err, sqlite3.ErrConstraintUnique) {
+				return utils.NewError("position is taken", utils.Conflict)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) MovePlaylistSong(ctx context.Context, playlistID string, entryID string, position string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	err := r.updatePlaylist(ctx, playlistID, func(tx *sqlx.Tx, _ time.Time) error {
+		q := `UPDATE playlist_songs SET position = ? WHERE id = ? AND playlist_id = ?`
+
+		res, err := tx.ExecContext(ctx, q, position, canonicalID(entryID), canonicalID(playlistID))
+		if err != nil {
+			if isConstraintViolation(err, sqlite3.ErrConstraintUnique) {
+				return utils.NewError("position is taken", utils.Conflict)
+			}
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return requireRow(res, "playlist entry not found")
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	err := r.updatePlaylist(ctx, playlistID, func(tx *sqlx.Tx, _ time.Time) error {
+		q := `DELETE FROM playlist_songs WHERE id = ? AND playlist_id = ?`
+
+		res, err := tx.ExecContext(ctx, q, canonicalID(entryID), canonicalID(playlistID))
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		return requireRow(res, "playlist entry not found")
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+// updatePlaylist runs fn in a transaction after updating the playlist
+func (r *repository) updatePlaylist(ctx context.Context, playlistID string, fn func(tx *sqlx.Tx, now time.Time) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := now()
+
+	res, err := tx.ExecContext(ctx, `UPDATE playlists SET updated_at = ? WHERE id = ?`, timestamp(now), canonicalID(playlistID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = requireRow(res, "playlist not found"); err != nil {
+		return err
+	}
+
+	if err = fn(tx, now); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+// removeFromPlaylists removes the song from all playlists and updates them
+func removeFromPlaylists(ctx context.Context, tx *sqlx.Tx, songID string, now time.Time) error {
+	q := `UPDATE playlists SET updated_at = ? WHERE id IN (SELECT playlist_id FROM playlist_songs WHERE song_id = ?)`
+
+	if _, err := tx.ExecContext(ctx, q, timestamp(now), songID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM playlist_songs WHERE song_id = ?`, songID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+// requireRow returns a NotFound error with the message if the statement changed no rows
+func requireRow(res sql.Result, msg string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	if n == 0 {
+		return utils.NewError(msg, utils.NotFound)
+	}
+
+	return nil
+}
+
+// playlistRow is a playlist selected with selectPlaylists, SmartJSON holds the smart playlist policy as JSON
+type playlistRow struct {
+	models.Playlist
+	SmartJSON []byte `db:"smart"`
+}
+
+func (r playlistRow) toModel() (models.Playlist, error) {
+	playlist := r.Playlist
+	if r.SmartJSON != nil {
+		playlist.Smart = new(models.SmartPlaylist)
+		if err := json.Unmarshal(r.SmartJSON, playlist.Smart); err != nil {
+			return models.Playlist{}, err
+		}
+	}
+
+	return playlist, nil
+}
+
+// smartJSON returns the value of the smart column, NULL for regular playlists
+func smartJSON(smart *models.SmartPlaylist) (sql.NullString, error) {
+	if smart == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(smart)
+	if err != nil {
+		return sql.NullString{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+type playlistSongRow struct {
+	EntryID  string    `db:"entry_id"`
+	Position string    `db:"position"`
+	AddedBy  string    `db:"added_by"`
+	AddedAt  time.Time `db:"added_at"`
+	songRow
+}
+
+func (r playlistSongRow) toModel() models.PlaylistSong {
+	return models.PlaylistSong{
+		EntryID:  r.EntryID,
+		Position: r.Position,
+		Song:     r.songRow.toModel(),
+		AddedBy:  r.AddedBy,
+		AddedAt:  r.AddedAt,
+	}
+}
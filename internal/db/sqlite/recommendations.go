@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+func (r *repository) StreamInteractions(ctx context.Context, fn func(interaction models.Interaction) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// a single statement reads a single snapshot, WAL lets fn write meanwhile
+	q := `SELECT
+				interactions.user_id,
+				interactions.song_id,
+				sum(interactions.plays) AS plays,
+				max(interactions.favorite) AS favorite,
+				max(interactions.at) AS last_at
+			FROM (
+				SELECT user_id, song_id, 1 AS plays, FALSE AS favorite, played_at AS at FROM plays
+				UNION ALL
+				SELECT user_id, song_id, 0 AS plays, TRUE AS favorite, created_at AS at FROM favorites
+			) AS interactions
+			JOIN songs ON songs.id = interactions.song_id
+			WHERE songs.deleted_at IS NULL
+			GROUP BY interactions.user_id, interactions.song_id
+			ORDER BY interactions.user_id, last_at DESC, interactions.song_id`
+
+	rows, err := r.db.QueryxContext(ctx, q)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var row interactionRow
+		if err = rows.StructScan(&row); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		interaction, err := row.toModel()
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if err = fn(interaction); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed StreamInteractions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) ReplaceSongNeighbors(ctx context.Context, neighbors []models.SongNeighbor) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM song_neighbors`); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	// songs purged since the training are skipped
+	q := `INSERT INTO song_neighbors (song_id, neighbor_id, score)
+			SELECT ?1, ?2, ?3
+			WHERE EXISTS (SELECT 1 FROM songs WHERE id = ?1) AND EXISTS (SELECT 1 FROM songs WHERE id = ?2)`
+
+	stmt, err := tx.PreparexContext(ctx, q)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	for _, neighbor := range neighbors {
+		songID, neighborID := canonicalID(neighbor.SongID), canonicalID(neighbor.NeighborID)
+		if songID == "" || neighborID == "" {
+			continue
+		}
+
+		if _, err = stmt.ExecContext(ctx, songID, neighborID, neighbor.Score); err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed ReplaceSongNeighbors",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetRecommendations(ctx context.Context, userID string, lim int) ([]models.Recommendation, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validatePagination(lim, 0); err != nil {
+		return nil, err
+	}
+
+	q := `WITH heard AS (
+				SELECT song_id FROM plays WHERE user_id = ?1
+				UNION
+				SELECT song_id FROM favorites WHERE user_id = ?1
+			), scores AS (
+				SELECT song_neighbors.neighbor_id AS song_id, sum(song_neighbors.score) AS score
+				FROM song_neighbors JOIN heard ON heard.song_id = song_neighbors.song_id
+				GROUP BY song_neighbors.neighbor_id
+			)
+			SELECT
+				COALESCE(scores.score, 0) AS score,
+				songs.id,
+				group_songs.group_name,
+				songs.song,
+				songs.release_date,
+				songs.text,
+				songs.link,
+				songs.version,
+				songs.updated_at,
+				songs.deleted_at,
+				COALESCE(song_stats.favorites, 0) AS favorites,
+				COALESCE(song_stats.ratings, 0) AS ratings,
+				COALESCE(CAST(song_stats.rating_sum AS REAL) / NULLIF(song_stats.ratings, 0), 0) AS average_rating,
+				COALESCE(song_stats.plays, 0) AS plays
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			LEFT JOIN song_stats ON songs.id = song_stats.song_id
+			LEFT JOIN scores ON songs.id = scores.song_id
+			WHERE songs.deleted_at IS NULL AND songs.id NOT IN (SELECT song_id FROM heard)
+			ORDER BY score DESC, plays DESC, songs.id LIMIT ?2`
+
+	var rows []recommendationRow
+	if err := r.db.SelectContext(ctx, &rows, q, canonicalID(userID), lim); err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	recommendations := make([]models.Recommendation, 0, len(rows))
+	for _, row := range rows {
+		recommendations = append(recommendations, models.Recommendation{Song: row.songStatsRow.toModel(), Score: row.Score})
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return recommendations, nil
+}
+
+// interactionRow keeps the time as text, sqlite returns the aggregates of timestamp columns untyped
+type interactionRow struct {
+	UserID   string `db:"user_id"`
+	SongID   string `db:"song_id"`
+	Plays    int64  `db:"plays"`
+	Favorite bool   `db:"favorite"`
+	LastAt   string `db:"last_at"`
+}
+
+func (i interactionRow) toModel() (models.Interaction, error) {
+	lastAt, err := time.Parse(timeFormat, i.LastAt)
+	if err != nil {
+		return models.Interaction{}, err
+	}
+
+	return models.Interaction{UserID: i.UserID, SongID: i.SongID, Plays: i.Plays, Favorite: i.Favorite, LastAt: lastAt}, nil
+}
+
+type recommendationRow struct {
+	Score float64 `db:"score"`
+	songStatsRow
+}
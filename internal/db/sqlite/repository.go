@@ -0,0 +1,581 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// NewRepository returns the repository of a database opened with MustConnect or MustOpen. It follows the postgres
+// repository semantics, including the errors
+func NewRepository(db *sqlx.DB) *repository {
+	return &repository{db: db}
+}
+
+type repository struct {
+	db *sqlx.DB
+}
+
+func (r *repository) CreateSong(ctx context.Context, song models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = insertSong(ctx, tx, &song, now()); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) EditSong(ctx context.Context, song models.Song) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = editSong(ctx, tx, song, now()); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeleteSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err = deleteSong(ctx, tx, songID, version, now()); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetSong(ctx context.Context, songID string) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := selectSongStats + ` WHERE songs.id = ? AND songs.deleted_at IS NULL LIMIT 1`
+
+	songID = canonicalID(songID)
+	if songID == "" {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	var row songStatsRow
+	if err := r.db.QueryRowxContext(ctx, q, songID).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Song{}, utils.NewError("song not found", utils.NotFound)
+		}
+		return models.Song{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return row.toModel(), nil
+}
+
+func (r *repository) GetSongsByID(ctx context.Context, songIDs []string) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	valid := make([]string, 0, len(songIDs))
+	for _, songID := range songIDs {
+		if songID = canonicalID(songID); songID != "" {
+			valid = append(valid, songID)
+		}
+	}
+
+	list, err := json.Marshal(valid)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q := selectSongStats + ` WHERE songs.id IN (SELECT value FROM json_each(?)) AND songs.deleted_at IS NULL`
+
+	rows, err := r.db.QueryxContext(ctx, q, string(list))
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	songs := make([]models.Song, 0, len(valid))
+	for rows.Next() {
+		var row songStatsRow
+		if err = rows.StructScan(&row); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		songs = append(songs, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongsByID",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) GetSongText(ctx context.Context, songID string) (string, time.Time, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongText",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT text, updated_at FROM songs WHERE id = ? AND deleted_at IS NULL LIMIT 1`
+
+	songID = canonicalID(songID)
+	if songID == "" {
+		return "", time.Time{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	var (
+		text      string
+		updatedAt time.Time
+	)
+	if err := r.db.QueryRowxContext(ctx, q, songID).Scan(&text, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, utils.NewError("song not found", utils.NotFound)
+		}
+		return "", time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongText",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return text, updatedAt, nil
+}
+
+func (r *repository) GetSongs(ctx context.Context, filter models.SongFilter) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	order, ok := songOrders[filter.Sort]
+	if !ok {
+		return nil, utils.NewError("unknown sort: "+filter.Sort, utils.Internal)
+	}
+
+	args := filterArgs(filter)
+	rules := "TRUE"
+	if filter.Rules != nil {
+		rules, args = rulesCondition(*filter.Rules, args)
+	}
+
+	q := selectFilteredSongs + ` AND
+          ` + rules + `
+      ` + order + ` LIMIT ?8 OFFSET ?7`
+
+	if filter.SongID != "" && canonicalID(filter.SongID) == "" {
+		return []models.Song{}, nil
+	}
+
+	if err := validatePagination(filter.Lim, filter.Off); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryxContext(ctx, q, args...)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	songs := make([]models.Song, 0, filter.Lim)
+	for rows.Next() {
+		var row songStatsRow
+		if err = rows.StructScan(&row); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		songs = append(songs, row.toModel())
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return songs, nil
+}
+
+func (r *repository) FindSongs(ctx context.Context, songs []models.NewSong) ([]models.NewSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	list, err := json.Marshal(songs)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q := `SELECT DISTINCT group_songs.group_name, songs.song
+			FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+			INNER JOIN json_each(?) AS found
+				ON found.value ->> 'group' = group_songs.group_name AND found.value ->> 'song' = songs.song
+			WHERE songs.deleted_at IS NULL`
+
+	rows, err := r.db.QueryxContext(ctx, q, string(list))
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var found []models.NewSong
+	for rows.Next() {
+		var song models.NewSong
+		if err = rows.Scan(&song.Group, &song.Song); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		found = append(found, song)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed FindSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return found, nil
+}
+
+const selectSongs = `SELECT 
+			songs.id, 
+			group_songs.group_name, 
+			songs.song, 
+			songs.release_date, 
+			songs.text, 
+			songs.link,
+			songs.version,
+			songs.updated_at,
+			songs.deleted_at
+		FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id`
+
+// selectSongStats selects the songs with their stats, songs without a row in song_stats have zero stats
+const selectSongStats = `SELECT 
+			songs.id, 
+			group_songs.group_name, 
+			songs.song, 
+			songs.release_date, 
+			songs.text, 
+			songs.link,
+			songs.version,
+			songs.updated_at,
+			songs.deleted_at,
+			COALESCE(song_stats.favorites, 0) AS favorites,
+			COALESCE(song_stats.ratings, 0) AS ratings,
+			COALESCE(CAST(song_stats.rating_sum AS REAL) / NULLIF(song_stats.ratings, 0), 0) AS average_rating,
+			COALESCE(song_stats.plays, 0) AS plays
+		FROM songs INNER JOIN group_songs ON songs.id = group_songs.song_id
+		LEFT JOIN song_stats ON songs.id = song_stats.song_id`
+
+// selectFilteredSongs selects the songs matching models.SongFilter with their stats, ?7 and ?8 are left for
+// the pagination. LIKE escapes wildcards with a backslash like postgres does by default
+const selectFilteredSongs = selectSongStats + `
+      WHERE 
+          (?1 = '' OR songs.id = ?1) AND
+          (group_songs.group_name LIKE '%' || ?2 || '%' ESCAPE '\' OR ?2 = '') AND
+          (songs.song LIKE '%' || ?3 || '%' ESCAPE '\' OR ?3 = '') AND
+          (songs.release_date = ?4 OR ?4 IS NULL) AND
+          (songs.text LIKE '%' || ?5 || '%' ESCAPE '\' OR ?5 = '') AND
+          (songs.link = ?6 OR ?6 = '') AND
+          (songs.deleted_at IS NULL OR ?9) AND
+          COALESCE(song_stats.plays, 0) >= ?10 AND
+          COALESCE(CAST(song_stats.rating_sum AS REAL) / NULLIF(song_stats.ratings, 0), 0) >= ?11`
+
+// songOrders are the ORDER BY clauses of the sorts of models.SongFilter
+var songOrders = map[string]string{
+	"":                   "",
+	models.SortPlays:     "ORDER BY plays DESC, songs.id",
+	models.SortFavorites: "ORDER BY favorites DESC, songs.id",
+	models.SortRating:    "ORDER BY average_rating DESC, ratings DESC, songs.id",
+}
+
+func filterArgs(filter models.SongFilter) []any {
+	var releaseDate any
+	if filter.ReleaseDate != nil {
+		releaseDate = timestamp(*filter.ReleaseDate)
+	}
+
+	return []any{canonicalID(filter.SongID), filter.Group, filter.Song, releaseDate, filter.Text, filter.Link, filter.Off, filter.Lim,
+		filter.IncludeDeleted, filter.MinPlays, filter.MinRating}
+}
+
+// validatePagination fails like postgres does, sqlite treats negative limits as no limit instead
+func validatePagination(lim, off int) error {
+	if lim < 0 {
+		return utils.NewError("LIMIT must not be negative", utils.Internal)
+	}
+	if off < 0 {
+		return utils.NewError("OFFSET must not be negative", utils.Internal)
+	}
+	return nil
+}
+
+// lockSong reads the song and checks its version, 0 matches any version. The transaction holds the database
+// lock from its beginning, so the song can not change till the end of it. deleted selects whether the song
+// is looked up in the trash or among the live songs
+func lockSong(ctx context.Context, tx *sqlx.Tx, songID string, version int, deleted bool) (models.Song, error) {
+	songID = canonicalID(songID)
+	if songID == "" {
+		return models.Song{}, utils.NewError("song not found", utils.NotFound)
+	}
+
+	q := selectSongs + ` WHERE songs.id = ? AND (songs.deleted_at IS NOT NULL) = ?`
+
+	var row songRow
+	if err := tx.QueryRowxContext(ctx, q, songID, deleted).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Song{}, utils.NewError("song not found", utils.NotFound)
+		}
+		return models.Song{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if version != 0 && version != row.Version {
+		return models.Song{}, utils.NewError(
+			fmt.Sprintf("song version mismatch: expected: %d current: %d", version, row.Version), utils.PreconditionFailed)
+	}
+
+	return row.toModel(), nil
+}
+
+// insertSong creates the song with its first revision, the version and the update time are set to the song
+func insertSong(ctx context.Context, tx *sqlx.Tx, song *models.Song, now time.Time) error {
+	songID := canonicalID(song.SongID)
+	if songID == "" {
+		return utils.NewError(fmt.Sprintf("invalid song ID: %q", song.SongID), utils.Internal)
+	}
+
+	song.SongID, song.Version, song.UpdatedAt = songID, 1, now
+
+	q := `INSERT INTO songs (id, song, release_date, text, link, version, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := tx.ExecContext(ctx, q, song.SongID, song.Song, timestamp(song.Data.ReleaseDate), song.Data.Text, song.Data.Link,
+		song.Version, timestamp(song.UpdatedAt))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q = `INSERT INTO group_songs (song_id, group_name) VALUES (?, ?)`
+
+	if _, err = tx.ExecContext(ctx, q, song.SongID, song.Group); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return insertRevision(ctx, tx, models.RevisionCreate, nil, song, 0, now)
+}
+
+func editSong(ctx context.Context, tx *sqlx.Tx, song models.Song, now time.Time) error {
+	before, err := lockSong(ctx, tx, song.SongID, song.Version, false)
+	if err != nil {
+		return err
+	}
+
+	song.SongID, song.Version = before.SongID, before.Version
+	if err = updateSong(ctx, tx, &song, now); err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, models.RevisionEdit, &before, &song, 0, now)
+}
+
+// deleteSong moves the song to the trash, it stays there till it is restored or purged. The song is removed from
+// the playlists for good, restoring it does not bring it back to them
+func deleteSong(ctx context.Context, tx *sqlx.Tx, songID string, version int, now time.Time) error {
+	before, err := lockSong(ctx, tx, songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	q := `UPDATE songs SET deleted_at = ?2, version = version + 1, updated_at = ?2 WHERE id = ?1`
+
+	if _, err = tx.ExecContext(ctx, q, before.SongID, timestamp(now)); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = removeFromPlaylists(ctx, tx, before.SongID, now); err != nil {
+		return err
+	}
+
+	return insertRevision(ctx, tx, models.RevisionDelete, &before, nil, 0, now)
+}
+
+// updateSong overwrites the song data and bumps the version the song holds, the new version is set to the song
+func updateSong(ctx context.Context, tx *sqlx.Tx, song *models.Song, now time.Time) error {
+	song.Version, song.UpdatedAt = song.Version+1, now
+
+	q := `UPDATE songs SET song = ?, release_date = ?, text = ?, link = ?, version = ?, updated_at = ? WHERE id = ?`
+
+	_, err := tx.ExecContext(ctx, q, song.Song, timestamp(song.Data.ReleaseDate), song.Data.Text, song.Data.Link,
+		song.Version, timestamp(song.UpdatedAt), song.SongID)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	q = `UPDATE group_songs SET group_name = ? WHERE song_id = ?`
+
+	if _, err = tx.ExecContext(ctx, q, song.Group, song.SongID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+// canonicalID returns the song ID in the form the songs are stored with, IDs that are not UUIDs can not
+// belong to any song and are returned empty
+func canonicalID(songID string) string {
+	id, err := uuid.Parse(songID)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// timeFormat has a fixed width, so the stored times compare as strings in the order of time
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+// timestamp converts the time like a postgres timestamp column does: the wall clock is kept without the zone
+// and the precision is microseconds
+func timestamp(t time.Time) string {
+	return t.Format(timeFormat)
+}
+
+// now is the time of the changes of a transaction, like now() of a postgres server in UTC
+func now() time.Time {
+	t, _ := time.Parse(timeFormat, timestamp(time.Now().UTC()))
+	return t
+}
+
+type songRow struct {
+	SongID      string       `db:"id"`
+	Group       string       `db:"group_name"`
+	Song        string       `db:"song"`
+	ReleaseDate time.Time    `db:"release_date"`
+	Text        string       `db:"text"`
+	Link        string       `db:"link"`
+	Version     int          `db:"version"`
+	UpdatedAt   time.Time    `db:"updated_at"`
+	DeletedAt   sql.NullTime `db:"deleted_at"`
+}
+
+func (s songRow) toModel() models.Song {
+	song := models.Song{
+		SongID: s.SongID,
+		Group:  s.Group,
+		Song:   s.Song,
+		Data: models.SongData{
+			ReleaseDate: s.ReleaseDate,
+			Text:        s.Text,
+			Link:        s.Link,
+		},
+		Version:   s.Version,
+		UpdatedAt: s.UpdatedAt,
+	}
+
+	if s.DeletedAt.Valid {
+		song.DeletedAt = &s.DeletedAt.Time
+	}
+
+	return song
+}
+
+// songStatsRow is a song selected with selectSongStats
+type songStatsRow struct {
+	songRow
+	Favorites     int64   `db:"favorites"`
+	Ratings       int64   `db:"ratings"`
+	AverageRating float64 `db:"average_rating"`
+	Plays         int64   `db:"plays"`
+}
+
+func (s songStatsRow) toModel() models.Song {
+	song := s.songRow.toModel()
+	song.Stats = &models.SongStats{
+		Favorites:     s.Favorites,
+		Ratings:       s.Ratings,
+		AverageRating: s.AverageRating,
+		Plays:         s.Plays,
+	}
+
+	return song
+}
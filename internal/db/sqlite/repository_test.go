@@ -0,0 +1,100 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/alserok/music_lib/internal/db/repotest"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/mocks"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformanceSuite(t *testing.T) {
+	suite.Run(t, &repotest.Suite{
+		NewRepository: func(s *suite.Suite) (db.Repository, func()) {
+			conn := MustConnect(filepath.Join(s.T().TempDir(), "music_lib.db"))
+			return NewRepository(conn), func() {
+				_ = conn.Close()
+			}
+		},
+	})
+}
+
+func TestRepositorySuite(t *testing.T) {
+	suite.Run(t, new(RepositorySuite))
+}
+
+type RepositorySuite struct {
+	suite.Suite
+
+	ctrl   *gomock.Controller
+	logger *mocks.MockLogger
+
+	path string
+}
+
+func (suite *RepositorySuite) SetupTest() {
+	suite.ctrl = gomock.NewController(suite.T())
+	suite.logger = mocks.NewMockLogger(suite.ctrl)
+
+	suite.path = filepath.Join(suite.T().TempDir(), "music_lib.db")
+}
+
+func (suite *RepositorySuite) TearDownTest() {
+	suite.ctrl.Finish()
+}
+
+func (suite *RepositorySuite) TestMigrations() {
+	conn := MustOpen(suite.path)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Any()).
+		AnyTimes()
+
+	ctx := logger.WrapLogger(context.Background(), suite.logger)
+	ctx = logger.WrapIdentifier(ctx)
+
+	repo := NewRepository(conn)
+
+	migrations, err := repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(migrations)
+	suite.Require().False(migrations[0].Applied)
+
+	suite.Require().NoError(repo.MigrateUp(ctx, 0))
+	suite.Require().NoError(repo.CreateSong(ctx, models.Song{SongID: "00000000-0000-0000-0000-000000000001", Song: "song", Group: "group"}))
+
+	migrations, err = repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	suite.Require().True(migrations[len(migrations)-1].Applied)
+	suite.Require().NotNil(migrations[0].AppliedAt)
+
+	suite.Require().NoError(repo.MigrateDown(ctx, 0))
+
+	migrations, err = repo.GetMigrations(ctx)
+	suite.Require().NoError(err)
+	suite.Require().False(migrations[0].Applied)
+
+	// the data is gone with the schema
+	suite.Require().NoError(repo.MigrateUp(ctx, 0))
+
+	songs, err := repo.GetSongs(ctx, models.SongFilter{Lim: 10})
+	suite.Require().NoError(err)
+	suite.Require().Empty(songs)
+}
+
+func (suite *RepositorySuite) TestReindex() {
+	conn := MustConnect(suite.path)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	suite.Require().NoError(Reindex(context.Background(), conn))
+}
@@ -0,0 +1,260 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+func (r *repository) RestoreSong(ctx context.Context, songID string, revision int, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	before, err := lockSong(ctx, tx, songID, version, false)
+	if err != nil {
+		return err
+	}
+
+	q := selectRevisions + ` WHERE song_id = ? AND version = ?`
+
+	target, err := getRevision(ctx, tx, q, before.SongID, revision)
+	if err != nil {
+		return err
+	}
+	if target.After == nil {
+		return utils.NewError("revision deletes the song and can not be restored", utils.BadRequest)
+	}
+
+	now := now()
+
+	song := *target.After
+	song.SongID, song.Version = before.SongID, before.Version
+	if err = updateSong(ctx, tx, &song, now); err != nil {
+		return err
+	}
+
+	if err = insertRevision(ctx, tx, models.RevisionRestore, &before, &song, revision, now); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := selectRevisions + ` WHERE song_id = ?
+			ORDER BY version DESC LIMIT ? OFFSET ?`
+
+	songID = canonicalID(songID)
+	if songID == "" {
+		return []models.SongRevision{}, nil
+	}
+
+	if err := validatePagination(lim, off); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryxContext(ctx, q, songID, lim, off)
+	if err != nil {
+		return nil, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	revisions := make([]models.SongRevision, 0, lim)
+	for rows.Next() {
+		var row revisionRow
+		if err = rows.StructScan(&row); err != nil {
+			return nil, utils.NewError(err.Error(), utils.Internal)
+		}
+
+		revision, err := row.toModel()
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revisions, nil
+}
+
+func (r *repository) GetSongRevision(ctx context.Context, songID string, version int) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := selectRevisions + ` WHERE song_id = ? AND version = ?`
+
+	revision, err := getRevision(ctx, r.db, q, songID, version)
+	if err != nil {
+		return models.SongRevision{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevision",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revision, nil
+}
+
+func (r *repository) GetSongRevisionAt(ctx context.Context, songID string, at time.Time) (models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSongRevisionAt",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := selectRevisions + ` WHERE song_id = ? AND created_at <= ?
+			ORDER BY version DESC LIMIT 1`
+
+	revision, err := getRevision(ctx, r.db, q, songID, timestamp(at))
+	if err != nil {
+		return models.SongRevision{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSongRevisionAt",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return revision, nil
+}
+
+const selectRevisions = `SELECT song_id, version, action, author, created_at, before, after, restored_from FROM song_revisions`
+
+// getRevision runs the revision query, the song ID must be the first argument
+func getRevision(ctx context.Context, db sqlx.QueryerContext, q string, songID string, args ...any) (models.SongRevision, error) {
+	songID = canonicalID(songID)
+	if songID == "" {
+		return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+	}
+
+	var row revisionRow
+	if err := db.QueryRowxContext(ctx, q, append([]any{songID}, args...)...).StructScan(&row); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SongRevision{}, utils.NewError("revision not found", utils.NotFound)
+		}
+		return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return row.toModel()
+}
+
+// insertRevision records the change, the revision takes the version of the after state or the next one for deletes
+func insertRevision(ctx context.Context, tx *sqlx.Tx, action string, before, after *models.Song, restoredFrom int, now time.Time) error {
+	var (
+		songID                string
+		version               int
+		beforeJSON, afterJSON any
+		restoredFromValue     sql.NullInt64
+	)
+
+	if before != nil {
+		songID, version = before.SongID, before.Version+1
+		b, err := json.Marshal(before)
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+		beforeJSON = string(b)
+	}
+	if after != nil {
+		// the stats sent with the song are not part of its versions
+		snapshot := *after
+		snapshot.Stats = nil
+		songID, version = after.SongID, after.Version
+		b, err := json.Marshal(snapshot)
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+		afterJSON = string(b)
+	}
+	if restoredFrom != 0 {
+		restoredFromValue = sql.NullInt64{Int64: int64(restoredFrom), Valid: true}
+	}
+
+	q := `INSERT INTO song_revisions (song_id, version, action, author, created_at, before, after, restored_from)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := tx.ExecContext(ctx, q, songID, version, action, actor.ExtractActor(ctx), timestamp(now), beforeJSON, afterJSON,
+		restoredFromValue)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return nil
+}
+
+type revisionRow struct {
+	SongID       string        `db:"song_id"`
+	Version      int           `db:"version"`
+	Action       string        `db:"action"`
+	Author       string        `db:"author"`
+	CreatedAt    time.Time     `db:"created_at"`
+	Before       []byte        `db:"before"`
+	After        []byte        `db:"after"`
+	RestoredFrom sql.NullInt64 `db:"restored_from"`
+}
+
+func (r revisionRow) toModel() (models.SongRevision, error) {
+	revision := models.SongRevision{
+		SongID:       r.SongID,
+		Version:      r.Version,
+		Action:       r.Action,
+		Author:       r.Author,
+		CreatedAt:    r.CreatedAt,
+		RestoredFrom: int(r.RestoredFrom.Int64),
+	}
+
+	if r.Before != nil {
+		revision.Before = new(models.Song)
+		if err := json.Unmarshal(r.Before, revision.Before); err != nil {
+			return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+	if r.After != nil {
+		revision.After = new(models.Song)
+		if err := json.Unmarshal(r.After, revision.After); err != nil {
+			return models.SongRevision{}, utils.NewError(err.Error(), utils.Internal)
+		}
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dsnParams enable the foreign keys and postgres-like case sensitive LIKE on every connection. Writes take the
+// database lock when their transaction begins and wait for it instead of failing, WAL lets reads go on meanwhile
+const dsnParams = "_fk=1&_cslike=1&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// MustConnect opens the database file and applies pending migrations, the embedded ones unless dir is set
+func MustConnect(path string, dir ...string) *sqlx.DB {
+	conn := MustOpen(path)
+
+	if err := MigrateUp(conn, dir...); err != nil {
+		panic("failed to migrate: " + err.Error())
+	}
+
+	return conn
+}
+
+// MustOpen opens the database file without applying migrations, the file is created if it does not exist
+func MustOpen(path string) *sqlx.DB {
+	conn, err := sqlx.Connect("sqlite3", fmt.Sprintf("file:%s?%s", path, dsnParams))
+	if err != nil {
+		panic("failed to open database: " + path)
+	}
+
+	return conn
+}
+
+// reindexTables are rebuilt by Reindex
+var reindexTables = []string{"songs", "group_songs", "song_revisions", "users", "sessions", "api_keys", "playlists",
+	"playlist_collaborators", "playlist_songs", "favorites", "ratings", "plays", "song_stats", "song_neighbors"}
+
+// Reindex rebuilds the indexes of the library tables and refreshes the planner statistics
+func Reindex(ctx context.Context, conn *sqlx.DB) error {
+	for _, table := range reindexTables {
+		if _, err := conn.ExecContext(ctx, "REINDEX "+table); err != nil {
+			return fmt.Errorf("failed to reindex %s: %w", table, err)
+		}
+
+		if _, err := conn.ExecContext(ctx, "ANALYZE "+table); err != nil {
+			return fmt.Errorf("failed to analyze %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
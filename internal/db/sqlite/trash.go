@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"time"
+)
+
+func (r *repository) RestoreDeletedSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	song, err := lockSong(ctx, tx, songID, version, true)
+	if err != nil {
+		return err
+	}
+
+	now := now()
+	song.Version, song.UpdatedAt, song.DeletedAt = song.Version+1, now, nil
+
+	q := `UPDATE songs SET deleted_at = NULL, version = ?, updated_at = ? WHERE id = ?`
+
+	if _, err = tx.ExecContext(ctx, q, song.Version, timestamp(song.UpdatedAt), song.SongID); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = insertRevision(ctx, tx, models.RevisionUndelete, nil, &song, 0, now); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	// the groups and revisions of the songs are removed by the cascade
+	q := `DELETE FROM songs WHERE deleted_at < ?`
+
+	res, err := r.db.ExecContext(ctx, q, timestamp(now().Add(-retention)))
+	if err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return purged, nil
+}
@@ -0,0 +1,235 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const selectUsers = `SELECT id, name, display_name, email, role, password_hash, created_at, updated_at FROM users`
+
+func (r *repository) CreateUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	now := timestamp(now())
+	q := `INSERT INTO users (id, name, display_name, email, role, password_hash, created_at, updated_at)
+	  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, q, canonicalID(user.UserID), user.Name, user.DisplayName, user.Email, user.Role,
+		user.PasswordHash, now, now)
+	if err != nil {
+		if isConstraintViolation(err, sqlite3.ErrConstraintUnique) {
+			return utils.NewError("user name is taken", utils.Conflict)
+		}
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) GetUser(ctx context.Context, userID string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := r.getUser(ctx, selectUsers+` WHERE id = ?`, canonicalID(userID))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetUserByName",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := r.getUser(ctx, selectUsers+` WHERE name = ?`, name)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetUserByName",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) EditUser(ctx context.Context, user models.User) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `UPDATE users SET display_name = ?, email = ?, role = ?, password_hash = ?, updated_at = ? WHERE id = ?`
+
+	res, err := r.db.ExecContext(ctx, q, user.DisplayName, user.Email, user.Role, user.PasswordHash, timestamp(now()),
+		canonicalID(user.UserID))
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	} else if n == 0 {
+		return utils.NewError("user not found", utils.NotFound)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed EditUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) CreateSession(ctx context.Context, tokenHash string, userID string, ttl time.Duration) (time.Time, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	userID = canonicalID(userID)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := now()
+
+	q := `DELETE FROM sessions WHERE user_id = ? AND expires_at <= ?`
+
+	if _, err = tx.ExecContext(ctx, q, userID, timestamp(now)); err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	expiresAt := now.Add(ttl).Truncate(time.Microsecond)
+	q = `INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`
+
+	if _, err = tx.ExecContext(ctx, q, tokenHash, userID, timestamp(now), timestamp(expiresAt)); err != nil {
+		if isConstraintViolation(err, sqlite3.ErrConstraintForeignKey) {
+			return time.Time{}, utils.NewError("user not found", utils.NotFound)
+		}
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return time.Time{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed CreateSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return expiresAt, nil
+}
+
+func (r *repository) GetSessionUser(ctx context.Context, tokenHash string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("repo received GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	q := `SELECT users.id, users.name, users.display_name, users.email, users.role, users.password_hash, users.created_at,
+       users.updated_at
+	  FROM sessions
+	  JOIN users ON users.id = sessions.user_id
+	  WHERE sessions.token_hash = ? AND sessions.expires_at > ?`
+
+	user, err := r.getUser(ctx, q, tokenHash, timestamp(now()))
+	if err != nil {
+		if utils.IsNotFound(err) {
+			return models.User{}, utils.NewError("session not found", utils.NotFound)
+		}
+		return models.User{}, err
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed GetSessionUser",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return user, nil
+}
+
+func (r *repository) DeleteSession(ctx context.Context, tokenHash string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, tokenHash); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteSession",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) DeleteUserSessions(ctx context.Context, userID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("repo received DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, canonicalID(userID)); err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+
+	logger.ExtractLogger(ctx).
+		Debug("repo passed DeleteUserSessions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	return nil
+}
+
+func (r *repository) getUser(ctx context.Context, q string, args ...any) (models.User, error) {
+	var user models.User
+	if err := r.db.QueryRowxContext(ctx, q, args...).StructScan(&user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, utils.NewError("user not found", utils.NotFound)
+		}
+		return models.User{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return user, nil
+}
+
+// isConstraintViolation reports whether the error is a violation of the constraint kind
+func isConstraintViolation(err error, code sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == code
+}
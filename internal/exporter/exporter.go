@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Song fields that can be selected for the export, the names match the import columns
+const (
+	FieldSongID      = "songID"
+	FieldGroup       = "group"
+	FieldSong        = "song"
+	FieldReleaseDate = "releaseDate"
+	FieldText        = "text"
+	FieldLink        = "link"
+	FieldVersion     = "version"
+	FieldUpdatedAt   = "updatedAt"
+)
+
+// Fields are all fields in the export order
+var Fields = []string{FieldSongID, FieldGroup, FieldSong, FieldReleaseDate, FieldText, FieldLink, FieldVersion, FieldUpdatedAt}
+
+// dataFields are nested into the data object of JSONL songs like in models.Song
+var dataFields = []string{FieldReleaseDate, FieldText, FieldLink}
+
+// Writer encodes songs one by one, Close flushes buffered songs and must be called after the last one
+type Writer interface {
+	Write(song models.Song) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format string, fields []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := &csvWriter{w: csv.NewWriter(w), fields: fields}
+		if err := cw.w.Write(fields); err != nil {
+			return nil, err
+		}
+		return cw, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w), fields: fields}, nil
+	default:
+		return nil, utils.NewError("invalid format, expected csv or jsonl", utils.BadRequest)
+	}
+}
+
+// ContentType returns the media type of the format
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// ParseFields parses a comma separated field list, all fields are selected if s is empty
+func ParseFields(s string) ([]string, error) {
+	if s == "" {
+		return Fields, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if !slices.Contains(Fields, field) {
+			return nil, utils.NewError(fmt.Sprintf("unknown field: %s, expected one of %s", field, strings.Join(Fields, ", ")), utils.BadRequest)
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	fields []string
+}
+
+func (c *csvWriter) Write(song models.Song) error {
+	record := make([]string, len(c.fields))
+	for i, field := range c.fields {
+		record[i] = fieldValue(song, field)
+	}
+
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc    *json.Encoder
+	fields []string
+}
+
+func (j *jsonlWriter) Write(song models.Song) error {
+	obj := make(map[string]any, len(j.fields))
+	data := make(map[string]any, len(dataFields))
+	for _, field := range j.fields {
+		var val any
+		switch field {
+		case FieldVersion:
+			val = song.Version
+		case FieldUpdatedAt:
+			val = song.UpdatedAt
+		default:
+			val = fieldValue(song, field)
+		}
+
+		if slices.Contains(dataFields, field) {
+			data[field] = val
+		} else {
+			obj[field] = val
+		}
+	}
+	if len(data) > 0 {
+		obj["data"] = data
+	}
+
+	return j.enc.Encode(obj)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+func fieldValue(song models.Song, field string) string {
+	switch field {
+	case FieldSongID:
+		return song.SongID
+	case FieldGroup:
+		return song.Group
+	case FieldSong:
+		return song.Song
+	case FieldReleaseDate:
+		if song.Data.ReleaseDate.IsZero() {
+			return ""
+		}
+		return song.Data.ReleaseDate.Format(time.DateOnly)
+	case FieldText:
+		return song.Data.Text
+	case FieldLink:
+		return song.Data.Link
+	case FieldVersion:
+		return strconv.Itoa(song.Version)
+	case FieldUpdatedAt:
+		return song.UpdatedAt.UTC().Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
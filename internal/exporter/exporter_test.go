@@ -0,0 +1,68 @@
+package exporter
+
+import (
+	"bytes"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+func TestExporterSuite(t *testing.T) {
+	suite.Run(t, new(ExporterSuite))
+}
+
+type ExporterSuite struct {
+	suite.Suite
+}
+
+var song = models.Song{
+	SongID: "id",
+	Group:  "Muse",
+	Song:   "Uprising",
+	Data: models.SongData{
+		ReleaseDate: time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC),
+		Text:        "Paranoia is in bloom,\nthe PR transmissions will resume",
+		Link:        "https://example.com",
+	},
+	Version:   2,
+	UpdatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+}
+
+func (suite *ExporterSuite) TestCSV() {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, FormatCSV, Fields)
+	suite.Require().NoError(err)
+	suite.Require().NoError(w.Write(song))
+	suite.Require().NoError(w.Write(models.Song{SongID: "id2", Group: "Muse", Song: "Starlight"}))
+	suite.Require().NoError(w.Close())
+
+	suite.Equal("songID,group,song,releaseDate,text,link,version,updatedAt\n"+
+		"id,Muse,Uprising,2009-09-07,\"Paranoia is in bloom,\nthe PR transmissions will resume\",https://example.com,2,2024-01-01T12:00:00Z\n"+
+		"id2,Muse,Starlight,,,,0,0001-01-01T00:00:00Z\n", buf.String())
+}
+
+func (suite *ExporterSuite) TestJSONL() {
+	var buf bytes.Buffer
+
+	fields, err := ParseFields("group, song,link,group")
+	suite.Require().NoError(err)
+
+	w, err := NewWriter(&buf, FormatJSONL, fields)
+	suite.Require().NoError(err)
+	suite.Require().NoError(w.Write(song))
+	suite.Require().NoError(w.Write(song))
+	suite.Require().NoError(w.Close())
+
+	line := `{"data":{"link":"https://example.com"},"group":"Muse","song":"Uprising"}` + "\n"
+	suite.Equal(line+line, buf.String())
+}
+
+func (suite *ExporterSuite) TestInvalidOptions() {
+	_, err := ParseFields("group,album")
+	suite.Error(err)
+
+	_, err = NewWriter(&bytes.Buffer{}, "xlsx", Fields)
+	suite.Error(err)
+}
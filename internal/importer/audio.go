@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/tags"
+	"github.com/alserok/music_lib/internal/utils"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// audioExtensions are the files ReadDir reads tags of, other files are ignored
+var audioExtensions = map[string]bool{
+	".mp3":  true,
+	".flac": true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".m4a":  true,
+	".mp4":  true,
+}
+
+// ReadDir walks the directory and reads the tags of audio files into rows. The row source is the file path
+// relative to root and the song link is the file URL. Files with broken tags are returned with Err set
+func ReadDir(root string) ([]models.ImportRow, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.ImportRow
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !audioExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		row := models.ImportRow{Source: filepath.ToSlash(rel)}
+		row.Song, row.Err = readAudioFile(path)
+
+		rows = append(rows, row)
+		if len(rows) > MaxRows {
+			return utils.NewError(fmt.Sprintf("directory has more than %d audio files", MaxRows), utils.BadRequest)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func readAudioFile(path string) (models.Song, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return models.Song{}, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	t, err := tags.Read(file)
+	if err != nil {
+		return models.Song{}, utils.NewError(fmt.Sprintf("failed to read tags: %s", err.Error()), utils.BadRequest)
+	}
+
+	return models.Song{
+		Group: t.Artist,
+		Song:  t.Title,
+		Data: models.SongData{
+			ReleaseDate: t.Date,
+			Text:        t.Lyrics,
+			Link:        (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String(),
+		},
+	}, nil
+}
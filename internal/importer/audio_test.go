@@ -0,0 +1,39 @@
+package importer
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"os"
+	"path/filepath"
+)
+
+func (suite *ImporterSuite) TestReadDir() {
+	root := suite.T().TempDir()
+	suite.Require().NoError(os.MkdirAll(filepath.Join(root, "Muse"), 0o755))
+
+	// the ID3v1 tag of an MP3 file without ID3v2
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:], "Uprising")
+	copy(tag[33:], "Muse")
+	copy(tag[93:], "2009")
+	mp3 := append([]byte{0xFF, 0xFB, 0x90, 0x64}, make([]byte, 413)...)
+
+	suite.Require().NoError(os.WriteFile(filepath.Join(root, "Muse", "01 Uprising.MP3"), append(mp3, tag...), 0o644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(root, "broken.flac"), []byte("not a flac file"), 0o644))
+	suite.Require().NoError(os.WriteFile(filepath.Join(root, "cover.jpg"), []byte("image"), 0o644))
+
+	rows, err := ReadDir(root)
+	suite.Require().NoError(err)
+	suite.Require().Len(rows, 2)
+
+	suite.Equal("Muse/01 Uprising.MP3", rows[0].Source)
+	suite.Require().NoError(rows[0].Err)
+	suite.Equal("Muse", rows[0].Song.Group)
+	suite.Equal("Uprising", rows[0].Song.Song)
+	suite.Equal(2009, rows[0].Song.Data.ReleaseDate.Year())
+	suite.Equal("file://"+filepath.ToSlash(filepath.Join(root, "Muse", "01%20Uprising.MP3")), rows[0].Song.Data.Link)
+
+	suite.Equal("broken.flac", rows[1].Source)
+	suite.Require().Error(rows[1].Err)
+	suite.Equal(models.Song{}, rows[1].Song)
+}
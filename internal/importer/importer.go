@@ -0,0 +1,329 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Song fields that can be mapped to CSV columns
+const (
+	FieldGroup       = "group"
+	FieldSong        = "song"
+	FieldReleaseDate = "releaseDate"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+const (
+	// MaxRows limits the number of songs in a single import
+	MaxRows = 100000
+	// MaxEnrichedRows limits the number of songs in a single import through the API that requests the song data,
+	// larger files are enriched with the command line
+	MaxEnrichedRows = 1000
+	// MaxFileSize limits the size of the files imported through the API
+	MaxFileSize = 32 << 20
+
+	// maxLineSize limits a single JSONL line, song texts may be long
+	maxLineSize = 1 << 20
+)
+
+var fields = []string{FieldGroup, FieldSong, FieldReleaseDate, FieldText, FieldLink}
+
+// dateLayouts are the accepted release date formats, the first one is what the song data API returns
+var dateLayouts = []string{"02.01.2006", time.DateOnly, time.RFC3339}
+
+type Options struct {
+	// Format is FormatCSV or FormatJSONL
+	Format string
+	// Delimiter separates CSV fields, ',' if empty
+	Delimiter rune
+	// Encoding is the charset of the input like windows-1251, UTF-8 if empty
+	Encoding string
+	// Columns maps song fields to CSV header names, unmapped fields are looked up by their own names
+	Columns map[string]string
+}
+
+// Read parses all rows of the input. Rows that can not be parsed are returned with Err set,
+// an error is returned only if the input can not be read at all
+func Read(r io.Reader, opts Options) ([]models.ImportRow, error) {
+	if opts.Encoding != "" {
+		enc, err := htmlindex.Get(opts.Encoding)
+		if err != nil {
+			return nil, utils.NewError(fmt.Sprintf("unsupported encoding: %s", opts.Encoding), utils.BadRequest)
+		}
+		r = transform.NewReader(r, enc.NewDecoder())
+	}
+
+	switch opts.Format {
+	case FormatCSV:
+		return readCSV(r, opts)
+	case FormatJSONL:
+		return readJSONL(r)
+	default:
+		return nil, utils.NewError("invalid format, expected csv or jsonl", utils.BadRequest)
+	}
+}
+
+// FormatFromName guesses the format by the file extension, returns an empty string for unknown extensions
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return ""
+	}
+}
+
+// ParseDelimiter accepts a single character, `\t` and "tab" stand for the tab character
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, utils.NewError("delimiter must be a single character", utils.BadRequest)
+	}
+
+	delimiter, _ := utf8.DecodeRuneInString(s)
+	return delimiter, nil
+}
+
+// ParseColumns parses the column mapping in the "group=Artist,song=Title" form
+func ParseColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if s == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, utils.NewError(fmt.Sprintf("invalid column mapping: %s", pair), utils.BadRequest)
+		}
+		if !slices.Contains(fields, field) {
+			return nil, utils.NewError(fmt.Sprintf("unknown field: %s, expected one of %s", field, strings.Join(fields, ", ")), utils.BadRequest)
+		}
+
+		columns[field] = column
+	}
+
+	return columns, nil
+}
+
+// NewReport counts the results, reason converts errors to the messages shown to the user
+func NewReport(results []models.ImportResult, reason func(error) string) models.ImportReport {
+	report := models.ImportReport{
+		Total: len(results),
+		Rows:  make([]models.ImportRowStatus, len(results)),
+	}
+
+	for i, res := range results {
+		switch res.Status {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportSkipped:
+			report.Skipped++
+		case models.ImportFailed:
+			report.Failed++
+		}
+
+		report.Rows[i] = models.ImportRowStatus{Line: res.Line, Source: res.Source, Status: res.Status, SongID: res.SongID}
+		if res.Err != nil {
+			report.Rows[i].Reason = reason(res.Err)
+		}
+	}
+
+	return report
+}
+
+func readCSV(r io.Reader, opts Options) ([]models.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, utils.NewError("file is empty", utils.BadRequest)
+		}
+		return nil, utils.NewError(fmt.Sprintf("failed to read header: %s", err.Error()), utils.BadRequest)
+	}
+
+	indexes, err := columnIndexes(header, opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			rows = append(rows, models.ImportRow{Line: line, Err: utils.NewError(
+				fmt.Sprintf("expected %d fields, got %d", len(header), len(record)), utils.BadRequest)})
+		} else if err != nil {
+			return nil, utils.NewError(fmt.Sprintf("failed to read csv: %s", err.Error()), utils.BadRequest)
+		} else {
+			rows = append(rows, csvRow(line, record, indexes))
+		}
+
+		if len(rows) > MaxRows {
+			return nil, utils.NewError(fmt.Sprintf("file has more than %d rows", MaxRows), utils.BadRequest)
+		}
+	}
+
+	return rows, nil
+}
+
+// columnIndexes finds the header positions of the song fields, the group and song columns are required
+func columnIndexes(header []string, columns map[string]string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	indexes := make(map[string]int, len(fields))
+	for _, field := range fields {
+		column := field
+		if mapped, ok := columns[field]; ok {
+			column = mapped
+		}
+
+		i, ok := positions[strings.ToLower(column)]
+		if !ok {
+			if field == FieldGroup || field == FieldSong {
+				return nil, utils.NewError(fmt.Sprintf("missing column %s for field %s", column, field), utils.BadRequest)
+			}
+			continue
+		}
+
+		indexes[field] = i
+	}
+
+	return indexes, nil
+}
+
+func csvRow(line int, record []string, indexes map[string]int) models.ImportRow {
+	value := func(field string) string {
+		i, ok := indexes[field]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := models.ImportRow{
+		Line: line,
+		Song: models.Song{
+			Group: value(FieldGroup),
+			Song:  value(FieldSong),
+			Data: models.SongData{
+				Text: value(FieldText),
+				Link: value(FieldLink),
+			},
+		},
+	}
+	row.Song.Data.ReleaseDate, row.Err = parseDate(value(FieldReleaseDate))
+
+	return row
+}
+
+// jsonlSong is models.Song with a loosely formatted release date, so exported songs can be imported back
+type jsonlSong struct {
+	Group string `json:"group"`
+	Song  string `json:"song"`
+	Data  struct {
+		ReleaseDate string `json:"releaseDate"`
+		Text        string `json:"text"`
+		Link        string `json:"link"`
+	} `json:"data"`
+}
+
+func readJSONL(r io.Reader) ([]models.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var rows []models.ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		row := models.ImportRow{Line: line}
+
+		var song jsonlSong
+		if err := json.Unmarshal(scanner.Bytes(), &song); err != nil {
+			row.Err = utils.NewError(fmt.Sprintf("invalid json: %s", err.Error()), utils.BadRequest)
+		} else {
+			row.Song = models.Song{
+				Group: strings.TrimSpace(song.Group),
+				Song:  strings.TrimSpace(song.Song),
+				Data: models.SongData{
+					Text: song.Data.Text,
+					Link: strings.TrimSpace(song.Data.Link),
+				},
+			}
+			row.Song.Data.ReleaseDate, row.Err = parseDate(strings.TrimSpace(song.Data.ReleaseDate))
+		}
+
+		rows = append(rows, row)
+		if len(rows) > MaxRows {
+			return nil, utils.NewError(fmt.Sprintf("file has more than %d rows", MaxRows), utils.BadRequest)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, utils.NewError(fmt.Sprintf("failed to read jsonl: %s", err.Error()), utils.BadRequest)
+	}
+
+	return rows, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, utils.NewError(fmt.Sprintf("invalid release date: %s", s), utils.BadRequest)
+}
@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"bytes"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/encoding/charmap"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImporterSuite(t *testing.T) {
+	suite.Run(t, new(ImporterSuite))
+}
+
+type ImporterSuite struct {
+	suite.Suite
+}
+
+func (suite *ImporterSuite) TestReadCSV() {
+	input := "\ufeffArtist;Title;Released;Lyrics\n" +
+		"Muse;Supermassive Black Hole;16.07.2006;\"Oh baby; don't you know\"\n" +
+		"Muse;Starlight\n" +
+		"Muse;Uprising;not a date;text\n" +
+		";;;\n"
+
+	columns, err := ParseColumns("group=Artist, song=Title,releaseDate=Released,text=Lyrics")
+	suite.Require().NoError(err)
+
+	rows, err := Read(strings.NewReader(input), Options{Format: FormatCSV, Delimiter: ';', Columns: columns})
+	suite.Require().NoError(err)
+	suite.Require().Len(rows, 4)
+
+	suite.Require().NoError(rows[0].Err)
+	suite.Equal(2, rows[0].Line)
+	suite.Equal(models.Song{
+		Group: "Muse",
+		Song:  "Supermassive Black Hole",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC),
+			Text:        "Oh baby; don't you know",
+		},
+	}, rows[0].Song)
+
+	suite.Error(rows[1].Err)
+	suite.Equal(3, rows[1].Line)
+	suite.Error(rows[2].Err)
+	suite.Equal(4, rows[2].Line)
+
+	// validation of required fields is up to the service
+	suite.NoError(rows[3].Err)
+}
+
+func (suite *ImporterSuite) TestReadCSVEncoding() {
+	input, err := charmap.Windows1251.NewEncoder().String("group,song\nКино,Группа крови\n")
+	suite.Require().NoError(err)
+
+	rows, err := Read(bytes.NewReader([]byte(input)), Options{Format: FormatCSV, Encoding: "windows-1251"})
+	suite.Require().NoError(err)
+	suite.Require().Len(rows, 1)
+	suite.Equal("Кино", rows[0].Song.Group)
+	suite.Equal("Группа крови", rows[0].Song.Song)
+
+	_, err = Read(strings.NewReader(input), Options{Format: FormatCSV, Encoding: "unknown"})
+	suite.Error(err)
+}
+
+func (suite *ImporterSuite) TestReadCSVMissingColumn() {
+	_, err := Read(strings.NewReader("artist,song\nMuse,Uprising\n"), Options{Format: FormatCSV})
+	suite.Error(err)
+
+	_, err = Read(strings.NewReader(""), Options{Format: FormatCSV})
+	suite.Error(err)
+}
+
+func (suite *ImporterSuite) TestReadJSONL() {
+	input := `{"group":"Muse","song":"Uprising","data":{"releaseDate":"2009-09-07T00:00:00Z","link":"https://example.com"}}` + "\n" +
+		"\n" +
+		`{"group":"Muse","song":"Starlight","data":{"releaseDate":"2006-09-04"}}` + "\n" +
+		`{"group":"Muse"` + "\n"
+
+	rows, err := Read(strings.NewReader(input), Options{Format: FormatJSONL})
+	suite.Require().NoError(err)
+	suite.Require().Len(rows, 3)
+
+	suite.Require().NoError(rows[0].Err)
+	suite.Equal(1, rows[0].Line)
+	suite.Equal("https://example.com", rows[0].Song.Data.Link)
+	suite.Equal(time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC), rows[0].Song.Data.ReleaseDate)
+
+	suite.Require().NoError(rows[1].Err)
+	suite.Equal(3, rows[1].Line)
+	suite.Equal(time.Date(2006, 9, 4, 0, 0, 0, 0, time.UTC), rows[1].Song.Data.ReleaseDate)
+
+	suite.Error(rows[2].Err)
+	suite.Equal(4, rows[2].Line)
+}
+
+func (suite *ImporterSuite) TestParseOptions() {
+	delimiter, err := ParseDelimiter("tab")
+	suite.Require().NoError(err)
+	suite.Equal('\t', delimiter)
+
+	_, err = ParseDelimiter(";;")
+	suite.Error(err)
+
+	_, err = ParseColumns("album=Album")
+	suite.Error(err)
+
+	_, err = ParseColumns("group")
+	suite.Error(err)
+
+	suite.Equal(FormatCSV, FormatFromName("catalogue.CSV"))
+	suite.Equal(FormatJSONL, FormatFromName("catalogue.jsonl"))
+	suite.Empty(FormatFromName("catalogue.xlsx"))
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"time"
+)
+
+// RunKeySetReload reloads the JWT keys from the JSON Web Key Set file every interval till ctx is done, the keys
+// are kept if the file can not be loaded
+func RunKeySetReload(ctx context.Context, log logger.Logger, verifier *auth.JWTVerifier, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		keys, err := auth.LoadKeySet(path)
+		if err != nil {
+			log.Error("failed to reload JWT keys", logger.WithArg("error", err.Error()))
+			continue
+		}
+
+		verifier.SetKeys(keys)
+	}
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service"
+	"time"
+)
+
+// RunTrashPurge permanently removes songs that are in the trash longer than retention every interval till ctx is done
+func RunTrashPurge(ctx context.Context, log logger.Logger, srvc service.Service, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeTrash(ctx, log, srvc, retention)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeTrash(ctx context.Context, log logger.Logger, srvc service.Service, retention time.Duration) {
+	ctx = logger.WrapLogger(ctx, log)
+	ctx = logger.WrapIdentifier(ctx)
+	ctx = auth.WrapRole(ctx, auth.RoleAdmin)
+
+	purged, err := srvc.PurgeDeletedSongs(ctx, retention)
+	if err != nil {
+		log.Error("failed to purge trash",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+			logger.WithArg("error", err.Error()),
+		)
+		return
+	}
+
+	if purged > 0 {
+		log.Info("trash purged",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+			logger.WithArg("songs", purged),
+		)
+	}
+}
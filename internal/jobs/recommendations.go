@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service"
+	"time"
+)
+
+// RunRecommendationTraining trains the recommendations on the listening history every interval till ctx is done,
+// the trained model is stored, so it is served by all instances and survives restarts
+func RunRecommendationTraining(ctx context.Context, log logger.Logger, srvc service.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		trainRecommendations(ctx, log, srvc)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func trainRecommendations(ctx context.Context, log logger.Logger, srvc service.Service) {
+	ctx = logger.WrapLogger(ctx, log)
+	ctx = logger.WrapIdentifier(ctx)
+	ctx = auth.WrapRole(ctx, auth.RoleAdmin)
+
+	neighbors, err := srvc.TrainRecommendations(ctx)
+	if err != nil {
+		log.Error("failed to train recommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+			logger.WithArg("error", err.Error()),
+		)
+		return
+	}
+
+	log.Info("recommendations trained",
+		logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		logger.WithArg("neighbors", neighbors),
+	)
+}
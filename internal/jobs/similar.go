@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service"
+	"time"
+)
+
+// RunSimilarityRebuild builds the index of similar songs from the whole library at once and then every interval
+// till ctx is done, similar songs are not served till the first build
+func RunSimilarityRebuild(ctx context.Context, log logger.Logger, srvc service.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		rebuildSimilarity(ctx, log, srvc)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func rebuildSimilarity(ctx context.Context, log logger.Logger, srvc service.Service) {
+	ctx = logger.WrapLogger(ctx, log)
+	ctx = logger.WrapIdentifier(ctx)
+	ctx = auth.WrapRole(ctx, auth.RoleAdmin)
+
+	songs, err := srvc.RebuildSimilarityIndex(ctx)
+	if err != nil {
+		log.Error("failed to rebuild similarity index",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+			logger.WithArg("error", err.Error()),
+		)
+		return
+	}
+
+	log.Info("similarity index rebuilt",
+		logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		logger.WithArg("songs", songs),
+	)
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/alserok/music_lib/internal/service/models"
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +36,91 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddPlay mocks base method.
+func (m *MockRepository) AddPlay(ctx context.Context, userID string, play models.Play) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPlay", ctx, userID, play)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPlay indicates an expected call of AddPlay.
+func (mr *MockRepositoryMockRecorder) AddPlay(ctx, userID, play interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPlay", reflect.TypeOf((*MockRepository)(nil).AddPlay), ctx, userID, play)
+}
+
+// AddPlaylistCollaborator mocks base method.
+func (m *MockRepository) AddPlaylistCollaborator(ctx context.Context, playlistID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPlaylistCollaborator", ctx, playlistID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPlaylistCollaborator indicates an expected call of AddPlaylistCollaborator.
+func (mr *MockRepositoryMockRecorder) AddPlaylistCollaborator(ctx, playlistID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPlaylistCollaborator", reflect.TypeOf((*MockRepository)(nil).AddPlaylistCollaborator), ctx, playlistID, userID)
+}
+
+// AddPlaylistSong mocks base method.
+func (m *MockRepository) AddPlaylistSong(ctx context.Context, playlistID string, entry models.PlaylistSong) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPlaylistSong", ctx, playlistID, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPlaylistSong indicates an expected call of AddPlaylistSong.
+func (mr *MockRepositoryMockRecorder) AddPlaylistSong(ctx, playlistID, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPlaylistSong", reflect.TypeOf((*MockRepository)(nil).AddPlaylistSong), ctx, playlistID, entry)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockRepository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, key, keyHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockRepositoryMockRecorder) CreateAPIKey(ctx, key, keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepository)(nil).CreateAPIKey), ctx, key, keyHash)
+}
+
+// CreatePlaylist mocks base method.
+func (m *MockRepository) CreatePlaylist(ctx context.Context, playlist models.Playlist) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePlaylist", ctx, playlist)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreatePlaylist indicates an expected call of CreatePlaylist.
+func (mr *MockRepositoryMockRecorder) CreatePlaylist(ctx, playlist interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePlaylist", reflect.TypeOf((*MockRepository)(nil).CreatePlaylist), ctx, playlist)
+}
+
+// CreateSession mocks base method.
+func (m *MockRepository) CreateSession(ctx context.Context, tokenHash, userID string, ttl time.Duration) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, tokenHash, userID, ttl)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockRepositoryMockRecorder) CreateSession(ctx, tokenHash, userID, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockRepository)(nil).CreateSession), ctx, tokenHash, userID, ttl)
+}
+
 // CreateSong mocks base method.
 func (m *MockRepository) CreateSong(ctx context.Context, song models.Song) error {
 	m.ctrl.T.Helper()
@@ -49,18 +135,117 @@ func (mr *MockRepositoryMockRecorder) CreateSong(ctx, song interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSong", reflect.TypeOf((*MockRepository)(nil).CreateSong), ctx, song)
 }
 
+// CreateSongs mocks base method.
+func (m *MockRepository) CreateSongs(ctx context.Context, songs []models.Song) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSongs", ctx, songs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSongs indicates an expected call of CreateSongs.
+func (mr *MockRepositoryMockRecorder) CreateSongs(ctx, songs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSongs", reflect.TypeOf((*MockRepository)(nil).CreateSongs), ctx, songs)
+}
+
+// CreateUser mocks base method.
+func (m *MockRepository) CreateUser(ctx context.Context, user models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, user)
+}
+
+// DeletePlaylist mocks base method.
+func (m *MockRepository) DeletePlaylist(ctx context.Context, playlistID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePlaylist", ctx, playlistID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePlaylist indicates an expected call of DeletePlaylist.
+func (mr *MockRepositoryMockRecorder) DeletePlaylist(ctx, playlistID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePlaylist", reflect.TypeOf((*MockRepository)(nil).DeletePlaylist), ctx, playlistID)
+}
+
+// DeleteSession mocks base method.
+func (m *MockRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession.
+func (mr *MockRepositoryMockRecorder) DeleteSession(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockRepository)(nil).DeleteSession), ctx, tokenHash)
+}
+
 // DeleteSong mocks base method.
-func (m *MockRepository) DeleteSong(ctx context.Context, songID string) error {
+func (m *MockRepository) DeleteSong(ctx context.Context, songID string, version int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteSong", ctx, songID)
+	ret := m.ctrl.Call(m, "DeleteSong", ctx, songID, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteSong indicates an expected call of DeleteSong.
-func (mr *MockRepositoryMockRecorder) DeleteSong(ctx, songID interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) DeleteSong(ctx, songID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSong", reflect.TypeOf((*MockRepository)(nil).DeleteSong), ctx, songID, version)
+}
+
+// DeleteSongs mocks base method.
+func (m *MockRepository) DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSongs", ctx, songs, atomic)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteSongs indicates an expected call of DeleteSongs.
+func (mr *MockRepositoryMockRecorder) DeleteSongs(ctx, songs, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSongs", reflect.TypeOf((*MockRepository)(nil).DeleteSongs), ctx, songs, atomic)
+}
+
+// DeleteUserSessions mocks base method.
+func (m *MockRepository) DeleteUserSessions(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserSessions", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserSessions indicates an expected call of DeleteUserSessions.
+func (mr *MockRepositoryMockRecorder) DeleteUserSessions(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserSessions", reflect.TypeOf((*MockRepository)(nil).DeleteUserSessions), ctx, userID)
+}
+
+// EditPlaylist mocks base method.
+func (m *MockRepository) EditPlaylist(ctx context.Context, playlist models.Playlist) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditPlaylist", ctx, playlist)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EditPlaylist indicates an expected call of EditPlaylist.
+func (mr *MockRepositoryMockRecorder) EditPlaylist(ctx, playlist interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSong", reflect.TypeOf((*MockRepository)(nil).DeleteSong), ctx, songID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditPlaylist", reflect.TypeOf((*MockRepository)(nil).EditPlaylist), ctx, playlist)
 }
 
 // EditSong mocks base method.
@@ -77,13 +262,283 @@ func (mr *MockRepositoryMockRecorder) EditSong(ctx, song interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditSong", reflect.TypeOf((*MockRepository)(nil).EditSong), ctx, song)
 }
 
+// EditSongs mocks base method.
+func (m *MockRepository) EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditSongs", ctx, songs, atomic)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditSongs indicates an expected call of EditSongs.
+func (mr *MockRepositoryMockRecorder) EditSongs(ctx, songs, atomic interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditSongs", reflect.TypeOf((*MockRepository)(nil).EditSongs), ctx, songs, atomic)
+}
+
+// EditUser mocks base method.
+func (m *MockRepository) EditUser(ctx context.Context, user models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EditUser indicates an expected call of EditUser.
+func (mr *MockRepositoryMockRecorder) EditUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditUser", reflect.TypeOf((*MockRepository)(nil).EditUser), ctx, user)
+}
+
+// FindSongs mocks base method.
+func (m *MockRepository) FindSongs(ctx context.Context, songs []models.NewSong) ([]models.NewSong, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSongs", ctx, songs)
+	ret0, _ := ret[0].([]models.NewSong)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSongs indicates an expected call of FindSongs.
+func (mr *MockRepositoryMockRecorder) FindSongs(ctx, songs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSongs", reflect.TypeOf((*MockRepository)(nil).FindSongs), ctx, songs)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockRepository) GetAPIKey(ctx context.Context, keyID string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, keyID)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockRepositoryMockRecorder) GetAPIKey(ctx, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockRepository)(nil).GetAPIKey), ctx, keyID)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockRepository) GetAPIKeys(ctx context.Context, ownerID string) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", ctx, ownerID)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockRepositoryMockRecorder) GetAPIKeys(ctx, ownerID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockRepository)(nil).GetAPIKeys), ctx, ownerID)
+}
+
+// GetFavorites mocks base method.
+func (m *MockRepository) GetFavorites(ctx context.Context, userID string, lim, off int) ([]models.Favorite, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFavorites", ctx, userID, lim, off)
+	ret0, _ := ret[0].([]models.Favorite)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFavorites indicates an expected call of GetFavorites.
+func (mr *MockRepositoryMockRecorder) GetFavorites(ctx, userID, lim, off interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFavorites", reflect.TypeOf((*MockRepository)(nil).GetFavorites), ctx, userID, lim, off)
+}
+
+// GetMigrations mocks base method.
+func (m *MockRepository) GetMigrations(ctx context.Context) ([]models.Migration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMigrations", ctx)
+	ret0, _ := ret[0].([]models.Migration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMigrations indicates an expected call of GetMigrations.
+func (mr *MockRepositoryMockRecorder) GetMigrations(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMigrations", reflect.TypeOf((*MockRepository)(nil).GetMigrations), ctx)
+}
+
+// GetPlaylist mocks base method.
+func (m *MockRepository) GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlaylist", ctx, playlistID)
+	ret0, _ := ret[0].(models.Playlist)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlaylist indicates an expected call of GetPlaylist.
+func (mr *MockRepositoryMockRecorder) GetPlaylist(ctx, playlistID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlaylist", reflect.TypeOf((*MockRepository)(nil).GetPlaylist), ctx, playlistID)
+}
+
+// GetPlaylistSongs mocks base method.
+func (m *MockRepository) GetPlaylistSongs(ctx context.Context, playlistID string) ([]models.PlaylistSong, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlaylistSongs", ctx, playlistID)
+	ret0, _ := ret[0].([]models.PlaylistSong)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlaylistSongs indicates an expected call of GetPlaylistSongs.
+func (mr *MockRepositoryMockRecorder) GetPlaylistSongs(ctx, playlistID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlaylistSongs", reflect.TypeOf((*MockRepository)(nil).GetPlaylistSongs), ctx, playlistID)
+}
+
+// GetPlaylists mocks base method.
+func (m *MockRepository) GetPlaylists(ctx context.Context, filter models.PlaylistFilter) ([]models.Playlist, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlaylists", ctx, filter)
+	ret0, _ := ret[0].([]models.Playlist)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlaylists indicates an expected call of GetPlaylists.
+func (mr *MockRepositoryMockRecorder) GetPlaylists(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlaylists", reflect.TypeOf((*MockRepository)(nil).GetPlaylists), ctx, filter)
+}
+
+// GetPlays mocks base method.
+func (m *MockRepository) GetPlays(ctx context.Context, userID string, lim, off int) ([]models.Play, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPlays", ctx, userID, lim, off)
+	ret0, _ := ret[0].([]models.Play)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPlays indicates an expected call of GetPlays.
+func (mr *MockRepositoryMockRecorder) GetPlays(ctx, userID, lim, off interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPlays", reflect.TypeOf((*MockRepository)(nil).GetPlays), ctx, userID, lim, off)
+}
+
+// GetRatings mocks base method.
+func (m *MockRepository) GetRatings(ctx context.Context, userID string, lim, off int) ([]models.Rating, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRatings", ctx, userID, lim, off)
+	ret0, _ := ret[0].([]models.Rating)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRatings indicates an expected call of GetRatings.
+func (mr *MockRepositoryMockRecorder) GetRatings(ctx, userID, lim, off interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRatings", reflect.TypeOf((*MockRepository)(nil).GetRatings), ctx, userID, lim, off)
+}
+
+// GetRecommendations mocks base method.
+func (m *MockRepository) GetRecommendations(ctx context.Context, userID string, lim int) ([]models.Recommendation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecommendations", ctx, userID, lim)
+	ret0, _ := ret[0].([]models.Recommendation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRecommendations indicates an expected call of GetRecommendations.
+func (mr *MockRepositoryMockRecorder) GetRecommendations(ctx, userID, lim interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecommendations", reflect.TypeOf((*MockRepository)(nil).GetRecommendations), ctx, userID, lim)
+}
+
+// GetSessionUser mocks base method.
+func (m *MockRepository) GetSessionUser(ctx context.Context, tokenHash string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionUser", ctx, tokenHash)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionUser indicates an expected call of GetSessionUser.
+func (mr *MockRepositoryMockRecorder) GetSessionUser(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionUser", reflect.TypeOf((*MockRepository)(nil).GetSessionUser), ctx, tokenHash)
+}
+
+// GetSong mocks base method.
+func (m *MockRepository) GetSong(ctx context.Context, songID string) (models.Song, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSong", ctx, songID)
+	ret0, _ := ret[0].(models.Song)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSong indicates an expected call of GetSong.
+func (mr *MockRepositoryMockRecorder) GetSong(ctx, songID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSong", reflect.TypeOf((*MockRepository)(nil).GetSong), ctx, songID)
+}
+
+// GetSongRevision mocks base method.
+func (m *MockRepository) GetSongRevision(ctx context.Context, songID string, version int) (models.SongRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSongRevision", ctx, songID, version)
+	ret0, _ := ret[0].(models.SongRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSongRevision indicates an expected call of GetSongRevision.
+func (mr *MockRepositoryMockRecorder) GetSongRevision(ctx, songID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongRevision", reflect.TypeOf((*MockRepository)(nil).GetSongRevision), ctx, songID, version)
+}
+
+// GetSongRevisionAt mocks base method.
+func (m *MockRepository) GetSongRevisionAt(ctx context.Context, songID string, at time.Time) (models.SongRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSongRevisionAt", ctx, songID, at)
+	ret0, _ := ret[0].(models.SongRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSongRevisionAt indicates an expected call of GetSongRevisionAt.
+func (mr *MockRepositoryMockRecorder) GetSongRevisionAt(ctx, songID, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongRevisionAt", reflect.TypeOf((*MockRepository)(nil).GetSongRevisionAt), ctx, songID, at)
+}
+
+// GetSongRevisions mocks base method.
+func (m *MockRepository) GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSongRevisions", ctx, songID, lim, off)
+	ret0, _ := ret[0].([]models.SongRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSongRevisions indicates an expected call of GetSongRevisions.
+func (mr *MockRepositoryMockRecorder) GetSongRevisions(ctx, songID, lim, off interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongRevisions", reflect.TypeOf((*MockRepository)(nil).GetSongRevisions), ctx, songID, lim, off)
+}
+
 // GetSongText mocks base method.
-func (m *MockRepository) GetSongText(ctx context.Context, songID string) (string, error) {
+func (m *MockRepository) GetSongText(ctx context.Context, songID string) (string, time.Time, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetSongText", ctx, songID)
 	ret0, _ := ret[0].(string)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetSongText indicates an expected call of GetSongText.
@@ -106,3 +561,260 @@ func (mr *MockRepositoryMockRecorder) GetSongs(ctx, filter interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongs", reflect.TypeOf((*MockRepository)(nil).GetSongs), ctx, filter)
 }
+
+// GetSongsByID mocks base method.
+func (m *MockRepository) GetSongsByID(ctx context.Context, songIDs []string) ([]models.Song, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSongsByID", ctx, songIDs)
+	ret0, _ := ret[0].([]models.Song)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSongsByID indicates an expected call of GetSongsByID.
+func (mr *MockRepositoryMockRecorder) GetSongsByID(ctx, songIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSongsByID", reflect.TypeOf((*MockRepository)(nil).GetSongsByID), ctx, songIDs)
+}
+
+// GetUser mocks base method.
+func (m *MockRepository) GetUser(ctx context.Context, userID string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockRepositoryMockRecorder) GetUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepository)(nil).GetUser), ctx, userID)
+}
+
+// GetUserByName mocks base method.
+func (m *MockRepository) GetUserByName(ctx context.Context, name string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByName", ctx, name)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByName indicates an expected call of GetUserByName.
+func (mr *MockRepositoryMockRecorder) GetUserByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockRepository)(nil).GetUserByName), ctx, name)
+}
+
+// MigrateDown mocks base method.
+func (m *MockRepository) MigrateDown(ctx context.Context, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateDown", ctx, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateDown indicates an expected call of MigrateDown.
+func (mr *MockRepositoryMockRecorder) MigrateDown(ctx, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateDown", reflect.TypeOf((*MockRepository)(nil).MigrateDown), ctx, version)
+}
+
+// MigrateUp mocks base method.
+func (m *MockRepository) MigrateUp(ctx context.Context, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateUp", ctx, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateUp indicates an expected call of MigrateUp.
+func (mr *MockRepositoryMockRecorder) MigrateUp(ctx, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateUp", reflect.TypeOf((*MockRepository)(nil).MigrateUp), ctx, version)
+}
+
+// MovePlaylistSong mocks base method.
+func (m *MockRepository) MovePlaylistSong(ctx context.Context, playlistID, entryID, position string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MovePlaylistSong", ctx, playlistID, entryID, position)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MovePlaylistSong indicates an expected call of MovePlaylistSong.
+func (mr *MockRepositoryMockRecorder) MovePlaylistSong(ctx, playlistID, entryID, position interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MovePlaylistSong", reflect.TypeOf((*MockRepository)(nil).MovePlaylistSong), ctx, playlistID, entryID, position)
+}
+
+// PurgeDeletedSongs mocks base method.
+func (m *MockRepository) PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedSongs", ctx, retention)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedSongs indicates an expected call of PurgeDeletedSongs.
+func (mr *MockRepositoryMockRecorder) PurgeDeletedSongs(ctx, retention interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedSongs", reflect.TypeOf((*MockRepository)(nil).PurgeDeletedSongs), ctx, retention)
+}
+
+// RateSong mocks base method.
+func (m *MockRepository) RateSong(ctx context.Context, userID, songID string, rating int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RateSong", ctx, userID, songID, rating)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RateSong indicates an expected call of RateSong.
+func (mr *MockRepositoryMockRecorder) RateSong(ctx, userID, songID, rating interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RateSong", reflect.TypeOf((*MockRepository)(nil).RateSong), ctx, userID, songID, rating)
+}
+
+// RemovePlaylistCollaborator mocks base method.
+func (m *MockRepository) RemovePlaylistCollaborator(ctx context.Context, playlistID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePlaylistCollaborator", ctx, playlistID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePlaylistCollaborator indicates an expected call of RemovePlaylistCollaborator.
+func (mr *MockRepositoryMockRecorder) RemovePlaylistCollaborator(ctx, playlistID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePlaylistCollaborator", reflect.TypeOf((*MockRepository)(nil).RemovePlaylistCollaborator), ctx, playlistID, userID)
+}
+
+// RemovePlaylistSong mocks base method.
+func (m *MockRepository) RemovePlaylistSong(ctx context.Context, playlistID, entryID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePlaylistSong", ctx, playlistID, entryID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePlaylistSong indicates an expected call of RemovePlaylistSong.
+func (mr *MockRepositoryMockRecorder) RemovePlaylistSong(ctx, playlistID, entryID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePlaylistSong", reflect.TypeOf((*MockRepository)(nil).RemovePlaylistSong), ctx, playlistID, entryID)
+}
+
+// ReplaceSongNeighbors mocks base method.
+func (m *MockRepository) ReplaceSongNeighbors(ctx context.Context, neighbors []models.SongNeighbor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceSongNeighbors", ctx, neighbors)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceSongNeighbors indicates an expected call of ReplaceSongNeighbors.
+func (mr *MockRepositoryMockRecorder) ReplaceSongNeighbors(ctx, neighbors interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceSongNeighbors", reflect.TypeOf((*MockRepository)(nil).ReplaceSongNeighbors), ctx, neighbors)
+}
+
+// RestoreDeletedSong mocks base method.
+func (m *MockRepository) RestoreDeletedSong(ctx context.Context, songID string, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreDeletedSong", ctx, songID, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreDeletedSong indicates an expected call of RestoreDeletedSong.
+func (mr *MockRepositoryMockRecorder) RestoreDeletedSong(ctx, songID, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreDeletedSong", reflect.TypeOf((*MockRepository)(nil).RestoreDeletedSong), ctx, songID, version)
+}
+
+// RestoreSong mocks base method.
+func (m *MockRepository) RestoreSong(ctx context.Context, songID string, revision, version int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreSong", ctx, songID, revision, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreSong indicates an expected call of RestoreSong.
+func (mr *MockRepositoryMockRecorder) RestoreSong(ctx, songID, revision, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSong", reflect.TypeOf((*MockRepository)(nil).RestoreSong), ctx, songID, revision, version)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockRepository) RevokeAPIKey(ctx context.Context, keyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryMockRecorder) RevokeAPIKey(ctx, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepository)(nil).RevokeAPIKey), ctx, keyID)
+}
+
+// SetFavorite mocks base method.
+func (m *MockRepository) SetFavorite(ctx context.Context, userID, songID string, favorite bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFavorite", ctx, userID, songID, favorite)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFavorite indicates an expected call of SetFavorite.
+func (mr *MockRepositoryMockRecorder) SetFavorite(ctx, userID, songID, favorite interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFavorite", reflect.TypeOf((*MockRepository)(nil).SetFavorite), ctx, userID, songID, favorite)
+}
+
+// StreamInteractions mocks base method.
+func (m *MockRepository) StreamInteractions(ctx context.Context, fn func(models.Interaction) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamInteractions", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamInteractions indicates an expected call of StreamInteractions.
+func (mr *MockRepositoryMockRecorder) StreamInteractions(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamInteractions", reflect.TypeOf((*MockRepository)(nil).StreamInteractions), ctx, fn)
+}
+
+// StreamSongs mocks base method.
+func (m *MockRepository) StreamSongs(ctx context.Context, filter models.SongFilter, fn func(models.Song) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamSongs", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamSongs indicates an expected call of StreamSongs.
+func (mr *MockRepositoryMockRecorder) StreamSongs(ctx, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamSongs", reflect.TypeOf((*MockRepository)(nil).StreamSongs), ctx, filter, fn)
+}
+
+// UseAPIKey mocks base method.
+func (m *MockRepository) UseAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseAPIKey", ctx, keyHash)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseAPIKey indicates an expected call of UseAPIKey.
+func (mr *MockRepositoryMockRecorder) UseAPIKey(ctx, keyHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseAPIKey", reflect.TypeOf((*MockRepository)(nil).UseAPIKey), ctx, keyHash)
+}
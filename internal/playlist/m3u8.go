@@ -0,0 +1,89 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"io"
+	"strings"
+)
+
+const (
+	m3uHeader   = "#EXTM3U"
+	m3uInfo     = "#EXTINF:"
+	m3uPlaylist = "#PLAYLIST:"
+)
+
+func encodeM3U8(w io.Writer, title string, entries []models.PlaylistEntry) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, m3uHeader)
+	if title != "" {
+		fmt.Fprintln(bw, m3uPlaylist+oneLine(title))
+	}
+
+	for _, entry := range entries {
+		// the duration is unknown
+		fmt.Fprintf(bw, "%s-1,%s\n", m3uInfo, oneLine(displayTitle(entry)))
+		fmt.Fprintln(bw, oneLine(entry.Location))
+	}
+
+	return bw.Flush()
+}
+
+// decodeM3U8 reads extended and plain M3U, entries without #EXTINF have only the location
+func decodeM3U8(r io.Reader) ([]models.PlaylistEntry, error) {
+	scanner := bufio.NewScanner(r)
+
+	var (
+		entries []models.PlaylistEntry
+		info    *models.PlaylistEntry
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, m3uInfo):
+			info = new(models.PlaylistEntry)
+			info.Artist, info.Title = splitTitle(extInfTitle(strings.TrimPrefix(line, m3uInfo)))
+		case strings.HasPrefix(line, "#"):
+			// other directives and comments
+		default:
+			entry := models.PlaylistEntry{Location: line}
+			if info != nil {
+				entry.Artist, entry.Title = info.Artist, info.Title
+				info = nil
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, utils.NewError(fmt.Sprintf("failed to read m3u8: %s", err.Error()), utils.BadRequest)
+	}
+
+	return entries, nil
+}
+
+// extInfTitle returns the display title of `duration [key="value" ...],title`, attribute values may contain commas
+func extInfTitle(s string) string {
+	quoted := false
+	for i, r := range s {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				return s[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// oneLine keeps values from breaking the line based format
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,123 @@
+package playlist
+
+import (
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatM3U8 = "m3u8"
+	FormatXSPF = "xspf"
+	FormatPLS  = "pls"
+)
+
+const (
+	// SongURNPrefix refers to a library song by its ID, it is written as the location of songs without a link
+	SongURNPrefix = "urn:musiclib:song:"
+)
+
+// Encode writes the songs as a playlist, the song link is used as the entry location
+func Encode(w io.Writer, format string, title string, songs []models.Song) error {
+	entries := make([]models.PlaylistEntry, len(songs))
+	for i, song := range songs {
+		entries[i] = models.PlaylistEntry{
+			SongID:   song.SongID,
+			Artist:   song.Group,
+			Title:    song.Song,
+			Location: song.Data.Link,
+		}
+		if entries[i].Location == "" {
+			entries[i].Location = SongURNPrefix + song.SongID
+		}
+	}
+
+	switch format {
+	case FormatM3U8:
+		return encodeM3U8(w, title, entries)
+	case FormatXSPF:
+		return encodeXSPF(w, title, entries)
+	case FormatPLS:
+		return encodePLS(w, title, entries)
+	default:
+		return invalidFormat()
+	}
+}
+
+// Decode reads the playlist entries, SongID is set for entries referring to library songs by SongURNPrefix
+func Decode(r io.Reader, format string) ([]models.PlaylistEntry, error) {
+	var (
+		entries []models.PlaylistEntry
+		err     error
+	)
+
+	switch format {
+	case FormatM3U8:
+		entries, err = decodeM3U8(r)
+	case FormatXSPF:
+		entries, err = decodeXSPF(r)
+	case FormatPLS:
+		entries, err = decodePLS(r)
+	default:
+		return nil, invalidFormat()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if id, ok := strings.CutPrefix(entries[i].Location, SongURNPrefix); ok && entries[i].SongID == "" {
+			entries[i].SongID = id
+		}
+	}
+
+	return entries, nil
+}
+
+// ContentType returns the media type of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatXSPF:
+		return "application/xspf+xml"
+	case FormatPLS:
+		return "audio/x-scpls"
+	default:
+		return "audio/x-mpegurl"
+	}
+}
+
+// FormatFromName guesses the format by the file extension, returns an empty string for unknown extensions
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".m3u8", ".m3u":
+		return FormatM3U8
+	case ".xspf":
+		return FormatXSPF
+	case ".pls":
+		return FormatPLS
+	default:
+		return ""
+	}
+}
+
+// splitTitle splits "Artist - Title" display names used by M3U and PLS
+func splitTitle(s string) (artist, title string) {
+	artist, title, ok := strings.Cut(s, " - ")
+	if !ok {
+		return "", strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(artist), strings.TrimSpace(title)
+}
+
+func displayTitle(entry models.PlaylistEntry) string {
+	if entry.Artist == "" {
+		return entry.Title
+	}
+	return entry.Artist + " - " + entry.Title
+}
+
+func invalidFormat() error {
+	return utils.NewError("invalid format, expected m3u8, xspf or pls", utils.BadRequest)
+}
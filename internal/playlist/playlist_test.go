@@ -0,0 +1,122 @@
+package playlist
+
+import (
+	"bytes"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/stretchr/testify/suite"
+	"strings"
+	"testing"
+)
+
+func TestPlaylistSuite(t *testing.T) {
+	suite.Run(t, new(PlaylistSuite))
+}
+
+type PlaylistSuite struct {
+	suite.Suite
+}
+
+var songs = []models.Song{
+	{SongID: "id1", Group: "Muse", Song: "Uprising", Data: models.SongData{Link: "https://example.com/uprising"}},
+	{SongID: "id2", Group: "Muse", Song: "Starlight"},
+}
+
+func (suite *PlaylistSuite) TestRoundTrip() {
+	for _, format := range []string{FormatM3U8, FormatXSPF, FormatPLS} {
+		var buf bytes.Buffer
+		suite.Require().NoError(Encode(&buf, format, "Favourites", songs), format)
+
+		entries, err := Decode(&buf, format)
+		suite.Require().NoError(err, format)
+		suite.Require().Len(entries, 2, format)
+
+		suite.Equal("Muse", entries[0].Artist, format)
+		suite.Equal("Uprising", entries[0].Title, format)
+		suite.Equal("https://example.com/uprising", entries[0].Location, format)
+
+		// songs without a link refer to the library by ID
+		suite.Equal("id2", entries[1].SongID, format)
+		suite.Equal("Starlight", entries[1].Title, format)
+	}
+}
+
+func (suite *PlaylistSuite) TestDecodeM3U8() {
+	input := "\ufeff#EXTM3U\r\n" +
+		"#EXTINF:245 tvg-name=\"a, b\",Queen - Bohemian Rhapsody\r\n" +
+		"music/queen.mp3\r\n" +
+		"\r\n" +
+		"# a comment\r\n" +
+		"music/untitled.mp3\r\n" +
+		"#EXTINF:10,Just a title\n" +
+		"https://example.com/stream\n"
+
+	entries, err := Decode(strings.NewReader(input), FormatM3U8)
+	suite.Require().NoError(err)
+	suite.Equal([]models.PlaylistEntry{
+		{Artist: "Queen", Title: "Bohemian Rhapsody", Location: "music/queen.mp3"},
+		{Location: "music/untitled.mp3"},
+		{Title: "Just a title", Location: "https://example.com/stream"},
+	}, entries)
+}
+
+func (suite *PlaylistSuite) TestDecodeXSPF() {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<playlist version="1" xmlns="http://xspf.org/ns/0/">
+  <trackList>
+    <track>
+      <location>file:///music/song.flac</location>
+      <location>https://example.com/song</location>
+      <identifier>urn:musiclib:song:id1</identifier>
+      <creator>Muse</creator>
+      <title>Uprising</title>
+      <duration>305000</duration>
+    </track>
+    <track>
+      <title>Starlight</title>
+    </track>
+  </trackList>
+</playlist>`
+
+	entries, err := Decode(strings.NewReader(input), FormatXSPF)
+	suite.Require().NoError(err)
+	suite.Equal([]models.PlaylistEntry{
+		{SongID: "id1", Artist: "Muse", Title: "Uprising", Location: "file:///music/song.flac"},
+		{Title: "Starlight"},
+	}, entries)
+
+	_, err = Decode(strings.NewReader("<playlist>"), FormatXSPF)
+	suite.Error(err)
+}
+
+func (suite *PlaylistSuite) TestDecodePLS() {
+	input := "[playlist]\n" +
+		"; comment\n" +
+		"File2=https://example.com/2\n" +
+		"Title2=Muse - Starlight\n" +
+		"file1=https://example.com/1\n" +
+		"Length1=-1\n" +
+		"Title3=no file\n" +
+		"NumberOfEntries=3\n" +
+		"Version=2\n"
+
+	entries, err := Decode(strings.NewReader(input), FormatPLS)
+	suite.Require().NoError(err)
+	suite.Equal([]models.PlaylistEntry{
+		{Location: "https://example.com/1"},
+		{Artist: "Muse", Title: "Starlight", Location: "https://example.com/2"},
+	}, entries)
+
+	_, err = Decode(strings.NewReader("[playlist]\nnot a key value\n"), FormatPLS)
+	suite.Error(err)
+}
+
+func (suite *PlaylistSuite) TestFormats() {
+	suite.Equal(FormatM3U8, FormatFromName("list.M3U"))
+	suite.Equal(FormatXSPF, FormatFromName("list.xspf"))
+	suite.Equal(FormatPLS, FormatFromName("list.pls"))
+	suite.Empty(FormatFromName("list.txt"))
+
+	suite.Error(Encode(&bytes.Buffer{}, "wpl", "", songs))
+	_, err := Decode(strings.NewReader(""), "wpl")
+	suite.Error(err)
+}
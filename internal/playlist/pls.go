@@ -0,0 +1,103 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	plsSection = "[playlist]"
+)
+
+func encodePLS(w io.Writer, title string, entries []models.PlaylistEntry) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, plsSection)
+	if title != "" {
+		fmt.Fprintf(bw, "X-Title=%s\n", oneLine(title))
+	}
+
+	for i, entry := range entries {
+		n := i + 1
+		fmt.Fprintf(bw, "File%d=%s\n", n, oneLine(entry.Location))
+		fmt.Fprintf(bw, "Title%d=%s\n", n, oneLine(displayTitle(entry)))
+		fmt.Fprintf(bw, "Length%d=-1\n", n)
+	}
+
+	fmt.Fprintf(bw, "NumberOfEntries=%d\n", len(entries))
+	fmt.Fprintln(bw, "Version=2")
+
+	return bw.Flush()
+}
+
+// decodePLS reads the FileN and TitleN keys, the entries are ordered by N
+func decodePLS(r io.Reader) ([]models.PlaylistEntry, error) {
+	scanner := bufio.NewScanner(r)
+
+	tracks := make(map[int]*models.PlaylistEntry)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "[") {
+			continue
+		}
+
+		key, val, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, utils.NewError(fmt.Sprintf("invalid pls line %d: %s", line, text), utils.BadRequest)
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+
+		var field string
+		for _, prefix := range []string{"file", "title"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+		if err != nil {
+			continue
+		}
+
+		track, ok := tracks[n]
+		if !ok {
+			track = new(models.PlaylistEntry)
+			tracks[n] = track
+		}
+
+		if field == "file" {
+			track.Location = val
+		} else {
+			track.Artist, track.Title = splitTitle(val)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, utils.NewError(fmt.Sprintf("failed to read pls: %s", err.Error()), utils.BadRequest)
+	}
+
+	numbers := make([]int, 0, len(tracks))
+	for n := range tracks {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	entries := make([]models.PlaylistEntry, 0, len(numbers))
+	for _, n := range numbers {
+		if tracks[n].Location != "" {
+			entries = append(entries, *tracks[n])
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,220 @@
+package playlist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Positions order the songs of a playlist as strings compared byte by byte. A position between any two others
+// always exists, so a song is inserted or moved by giving it a new position without renumbering the others.
+//
+// A position is an integer part followed by a fraction of base 62 digits. The first character of the integer
+// part tells its length, 'a' to 'z' are positive integers of 1 to 26 digits and 'Z' to 'A' are negative ones,
+// so appending or prepending songs keeps the positions short. The fraction never ends with the zero digit
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const (
+	zeroDigit = '0'
+	lastDigit = 'z'
+)
+
+// smallestInteger can not be decremented, positions before it only get a fraction
+var smallestInteger = "A" + strings.Repeat(string(zeroDigit), 26)
+
+// PositionBetween returns a position ordered after before and before after, an empty bound is open. Both empty
+// return the position of the first song of a playlist
+func PositionBetween(before, after string) (string, error) {
+	if before != "" {
+		if err := validatePosition(before); err != nil {
+			return "", err
+		}
+	}
+	if after != "" {
+		if err := validatePosition(after); err != nil {
+			return "", err
+		}
+	}
+	if before != "" && after != "" && before >= after {
+		return "", fmt.Errorf("position %q is not before %q", before, after)
+	}
+
+	switch {
+	case before == "" && after == "":
+		return "a" + string(zeroDigit), nil
+	case before == "":
+		ib := integerPart(after)
+		if ib == smallestInteger {
+			return ib + midpoint("", after[len(ib):]), nil
+		}
+		if ib < after {
+			return ib, nil
+		}
+		return decrementInteger(ib)
+	case after == "":
+		ia := integerPart(before)
+		next, err := incrementInteger(ia)
+		if err != nil {
+			return ia + midpoint(before[len(ia):], ""), nil
+		}
+		return next, nil
+	}
+
+	ia, ib := integerPart(before), integerPart(after)
+	if ia == ib {
+		return ia + midpoint(before[len(ia):], after[len(ib):]), nil
+	}
+
+	next, err := incrementInteger(ia)
+	if err != nil {
+		return "", err
+	}
+	if next < after {
+		return next, nil
+	}
+
+	return ia + midpoint(before[len(ia):], ""), nil
+}
+
+// midpoint returns a fraction between the fractions a and b, the empty b is 1
+func midpoint(a, b string) string {
+	if b != "" {
+		// the common prefix is kept, a is padded with zero digits
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(positionDigits, a[0])
+	}
+	digitB := len(positionDigits)
+	if b != "" {
+		digitB = strings.IndexByte(positionDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(positionDigits[(digitA+digitB+1)/2])
+	}
+
+	// the digits are consecutive
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	return string(positionDigits[digitA]) + midpoint(suffix(a, 1), "")
+}
+
+func incrementInteger(x string) (string, error) {
+	head, digits := x[0], []byte(x[1:])
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] != lastDigit {
+			digits[i] = positionDigits[strings.IndexByte(positionDigits, digits[i])+1]
+			return string(head) + string(digits), nil
+		}
+		digits[i] = zeroDigit
+	}
+
+	// the integer overflows into a longer one
+	switch head {
+	case 'Z':
+		return "a" + string(zeroDigit), nil
+	case 'z':
+		return "", fmt.Errorf("position %q can not be incremented", x)
+	}
+
+	head++
+	if head > 'a' {
+		digits = append(digits, zeroDigit)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+
+	return string(head) + string(digits), nil
+}
+
+func decrementInteger(x string) (string, error) {
+	head, digits := x[0], []byte(x[1:])
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] != zeroDigit {
+			digits[i] = positionDigits[strings.IndexByte(positionDigits, digits[i])-1]
+			return string(head) + string(digits), nil
+		}
+		digits[i] = lastDigit
+	}
+
+	// the integer underflows into a longer negative one
+	switch head {
+	case 'a':
+		return "Z" + string(lastDigit), nil
+	case 'A':
+		return "", fmt.Errorf("position %q can not be decremented", x)
+	}
+
+	head--
+	if head < 'Z' {
+		digits = append(digits, lastDigit)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+
+	return string(head) + string(digits), nil
+}
+
+// integerLength returns the length of the integer part starting with the head, including the head
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	default:
+		return 0, false
+	}
+}
+
+// integerPart returns the integer part of a valid position
+func integerPart(position string) string {
+	n, _ := integerLength(position[0])
+	return position[:n]
+}
+
+func validatePosition(position string) error {
+	n, ok := integerLength(position[0])
+	if !ok || len(position) < n || position == smallestInteger {
+		return fmt.Errorf("invalid position %q", position)
+	}
+
+	for i := 1; i < len(position); i++ {
+		if strings.IndexByte(positionDigits, position[i]) < 0 {
+			return fmt.Errorf("invalid position %q", position)
+		}
+	}
+
+	if len(position) > n && position[len(position)-1] == zeroDigit {
+		return fmt.Errorf("invalid position %q", position)
+	}
+
+	return nil
+}
+
+// digitAt returns the digit of the fraction at i, fractions are padded with zero digits
+func digitAt(fraction string, i int) byte {
+	if i < len(fraction) {
+		return fraction[i]
+	}
+	return zeroDigit
+}
+
+func suffix(s string, i int) string {
+	if i >= len(s) {
+		return ""
+	}
+	return s[i:]
+}
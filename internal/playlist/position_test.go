@@ -0,0 +1,74 @@
+package playlist
+
+import (
+	"math/rand"
+	"slices"
+)
+
+func (suite *PlaylistSuite) TestPositionBetween() {
+	first, err := PositionBetween("", "")
+	suite.Require().NoError(err)
+	suite.Equal("a0", first)
+
+	tests := []struct {
+		before, after, expected string
+	}{
+		{before: "a0", expected: "a1"},
+		{after: "a0", expected: "Zz"},
+		{before: "a0", after: "a1", expected: "a0V"},
+		{before: "a1", after: "a2", expected: "a1V"},
+		{before: "a0V", after: "a1", expected: "a0l"},
+		{before: "az", expected: "b00"},
+		{before: "Zz", expected: "a0"},
+		{after: "b00", expected: "az"},
+		{before: "a0", after: "a0V", expected: "a0G"},
+		{before: "a0G", after: "a0H", expected: "a0GV"},
+	}
+
+	for _, tc := range tests {
+		res, err := PositionBetween(tc.before, tc.after)
+		suite.Require().NoError(err)
+		suite.Equal(tc.expected, res, "%q %q", tc.before, tc.after)
+	}
+
+	for _, bounds := range [][2]string{{"a1", "a0"}, {"a1", "a1"}, {"a0", "a10"}, {"0", ""}, {"", "b0"}} {
+		_, err = PositionBetween(bounds[0], bounds[1])
+		suite.Error(err, "%q %q", bounds[0], bounds[1])
+	}
+}
+
+func (suite *PlaylistSuite) TestPositionsOrder() {
+	rnd := rand.New(rand.NewSource(1))
+
+	var positions []string
+	for i := 0; i < 2000; i++ {
+		at := rnd.Intn(len(positions) + 1)
+
+		var before, after string
+		if at > 0 {
+			before = positions[at-1]
+		}
+		if at < len(positions) {
+			after = positions[at]
+		}
+
+		position, err := PositionBetween(before, after)
+		suite.Require().NoError(err)
+		suite.Require().True(before == "" || before < position, "%q %q %q", before, position, after)
+		suite.Require().True(after == "" || position < after, "%q %q %q", before, position, after)
+
+		positions = slices.Insert(positions, at, position)
+	}
+
+	suite.Require().True(slices.IsSorted(positions))
+
+	// appended songs keep short positions
+	last := positions[len(positions)-1]
+	for i := 0; i < 10000; i++ {
+		next, err := PositionBetween(last, "")
+		suite.Require().NoError(err)
+		suite.Require().Less(last, next)
+		last = next
+	}
+	suite.Require().LessOrEqual(len(last), 4)
+}
@@ -0,0 +1,89 @@
+package playlist
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"io"
+	"strings"
+)
+
+const (
+	xspfNamespace = "http://xspf.org/ns/0/"
+)
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr,omitempty"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Locations   []string `xml:"location"`
+	Identifiers []string `xml:"identifier"`
+	Title       string   `xml:"title,omitempty"`
+	Creator     string   `xml:"creator,omitempty"`
+}
+
+func encodeXSPF(w io.Writer, title string, entries []models.PlaylistEntry) error {
+	playlist := xspfPlaylist{
+		Version: "1",
+		XMLNS:   xspfNamespace,
+		Title:   title,
+		Tracks:  make([]xspfTrack, len(entries)),
+	}
+
+	for i, entry := range entries {
+		playlist.Tracks[i] = xspfTrack{
+			Locations: []string{entry.Location},
+			Title:     entry.Title,
+			Creator:   entry.Artist,
+		}
+		if entry.SongID != "" {
+			playlist.Tracks[i].Identifiers = []string{SongURNPrefix + entry.SongID}
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(playlist); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func decodeXSPF(r io.Reader) ([]models.PlaylistEntry, error) {
+	var playlist xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&playlist); err != nil {
+		return nil, utils.NewError(fmt.Sprintf("invalid xspf: %s", err.Error()), utils.BadRequest)
+	}
+
+	entries := make([]models.PlaylistEntry, len(playlist.Tracks))
+	for i, track := range playlist.Tracks {
+		entries[i] = models.PlaylistEntry{
+			Artist: strings.TrimSpace(track.Creator),
+			Title:  strings.TrimSpace(track.Title),
+		}
+		if len(track.Locations) > 0 {
+			entries[i].Location = strings.TrimSpace(track.Locations[0])
+		}
+
+		for _, identifier := range track.Identifiers {
+			if id, ok := strings.CutPrefix(strings.TrimSpace(identifier), SongURNPrefix); ok {
+				entries[i].SongID = id
+				break
+			}
+		}
+	}
+
+	return entries, nil
+}
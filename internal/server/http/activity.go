@@ -0,0 +1,280 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary FavoriteSong
+// @Description Add a song to the favorites of the authenticated user, adding it again changes nothing
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Song ID"
+// @Success 200 {object} models.Song "Song with its stats"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /songs/{id}/favorite [put]
+func (h *handler) FavoriteSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received FavoriteSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	song, err := h.srvc.FavoriteSong(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return fmt.Errorf("failed to favorite song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed FavoriteSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"song": song})
+}
+
+// @Summary UnfavoriteSong
+// @Description Remove a song from the favorites of the authenticated user
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Song ID"
+// @Success 200 "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /songs/{id}/favorite [delete]
+func (h *handler) UnfavoriteSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received UnfavoriteSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.UnfavoriteSong(c.Request().Context(), c.Param("id")); err != nil {
+		return fmt.Errorf("failed to unfavorite song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed UnfavoriteSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary RateSong
+// @Description Rate a song from 1 to 5 by the authenticated user, rating it again replaces the rating
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Song ID"
+// @Param rating body models.NewRating true "Rating"
+// @Success 200 {object} models.Song "Song with its stats"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /songs/{id}/rating [put]
+func (h *handler) RateSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RateSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var rating models.NewRating
+	if err := c.Bind(&rating); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	song, err := h.srvc.RateSong(c.Request().Context(), c.Param("id"), rating.Rating)
+	if err != nil {
+		return fmt.Errorf("failed to rate song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RateSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"song": song})
+}
+
+// @Summary UnrateSong
+// @Description Remove the rating of a song by the authenticated user
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Song ID"
+// @Success 200 "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /songs/{id}/rating [delete]
+func (h *handler) UnrateSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received UnrateSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.UnrateSong(c.Request().Context(), c.Param("id")); err != nil {
+		return fmt.Errorf("failed to unrate song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed UnrateSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary RecordPlay
+// @Description Record a play of a song by the authenticated user, the duration is the number of seconds listened and the play starts now by default
+// @Tags activity
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Song ID"
+// @Param play body models.NewPlay true "Start and duration of the play"
+// @Success 201 {object} models.Play "Created"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /songs/{id}/plays [post]
+func (h *handler) RecordPlay(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RecordPlay request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var newPlay models.NewPlay
+	if err := c.Bind(&newPlay); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	play, err := h.srvc.RecordPlay(c.Request().Context(), c.Param("id"), newPlay)
+	if err != nil {
+		return fmt.Errorf("failed to record play: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RecordPlay request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"play": play})
+}
+
+// @Summary GetFavorites
+// @Description Get the favorites of the authenticated user, the most recent first
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param limit query int true "Limit of favorites to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.Favorite "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /users/me/favorites [get]
+func (h *handler) GetFavorites(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetFavorites request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, offset, err := parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	favorites, err := h.srvc.GetFavorites(c.Request().Context(), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get favorites: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetFavorites request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"favorites": favorites})
+}
+
+// @Summary GetRatings
+// @Description Get the ratings of the authenticated user, the most recent first
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param limit query int true "Limit of ratings to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.Rating "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /users/me/ratings [get]
+func (h *handler) GetRatings(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetRatings request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, offset, err := parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	ratings, err := h.srvc.GetRatings(c.Request().Context(), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get ratings: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetRatings request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"ratings": ratings})
+}
+
+// @Summary GetPlays
+// @Description Get the listening history of the authenticated user, the most recent plays first
+// @Tags activity
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param limit query int true "Limit of plays to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.Play "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /users/me/plays [get]
+func (h *handler) GetPlays(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetPlays request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, offset, err := parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	plays, err := h.srvc.GetPlays(c.Request().Context(), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get plays: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetPlays request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"plays": plays})
+}
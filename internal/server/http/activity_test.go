@@ -0,0 +1,243 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HTTPHandlersSuite) TestRateSong() {
+	song := models.Song{SongID: "id", Stats: &models.SongStats{Ratings: 1, AverageRating: 4}}
+
+	suite.repo.EXPECT().
+		RateSong(gomock.Any(), gomock.Eq("user id"), gomock.Eq("id"), gomock.Eq(4)).
+		Return(nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id")).
+		Return(song, nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPut, models.NewRating{Rating: 4}), auth.RoleViewer)
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	suite.Require().NoError(suite.handler.RateSong(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res struct {
+		Song models.Song `json:"song"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal(song, res.Song)
+}
+
+func (suite *HTTPHandlersSuite) TestRateSongInvalid() {
+	tests := []struct {
+		name   string
+		rating int
+		req    func(req *http.Request) *http.Request
+		code   int
+	}{
+		{
+			name:   "anonymous",
+			rating: 3,
+			req:    func(req *http.Request) *http.Request { return req },
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "too low",
+			rating: 0,
+			req:    func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "too high",
+			rating: 6,
+			req:    func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := tc.req(suite.authRequest(http.MethodPut, models.NewRating{Rating: tc.rating}))
+			c := suite.e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames("id")
+			c.SetParamValues("id")
+
+			err := suite.handler.RateSong(c)
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestFavoriteSong() {
+	gomock.InOrder(
+		suite.repo.EXPECT().
+			SetFavorite(gomock.Any(), gomock.Eq("user id"), gomock.Eq("id"), gomock.Eq(true)).
+			Return(nil),
+		suite.repo.EXPECT().
+			GetSong(gomock.Any(), gomock.Eq("id")).
+			Return(models.Song{SongID: "id", Stats: &models.SongStats{Favorites: 1}}, nil),
+		suite.repo.EXPECT().
+			SetFavorite(gomock.Any(), gomock.Eq("user id"), gomock.Eq("id"), gomock.Eq(false)).
+			Return(nil),
+	)
+
+	c := suite.e.NewContext(withRole(suite.authRequest(http.MethodPut, nil), auth.RoleViewer), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+	suite.Require().NoError(suite.handler.FavoriteSong(c))
+
+	c = suite.e.NewContext(withRole(suite.authRequest(http.MethodDelete, nil), auth.RoleViewer), httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+	suite.Require().NoError(suite.handler.UnfavoriteSong(c))
+}
+
+func (suite *HTTPHandlersSuite) TestRecordPlay() {
+	playedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var added models.Play
+	suite.repo.EXPECT().
+		AddPlay(gomock.Any(), gomock.Eq("user id"), gomock.Any()).
+		DoAndReturn(func(_ any, _ string, play models.Play) error {
+			added = play
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id")).
+		Return(models.Song{SongID: "id"}, nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPost, models.NewPlay{PlayedAt: &playedAt, Duration: 180}),
+		auth.RoleViewer)
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	suite.Require().NoError(suite.handler.RecordPlay(c))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	suite.Require().NotEmpty(added.PlayID)
+	suite.Require().Equal("id", added.Song.SongID)
+	suite.Require().Equal(playedAt, added.PlayedAt)
+	suite.Require().Equal(180, added.Duration)
+
+	var res struct {
+		Play models.Play `json:"play"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal(added.PlayID, res.Play.PlayID)
+}
+
+func (suite *HTTPHandlersSuite) TestRecordPlayInvalid() {
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		play models.NewPlay
+	}{
+		{
+			name: "negative duration",
+			play: models.NewPlay{Duration: -1},
+		},
+		{
+			name: "too long",
+			play: models.NewPlay{Duration: 24*60*60 + 1},
+		},
+		{
+			name: "future",
+			play: models.NewPlay{PlayedAt: &future, Duration: 10},
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := withRole(suite.authRequest(http.MethodPost, tc.play), auth.RoleViewer)
+			c := suite.e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames("id")
+			c.SetParamValues("id")
+
+			err := suite.handler.RecordPlay(c)
+			suite.Require().Equal(utils.BadRequest, utils.ErrorCode(err))
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestGetPlays() {
+	plays := []models.Play{{PlayID: "play id", Song: models.Song{SongID: "id"}, Duration: 60}}
+
+	suite.repo.EXPECT().
+		GetPlays(gomock.Any(), gomock.Eq("user id"), gomock.Eq(10), gomock.Eq(5)).
+		Return(plays, nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodGet, nil), auth.RoleViewer)
+	query := req.URL.Query()
+	query.Set("limit", "10")
+	query.Set("offset", "5")
+	req.URL.RawQuery = query.Encode()
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(suite.handler.GetPlays(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string][]models.Play
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal(plays, res["plays"])
+
+	// the history is private, so there is none without a user
+	req = suite.authRequest(http.MethodGet, nil)
+	req.URL.RawQuery = query.Encode()
+	err := suite.handler.GetPlays(suite.e.NewContext(req, httptest.NewRecorder()))
+	suite.Require().Equal(utils.Unauthorized, utils.ErrorCode(err))
+}
+
+func (suite *HTTPHandlersSuite) TestGetSongsPopularity() {
+	suite.repo.EXPECT().
+		GetSongs(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, filter models.SongFilter) ([]models.Song, error) {
+			suite.Require().Equal(models.SortRating, filter.Sort)
+			suite.Require().Equal(int64(3), filter.MinPlays)
+			suite.Require().Equal(3.5, filter.MinRating)
+			return nil, nil
+		}).
+		Times(1)
+
+	get := func(params map[string]string) error {
+		req := suite.authRequest(http.MethodGet, nil)
+		query := req.URL.Query()
+		query.Set("limit", "10")
+		query.Set("offset", "0")
+		for key, val := range params {
+			query.Set(key, val)
+		}
+		req.URL.RawQuery = query.Encode()
+		return suite.handler.GetSongs(suite.e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	suite.Require().NoError(get(map[string]string{"sort": "rating", "minPlays": "3", "minRating": "3.5"}))
+
+	for _, params := range []map[string]string{
+		{"sort": "random"},
+		{"minPlays": "many"},
+		{"minPlays": "-1"},
+		{"minRating": "6"},
+		{"minRating": "NaN"},
+	} {
+		suite.Require().Equal(utils.BadRequest, utils.ErrorCode(get(params)), params)
+	}
+}
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetMigrations
+// @Description Get the status of the database migrations
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.Migration "Migrations"
+// @Failure 500 {object} string "Internal error"
+// @Router /admin/migrations [get]
+func (h *handler) GetMigrations(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetMigrations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	migrations, err := h.srvc.GetMigrations(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to get migrations: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetMigrations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"migrations": migrations})
+}
+
+// @Summary MigrateUp
+// @Description Apply the pending migrations up to and including the version, all of them if the version is not set
+// @Tags admin
+// @Produce json
+// @Param version query int false "Target migration version"
+// @Success 200 {array} models.Migration "Migrations"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /admin/migrations/up [post]
+func (h *handler) MigrateUp(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received MigrateUp request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var version int64
+	if val := c.QueryParam("version"); val != "" {
+		var err error
+		if version, err = parseMigrationVersion(val); err != nil {
+			return err
+		}
+	}
+
+	if err := h.srvc.MigrateUp(c.Request().Context(), version); err != nil {
+		return fmt.Errorf("failed to migrate up: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed MigrateUp request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return h.GetMigrations(c)
+}
+
+// @Summary MigrateDown
+// @Description Roll back the migrations newer than the version, 0 rolls back all of them
+// @Tags admin
+// @Produce json
+// @Param version query int true "Target migration version"
+// @Success 200 {array} models.Migration "Migrations"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /admin/migrations/down [post]
+func (h *handler) MigrateDown(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received MigrateDown request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	// rolling back is destructive, so the target is never implied
+	val := c.QueryParam("version")
+	if val == "" {
+		return utils.NewError("version is required", utils.BadRequest)
+	}
+
+	version, err := parseMigrationVersion(val)
+	if err != nil {
+		return err
+	}
+
+	if err = h.srvc.MigrateDown(c.Request().Context(), version); err != nil {
+		return fmt.Errorf("failed to migrate down: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed MigrateDown request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return h.GetMigrations(c)
+}
+
+func parseMigrationVersion(val string) (int64, error) {
+	version, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || version < 0 {
+		return 0, utils.NewError("invalid version", utils.BadRequest)
+	}
+	return version, nil
+}
+
+// @Summary SetUserRole
+// @Description Grant a role to a user, viewers read the library, editors create and edit songs, admins manage it
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "User name"
+// @Param role body models.RoleChange true "viewer, editor or admin"
+// @Success 200 {object} models.User "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /admin/users/{name}/role [put]
+func (h *handler) SetUserRole(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received SetUserRole request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var change models.RoleChange
+	if err := c.Bind(&change); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	user, err := h.srvc.SetUserRole(c.Request().Context(), c.Param("name"), change.Role)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed SetUserRole request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user})
+}
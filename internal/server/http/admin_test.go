@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HTTPHandlersSuite) TestMigrateDown() {
+	migrations := []models.Migration{
+		{Version: 20241122103006, Name: "20241122103006_music_lib_migration.sql", Applied: true},
+		{Version: 20241204151210, Name: "20241204151210_songs_version.sql"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/?version=20241122103006", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		GetMigrations(gomock.Any()).
+		Return(migrations, nil).
+		Times(2)
+	suite.repo.EXPECT().
+		MigrateDown(gomock.Any(), gomock.Eq(int64(20241122103006))).
+		Return(nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.MigrateDown(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string][]models.Migration
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal(migrations, res["migrations"])
+}
+
+func (suite *HTTPHandlersSuite) TestMigrateDownInvalidVersion() {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "missing", query: "/"},
+		{name: "negative", query: "/?version=-1"},
+		{name: "unknown", query: "/?version=20240101000000"},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := httptest.NewRequest(http.MethodPost, tc.query, nil)
+			req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+			req = req.WithContext(logger.WrapIdentifier(req.Context()))
+			req = withRole(req, auth.RoleAdmin)
+			rec := httptest.NewRecorder()
+
+			suite.repo.EXPECT().
+				GetMigrations(gomock.Any()).
+				Return([]models.Migration{{Version: 20241122103006}}, nil).
+				MaxTimes(1)
+
+			suite.logger.EXPECT().
+				Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+				AnyTimes()
+
+			c := suite.e.NewContext(req, rec)
+			err := suite.handler.MigrateDown(c)
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(http.StatusBadRequest, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestMigrateUp() {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		MigrateUp(gomock.Any(), gomock.Eq(int64(0))).
+		Return(nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetMigrations(gomock.Any()).
+		Return([]models.Migration{{Version: 20241122103006, Applied: true}}, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.MigrateUp(c))
+	suite.Equal(http.StatusOK, rec.Code)
+}
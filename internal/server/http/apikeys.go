@@ -0,0 +1,105 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary IssueAPIKey
+// @Description Issue an API key of the authenticated user for machine clients, the key is sent in the X-API-Key header
+// @Description and is shown only once. Scopes are read, write, admin and export, they can not exceed the role of the user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param key body models.NewAPIKey true "Name and scopes of the key"
+// @Success 201 {object} models.IssuedAPIKey "Created"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Scopes exceed the role"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/keys [post]
+func (h *handler) IssueAPIKey(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received IssueAPIKey request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var newKey models.NewAPIKey
+	if err := c.Bind(&newKey); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	issued, err := h.srvc.IssueAPIKey(c.Request().Context(), newKey)
+	if err != nil {
+		return fmt.Errorf("failed to issue API key: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed IssueAPIKey request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"key": issued.Key, "apiKey": issued.APIKey})
+}
+
+// @Summary GetAPIKeys
+// @Description Get the API keys of the authenticated user including the revoked ones, the keys themselves are not stored
+// @Tags auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} []models.APIKey "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/keys [get]
+func (h *handler) GetAPIKeys(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetAPIKeys request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	keys, err := h.srvc.GetAPIKeys(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to get API keys: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetAPIKeys request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"apiKeys": keys})
+}
+
+// @Summary RevokeAPIKey
+// @Description Revoke an API key of the authenticated user for good, admins revoke the keys of any user
+// @Tags auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "API key ID"
+// @Success 200 {object} interface{} "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/keys/{id} [delete]
+func (h *handler) RevokeAPIKey(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RevokeAPIKey request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.RevokeAPIKey(c.Request().Context(), c.Param("id")); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RevokeAPIKey request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
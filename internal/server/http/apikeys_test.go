@@ -0,0 +1,220 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HTTPHandlersSuite) TestIssueAPIKey() {
+	var (
+		created models.APIKey
+		hash    string
+	)
+	suite.repo.EXPECT().
+		CreateAPIKey(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, key models.APIKey, keyHash string) error {
+			created, hash = key, keyHash
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		GetAPIKey(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, keyID string) (models.APIKey, error) {
+			suite.Require().Equal(created.KeyID, keyID)
+			return created, nil
+		}).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPost, models.NewAPIKey{
+		Name:   " importer ",
+		Scopes: []string{"write", "read", " READ"},
+	}), auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(suite.handler.IssueAPIKey(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	suite.Require().Equal("importer", created.Name)
+	suite.Require().Equal([]string{"read", "write"}, created.Scopes)
+	suite.Require().Equal("user id", created.OwnerID)
+
+	var res struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"apiKey"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().True(auth.IsAPIKey(res.Key))
+	suite.Require().True(strings.HasPrefix(res.Key, created.Prefix))
+	suite.Require().Equal(auth.HashToken(res.Key), hash)
+	suite.Require().Equal(created.KeyID, res.APIKey.KeyID)
+}
+
+func (suite *HTTPHandlersSuite) TestIssueAPIKeyInvalid() {
+	tests := []struct {
+		name string
+		key  models.NewAPIKey
+		req  func(req *http.Request) *http.Request
+		code int
+	}{
+		{
+			name: "anonymous",
+			key:  models.NewAPIKey{Name: "key", Scopes: []string{"read"}},
+			req:  func(req *http.Request) *http.Request { return req },
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "no scopes",
+			key:  models.NewAPIKey{Name: "key"},
+			req:  func(req *http.Request) *http.Request { return withRole(req, auth.RoleAdmin) },
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "unknown scope",
+			key:  models.NewAPIKey{Name: "key", Scopes: []string{"delete"}},
+			req:  func(req *http.Request) *http.Request { return withRole(req, auth.RoleAdmin) },
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "no name",
+			key:  models.NewAPIKey{Name: " ", Scopes: []string{"read"}},
+			req:  func(req *http.Request) *http.Request { return withRole(req, auth.RoleAdmin) },
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "scopes exceed role",
+			key:  models.NewAPIKey{Name: "key", Scopes: []string{"read", "admin"}},
+			req:  func(req *http.Request) *http.Request { return withRole(req, auth.RoleEditor) },
+			code: http.StatusForbidden,
+		},
+		{
+			name: "issued by key",
+			key:  models.NewAPIKey{Name: "key", Scopes: []string{"read"}},
+			req: func(req *http.Request) *http.Request {
+				req = withRole(req, auth.RoleAdmin)
+				return req.WithContext(auth.WrapAPIKey(req.Context(), models.APIKey{Scopes: []string{"admin"}}))
+			},
+			code: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := tc.req(suite.authRequest(http.MethodPost, tc.key))
+
+			err := suite.handler.IssueAPIKey(suite.e.NewContext(req, httptest.NewRecorder()))
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestRevokeAPIKey() {
+	suite.repo.EXPECT().
+		GetAPIKey(gomock.Any(), gomock.Eq("own")).
+		Return(models.APIKey{KeyID: "own", OwnerID: "user id"}, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetAPIKey(gomock.Any(), gomock.Eq("other")).
+		Return(models.APIKey{KeyID: "other", OwnerID: "other user id"}, nil).
+		Times(2)
+	suite.repo.EXPECT().
+		RevokeAPIKey(gomock.Any(), gomock.Eq("own")).
+		Return(nil).
+		Times(1)
+	suite.repo.EXPECT().
+		RevokeAPIKey(gomock.Any(), gomock.Eq("other")).
+		Return(nil).
+		Times(1)
+
+	revoke := func(keyID string, role string) error {
+		req := withRole(suite.authRequest(http.MethodDelete, nil), role)
+		c := suite.e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues(keyID)
+		return suite.handler.RevokeAPIKey(c)
+	}
+
+	suite.Require().NoError(revoke("own", auth.RoleViewer))
+
+	// the keys of other users are not found, admins revoke them
+	err := revoke("other", auth.RoleEditor)
+	suite.Require().True(utils.IsNotFound(err))
+
+	suite.Require().NoError(revoke("other", auth.RoleAdmin))
+}
+
+func (suite *HTTPHandlersSuite) TestWithAPIKey() {
+	const secret = "mlk_secret"
+	owner := models.User{UserID: "user id", Name: "user", Role: auth.RoleEditor}
+
+	suite.repo.EXPECT().
+		UseAPIKey(gomock.Any(), gomock.Eq(auth.HashToken(secret))).
+		Return(models.APIKey{KeyID: "key id", OwnerID: owner.UserID, Scopes: []string{"read"}}, nil).
+		Times(3)
+	suite.repo.EXPECT().
+		UseAPIKey(gomock.Any(), gomock.Eq(auth.HashToken("mlk_revoked"))).
+		Return(models.APIKey{}, utils.NewError("API key not found", utils.NotFound)).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(owner.UserID)).
+		Return(owner, nil).
+		Times(3)
+
+	// session tokens are not looked up for requests authenticated by keys
+	authenticate := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return middleware.WithAPIKey(suite.handler.srvc.AuthenticateAPIKey)(
+			middleware.WithUser(suite.handler.srvc.Authenticate)(next),
+		)
+	}
+
+	request := func(key string) *http.Request {
+		req := suite.authRequest(http.MethodGet, nil)
+		req.Header.Set(middleware.HeaderAPIKey, key)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer token")
+		return req
+	}
+
+	handler := authenticate(func(c echo.Context) error {
+		key, ok := auth.ExtractAPIKey(c.Request().Context())
+		suite.Require().True(ok)
+		suite.Require().Equal("key id", key.KeyID)
+		suite.Require().Equal("user", actor.ExtractActor(c.Request().Context()))
+		// the key of an editor with the read scope only reads
+		suite.Require().Equal(auth.RoleViewer, auth.ExtractRole(c.Request().Context()))
+		return nil
+	})
+	suite.Require().NoError(handler(suite.e.NewContext(request(secret), httptest.NewRecorder())))
+
+	tests := []struct {
+		name    string
+		key     string
+		handler echo.HandlerFunc
+		code    int
+	}{
+		{name: "missing scope", key: secret, handler: middleware.WithScope(auth.ScopeExport)(nil), code: http.StatusForbidden},
+		{name: "low role", key: secret, handler: middleware.WithRole(auth.RoleEditor)(nil), code: http.StatusForbidden},
+		{name: "revoked", key: "mlk_revoked", handler: nil, code: http.StatusUnauthorized},
+		{name: "not a key", key: "secret", handler: nil, code: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := request(tc.key)
+
+			err := authenticate(tc.handler)(suite.e.NewContext(req, httptest.NewRecorder()))
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// @Summary Register
+// @Description Create an account and log in, the token of the session authenticates requests as a bearer token
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param user body models.NewUser true "Account details"
+// @Success 201 {object} models.Session "Created"
+// @Failure 400 {object} string "Bad request"
+// @Failure 409 {object} string "Name is taken"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/register [post]
+func (h *handler) Register(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received Register request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var user models.NewUser
+	if err := c.Bind(&user); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	session, err := h.srvc.Register(c.Request().Context(), user)
+	if err != nil {
+		return fmt.Errorf("failed to register: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed Register request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"session": session})
+}
+
+// @Summary Login
+// @Description Start a session of the account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.Credentials true "Name and password"
+// @Success 200 {object} models.Session "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Invalid name or password"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/login [post]
+func (h *handler) Login(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received Login request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var credentials models.Credentials
+	if err := c.Bind(&credentials); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	session, err := h.srvc.Login(c.Request().Context(), credentials)
+	if err != nil {
+		return fmt.Errorf("failed to login: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed Login request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"session": session})
+}
+
+// @Summary Logout
+// @Description End the session of the bearer token
+// @Tags auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} interface{} "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/logout [post]
+func (h *handler) Logout(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received Logout request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	token, ok := middleware.BearerToken(c.Request())
+	if !ok {
+		return utils.NewError("authentication required", utils.Unauthorized)
+	}
+
+	if err := h.srvc.Logout(c.Request().Context(), token); err != nil {
+		return fmt.Errorf("failed to logout: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed Logout request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary GetProfile
+// @Description Get the account of the authenticated user
+// @Tags auth
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Success 200 {object} models.User "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/me [get]
+func (h *handler) GetProfile(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetProfile request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	user, err := h.srvc.GetProfile(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetProfile request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user})
+}
+
+// @Summary EditProfile
+// @Description Change the display name and the email of the authenticated user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param profile body models.UserProfile true "Profile"
+// @Success 200 {object} models.User "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/me [put]
+func (h *handler) EditProfile(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received EditProfile request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var profile models.UserProfile
+	if err := c.Bind(&profile); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	user, err := h.srvc.EditProfile(c.Request().Context(), profile)
+	if err != nil {
+		return fmt.Errorf("failed to edit profile: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed EditProfile request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"user": user})
+}
+
+// @Summary ChangePassword
+// @Description Change the password of the authenticated user, all sessions of the user end and a new one starts
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param change body models.PasswordChange true "Old and new passwords"
+// @Success 200 {object} models.Session "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /auth/me/password [put]
+func (h *handler) ChangePassword(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received ChangePassword request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var change models.PasswordChange
+	if err := c.Bind(&change); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	session, err := h.srvc.ChangePassword(c.Request().Context(), change)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed ChangePassword request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"session": session})
+}
@@ -0,0 +1,382 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HTTPHandlersSuite) TestRegister() {
+	var created models.User
+	suite.repo.EXPECT().
+		CreateUser(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, user models.User) error {
+			created = user
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(time.Now().Add(time.Hour), nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, userID string) (models.User, error) {
+			suite.Require().Equal(created.UserID, userID)
+			return created, nil
+		}).
+		Times(1)
+
+	req := suite.authRequest(http.MethodPost, models.NewUser{Name: " User1 ", Password: "password", Email: "user1@example.com"})
+	rec := httptest.NewRecorder()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.Register(c))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	suite.Require().Equal("user1", created.Name)
+	suite.Require().Equal("User1", created.DisplayName)
+	ok, err := auth.VerifyPassword("password", created.PasswordHash)
+	suite.Require().NoError(err)
+	suite.Require().True(ok)
+
+	var res map[string]models.Session
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().NotEmpty(res["session"].Token)
+	suite.Require().Equal("user1", res["session"].User.Name)
+	suite.Require().NotContains(rec.Body.String(), created.PasswordHash)
+}
+
+func (suite *HTTPHandlersSuite) TestRegisterInvalid() {
+	tests := []struct {
+		name string
+		user models.NewUser
+	}{
+		{name: "short name", user: models.NewUser{Name: "us", Password: "password"}},
+		{name: "invalid name", user: models.NewUser{Name: "user name", Password: "password"}},
+		{name: "short password", user: models.NewUser{Name: "user", Password: "pass"}},
+		{name: "invalid email", user: models.NewUser{Name: "user", Password: "password", Email: "user"}},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := suite.authRequest(http.MethodPost, tc.user)
+			rec := httptest.NewRecorder()
+
+			c := suite.e.NewContext(req, rec)
+			err := suite.handler.Register(c)
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(http.StatusBadRequest, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestLogin() {
+	hash, err := auth.HashPassword("password")
+	suite.Require().NoError(err)
+	user := models.User{UserID: "user id", Name: "user", PasswordHash: hash}
+
+	suite.repo.EXPECT().
+		GetUserByName(gomock.Any(), gomock.Eq("user")).
+		Return(user, nil).
+		Times(2)
+	suite.repo.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any(), gomock.Eq(user.UserID), gomock.Any()).
+		Return(time.Now().Add(time.Hour), nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.UserID)).
+		Return(user, nil).
+		Times(1)
+
+	req := suite.authRequest(http.MethodPost, models.Credentials{Name: "User", Password: "password"})
+	rec := httptest.NewRecorder()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.Login(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	req = suite.authRequest(http.MethodPost, models.Credentials{Name: "user", Password: "wrong password"})
+	err = suite.handler.Login(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+}
+
+func (suite *HTTPHandlersSuite) TestLoginUnknownUser() {
+	suite.repo.EXPECT().
+		GetUserByName(gomock.Any(), gomock.Eq("user")).
+		Return(models.User{}, utils.NewError("user not found", utils.NotFound)).
+		Times(1)
+
+	req := suite.authRequest(http.MethodPost, models.Credentials{Name: "user", Password: "password"})
+
+	err := suite.handler.Login(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, msg := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+	suite.Equal("invalid name or password", msg)
+}
+
+func (suite *HTTPHandlersSuite) TestWithUser() {
+	user := models.User{UserID: "user id", Name: "user"}
+
+	suite.repo.EXPECT().
+		GetSessionUser(gomock.Any(), gomock.Eq(auth.HashToken("token"))).
+		Return(user, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSessionUser(gomock.Any(), gomock.Eq(auth.HashToken("expired"))).
+		Return(models.User{}, utils.NewError("session not found", utils.NotFound)).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.UserID)).
+		Return(user, nil).
+		Times(1)
+
+	handler := middleware.WithUser(suite.handler.srvc.Authenticate)(func(c echo.Context) error {
+		suite.Require().Equal("user", actor.ExtractActor(c.Request().Context()))
+		return suite.handler.GetProfile(c)
+	})
+
+	req := suite.authRequest(http.MethodGet, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer token")
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(handler(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	req = suite.authRequest(http.MethodGet, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer expired")
+
+	err := handler(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+}
+
+func (suite *HTTPHandlersSuite) TestWithJWT() {
+	secret := []byte("secret")
+	keys, err := auth.ParseKeySet([]byte(`{"keys": [{"kty": "oct", "kid": "key", "k": "` +
+		base64.RawURLEncoding.EncodeToString(secret) + `"}]}`))
+	suite.Require().NoError(err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "ingestion",
+			Audience:  jwt.ClaimStrings{"music_lib"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = "key"
+	signed, err := token.SignedString(secret)
+	suite.Require().NoError(err)
+
+	// session tokens are not looked up for requests authenticated by JWTs
+	handler := middleware.WithJWT(auth.NewJWTVerifier(keys, "", "music_lib", 0))(
+		middleware.WithUser(suite.handler.srvc.Authenticate)(func(c echo.Context) error {
+			claims, ok := auth.ExtractClaims(c.Request().Context())
+			suite.Require().True(ok)
+			suite.Require().Equal("ingestion", claims.Subject)
+			suite.Require().Equal("ingestion", actor.ExtractActor(c.Request().Context()))
+			return nil
+		}),
+	)
+
+	req := suite.authRequest(http.MethodGet, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+signed)
+	suite.Require().NoError(handler(suite.e.NewContext(req, httptest.NewRecorder())))
+
+	req = suite.authRequest(http.MethodGet, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+signed[:len(signed)-2])
+
+	err = handler(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+
+	// the author of revisions is never taken from the request headers, tokens without a subject are anonymous
+	token = jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"music_lib"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	token.Header["kid"] = "key"
+	signed, err = token.SignedString(secret)
+	suite.Require().NoError(err)
+
+	handler = middleware.WithJWT(auth.NewJWTVerifier(keys, "", "music_lib", 0))(func(c echo.Context) error {
+		suite.Require().Equal(actor.Anonymous, actor.ExtractActor(c.Request().Context()))
+		return nil
+	})
+
+	req = suite.authRequest(http.MethodGet, nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+signed)
+	req.Header.Set("X-Author", "someone else")
+	suite.Require().NoError(handler(suite.e.NewContext(req, httptest.NewRecorder())))
+}
+
+func (suite *HTTPHandlersSuite) TestGetProfileAnonymous() {
+	req := suite.authRequest(http.MethodGet, nil)
+
+	err := suite.handler.GetProfile(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+}
+
+func (suite *HTTPHandlersSuite) TestChangePassword() {
+	hash, err := auth.HashPassword("password")
+	suite.Require().NoError(err)
+	user := models.User{UserID: "user id", Name: "user", PasswordHash: hash}
+
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.UserID)).
+		Return(user, nil).
+		Times(3)
+	suite.repo.EXPECT().
+		EditUser(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, edited models.User) error {
+			ok, err := auth.VerifyPassword("new password", edited.PasswordHash)
+			suite.Require().NoError(err)
+			suite.Require().True(ok)
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		DeleteUserSessions(gomock.Any(), gomock.Eq(user.UserID)).
+		Return(nil).
+		Times(1)
+	suite.repo.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any(), gomock.Eq(user.UserID), gomock.Any()).
+		Return(time.Now().Add(time.Hour), nil).
+		Times(1)
+
+	req := suite.authRequest(http.MethodPut, models.PasswordChange{OldPassword: "password", NewPassword: "new password"})
+	req = req.WithContext(auth.WrapUser(req.Context(), user))
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(suite.handler.ChangePassword(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	req = suite.authRequest(http.MethodPut, models.PasswordChange{OldPassword: "wrong password", NewPassword: "new password"})
+	req = req.WithContext(auth.WrapUser(req.Context(), user))
+
+	err = suite.handler.ChangePassword(suite.e.NewContext(req, httptest.NewRecorder()))
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusUnauthorized, code)
+}
+
+// authRequest returns a request with the JSON body, nil body is not sent
+func (suite *HTTPHandlersSuite) authRequest(method string, body any) *http.Request {
+	var b []byte
+	if body != nil {
+		var err error
+		b, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+
+	req := httptest.NewRequest(method, "/", bytes.NewReader(b))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	return req
+}
+
+func (suite *HTTPHandlersSuite) TestRoles() {
+	tests := []struct {
+		name    string
+		role    string
+		handler echo.HandlerFunc
+		code    int
+	}{
+		{name: "anonymous create", handler: suite.handler.CreateSong, code: http.StatusUnauthorized},
+		{name: "viewer create", role: auth.RoleViewer, handler: suite.handler.CreateSong, code: http.StatusForbidden},
+		{name: "editor delete", role: auth.RoleEditor, handler: suite.handler.DeleteSong, code: http.StatusForbidden},
+		{name: "editor migrate", role: auth.RoleEditor, handler: suite.handler.MigrateUp, code: http.StatusForbidden},
+		{name: "viewer restore", role: auth.RoleViewer, handler: suite.handler.RestoreDeletedSong, code: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			for _, wrapped := range []bool{false, true} {
+				req := suite.authRequest(http.MethodPost, models.NewSong{Group: "group", Song: "song"})
+				if tc.role != "" {
+					req = withRole(req, tc.role)
+				}
+
+				c := suite.e.NewContext(req, httptest.NewRecorder())
+				c.SetParamNames("id")
+				c.SetParamValues("id")
+
+				// the routes check the role before the handlers, the service checks it again
+				handler := tc.handler
+				if wrapped {
+					handler = middleware.WithRole(auth.RoleAdmin)(handler)
+				}
+
+				err := handler(c)
+				suite.Require().Error(err)
+				code, _ := utils.FromErrorToHTTP(req.Context(), err)
+				suite.Equal(tc.code, code)
+			}
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestSetUserRole() {
+	user := models.User{UserID: "user id", Name: "user", Role: auth.RoleViewer}
+
+	suite.repo.EXPECT().
+		GetUserByName(gomock.Any(), gomock.Eq("user")).
+		Return(user, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		EditUser(gomock.Any(), gomock.Eq(models.User{UserID: "user id", Name: "user", Role: auth.RoleEditor})).
+		Return(nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq(user.UserID)).
+		Return(models.User{UserID: "user id", Name: "user", Role: auth.RoleEditor}, nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPut, models.RoleChange{Role: auth.RoleEditor}), auth.RoleAdmin)
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues("User")
+
+	suite.Require().NoError(suite.handler.SetUserRole(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string]models.User
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal(auth.RoleEditor, res["user"].Role)
+
+	req = withRole(suite.authRequest(http.MethodPut, models.RoleChange{Role: "owner"}), auth.RoleAdmin)
+	c = suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("name")
+	c.SetParamValues("user")
+
+	code, _ := utils.FromErrorToHTTP(req.Context(), suite.handler.SetUserRole(c))
+	suite.Equal(http.StatusBadRequest, code)
+}
+
+// withRole authenticates the request as a user of the role
+func withRole(req *http.Request, role string) *http.Request {
+	return req.WithContext(auth.WrapUser(req.Context(), models.User{UserID: "user id", Name: role, Role: role}))
+}
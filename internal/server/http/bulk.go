@@ -0,0 +1,165 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+const (
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best-effort"
+)
+
+// @Summary CreateSongs
+// @Description Add several songs to the library, song data is requested for every song
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Param songs body []models.NewSong true "Songs"
+// @Param mode query string false "atomic (default) or best-effort"
+// @Success 201 {array} models.BulkItemStatus "Created"
+// @Success 207 {array} models.BulkItemStatus "Some items failed"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /bulk/songs [post]
+func (h *handler) CreateSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received CreateSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	atomic, err := parseBulkMode(c.QueryParam("mode"))
+	if err != nil {
+		return err
+	}
+
+	var songs []models.NewSong
+	if err = c.Bind(&songs); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	results, err := h.srvc.CreateSongs(c.Request().Context(), songs, atomic)
+	if err != nil {
+		return fmt.Errorf("failed to create songs: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed CreateSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return writeBulkResults(c, http.StatusCreated, results)
+}
+
+// @Summary EditSongs
+// @Description Edit several songs, song versions are checked like If-Match of a single edit
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Param songs body []models.Song true "Songs"
+// @Param mode query string false "atomic (default) or best-effort"
+// @Success 200 {array} models.BulkItemStatus "Success"
+// @Success 207 {array} models.BulkItemStatus "Some items failed"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /bulk/songs [put]
+func (h *handler) EditSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received EditSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	atomic, err := parseBulkMode(c.QueryParam("mode"))
+	if err != nil {
+		return err
+	}
+
+	var songs []models.Song
+	if err = c.Bind(&songs); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	results, err := h.srvc.EditSongs(c.Request().Context(), songs, atomic)
+	if err != nil {
+		return fmt.Errorf("failed to edit songs: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed EditSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return writeBulkResults(c, http.StatusOK, results)
+}
+
+// @Summary DeleteSongs
+// @Description Move several songs to the trash
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Param songs body []models.SongVersion true "Songs with expected versions, 0 matches any"
+// @Param mode query string false "atomic (default) or best-effort"
+// @Success 200 {array} models.BulkItemStatus "Success"
+// @Success 207 {array} models.BulkItemStatus "Some items failed"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /bulk/songs [delete]
+func (h *handler) DeleteSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received DeleteSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	atomic, err := parseBulkMode(c.QueryParam("mode"))
+	if err != nil {
+		return err
+	}
+
+	var songs []models.SongVersion
+	if err = c.Bind(&songs); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	results, err := h.srvc.DeleteSongs(c.Request().Context(), songs, atomic)
+	if err != nil {
+		return fmt.Errorf("failed to delete songs: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed DeleteSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return writeBulkResults(c, http.StatusOK, results)
+}
+
+func parseBulkMode(mode string) (bool, error) {
+	switch mode {
+	case "", bulkModeAtomic:
+		return true, nil
+	case bulkModeBestEffort:
+		return false, nil
+	default:
+		return false, utils.NewError("invalid mode, expected atomic or best-effort", utils.BadRequest)
+	}
+}
+
+// writeBulkResults responds with okStatus if all items succeeded and with 207 Multi-Status otherwise
+func writeBulkResults(c echo.Context, okStatus int, results []models.BulkResult) error {
+	status := okStatus
+
+	statuses := make([]models.BulkItemStatus, len(results))
+	for i, res := range results {
+		statuses[i] = models.BulkItemStatus{Index: i, SongID: res.SongID, Status: okStatus}
+		if res.Err != nil {
+			statuses[i].Status, statuses[i].Error = utils.FromErrorToHTTP(c.Request().Context(), res.Err)
+			status = http.StatusMultiStatus
+		}
+	}
+
+	return c.JSON(status, map[string]interface{}{"results": statuses})
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HTTPHandlersSuite) TestCreateSongsBestEffort() {
+	songs := []models.NewSong{
+		{Group: "group", Song: "song1"},
+		{Group: "group", Song: "song2"},
+		{Group: "group", Song: "song1"},
+		{Group: "", Song: "song3"},
+	}
+
+	b, err := json.Marshal(songs)
+	suite.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/?mode="+bulkModeBestEffort, bytes.NewReader(b))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	// duplicated songs are requested once
+	suite.api.EXPECT().
+		GetSongData(gomock.Any(), gomock.Eq("group"), gomock.Eq("song1")).
+		Return(models.SongData{Text: "text"}, nil).
+		Times(1)
+	suite.api.EXPECT().
+		GetSongData(gomock.Any(), gomock.Eq("group"), gomock.Eq("song2")).
+		Return(models.SongData{}, utils.NewError("api request failed", utils.BadRequest)).
+		Times(1)
+
+	suite.repo.EXPECT().
+		CreateSongs(gomock.Any(), gomock.Len(2)).
+		Return(nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.CreateSongs(c))
+	suite.Equal(http.StatusMultiStatus, rec.Code)
+
+	var res map[string][]models.BulkItemStatus
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Len(res["results"], len(songs))
+	suite.Equal(http.StatusCreated, res["results"][0].Status)
+	suite.NotEmpty(res["results"][0].SongID)
+	suite.Equal(http.StatusBadRequest, res["results"][1].Status)
+	suite.Empty(res["results"][1].SongID)
+	suite.Equal(http.StatusCreated, res["results"][2].Status)
+	suite.NotEqual(res["results"][0].SongID, res["results"][2].SongID)
+	suite.Equal(http.StatusBadRequest, res["results"][3].Status)
+}
+
+func (suite *HTTPHandlersSuite) TestEditSongsAtomic() {
+	songs := []models.Song{
+		{SongID: "id1", Song: "song1", Group: "group", Version: 1},
+		{SongID: "id2", Song: "song2", Group: "group", Version: 1},
+	}
+
+	b, err := json.Marshal(songs)
+	suite.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(b))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		EditSongs(gomock.Any(), gomock.Eq(songs), gomock.Eq(true)).
+		Return([]error{nil, utils.NewError("song version mismatch", utils.PreconditionFailed)}, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.EditSongs(c))
+	suite.Equal(http.StatusMultiStatus, rec.Code)
+
+	var res map[string][]models.BulkItemStatus
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal([]models.BulkItemStatus{
+		{Index: 0, SongID: "id1", Status: http.StatusFailedDependency, Error: "not applied: another item of the batch failed"},
+		{Index: 1, SongID: "id2", Status: http.StatusPreconditionFailed, Error: "song version mismatch"},
+	}, res["results"])
+}
+
+func (suite *HTTPHandlersSuite) TestDeleteSongs() {
+	songs := []models.SongVersion{{SongID: "id1"}, {SongID: "id2", Version: 3}}
+
+	b, err := json.Marshal(songs)
+	suite.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodDelete, "/?mode="+bulkModeAtomic, bytes.NewReader(b))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		DeleteSongs(gomock.Any(), gomock.Eq(songs), gomock.Eq(true)).
+		Return([]error{nil, nil}, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.DeleteSongs(c))
+	suite.Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *HTTPHandlersSuite) TestCreateSongsSongDataAPILimit() {
+	suite.handler.srvc = service.New(suite.repo, &service.Clients{
+		SongDataAPIClient:  suite.api,
+		SongDataAPILimiter: rate.NewLimiter(rate.Every(time.Hour), 2),
+	})
+
+	songs := []models.NewSong{
+		{Group: "group", Song: "song1"},
+		{Group: "group", Song: "song2"},
+		{Group: "group", Song: "song3"},
+		{Group: "group", Song: "song4"},
+	}
+
+	b, err := json.Marshal(songs)
+	suite.Require().NoError(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/?mode="+bulkModeBestEffort, bytes.NewReader(b))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	// the budget of the song data API is spent by the first songs whatever the write budget of the client
+	suite.api.EXPECT().
+		GetSongData(gomock.Any(), gomock.Eq("group"), gomock.Any()).
+		Return(models.SongData{Text: "text"}, nil).
+		Times(2)
+
+	suite.repo.EXPECT().
+		CreateSongs(gomock.Any(), gomock.Len(2)).
+		Return(nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.CreateSongs(c))
+	suite.Equal(http.StatusMultiStatus, rec.Code)
+
+	var res map[string][]models.BulkItemStatus
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Len(res["results"], len(songs))
+
+	var created, limited int
+	for _, item := range res["results"] {
+		switch item.Status {
+		case http.StatusCreated:
+			created++
+		case http.StatusTooManyRequests:
+			limited++
+		}
+	}
+	suite.Equal(2, created)
+	suite.Equal(2, limited)
+}
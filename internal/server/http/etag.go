@@ -0,0 +1,73 @@
+package http
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// songETag returns a strong entity tag for the given song version
+func songETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// songStatsETag returns a strong entity tag for the song version and its stats, which change without the version.
+// The digest of the stats follows the version, so the tag is accepted by If-Match as well
+func songStatsETag(song models.Song) string {
+	if song.Stats == nil {
+		return songETag(song.Version)
+	}
+
+	stats := fmt.Sprintf("%d:%d:%g:%d", song.Stats.Favorites, song.Stats.Ratings, song.Stats.AverageRating, song.Stats.Plays)
+	sum := sha1.Sum([]byte(stats))
+
+	return strconv.Quote(strconv.Itoa(song.Version) + "." + hex.EncodeToString(sum[:8]))
+}
+
+// parseIfMatch returns the song version expected by the If-Match header, 0 if the header is empty or "*". The
+// stats digest of the tags of songStatsETag is ignored, the stats do not conflict with edits
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	// weak tags never match with the strong comparison required for If-Match
+	if strings.HasPrefix(header, "W/") {
+		return 0, utils.NewError("weak entity tags are not allowed in If-Match", utils.PreconditionFailed)
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, utils.NewError("invalid If-Match header", utils.BadRequest)
+	}
+
+	unquoted, _, _ = strings.Cut(unquoted, ".")
+
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		return 0, utils.NewError("invalid If-Match header", utils.BadRequest)
+	}
+
+	return version, nil
+}
+
+// setLastModified sets the Last-Modified header, zero time is skipped
+func setLastModified(c echo.Context, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	c.Response().Header().Set(echo.HeaderLastModified, t.UTC().Format(http.TimeFormat))
+}
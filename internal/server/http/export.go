@@ -0,0 +1,162 @@
+package http
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/alserok/music_lib/internal/exporter"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// headerExportError is a trailer set if the export failed after the response was started
+	headerExportError = "X-Export-Error"
+)
+
+// @Summary ExportSongs
+// @Description Stream all songs matching the filters as CSV or JSON Lines. If the export fails after the response was
+// @Description started the X-Export-Error trailer is set
+// @Tags export
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "csv (default) or jsonl"
+// @Param fields query string false "Comma separated fields: songID, group, song, releaseDate, text, link, version, updatedAt"
+// @Param gzip query bool false "Compress the export"
+// @Param limit query int false "Limit of songs to export, all songs if 0"
+// @Param offset query int false "Offset of the first song"
+// @Param songID query string false "Filter by songID"
+// @Param group query string false "Filter by group"
+// @Param song query string false "Filter by song name"
+// @Param text query string false "Filter by text"
+// @Param releaseDate query string false "Filter by release date"
+// @Param link query string false "Filter by link"
+// @Param includeDeleted query bool false "Include songs from the trash"
+// @Success 200 {string} string "Songs"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /export [get]
+func (h *handler) ExportSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received ExportSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	filter, err := parseExportFilter(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = exporter.FormatCSV
+	}
+
+	fields, err := exporter.ParseFields(c.QueryParam("fields"))
+	if err != nil {
+		return err
+	}
+
+	var compress bool
+	if val := c.QueryParam("gzip"); val != "" {
+		if compress, err = strconv.ParseBool(val); err != nil {
+			return utils.NewError("invalid gzip value", utils.BadRequest)
+		}
+	}
+
+	res := c.Response()
+	filename := "songs." + format
+
+	var (
+		w  io.Writer = res
+		gz *gzip.Writer
+	)
+	if compress {
+		gz = gzip.NewWriter(res)
+		w = gz
+		filename += ".gz"
+		res.Header().Set(echo.HeaderContentType, "application/gzip")
+	} else {
+		res.Header().Set(echo.HeaderContentType, exporter.ContentType(format))
+	}
+
+	writer, err := exporter.NewWriter(w, format, fields)
+	if err != nil {
+		return err
+	}
+
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	res.Header().Set("Trailer", headerExportError)
+
+	err = h.srvc.ExportSongs(c.Request().Context(), filter, writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+
+	if err != nil {
+		// songs are buffered by the writers, so the response may not be started yet and can still report the error
+		if !res.Committed {
+			res.Header().Del(echo.HeaderContentDisposition)
+			res.Header().Del("Trailer")
+			return fmt.Errorf("failed to export songs: %w", err)
+		}
+
+		_, msg := utils.FromErrorToHTTP(c.Request().Context(), err)
+		res.Header().Set(headerExportError, msg)
+		return nil
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed ExportSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if !res.Committed {
+		res.WriteHeader(http.StatusOK)
+	}
+	return nil
+}
+
+// parseExportFilter reads the GetSongs filters, the pagination is optional for exports
+func parseExportFilter(c echo.Context) (models.SongFilter, error) {
+	filter := models.SongFilter{
+		SongID: c.QueryParam("songID"),
+		Group:  c.QueryParam("group"),
+		Song:   c.QueryParam("song"),
+		Text:   c.QueryParam("text"),
+		Link:   c.QueryParam("link"),
+
+		IncludeDeleted: c.QueryParam("includeDeleted") == "true",
+	}
+
+	var err error
+	if val := c.QueryParam("limit"); val != "" {
+		if filter.Lim, err = strconv.Atoi(val); err != nil {
+			return models.SongFilter{}, utils.NewError("failed to parse limit", utils.BadRequest)
+		}
+	}
+
+	if val := c.QueryParam("offset"); val != "" {
+		if filter.Off, err = strconv.Atoi(val); err != nil {
+			return models.SongFilter{}, utils.NewError("failed to parse offset", utils.BadRequest)
+		}
+	}
+
+	if val := c.QueryParam("releaseDate"); val != "" {
+		releaseDate, err := time.Parse(time.DateOnly, val)
+		if err != nil {
+			return models.SongFilter{}, utils.NewError("failed to parse releaseDate", utils.BadRequest)
+		}
+		filter.ReleaseDate = &releaseDate
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"compress/gzip"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HTTPHandlersSuite) TestExportSongs() {
+	songs := []models.Song{
+		{SongID: "id1", Group: "group", Song: "song1", Version: 1},
+		{SongID: "id2", Group: "group", Song: "song2", Version: 3},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?gzip=true&fields=songID,song,version&group=group&releaseDate=2024-01-02", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		StreamSongs(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, filter models.SongFilter, fn func(models.Song) error) error {
+			suite.Equal("group", filter.Group)
+			suite.Require().NotNil(filter.ReleaseDate)
+			suite.Equal("2024-01-02", filter.ReleaseDate.Format("2006-01-02"))
+			suite.Zero(filter.Lim)
+
+			for _, song := range songs {
+				suite.Require().NoError(fn(song))
+			}
+			return nil
+		}).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.ExportSongs(c))
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal("application/gzip", rec.Header().Get(echo.HeaderContentType))
+	suite.Equal(`attachment; filename="songs.csv.gz"`, rec.Header().Get(echo.HeaderContentDisposition))
+
+	gz, err := gzip.NewReader(rec.Body)
+	suite.Require().NoError(err)
+	body, err := io.ReadAll(gz)
+	suite.Require().NoError(err)
+	suite.Equal("songID,song,version\nid1,song1,1\nid2,song2,3\n", string(body))
+}
+
+func (suite *HTTPHandlersSuite) TestExportSongsFailure() {
+	req := httptest.NewRequest(http.MethodGet, "/?format=jsonl", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		StreamSongs(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(utils.NewError("cursor failed", utils.Internal)).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	// nothing was written yet, so the error is returned to the error handler
+	c := suite.e.NewContext(req, rec)
+	suite.Error(suite.handler.ExportSongs(c))
+	suite.False(c.Response().Committed)
+	suite.Empty(rec.Header().Get(echo.HeaderContentDisposition))
+}
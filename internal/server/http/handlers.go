@@ -34,7 +34,14 @@ func newHandler(srvc service.Service, log logger.Logger) handler {
 // @Param text query string false "Filter by text"
 // @Param releaseDate query string false "Filter by release date"
 // @Param link query string false "Filter by link"
+// @Param includeDeleted query bool false "Include songs from the trash"
+// @Param sort query string false "Sort by popularity: plays, favorites or rating"
+// @Param minPlays query int false "Filter by the minimum number of plays"
+// @Param minRating query number false "Filter by the minimum average rating"
+// @Param If-None-Match header string false "ETag of a cached response"
 // @Success 200 {array} models.Song "Success"
+// @Header 200 {string} ETag "Response content tag"
+// @Success 304 "Not modified"
 // @Failure 400 {object} string "Bad request"
 // @Failure 500 {object} string "Internal error"
 // @Router /get/songs [get]
@@ -54,6 +61,20 @@ func (h *handler) GetSongs(c echo.Context) error {
 		return utils.NewError("failed to parse offset", utils.BadRequest)
 	}
 
+	var minPlays int64
+	if param := c.QueryParam("minPlays"); param != "" {
+		if minPlays, err = strconv.ParseInt(param, 10, 64); err != nil {
+			return utils.NewError("failed to parse minPlays", utils.BadRequest)
+		}
+	}
+
+	var minRating float64
+	if param := c.QueryParam("minRating"); param != "" {
+		if minRating, err = strconv.ParseFloat(param, 64); err != nil {
+			return utils.NewError("failed to parse minRating", utils.BadRequest)
+		}
+	}
+
 	releaseDate, _ := time.Parse("2006-01-02", c.QueryParam("releaseDate"))
 	filter := models.SongFilter{
 		Lim:         lim,
@@ -64,6 +85,12 @@ func (h *handler) GetSongs(c echo.Context) error {
 		Text:        c.QueryParam("text"),
 		ReleaseDate: &releaseDate,
 		Link:        c.QueryParam("link"),
+
+		IncludeDeleted: c.QueryParam("includeDeleted") == "true",
+
+		MinPlays:  minPlays,
+		MinRating: minRating,
+		Sort:      c.QueryParam("sort"),
 	}
 
 	songs, err := h.srvc.GetSongs(c.Request().Context(), filter)
@@ -79,6 +106,52 @@ func (h *handler) GetSongs(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]interface{}{"songs": songs})
 }
 
+// @Summary GetSong
+// @Description Get a specific song, the ETag header holds the song version and the digest of its stats. Songs
+// @Description with stats have no Last-Modified header as the stats change without the song
+// @Tags songs
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Param If-Modified-Since header string false "Last-Modified of a cached response"
+// @Success 200 {object} models.Song "Success"
+// @Header 200 {string} ETag "Song version and stats digest"
+// @Header 200 {string} Last-Modified "Last song update, songs without stats only"
+// @Success 304 "Not modified"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /get/song/{id} [get]
+func (h *handler) GetSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	songID := c.Param("id")
+	if songID == "" {
+		return utils.NewError("songID is required", utils.BadRequest)
+	}
+
+	song, err := h.srvc.GetSong(c.Request().Context(), songID)
+	if err != nil {
+		return fmt.Errorf("failed to get song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	// the stats have no time of their last change, so only the ETag tells them apart
+	c.Response().Header().Set(headerETag, songStatsETag(song))
+	if song.Stats == nil {
+		setLastModified(c, song.UpdatedAt)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"song": song})
+}
+
 // @Summary GetSongText
 // @Description Get the text of a specific song
 // @Tags songs
@@ -87,7 +160,12 @@ func (h *handler) GetSongs(c echo.Context) error {
 // @Param id path string true "Song ID"
 // @Param limit query int true "Limit of text entries to return"
 // @Param offset query int true "Offset for pagination"
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Param If-Modified-Since header string false "Last-Modified of a cached response"
 // @Success 200 {string} string "Success"
+// @Header 200 {string} ETag "Response content tag"
+// @Header 200 {string} Last-Modified "Last song update"
+// @Success 304 "Not modified"
 // @Failure 400 {object} string "Bad request"
 // @Failure 404 {object} string "Not found"
 // @Failure 500 {object} string "Internal error"
@@ -113,7 +191,7 @@ func (h *handler) GetSongText(c echo.Context) error {
 		return utils.NewError("songID is required", utils.BadRequest)
 	}
 
-	text, err := h.srvc.GetSongText(c.Request().Context(), songID, lim, offset)
+	text, updatedAt, err := h.srvc.GetSongText(c.Request().Context(), songID, lim, offset)
 	if err != nil {
 		return fmt.Errorf("failed to get song text: %w", err)
 	}
@@ -123,18 +201,21 @@ func (h *handler) GetSongText(c echo.Context) error {
 			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
 		)
 
+	setLastModified(c, updatedAt)
 	return c.JSON(http.StatusOK, map[string]interface{}{"text": text})
 }
 
 // @Summary DeleteSong
-// @Description Delete a specific song
+// @Description Move a specific song to the trash
 // @Tags songs
 // @Accept json
 // @Produce json
 // @Param id path string true "Song ID"
+// @Param If-Match header string false "Expected song version (ETag)"
 // @Success 200 {object} interface{} "Success"
 // @Failure 400 {object} string "Bad request"
 // @Failure 404 {object} string "Not found"
+// @Failure 412 {object} string "Precondition failed"
 // @Failure 500 {object} string "Internal error"
 // @Router /del/{id} [delete]
 func (h *handler) DeleteSong(c echo.Context) error {
@@ -145,7 +226,12 @@ func (h *handler) DeleteSong(c echo.Context) error {
 
 	songID := c.Param("id")
 
-	err := h.srvc.DeleteSong(c.Request().Context(), songID)
+	version, err := parseIfMatch(c.Request().Header.Get(headerIfMatch))
+	if err != nil {
+		return err
+	}
+
+	err = h.srvc.DeleteSong(c.Request().Context(), songID, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete song: %w", err)
 	}
@@ -164,8 +250,11 @@ func (h *handler) DeleteSong(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Param song body models.Song true "Song details"
+// @Param If-Match header string false "Expected song version (ETag), overrides the body version"
 // @Success 200 {object} interface{} "Success"
 // @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 412 {object} string "Precondition failed"
 // @Failure 500 {object} string "Internal error"
 // @Router /edit/ [put]
 func (h *handler) EditSong(c echo.Context) error {
@@ -179,6 +268,14 @@ func (h *handler) EditSong(c echo.Context) error {
 		return utils.NewError(err.Error(), utils.BadRequest)
 	}
 
+	version, err := parseIfMatch(c.Request().Header.Get(headerIfMatch))
+	if err != nil {
+		return err
+	}
+	if version != 0 {
+		song.Version = version
+	}
+
 	if err := h.srvc.EditSong(c.Request().Context(), song); err != nil {
 		return fmt.Errorf("failed to create song: %w", err)
 	}
@@ -223,3 +320,172 @@ func (h *handler) CreateSong(c echo.Context) error {
 
 	return c.JSON(http.StatusCreated, nil)
 }
+
+// @Summary GetSongRevisions
+// @Description Get the change history of a song, newest revisions first
+// @Tags revisions
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param limit query int true "Limit of revisions to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.SongRevision "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /get/song/{id}/revisions [get]
+func (h *handler) GetSongRevisions(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetSongRevisions request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil {
+		return utils.NewError("failed to parse limit", utils.BadRequest)
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil {
+		return utils.NewError("failed to parse offset", utils.BadRequest)
+	}
+
+	revisions, err := h.srvc.GetSongRevisions(c.Request().Context(), c.Param("id"), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get song revisions: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetSongRevisions request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"revisions": revisions})
+}
+
+// @Summary GetSongAsOf
+// @Description Get a song as it was at the revision or at the given time
+// @Tags revisions
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param revision query int false "Song revision (version)"
+// @Param at query string false "Point in time in RFC3339 format, used if revision is not set"
+// @Success 200 {object} models.Song "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /get/song/{id}/snapshot [get]
+func (h *handler) GetSongAsOf(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetSongAsOf request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var (
+		revision int
+		at       time.Time
+		err      error
+	)
+	switch {
+	case c.QueryParam("revision") != "":
+		if revision, err = strconv.Atoi(c.QueryParam("revision")); err != nil || revision <= 0 {
+			return utils.NewError("failed to parse revision", utils.BadRequest)
+		}
+	case c.QueryParam("at") != "":
+		if at, err = time.Parse(time.RFC3339, c.QueryParam("at")); err != nil {
+			return utils.NewError("failed to parse at", utils.BadRequest)
+		}
+	default:
+		return utils.NewError("revision or at is required", utils.BadRequest)
+	}
+
+	song, err := h.srvc.GetSongAsOf(c.Request().Context(), c.Param("id"), revision, at)
+	if err != nil {
+		return fmt.Errorf("failed to get song as of revision: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetSongAsOf request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"song": song})
+}
+
+// @Summary RestoreSong
+// @Description Revert a song to the state of the revision, the restore is recorded as a new revision
+// @Tags revisions
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param revision query int true "Revision to restore"
+// @Param If-Match header string false "Expected song version (ETag)"
+// @Success 200 {object} interface{} "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 412 {object} string "Precondition failed"
+// @Failure 500 {object} string "Internal error"
+// @Router /edit/{id}/restore [post]
+func (h *handler) RestoreSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RestoreSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	revision, err := strconv.Atoi(c.QueryParam("revision"))
+	if err != nil {
+		return utils.NewError("failed to parse revision", utils.BadRequest)
+	}
+
+	version, err := parseIfMatch(c.Request().Header.Get(headerIfMatch))
+	if err != nil {
+		return err
+	}
+
+	if err = h.srvc.RestoreSong(c.Request().Context(), c.Param("id"), revision, version); err != nil {
+		return fmt.Errorf("failed to restore song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RestoreSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary RestoreDeletedSong
+// @Description Move a song out of the trash
+// @Tags trash
+// @Accept json
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param If-Match header string false "Expected song version (ETag)"
+// @Success 200 {object} interface{} "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 412 {object} string "Precondition failed"
+// @Failure 500 {object} string "Internal error"
+// @Router /trash/{id}/restore [post]
+func (h *handler) RestoreDeletedSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RestoreDeletedSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	version, err := parseIfMatch(c.Request().Header.Get(headerIfMatch))
+	if err != nil {
+		return err
+	}
+
+	if err = h.srvc.RestoreDeletedSong(c.Request().Context(), c.Param("id"), version); err != nil {
+		return fmt.Errorf("failed to restore deleted song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RestoreDeletedSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/mocks"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
 	"github.com/alserok/music_lib/internal/service"
 	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/suite"
@@ -132,6 +135,7 @@ func (suite *HTTPHandlersSuite) TestCreateSong() {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
 	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
 	rec := httptest.NewRecorder()
 
 	suite.repo.EXPECT().
@@ -168,10 +172,14 @@ func (suite *HTTPHandlersSuite) TestEditSong() {
 	b, err := json.Marshal(song)
 	suite.Require().NoError(err)
 
+	song.Version = 3
+
 	req := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(b))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(headerIfMatch, songETag(song.Version))
 	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
 	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
 	rec := httptest.NewRecorder()
 
 	suite.repo.EXPECT().
@@ -190,14 +198,17 @@ func (suite *HTTPHandlersSuite) TestEditSong() {
 
 func (suite *HTTPHandlersSuite) TestDeleteSong() {
 	songID := "id"
+	version := 2
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(headerIfMatch, songETag(version))
 	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
 	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleAdmin)
 	rec := httptest.NewRecorder()
 
 	suite.repo.EXPECT().
-		DeleteSong(gomock.Any(), gomock.Eq(songID)).
+		DeleteSong(gomock.Any(), gomock.Eq(songID), gomock.Eq(version)).
 		Return(nil).
 		Times(1)
 
@@ -212,6 +223,118 @@ func (suite *HTTPHandlersSuite) TestDeleteSong() {
 	suite.Equal(http.StatusOK, rec.Code)
 }
 
+func (suite *HTTPHandlersSuite) TestGetSong() {
+	song := models.Song{
+		Song:   "song",
+		Group:  "group",
+		SongID: "id",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC),
+			Text:        "text",
+			Link:        "link",
+		},
+		Version:   4,
+		UpdatedAt: time.Date(2024, 2, 1, 1, 1, 1, 0, time.UTC),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq(song.SongID)).
+		Return(song, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(song.SongID)
+	suite.Require().NoError(suite.handler.GetSong(c))
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(songETag(song.Version), rec.Header().Get(headerETag))
+	suite.Equal(song.UpdatedAt.Format(http.TimeFormat), rec.Header().Get(echo.HeaderLastModified))
+
+	var res map[string]models.Song
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal(song, res["song"])
+}
+
+func (suite *HTTPHandlersSuite) TestGetSongStatsETag() {
+	song := models.Song{SongID: "id", Version: 4, UpdatedAt: time.Date(2024, 2, 1, 1, 1, 1, 0, time.UTC),
+		Stats: &models.SongStats{Plays: 1}}
+
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq(song.SongID)).
+		DoAndReturn(func(_ any, _ string) (models.Song, error) {
+			return song, nil
+		}).
+		Times(3)
+
+	handler := middleware.WithHTTPCache(middleware.CachePolicy{NoCache: true})(suite.handler.GetSong)
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := suite.authRequest(http.MethodGet, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		req.Header.Set(echo.HeaderIfModifiedSince, song.UpdatedAt.Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		c := suite.e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(song.SongID)
+
+		suite.Require().NoError(handler(c))
+		return rec
+	}
+
+	rec := get("")
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Empty(rec.Header().Get(echo.HeaderLastModified))
+	etag := rec.Header().Get(headerETag)
+
+	version, err := parseIfMatch(etag)
+	suite.Require().NoError(err)
+	suite.Equal(song.Version, version)
+
+	suite.Equal(http.StatusNotModified, get(etag).Code)
+
+	// a play changes the stats but not the version, so the cached song is stale
+	song.Stats = &models.SongStats{Plays: 2}
+	rec = get(etag)
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.NotEqual(etag, rec.Header().Get(headerETag))
+}
+
+func (suite *HTTPHandlersSuite) TestParseIfMatch() {
+	tests := []struct {
+		header  string
+		version int
+		fail    bool
+	}{
+		{header: "", version: 0},
+		{header: "*", version: 0},
+		{header: `"5"`, version: 5},
+		{header: `"5.0a1b2c3d4e5f6a7b"`, version: 5},
+		{header: `W/"5"`, fail: true},
+		{header: "5", fail: true},
+		{header: `"abc"`, fail: true},
+	}
+
+	for _, tc := range tests {
+		version, err := parseIfMatch(tc.header)
+		if tc.fail {
+			suite.Require().Error(err, tc.header)
+			continue
+		}
+		suite.Require().NoError(err, tc.header)
+		suite.Require().Equal(tc.version, version, tc.header)
+	}
+}
+
 func (suite *HTTPHandlersSuite) TestGetSongText() {
 	couplets := []string{"c0", "c1", "c2", "c3"}
 	song := models.Song{
@@ -223,6 +346,7 @@ func (suite *HTTPHandlersSuite) TestGetSongText() {
 			Text:        strings.Join(couplets, "\n\n"),
 			Link:        "link",
 		},
+		UpdatedAt: time.Date(2024, 2, 1, 1, 1, 1, 0, time.UTC),
 	}
 	lim, off := 1, 2
 
@@ -237,7 +361,7 @@ func (suite *HTTPHandlersSuite) TestGetSongText() {
 
 	suite.repo.EXPECT().
 		GetSongText(gomock.Any(), gomock.Eq(song.SongID)).
-		Return(song.Data.Text, nil).
+		Return(song.Data.Text, song.UpdatedAt, nil).
 		Times(1)
 
 	suite.logger.EXPECT().
@@ -249,8 +373,177 @@ func (suite *HTTPHandlersSuite) TestGetSongText() {
 	c.SetParamValues(song.SongID)
 	suite.Require().NoError(suite.handler.GetSongText(c))
 	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(song.UpdatedAt.Format(http.TimeFormat), rec.Header().Get(echo.HeaderLastModified))
 
 	var res map[string]string
 	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
 	suite.Equal(strings.Join(couplets[off:off+lim], "\n\n"), res["text"])
 }
+
+func (suite *HTTPHandlersSuite) TestGetSongRevisions() {
+	before := models.Song{
+		Song:   "song",
+		Group:  "group",
+		SongID: "id",
+		Data: models.SongData{
+			ReleaseDate: time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC),
+			Text:        "text",
+			Link:        "link",
+		},
+		Version: 1,
+	}
+	after := before
+	after.Song = "edited song"
+	after.Data.Link = "edited link"
+	after.Version = 2
+
+	revisions := []models.SongRevision{
+		{SongID: "id", Version: 2, Action: models.RevisionEdit, Author: "author", Before: &before, After: &after},
+		{SongID: "id", Version: 1, Action: models.RevisionCreate, Author: "author", After: &before},
+	}
+	lim, off := 2, 0
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	query := req.URL.Query()
+	query.Set("limit", strconv.Itoa(lim))
+	query.Set("offset", strconv.Itoa(off))
+	req.URL.RawQuery = query.Encode()
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		GetSongRevisions(gomock.Any(), gomock.Eq("id"), gomock.Eq(lim), gomock.Eq(off)).
+		Return(revisions, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+	suite.Require().NoError(suite.handler.GetSongRevisions(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string][]models.SongRevision
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Len(res["revisions"], 2)
+	suite.Equal([]models.SongChange{
+		{Field: "song", Before: "song", After: "edited song"},
+		{Field: "link", Before: "link", After: "edited link"},
+	}, res["revisions"][0].Changes)
+	suite.Len(res["revisions"][1].Changes, 5)
+}
+
+func (suite *HTTPHandlersSuite) TestGetSongAsOf() {
+	song := models.Song{
+		Song:    "song",
+		Group:   "group",
+		SongID:  "id",
+		Version: 1,
+	}
+	at := time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	query := req.URL.Query()
+	query.Set("at", at.Format(time.RFC3339))
+	req.URL.RawQuery = query.Encode()
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		GetSongRevisionAt(gomock.Any(), gomock.Eq(song.SongID), gomock.Eq(at)).
+		Return(models.SongRevision{SongID: song.SongID, Version: 1, After: &song}, nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(song.SongID)
+	suite.Require().NoError(suite.handler.GetSongAsOf(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string]models.Song
+	suite.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal(song, res["song"])
+}
+
+func (suite *HTTPHandlersSuite) TestRestoreSong() {
+	songID, revision, version := "id", 2, 5
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(headerIfMatch, songETag(version))
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	query := req.URL.Query()
+	query.Set("revision", strconv.Itoa(revision))
+	req.URL.RawQuery = query.Encode()
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		RestoreSong(gomock.Any(), gomock.Eq(songID), gomock.Eq(revision), gomock.Eq(version)).
+		Return(nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(songID)
+	suite.Require().NoError(suite.handler.RestoreSong(c))
+	suite.Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *HTTPHandlersSuite) TestRestoreDeletedSong() {
+	songID, version := "id", 3
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(headerIfMatch, songETag(version))
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		RestoreDeletedSong(gomock.Any(), gomock.Eq(songID), gomock.Eq(version)).
+		Return(nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(songID)
+	suite.Require().NoError(suite.handler.RestoreDeletedSong(c))
+	suite.Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *HTTPHandlersSuite) TestPurgeDeletedSongsRetention() {
+	ctx := withRole(suite.authRequest(http.MethodDelete, nil), auth.RoleAdmin).Context()
+
+	suite.repo.EXPECT().
+		PurgeDeletedSongs(gomock.Any(), gomock.Eq(time.Hour)).
+		Return(int64(2), nil).
+		Times(1)
+
+	purged, err := suite.handler.srvc.PurgeDeletedSongs(ctx, time.Hour)
+	suite.Require().NoError(err)
+	suite.Equal(int64(2), purged)
+
+	// the trash is never purged as a whole
+	for _, retention := range []time.Duration{0, -time.Hour} {
+		_, err = suite.handler.srvc.PurgeDeletedSongs(ctx, retention)
+		suite.Require().Equal(utils.BadRequest, utils.ErrorCode(err))
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/importer"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// @Summary ImportSongs
+// @Description Import songs from a CSV or JSON Lines file of at most 32 MB, returns a report with the outcome of
+// @Description every row. Files with songs to enrich have at most 1000 rows
+// @Tags import
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "CSV with a header row or JSON Lines with a song object per line"
+// @Param format formData string false "csv or jsonl, guessed by the file extension if empty"
+// @Param delimiter formData string false "CSV delimiter, ',' by default, 'tab' for TSV"
+// @Param encoding formData string false "File encoding like windows-1251, UTF-8 by default"
+// @Param columns formData string false "CSV column mapping like group=Artist,song=Title,releaseDate=Released"
+// @Param enrich formData bool false "Request missing song data from the song data API"
+// @Success 200 {object} models.ImportReport "Report"
+// @Failure 400 {object} string "Bad request"
+// @Failure 413 {object} string "File too large"
+// @Failure 500 {object} string "Internal error"
+// @Router /import/songs [post]
+func (h *handler) ImportSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received ImportSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return utils.NewError("file is required", utils.BadRequest)
+	}
+
+	opts := importer.Options{Format: c.FormValue("format"), Encoding: c.FormValue("encoding")}
+	if opts.Format == "" {
+		opts.Format = importer.FormatFromName(fileHeader.Filename)
+	}
+
+	if opts.Delimiter, err = importer.ParseDelimiter(c.FormValue("delimiter")); err != nil {
+		return err
+	}
+
+	if opts.Columns, err = importer.ParseColumns(c.FormValue("columns")); err != nil {
+		return err
+	}
+
+	var enrich bool
+	if val := c.FormValue("enrich"); val != "" {
+		if enrich, err = strconv.ParseBool(val); err != nil {
+			return utils.NewError("invalid enrich value", utils.BadRequest)
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	rows, err := importer.Read(file, opts)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// every row may take a request to the song data API
+	if enrich && len(rows) > importer.MaxEnrichedRows {
+		return utils.NewError(fmt.Sprintf("file has more than %d rows to enrich, import it with the command line",
+			importer.MaxEnrichedRows), utils.BadRequest)
+	}
+
+	results, err := h.srvc.ImportSongs(c.Request().Context(), rows, enrich)
+	if err != nil {
+		return fmt.Errorf("failed to import songs: %w", err)
+	}
+
+	report := importer.NewReport(results, func(err error) string {
+		_, msg := utils.FromErrorToHTTP(c.Request().Context(), err)
+		return msg
+	})
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed ImportSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"report": report})
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/importer"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HTTPHandlersSuite) TestImportSongs() {
+	csv := "Artist\tTitle\tLink\n" +
+		"Muse\tUprising\thttps://example.com/uprising\n" +
+		"Muse\tStarlight\t\n" +
+		"Muse\tUprising\t\n" +
+		"Muse\tMadness\tnot a link\n" +
+		"\tNo group\t\n"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "catalogue.tsv")
+	suite.Require().NoError(err)
+	_, err = file.Write([]byte(csv))
+	suite.Require().NoError(err)
+	suite.Require().NoError(form.WriteField("delimiter", "tab"))
+	suite.Require().NoError(form.WriteField("columns", "group=Artist,song=Title"))
+	suite.Require().NoError(form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	req = withRole(req, auth.RoleEditor)
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		FindSongs(gomock.Any(), gomock.Eq([]models.NewSong{{Group: "Muse", Song: "Uprising"}, {Group: "Muse", Song: "Starlight"}})).
+		Return([]models.NewSong{{Group: "Muse", Song: "Starlight"}}, nil).
+		Times(1)
+
+	suite.repo.EXPECT().
+		CreateSongs(gomock.Any(), gomock.Len(1)).
+		DoAndReturn(func(_ any, songs []models.Song) error {
+			suite.Equal("Uprising", songs[0].Song)
+			suite.Equal("https://example.com/uprising", songs[0].Data.Link)
+			return nil
+		}).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.ImportSongs(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string]models.ImportReport
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+
+	report := res["report"]
+	suite.Equal(5, report.Total)
+	suite.Equal(1, report.Created)
+	suite.Equal(2, report.Skipped)
+	suite.Equal(2, report.Failed)
+
+	suite.Require().Len(report.Rows, 5)
+	suite.Equal(models.ImportCreated, report.Rows[0].Status)
+	suite.NotEmpty(report.Rows[0].SongID)
+	suite.Equal(models.ImportRowStatus{Line: 3, Status: models.ImportSkipped, Reason: "song already exists"}, report.Rows[1])
+	suite.Equal(models.ImportRowStatus{Line: 4, Status: models.ImportSkipped, Reason: "duplicate of line 2"}, report.Rows[2])
+	suite.Equal(models.ImportFailed, report.Rows[3].Status)
+	suite.Equal(models.ImportFailed, report.Rows[4].Status)
+}
+
+func (suite *HTTPHandlersSuite) TestImportSongsLimits() {
+	upload := func(csv string, enrich bool) *http.Request {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, err := form.CreateFormFile("file", "catalogue.csv")
+		suite.Require().NoError(err)
+		_, err = file.Write([]byte(csv))
+		suite.Require().NoError(err)
+		if enrich {
+			suite.Require().NoError(form.WriteField("enrich", "true"))
+		}
+		suite.Require().NoError(form.Close())
+
+		req := withRole(suite.authRequest(http.MethodPost, nil), auth.RoleEditor)
+		req.Body = io.NopCloser(&body)
+		req.ContentLength = int64(body.Len())
+		req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+		return req
+	}
+
+	csv := "group,song\n" + strings.Repeat("Muse,Uprising\n", importer.MaxEnrichedRows+1)
+
+	// every enriched row may take a request to the song data API
+	req := upload(csv, true)
+	err := suite.handler.ImportSongs(suite.e.NewContext(req, httptest.NewRecorder()))
+	suite.Require().Equal(utils.BadRequest, utils.ErrorCode(err))
+
+	// files are limited by their size whether it is known or not
+	handler := middleware.WithBodyLimit(1024)(suite.handler.ImportSongs)
+	for _, length := range []int64{0, -1} {
+		req = upload(csv, false)
+		if length != 0 {
+			req.ContentLength = length
+		}
+
+		err = handler(suite.e.NewContext(req, httptest.NewRecorder()))
+		code, _ := utils.FromErrorToHTTP(req.Context(), err)
+		suite.Equal(http.StatusRequestEntityTooLarge, code)
+	}
+}
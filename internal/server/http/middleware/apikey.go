@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/labstack/echo/v4"
+	"strings"
+)
+
+// HeaderAPIKey holds the API keys of machine clients
+const HeaderAPIKey = "X-API-Key"
+
+// WithAPIKey authenticates the key of the X-API-Key header and stores it with its owner in the context, the owner
+// name is the actor of the request. A key takes precedence over the bearer token of the request, requests without
+// a key are left to WithJWT and WithUser
+func WithAPIKey(authenticate func(ctx context.Context, key string) (models.User, models.APIKey, error)) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			secret := strings.TrimSpace(c.Request().Header.Get(HeaderAPIKey))
+			if secret == "" {
+				return next(c)
+			}
+
+			user, key, err := authenticate(c.Request().Context(), secret)
+			if err != nil {
+				return err
+			}
+
+			ctx := auth.WrapUser(c.Request().Context(), user)
+			ctx = auth.WrapAPIKey(ctx, key)
+			ctx = actor.WrapActor(ctx, user.Name)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// WithScope rejects requests authenticated by API keys or JWTs without the scope, other requests are passed as is
+func WithScope(scope string) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := auth.RequireScope(c.Request().Context(), scope); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+)
+
+// WithBodyLimit rejects requests which bodies are larger than limit bytes. Bodies of unknown length are cut at the
+// limit, the error of the handler is replaced if it read past it
+func WithBodyLimit(limit int64) func(echo.HandlerFunc) echo.HandlerFunc {
+	tooLarge := utils.NewError(fmt.Sprintf("request body is larger than %d bytes", limit), utils.TooLarge)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.ContentLength > limit {
+				return tooLarge
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Response(), req.Body, limit)}
+			req.Body = body
+
+			if err := next(c); err != nil {
+				if body.exceeded {
+					return tooLarge
+				}
+				return err
+			}
+
+			return nil
+		}
+	}
+}
+
+// limitedBody records whether the handler read past the limit, handlers may wrap or replace the read error
+type limitedBody struct {
+	io.ReadCloser
+
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		b.exceeded = true
+	}
+
+	return n, err
+}
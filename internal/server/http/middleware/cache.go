@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// CachePolicy describes the Cache-Control header of a route
+type CachePolicy struct {
+	MaxAge time.Duration
+	// Private forbids shared caches (proxies, CDNs) from storing the response
+	Private bool
+	// NoCache makes clients revalidate the response on every request
+	NoCache bool
+}
+
+func (p CachePolicy) String() string {
+	directives := make([]string, 0, 3)
+
+	if p.Private {
+		directives = append(directives, "private")
+	} else {
+		directives = append(directives, "public")
+	}
+
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	} else {
+		directives = append(directives, fmt.Sprintf("max-age=%d", int(p.MaxAge.Seconds())))
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// WithHTTPCache sets the Cache-Control header of successful GET responses, generates a weak ETag
+// if the handler did not set one and answers with 304 Not Modified to matching If-None-Match
+// or If-Modified-Since requests
+func WithHTTPCache(policy CachePolicy) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+
+			res := c.Response()
+			original := res.Writer
+			buffered := &bufferedWriter{ResponseWriter: original}
+
+			res.Writer = buffered
+			err := next(c)
+			res.Writer = original
+
+			if err != nil {
+				// nothing was written, the error handler writes to the original writer
+				return err
+			}
+
+			if buffered.status != http.StatusOK {
+				return buffered.flush()
+			}
+
+			header := original.Header()
+			if header.Get(headerETag) == "" {
+				sum := sha1.Sum(buffered.buf.Bytes())
+				header.Set(headerETag, `W/"`+hex.EncodeToString(sum[:])+`"`)
+			}
+			header.Set(echo.HeaderCacheControl, policy.String())
+
+			if notModified(c.Request(), header) {
+				header.Del(echo.HeaderContentLength)
+				header.Del(echo.HeaderContentType)
+				original.WriteHeader(http.StatusNotModified)
+				res.Status = http.StatusNotModified
+				return nil
+			}
+
+			return buffered.flush()
+		}
+	}
+}
+
+// notModified evaluates conditional headers, If-None-Match takes precedence over If-Modified-Since
+func notModified(req *http.Request, header http.Header) bool {
+	if inm := req.Header.Get(headerIfNoneMatch); inm != "" {
+		return etagListMatches(inm, header.Get(headerETag))
+	}
+
+	ims, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+
+	lastModified, err := http.ParseTime(header.Get(echo.HeaderLastModified))
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.After(ims)
+}
+
+// etagListMatches uses the weak comparison as required for If-None-Match
+func etagListMatches(list string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+type bufferedWriter struct {
+	http.ResponseWriter
+
+	buf    bytes.Buffer
+	status int
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+func (w *bufferedWriter) flush() error {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
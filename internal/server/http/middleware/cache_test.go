@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheSuite(t *testing.T) {
+	suite.Run(t, new(CacheSuite))
+}
+
+type CacheSuite struct {
+	suite.Suite
+
+	e *echo.Echo
+}
+
+func (suite *CacheSuite) SetupTest() {
+	suite.e = echo.New()
+}
+
+func (suite *CacheSuite) TestCachePolicy() {
+	suite.Equal("public, max-age=60", CachePolicy{MaxAge: time.Minute}.String())
+	suite.Equal("private, max-age=0", CachePolicy{Private: true}.String())
+	suite.Equal("public, no-cache", CachePolicy{NoCache: true}.String())
+}
+
+func (suite *CacheSuite) TestGeneratedETag() {
+	handler := WithHTTPCache(CachePolicy{MaxAge: time.Minute})(func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"text": "text"})
+	})
+
+	rec := suite.serve(handler, nil)
+	suite.Require().Equal(http.StatusOK, rec.Code)
+	suite.Require().NotEmpty(rec.Body.String())
+	suite.Require().Equal("public, max-age=60", rec.Header().Get(echo.HeaderCacheControl))
+
+	etag := rec.Header().Get(headerETag)
+	suite.Require().NotEmpty(etag)
+
+	rec = suite.serve(handler, map[string]string{headerIfNoneMatch: etag})
+	suite.Require().Equal(http.StatusNotModified, rec.Code)
+	suite.Require().Empty(rec.Body.String())
+
+	rec = suite.serve(handler, map[string]string{headerIfNoneMatch: `W/"other"`})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *CacheSuite) TestHandlerETagAndLastModified() {
+	lastModified := time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC)
+	handler := WithHTTPCache(CachePolicy{})(func(c echo.Context) error {
+		c.Response().Header().Set(headerETag, `"2"`)
+		c.Response().Header().Set(echo.HeaderLastModified, lastModified.Format(http.TimeFormat))
+		return c.JSON(http.StatusOK, nil)
+	})
+
+	rec := suite.serve(handler, map[string]string{headerIfNoneMatch: `"1", "2"`})
+	suite.Require().Equal(http.StatusNotModified, rec.Code)
+	suite.Require().Equal(`"2"`, rec.Header().Get(headerETag))
+
+	rec = suite.serve(handler, map[string]string{echo.HeaderIfModifiedSince: lastModified.Format(http.TimeFormat)})
+	suite.Require().Equal(http.StatusNotModified, rec.Code)
+
+	rec = suite.serve(handler, map[string]string{echo.HeaderIfModifiedSince: lastModified.Add(-time.Hour).Format(http.TimeFormat)})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+
+	// If-None-Match takes precedence
+	rec = suite.serve(handler, map[string]string{
+		headerIfNoneMatch:          `"1"`,
+		echo.HeaderIfModifiedSince: lastModified.Format(http.TimeFormat),
+	})
+	suite.Require().Equal(http.StatusOK, rec.Code)
+}
+
+func (suite *CacheSuite) TestErrorsPassThrough() {
+	handler := WithHTTPCache(CachePolicy{MaxAge: time.Minute})(func(c echo.Context) error {
+		return echo.ErrNotFound
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(req, rec)
+
+	suite.Require().Error(handler(c))
+	suite.Require().Empty(rec.Header().Get(echo.HeaderCacheControl))
+	suite.Require().NoError(c.JSON(http.StatusNotFound, nil))
+	suite.Require().Equal(http.StatusNotFound, rec.Code)
+}
+
+func (suite *CacheSuite) serve(handler echo.HandlerFunc, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(handler(suite.e.NewContext(req, rec)))
+
+	return rec
+}
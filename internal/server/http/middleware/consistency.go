@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/alserok/music_lib/internal/db"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderConsistency set to ConsistencyPrimary makes all reads of the request go to the primary database,
+	// clients use it to read their writes made by previous requests
+	HeaderConsistency  = "X-Consistency"
+	ConsistencyPrimary = "primary"
+)
+
+// WithReadYourWrites pins the request to the primary database after its first write, replicas may lag behind it
+func WithReadYourWrites(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+		if c.Request().Header.Get(HeaderConsistency) == ConsistencyPrimary {
+			ctx = db.WrapPrimary(ctx)
+		} else {
+			ctx = db.WrapReadYourWrites(ctx)
+		}
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		return next(c)
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// WithJWT authenticates the requests of other services by their bearer JWTs, the claims are stored in the context
+// and the subject is the actor of the request, tokens without one are anonymous actors. Other bearer tokens are left
+// to WithUser, requests authenticated by WithAPIKey are passed as is
+func WithJWT(verifier *auth.JWTVerifier) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := auth.ExtractAPIKey(c.Request().Context()); ok {
+				return next(c)
+			}
+
+			token, ok := BearerToken(c.Request())
+			if !ok || !auth.IsJWT(token) {
+				return next(c)
+			}
+
+			claims, err := verifier.Verify(token)
+			if err != nil {
+				return utils.NewError("invalid token: "+err.Error(), utils.Unauthorized)
+			}
+
+			ctx := auth.WrapClaims(c.Request().Context(), claims)
+			if claims.Subject != "" {
+				ctx = actor.WrapActor(ctx, claims.Subject)
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+	headerRateLimitPolicy    = "RateLimit-Policy"
+	headerRetryAfter         = "Retry-After"
+)
+
+// RateLimit is the budget of a client: Requests per Period, which can be spent at once. The zero value has
+// no limit
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled reports whether the budget limits requests
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// RateLimiter keeps a token bucket of every client, the buckets refill with the rate of the budget and hold
+// up to its requests
+type RateLimiter struct {
+	limit RateLimit
+	// now is replaced in tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+	// swept is the last time the full buckets were dropped
+	swept time.Time
+}
+
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+// rateLimitResult describes the bucket of a client after a request
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	// reset is the time till the bucket is full again
+	reset time.Duration
+	// retryAfter is the time till the next request is allowed if this one is not
+	retryAfter time.Duration
+}
+
+// take spends a request of the client
+func (l *RateLimiter) take(client string) rateLimitResult {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = rate.NewLimiter(rate.Limit(float64(l.limit.Requests)/l.limit.Period.Seconds()), l.limit.Requests)
+		l.buckets[client] = bucket
+	}
+
+	res := rateLimitResult{allowed: true}
+
+	// a request that has to wait for a token is rejected and its token is given back
+	if r := bucket.ReserveN(now, 1); r.DelayFrom(now) > 0 {
+		res.allowed, res.retryAfter = false, r.DelayFrom(now)
+		r.CancelAt(now)
+	}
+
+	tokens := bucket.TokensAt(now)
+	res.remaining = max(int(math.Floor(tokens)), 0)
+	res.reset = time.Duration((float64(l.limit.Requests) - tokens) / float64(bucket.Limit()) * float64(time.Second))
+
+	return res
+}
+
+// sweep drops the buckets that refilled since the last sweep once a period, a full bucket is the same as a new one
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.limit.Period {
+		return
+	}
+	l.swept = now
+
+	for client, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(l.limit.Requests) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// WithRateLimit limits the requests of every client to the budget of the limiter, clients are told by their API
+// key, their user, the subject of their JWT or their IP address in this order. The RateLimit-* headers describe
+// the budget left, rejected requests get a Retry-After header
+func WithRateLimit(limiter *RateLimiter) func(echo.HandlerFunc) echo.HandlerFunc {
+	policy := fmt.Sprintf("%d;w=%d", limiter.limit.Requests, int(limiter.limit.Period.Seconds()))
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res := limiter.take(rateLimitClient(c))
+
+			header := c.Response().Header()
+			header.Set(headerRateLimitLimit, strconv.Itoa(limiter.limit.Requests))
+			header.Set(headerRateLimitRemaining, strconv.Itoa(res.remaining))
+			header.Set(headerRateLimitReset, strconv.Itoa(ceilSeconds(res.reset)))
+			header.Set(headerRateLimitPolicy, policy)
+
+			if !res.allowed {
+				retryAfter := ceilSeconds(res.retryAfter)
+				header.Set(headerRetryAfter, strconv.Itoa(retryAfter))
+
+				return utils.NewError(fmt.Sprintf("rate limit exceeded, retry in %d seconds", retryAfter),
+					utils.TooManyRequests)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// rateLimitClient returns the key of the bucket of the request
+func rateLimitClient(c echo.Context) string {
+	ctx := c.Request().Context()
+
+	if key, ok := auth.ExtractAPIKey(ctx); ok {
+		return "key:" + key.KeyID
+	}
+	if user, ok := auth.ExtractUser(ctx); ok {
+		return "user:" + user.UserID
+	}
+	if claims, ok := auth.ExtractClaims(ctx); ok && claims.Subject != "" {
+		return "service:" + claims.Subject
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+// IPExtractor returns the client IP address of requests for echo. The X-Forwarded-For header is only trusted when
+// the request comes from one of the proxies, the address is then the last one the proxies did not add. Without
+// proxies the address of the connection is used, so clients can not get new budgets by forging the header
+func IPExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		opts = append(opts, echo.TrustIPRange(proxy))
+	}
+
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
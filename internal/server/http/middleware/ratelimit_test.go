@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/suite"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitSuite))
+}
+
+type RateLimitSuite struct {
+	suite.Suite
+
+	e   *echo.Echo
+	now time.Time
+
+	limiter *RateLimiter
+	handler echo.HandlerFunc
+}
+
+func (suite *RateLimitSuite) SetupTest() {
+	suite.e = echo.New()
+	suite.e.IPExtractor = IPExtractor(nil)
+	suite.now = time.Date(2024, 1, 1, 1, 1, 1, 0, time.UTC)
+
+	suite.limiter = NewRateLimiter(RateLimit{Requests: 3, Period: 3 * time.Second})
+	suite.limiter.now = func() time.Time { return suite.now }
+
+	suite.handler = WithRateLimit(suite.limiter)(func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+}
+
+func (suite *RateLimitSuite) TestBudget() {
+	for remaining := 2; remaining >= 0; remaining-- {
+		rec, err := suite.serve("1.1.1.1", nil)
+		suite.Require().NoError(err)
+		suite.Require().Equal("3", rec.Header().Get(headerRateLimitLimit))
+		suite.Require().Equal(strconv.Itoa(remaining), rec.Header().Get(headerRateLimitRemaining))
+		suite.Require().Equal("3;w=3", rec.Header().Get(headerRateLimitPolicy))
+	}
+
+	rec, err := suite.serve("1.1.1.1", nil)
+	suite.Require().Equal(utils.TooManyRequests, utils.ErrorCode(err))
+	suite.Require().Equal("1", rec.Header().Get(headerRetryAfter))
+	suite.Require().Equal("0", rec.Header().Get(headerRateLimitRemaining))
+	suite.Require().Equal("3", rec.Header().Get(headerRateLimitReset))
+
+	// other clients have their own buckets
+	_, err = suite.serve("2.2.2.2", nil)
+	suite.Require().NoError(err)
+
+	// a token is added every second
+	suite.now = suite.now.Add(time.Second)
+	rec, err = suite.serve("1.1.1.1", nil)
+	suite.Require().NoError(err)
+	suite.Require().Equal("0", rec.Header().Get(headerRateLimitRemaining))
+}
+
+func (suite *RateLimitSuite) TestClients() {
+	user := func(req *http.Request) *http.Request {
+		return req.WithContext(auth.WrapUser(req.Context(), models.User{UserID: "user id"}))
+	}
+	key := func(req *http.Request) *http.Request {
+		return req.WithContext(auth.WrapAPIKey(user(req).Context(), models.APIKey{KeyID: "key id"}))
+	}
+
+	// a user is limited wherever the requests come from, the keys of the user have their own budgets
+	for i := 0; i < 3; i++ {
+		_, err := suite.serve("10.0.0."+strconv.Itoa(i), user)
+		suite.Require().NoError(err)
+	}
+
+	_, err := suite.serve("1.1.1.1", user)
+	suite.Require().Equal(utils.TooManyRequests, utils.ErrorCode(err))
+
+	_, err = suite.serve("1.1.1.1", key)
+	suite.Require().NoError(err)
+
+	_, err = suite.serve("1.1.1.1", nil)
+	suite.Require().NoError(err)
+}
+
+func (suite *RateLimitSuite) TestForwardedFor() {
+	forged := func(ip string) func(req *http.Request) *http.Request {
+		return func(req *http.Request) *http.Request {
+			req.Header.Set(echo.HeaderXForwardedFor, ip)
+			req.Header.Set(echo.HeaderXRealIP, ip)
+			return req
+		}
+	}
+
+	// the headers of clients do not reset their budget
+	for i := 0; i < 3; i++ {
+		_, err := suite.serve("1.1.1.1", forged("3.3.3."+strconv.Itoa(i)))
+		suite.Require().NoError(err)
+	}
+
+	_, err := suite.serve("1.1.1.1", forged("4.4.4.4"))
+	suite.Require().Equal(utils.TooManyRequests, utils.ErrorCode(err))
+
+	// behind trusted proxies the clients are told by the addresses the proxies add
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	suite.Require().NoError(err)
+	suite.e.IPExtractor = IPExtractor([]*net.IPNet{proxies})
+
+	for i := 0; i < 3; i++ {
+		_, err = suite.serve("10.0.0.1", forged("3.3.3."+strconv.Itoa(i)+", 2.2.2.2, 10.0.0.2"))
+		suite.Require().NoError(err)
+	}
+
+	_, err = suite.serve("10.0.0.1", forged("2.2.2.2"))
+	suite.Require().Equal(utils.TooManyRequests, utils.ErrorCode(err))
+
+	// proxies are trusted only if they are configured
+	_, err = suite.serve("192.168.0.1", forged("2.2.2.2"))
+	suite.Require().NoError(err)
+}
+
+func (suite *RateLimitSuite) TestSweep() {
+	_, err := suite.serve("1.1.1.1", nil)
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.limiter.buckets, 1)
+
+	// refilled buckets are dropped
+	suite.now = suite.now.Add(3 * time.Second)
+	_, err = suite.serve("2.2.2.2", nil)
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.limiter.buckets, 1)
+	suite.Require().Contains(suite.limiter.buckets, "ip:2.2.2.2")
+}
+
+func (suite *RateLimitSuite) serve(ip string, wrap func(req *http.Request) *http.Request) (*httptest.ResponseRecorder, error) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = ip + ":1234"
+	if wrap != nil {
+		req = wrap(req)
+	}
+
+	rec := httptest.NewRecorder()
+	return rec, suite.handler(suite.e.NewContext(req, rec))
+}
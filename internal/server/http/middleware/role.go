@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// WithRole rejects anonymous requests and requests which role is lower than the required one
+func WithRole(role string) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := auth.RequireRole(c.Request().Context(), role); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
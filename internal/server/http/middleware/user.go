@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"github.com/alserok/music_lib/internal/actor"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// WithUser authenticates the bearer token of the request and stores its user in the context, the user name is
+// the actor of the request. Requests without a token stay anonymous, invalid tokens are rejected. Requests
+// authenticated by WithAPIKey or WithJWT are passed as is
+func WithUser(authenticate func(ctx context.Context, token string) (models.User, error)) func(echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := auth.ExtractUser(c.Request().Context()); ok {
+				return next(c)
+			}
+			if _, ok := auth.ExtractClaims(c.Request().Context()); ok {
+				return next(c)
+			}
+
+			token, ok := BearerToken(c.Request())
+			if !ok {
+				return next(c)
+			}
+
+			user, err := authenticate(c.Request().Context(), token)
+			if err != nil {
+				return err
+			}
+
+			ctx := auth.WrapUser(c.Request().Context(), user)
+			ctx = actor.WrapActor(ctx, user.Name)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// BearerToken returns the token of the Authorization header
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get(echo.HeaderAuthorization)
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(bearerPrefix):])
+	return token, token != ""
+}
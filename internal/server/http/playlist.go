@@ -0,0 +1,132 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/playlist"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxPlaylistSongs = 1000
+)
+
+// @Summary ExportPlaylist
+// @Description Write the songs as a playlist file, the song link is the entry location
+// @Tags playlists
+// @Produce audio/x-mpegurl
+// @Produce application/xspf+xml
+// @Produce audio/x-scpls
+// @Param ids query string true "Comma separated song IDs in the playlist order"
+// @Param format query string false "m3u8 (default), xspf or pls"
+// @Param title query string false "Playlist title"
+// @Success 200 {string} string "Playlist"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlist/export [get]
+func (h *handler) ExportPlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received ExportPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = playlist.FormatM3U8
+	}
+
+	ids := strings.Split(c.QueryParam("ids"), ",")
+	if c.QueryParam("ids") == "" || len(ids) > maxPlaylistSongs {
+		return utils.NewError(fmt.Sprintf("number of ids must be between 1 and %d", maxPlaylistSongs), utils.BadRequest)
+	}
+
+	songs := make([]models.Song, len(ids))
+	for i, id := range ids {
+		song, err := h.srvc.GetSong(c.Request().Context(), strings.TrimSpace(id))
+		if err != nil {
+			return fmt.Errorf("failed to get song: %w", err)
+		}
+		songs[i] = song
+	}
+
+	var body strings.Builder
+	if err := playlist.Encode(&body, format, c.QueryParam("title"), songs); err != nil {
+		return fmt.Errorf("failed to encode playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed ExportPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="playlist.%s"`, format))
+	return c.Blob(http.StatusOK, playlist.ContentType(format), []byte(body.String()))
+}
+
+// @Summary ImportPlaylist
+// @Description Match the entries of a playlist file to library songs by song ID, link or similar artist and title
+// @Tags playlists
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "M3U8, XSPF or PLS playlist"
+// @Param format formData string false "m3u8, xspf or pls, guessed by the file extension if empty"
+// @Success 200 {array} models.PlaylistMatch "Matches in the playlist order and unmatched entries"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlist/import [post]
+func (h *handler) ImportPlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received ImportPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return utils.NewError("file is required", utils.BadRequest)
+	}
+
+	format := c.FormValue("format")
+	if format == "" {
+		format = playlist.FormatFromName(fileHeader.Filename)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return utils.NewError(err.Error(), utils.Internal)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	entries, err := playlist.Decode(file, format)
+	if err != nil {
+		return fmt.Errorf("failed to decode playlist: %w", err)
+	}
+	if len(entries) > maxPlaylistSongs {
+		return utils.NewError(fmt.Sprintf("playlist has more than %d entries", maxPlaylistSongs), utils.BadRequest)
+	}
+
+	matches, err := h.srvc.MatchPlaylist(c.Request().Context(), entries)
+	if err != nil {
+		return fmt.Errorf("failed to match playlist: %w", err)
+	}
+
+	unmatched := make([]models.PlaylistMatch, 0)
+	for _, match := range matches {
+		if match.Song == nil {
+			unmatched = append(unmatched, match)
+		}
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed ImportPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"matches": matches, "unmatched": unmatched})
+}
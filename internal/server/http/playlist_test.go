@@ -0,0 +1,129 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HTTPHandlersSuite) TestImportPlaylist() {
+	playlist := "#EXTM3U\n" +
+		"#EXTINF:-1,Muse - Uprising\n" +
+		"urn:musiclib:song:id1\n" +
+		"#EXTINF:-1,Unknown - Song\n" +
+		"https://example.com/starlight\n" +
+		"#EXTINF:-1,The Beatles - Let It Be (Remastered 2009)\n" +
+		"music/let_it_be.mp3\n" +
+		"#EXTINF:-1,Beyonce - Halo\n" +
+		"music/halo.mp3\n" +
+		"music/unknown.mp3\n"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "list.m3u8")
+	suite.Require().NoError(err)
+	_, err = file.Write([]byte(playlist))
+	suite.Require().NoError(err)
+	suite.Require().NoError(form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	rec := httptest.NewRecorder()
+
+	library := []models.Song{
+		{SongID: "id1", Group: "Muse", Song: "Uprising"},
+		{SongID: "id2", Group: "Muse", Song: "Starlight", Data: models.SongData{Link: "https://example.com/starlight"}},
+		{SongID: "id3", Group: "Beatles", Song: "Let it be"},
+		{SongID: "id4", Group: "Beyoncé", Song: "Halo"},
+	}
+
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id1")).
+		Return(library[0], nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSongs(gomock.Any(), gomock.Eq(models.SongFilter{Link: "https://example.com/starlight", Lim: 1})).
+		Return(library[1:2], nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSongs(gomock.Any(), gomock.Any()).
+		Return(nil, nil).
+		Times(3)
+	suite.repo.EXPECT().
+		StreamSongs(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, _ models.SongFilter, fn func(models.Song) error) error {
+			for _, song := range library {
+				suite.Require().NoError(fn(song))
+			}
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id3")).
+		Return(library[2], nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id4")).
+		Return(library[3], nil).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	suite.Require().NoError(suite.handler.ImportPlaylist(c))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string][]models.PlaylistMatch
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+
+	matches := res["matches"]
+	suite.Require().Len(matches, 5)
+	suite.Equal(models.MatchByID, matches[0].MatchedBy)
+	suite.Equal(models.MatchByLink, matches[1].MatchedBy)
+	suite.Equal("id2", matches[1].Song.SongID)
+	suite.Equal(models.MatchByTitle, matches[2].MatchedBy)
+	suite.Equal("id3", matches[2].Song.SongID)
+	suite.Equal(1.0, matches[2].Score)
+	suite.Equal("id4", matches[3].Song.SongID)
+	suite.Nil(matches[4].Song)
+
+	suite.Require().Len(res["unmatched"], 1)
+	suite.Equal(4, res["unmatched"][0].Index)
+}
+
+func (suite *HTTPHandlersSuite) TestExportPlaylist() {
+	req := httptest.NewRequest(http.MethodGet, "/?format=pls&ids=id1,id2", nil)
+	req = req.WithContext(logger.WrapLogger(req.Context(), suite.logger))
+	req = req.WithContext(logger.WrapIdentifier(req.Context()))
+	rec := httptest.NewRecorder()
+
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id1")).
+		Return(models.Song{SongID: "id1", Group: "Muse", Song: "Uprising", Data: models.SongData{Link: "https://example.com"}}, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("id2")).
+		Return(models.Song{}, utils.NewError("song not found", utils.NotFound)).
+		Times(1)
+
+	suite.logger.EXPECT().
+		Debug(gomock.Any(), gomock.Eq(logger.Arg{Key: "id", Val: logger.ExtractIdentifier(req.Context())})).
+		AnyTimes()
+
+	c := suite.e.NewContext(req, rec)
+	err := suite.handler.ExportPlaylist(c)
+	suite.Require().Error(err)
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusNotFound, code)
+}
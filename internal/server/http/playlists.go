@@ -0,0 +1,441 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// @Summary CreatePlaylist
+// @Description Create a playlist of the authenticated user, the visibility is private, unlisted or public and private by default. Playlists with smart rules get their songs from the rules on every read
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param playlist body models.NewPlaylist true "Name, description, visibility and smart rules of the playlist"
+// @Success 201 {object} models.Playlist "Created"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists [post]
+func (h *handler) CreatePlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received CreatePlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var newPlaylist models.NewPlaylist
+	if err := c.Bind(&newPlaylist); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	playlist, err := h.srvc.CreatePlaylist(c.Request().Context(), newPlaylist)
+	if err != nil {
+		return fmt.Errorf("failed to create playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed CreatePlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"playlist": playlist})
+}
+
+// @Summary GetPlaylists
+// @Description Get the playlists the authenticated user owns or collaborates on, recently updated first
+// @Tags playlists
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param limit query int true "Limit of playlists to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.Playlist "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists [get]
+func (h *handler) GetPlaylists(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetPlaylists request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, offset, err := parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	playlists, err := h.srvc.GetPlaylists(c.Request().Context(), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get playlists: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetPlaylists request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"playlists": playlists})
+}
+
+// @Summary GetPublicPlaylists
+// @Description Get the public playlists of all users, recently updated first
+// @Tags playlists
+// @Produce json
+// @Param limit query int true "Limit of playlists to return"
+// @Param offset query int true "Offset for pagination"
+// @Success 200 {array} models.Playlist "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/public [get]
+func (h *handler) GetPublicPlaylists(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetPublicPlaylists request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, offset, err := parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	playlists, err := h.srvc.GetPublicPlaylists(c.Request().Context(), lim, offset)
+	if err != nil {
+		return fmt.Errorf("failed to get public playlists: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetPublicPlaylists request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"playlists": playlists})
+}
+
+// @Summary GetPlaylist
+// @Description Get a playlist with its songs in order, private playlists are seen by their owner and collaborators only
+// @Tags playlists
+// @Produce json
+// @Param id path string true "Playlist ID"
+// @Success 200 {object} models.Playlist "Success"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id} [get]
+func (h *handler) GetPlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	playlist, err := h.srvc.GetPlaylist(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return fmt.Errorf("failed to get playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"playlist": playlist})
+}
+
+// @Summary EditPlaylist
+// @Description Replace the name, description and visibility of a playlist, requires its owner. The smart rules of smart playlists are replaced if they are set
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param playlist body models.NewPlaylist true "Name, description, visibility and smart rules of the playlist"
+// @Success 200 {object} models.Playlist "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id} [put]
+func (h *handler) EditPlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received EditPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var details models.NewPlaylist
+	if err := c.Bind(&details); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	playlist, err := h.srvc.EditPlaylist(c.Request().Context(), c.Param("id"), details)
+	if err != nil {
+		return fmt.Errorf("failed to edit playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed EditPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"playlist": playlist})
+}
+
+// @Summary DeletePlaylist
+// @Description Delete a playlist with its songs, requires its owner. The songs stay in the library
+// @Tags playlists
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Success 200 {object} interface{} "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id} [delete]
+func (h *handler) DeletePlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received DeletePlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.DeletePlaylist(c.Request().Context(), c.Param("id")); err != nil {
+		return fmt.Errorf("failed to delete playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed DeletePlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary AddPlaylistSong
+// @Description Add a library song to a playlist after or before an entry, at the end by default. The other songs keep
+// @Description their positions. Requires the owner or a collaborator of the playlist
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param placement body models.PlaylistPlacement true "Song ID and the entries to place the song between"
+// @Success 201 {object} models.PlaylistSong "Created"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 409 {object} string "The entries are no longer neighbours or the playlist is smart"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id}/songs [post]
+func (h *handler) AddPlaylistSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received AddPlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var placement models.PlaylistPlacement
+	if err := c.Bind(&placement); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	entry, err := h.srvc.AddPlaylistSong(c.Request().Context(), c.Param("id"), placement)
+	if err != nil {
+		return fmt.Errorf("failed to add playlist song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed AddPlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{"entry": entry})
+}
+
+// @Summary MovePlaylistSong
+// @Description Move an entry of a playlist after or before another entry, to the end by default. The other songs keep
+// @Description their positions. Requires the owner or a collaborator of the playlist
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param entry path string true "Entry ID"
+// @Param placement body models.PlaylistPlacement true "The entries to place the song between"
+// @Success 200 {object} models.PlaylistSong "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 409 {object} string "The entries are no longer neighbours or the playlist is smart"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id}/songs/{entry} [put]
+func (h *handler) MovePlaylistSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received MovePlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var placement models.PlaylistPlacement
+	if err := c.Bind(&placement); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	entry, err := h.srvc.MovePlaylistSong(c.Request().Context(), c.Param("id"), c.Param("entry"), placement)
+	if err != nil {
+		return fmt.Errorf("failed to move playlist song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed MovePlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"entry": entry})
+}
+
+// @Summary RemovePlaylistSong
+// @Description Remove an entry from a playlist, requires the owner or a collaborator of the playlist
+// @Tags playlists
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param entry path string true "Entry ID"
+// @Success 200 {object} interface{} "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 409 {object} string "The playlist is smart"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id}/songs/{entry} [delete]
+func (h *handler) RemovePlaylistSong(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RemovePlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.RemovePlaylistSong(c.Request().Context(), c.Param("id"), c.Param("entry")); err != nil {
+		return fmt.Errorf("failed to remove playlist song: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RemovePlaylistSong request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// @Summary AddPlaylistCollaborator
+// @Description Let a user edit the songs of a playlist, requires the owner of the playlist
+// @Tags playlists
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param name path string true "User name"
+// @Success 200 {object} models.Playlist "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id}/collaborators/{name} [put]
+func (h *handler) AddPlaylistCollaborator(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received AddPlaylistCollaborator request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	playlist, err := h.srvc.AddPlaylistCollaborator(c.Request().Context(), c.Param("id"), c.Param("name"))
+	if err != nil {
+		return fmt.Errorf("failed to add playlist collaborator: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed AddPlaylistCollaborator request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"playlist": playlist})
+}
+
+// @Summary RemovePlaylistCollaborator
+// @Description Remove a collaborator from a playlist, requires the owner of the playlist or the collaborator
+// @Tags playlists
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param id path string true "Playlist ID"
+// @Param name path string true "User name"
+// @Success 200 {object} interface{} "Success"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/{id}/collaborators/{name} [delete]
+func (h *handler) RemovePlaylistCollaborator(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received RemovePlaylistCollaborator request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	if err := h.srvc.RemovePlaylistCollaborator(c.Request().Context(), c.Param("id"), c.Param("name")); err != nil {
+		return fmt.Errorf("failed to remove playlist collaborator: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed RemovePlaylistCollaborator request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+func parsePagination(c echo.Context) (int, int, error) {
+	lim, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil {
+		return 0, 0, utils.NewError("failed to parse limit", utils.BadRequest)
+	}
+
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil {
+		return 0, 0, utils.NewError("failed to parse offset", utils.BadRequest)
+	}
+
+	return lim, offset, nil
+}
+
+// @Summary PreviewSmartPlaylist
+// @Description Get the songs a smart playlist with the rules, the sort and the limit would have now
+// @Tags playlists
+// @Accept json
+// @Produce json
+// @Param smart body models.SmartPlaylist true "Rules, sort and limit of the smart playlist"
+// @Success 200 {array} models.Song "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 500 {object} string "Internal error"
+// @Router /playlists/preview [post]
+func (h *handler) PreviewSmartPlaylist(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received PreviewSmartPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	var smart models.SmartPlaylist
+	if err := c.Bind(&smart); err != nil {
+		return utils.NewError(err.Error(), utils.BadRequest)
+	}
+
+	songs, err := h.srvc.PreviewSmartPlaylist(c.Request().Context(), smart)
+	if err != nil {
+		return fmt.Errorf("failed to preview smart playlist: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed PreviewSmartPlaylist request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"songs": songs})
+}
@@ -0,0 +1,522 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func (suite *HTTPHandlersSuite) TestCreatePlaylist() {
+	var created models.Playlist
+	suite.repo.EXPECT().
+		CreatePlaylist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, playlist models.Playlist) error {
+			created = playlist
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, playlistID string) (models.Playlist, error) {
+			suite.Require().Equal(created.PlaylistID, playlistID)
+			return created, nil
+		}).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPost, models.NewPlaylist{Name: " road trip "}), auth.RoleViewer)
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(suite.handler.CreatePlaylist(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	suite.Require().Equal("road trip", created.Name)
+	suite.Require().Equal(models.PlaylistPrivate, created.Visibility)
+	suite.Require().Equal("user id", created.OwnerID)
+
+	var res struct {
+		Playlist models.Playlist `json:"playlist"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal(created.PlaylistID, res.Playlist.PlaylistID)
+}
+
+func (suite *HTTPHandlersSuite) TestCreatePlaylistInvalid() {
+	tests := []struct {
+		name     string
+		playlist models.NewPlaylist
+		req      func(req *http.Request) *http.Request
+		code     int
+	}{
+		{
+			name:     "anonymous",
+			playlist: models.NewPlaylist{Name: "mix"},
+			req:      func(req *http.Request) *http.Request { return req },
+			code:     http.StatusUnauthorized,
+		},
+		{
+			name:     "no name",
+			playlist: models.NewPlaylist{Name: " "},
+			req:      func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:     "unknown visibility",
+			playlist: models.NewPlaylist{Name: "mix", Visibility: "friends"},
+			req:      func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:     http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := tc.req(suite.authRequest(http.MethodPost, tc.playlist))
+
+			err := suite.handler.CreatePlaylist(suite.e.NewContext(req, httptest.NewRecorder()))
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestGetPlaylistVisibility() {
+	playlists := map[string]models.Playlist{
+		"private": {PlaylistID: "private", OwnerID: "owner id", Visibility: models.PlaylistPrivate,
+			Collaborators: []models.PlaylistCollaborator{{UserID: "user id", Name: "collaborator"}}},
+		"foreign":  {PlaylistID: "foreign", OwnerID: "owner id", Visibility: models.PlaylistPrivate},
+		"unlisted": {PlaylistID: "unlisted", OwnerID: "owner id", Visibility: models.PlaylistUnlisted},
+	}
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, playlistID string) (models.Playlist, error) {
+			return playlists[playlistID], nil
+		}).
+		AnyTimes()
+	suite.repo.EXPECT().
+		GetPlaylistSongs(gomock.Any(), gomock.Any()).
+		Return([]models.PlaylistSong{{EntryID: "entry", Position: "a0"}}, nil).
+		AnyTimes()
+
+	get := func(playlistID string, req *http.Request) error {
+		c := suite.e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id")
+		c.SetParamValues(playlistID)
+		return suite.handler.GetPlaylist(c)
+	}
+
+	// collaborators see private playlists
+	suite.Require().NoError(get("private", withRole(suite.authRequest(http.MethodGet, nil), auth.RoleViewer)))
+	// unlisted playlists are seen by anyone with the ID
+	suite.Require().NoError(get("unlisted", suite.authRequest(http.MethodGet, nil)))
+	// admins see all playlists
+	suite.Require().NoError(get("foreign", withRole(suite.authRequest(http.MethodGet, nil), auth.RoleAdmin)))
+
+	err := get("foreign", withRole(suite.authRequest(http.MethodGet, nil), auth.RoleEditor))
+	suite.Require().True(utils.IsNotFound(err))
+
+	err = get("private", suite.authRequest(http.MethodGet, nil))
+	suite.Require().True(utils.IsNotFound(err))
+}
+
+func (suite *HTTPHandlersSuite) TestEditPlaylistRights() {
+	playlist := models.Playlist{PlaylistID: "id", OwnerID: "owner id", Visibility: models.PlaylistPublic,
+		Collaborators: []models.PlaylistCollaborator{{UserID: "user id", Name: "collaborator"}}}
+
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("id")).
+		Return(playlist, nil).
+		AnyTimes()
+
+	req := withRole(suite.authRequest(http.MethodPut, models.NewPlaylist{Name: "renamed"}), auth.RoleEditor)
+	c := suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	// collaborators edit the songs only
+	err := suite.handler.EditPlaylist(c)
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(err))
+
+	req = withRole(suite.authRequest(http.MethodDelete, nil), auth.RoleEditor)
+	c = suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	err = suite.handler.DeletePlaylist(c)
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(err))
+}
+
+func (suite *HTTPHandlersSuite) TestAddPlaylistSong() {
+	playlist := models.Playlist{PlaylistID: "id", OwnerID: "user id", Visibility: models.PlaylistPrivate}
+	songs := []models.PlaylistSong{
+		{EntryID: "first", Position: "a0"},
+		{EntryID: "second", Position: "a1"},
+	}
+
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("id")).
+		Return(playlist, nil).
+		AnyTimes()
+	suite.repo.EXPECT().
+		GetPlaylistSongs(gomock.Any(), gomock.Eq("id")).
+		DoAndReturn(func(_ any, _ string) ([]models.PlaylistSong, error) {
+			return append([]models.PlaylistSong(nil), songs...), nil
+		}).
+		AnyTimes()
+
+	var added []models.PlaylistSong
+	suite.repo.EXPECT().
+		AddPlaylistSong(gomock.Any(), gomock.Eq("id"), gomock.Any()).
+		DoAndReturn(func(_ any, _ string, entry models.PlaylistSong) error {
+			added = append(added, entry)
+			// the first attempt loses the position to a concurrent change
+			if len(added) == 1 {
+				return utils.NewError("position is taken", utils.Conflict)
+			}
+			songs = append(songs, entry)
+			return nil
+		}).
+		Times(2)
+
+	req := withRole(suite.authRequest(http.MethodPost, models.PlaylistPlacement{SongID: "song id", After: "first"}),
+		auth.RoleViewer)
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	suite.Require().NoError(suite.handler.AddPlaylistSong(c))
+	suite.Equal(http.StatusCreated, rec.Code)
+
+	suite.Require().Len(added, 2)
+	suite.Require().Equal("song id", added[1].Song.SongID)
+	suite.Require().Equal("user id", added[1].AddedBy)
+	suite.Require().Equal(added[0].EntryID, added[1].EntryID)
+	// the song is placed between the entries without moving them
+	suite.Require().Greater(added[1].Position, "a0")
+	suite.Require().Less(added[1].Position, "a1")
+
+	var res struct {
+		Entry models.PlaylistSong `json:"entry"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal(added[1].EntryID, res.Entry.EntryID)
+}
+
+func (suite *HTTPHandlersSuite) TestMovePlaylistSong() {
+	playlist := models.Playlist{PlaylistID: "id", OwnerID: "owner id", Visibility: models.PlaylistPrivate,
+		Collaborators: []models.PlaylistCollaborator{{UserID: "user id", Name: "collaborator"}}}
+	songs := []models.PlaylistSong{
+		{EntryID: "first", Position: "a0"},
+		{EntryID: "second", Position: "a1"},
+		{EntryID: "third", Position: "a2"},
+	}
+
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("id")).
+		Return(playlist, nil).
+		AnyTimes()
+	suite.repo.EXPECT().
+		GetPlaylistSongs(gomock.Any(), gomock.Eq("id")).
+		DoAndReturn(func(_ any, _ string) ([]models.PlaylistSong, error) {
+			return append([]models.PlaylistSong(nil), songs...), nil
+		}).
+		AnyTimes()
+
+	move := func(entryID string, placement models.PlaylistPlacement) error {
+		req := withRole(suite.authRequest(http.MethodPut, placement), auth.RoleViewer)
+		c := suite.e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id", "entry")
+		c.SetParamValues("id", entryID)
+		return suite.handler.MovePlaylistSong(c)
+	}
+
+	suite.repo.EXPECT().
+		MovePlaylistSong(gomock.Any(), gomock.Eq("id"), gomock.Eq("third"), gomock.Any()).
+		DoAndReturn(func(_ any, _, _ string, position string) error {
+			suite.Require().Less(position, "a0")
+			return nil
+		}).
+		Times(1)
+	suite.Require().NoError(move("third", models.PlaylistPlacement{Before: "first"}))
+
+	tests := []struct {
+		name      string
+		entryID   string
+		placement models.PlaylistPlacement
+		code      int
+	}{
+		{
+			name:      "next to itself",
+			entryID:   "first",
+			placement: models.PlaylistPlacement{After: "first"},
+			code:      utils.BadRequest,
+		},
+		{
+			name:      "unknown neighbour",
+			entryID:   "first",
+			placement: models.PlaylistPlacement{After: "missing"},
+			code:      utils.BadRequest,
+		},
+		{
+			name:      "not neighbours",
+			entryID:   "second",
+			placement: models.PlaylistPlacement{After: "third", Before: "first"},
+			code:      utils.Conflict,
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			err := move(tc.entryID, tc.placement)
+			suite.Require().Error(err)
+			suite.Equal(tc.code, utils.ErrorCode(err), err.Error())
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestPlaylistCollaborators() {
+	playlist := models.Playlist{PlaylistID: "id", OwnerID: "user id", Visibility: models.PlaylistPrivate}
+
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("id")).
+		Return(playlist, nil).
+		Times(2)
+	suite.repo.EXPECT().
+		GetUserByName(gomock.Any(), gomock.Eq("alice")).
+		Return(models.User{UserID: "alice id", Name: "alice"}, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		AddPlaylistCollaborator(gomock.Any(), gomock.Eq("id"), gomock.Eq("alice id")).
+		Return(nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPut, nil), auth.RoleViewer)
+	c := suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id", "name")
+	c.SetParamValues("id", " Alice ")
+
+	suite.Require().NoError(suite.handler.AddPlaylistCollaborator(c))
+
+	// collaborators leave on their own but do not remove others
+	playlist.OwnerID = "owner id"
+	playlist.Collaborators = []models.PlaylistCollaborator{
+		{UserID: "user id", Name: "bob"},
+		{UserID: "alice id", Name: "alice"},
+	}
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("shared")).
+		Return(playlist, nil).
+		Times(2)
+	suite.repo.EXPECT().
+		RemovePlaylistCollaborator(gomock.Any(), gomock.Eq("id"), gomock.Eq("user id")).
+		Return(nil).
+		Times(1)
+
+	remove := func(name string) error {
+		req := withRole(suite.authRequest(http.MethodDelete, nil), auth.RoleViewer)
+		c := suite.e.NewContext(req, httptest.NewRecorder())
+		c.SetParamNames("id", "name")
+		c.SetParamValues("shared", name)
+		return suite.handler.RemovePlaylistCollaborator(c)
+	}
+
+	suite.Require().NoError(remove("bob"))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(remove("alice")))
+}
+
+func (suite *HTTPHandlersSuite) TestPlaylistRoutesScopes() {
+	e := echo.New()
+	setupRoutes(e, suite.handler, Options{})
+
+	// keys with the read scope only can not change playlists
+	suite.repo.EXPECT().
+		UseAPIKey(gomock.Any(), gomock.Any()).
+		Return(models.APIKey{KeyID: "key id", OwnerID: "user id", Scopes: []string{auth.ScopeRead}}, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetUser(gomock.Any(), gomock.Eq("user id")).
+		Return(models.User{UserID: "user id", Name: "user", Role: auth.RoleEditor}, nil).
+		Times(1)
+	suite.logger.EXPECT().Debug(gomock.Any(), gomock.Any()).AnyTimes()
+	suite.logger.EXPECT().Info(gomock.Any(), gomock.Any()).AnyTimes()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/playlists", strings.NewReader(`{"name":"mix"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-API-Key", "mlk_secret")
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+	suite.Equal(http.StatusForbidden, rec.Code)
+}
+
+func (suite *HTTPHandlersSuite) TestSmartPlaylist() {
+	smart := models.SmartPlaylist{
+		Rules: models.SmartRules{Conditions: []models.SmartCondition{{Group: "group", MinRating: 4}}},
+		Sort:  models.SortRating,
+	}
+	playlist := models.Playlist{PlaylistID: "id", OwnerID: "user id", Visibility: models.PlaylistPrivate}
+
+	suite.repo.EXPECT().
+		CreatePlaylist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, created models.Playlist) error {
+			playlist.Smart = created.Smart
+			return nil
+		}).
+		Times(1)
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, _ string) (models.Playlist, error) {
+			return playlist, nil
+		}).
+		AnyTimes()
+	suite.repo.EXPECT().
+		GetSongs(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, filter models.SongFilter) ([]models.Song, error) {
+			suite.Require().Equal(100, filter.Lim)
+			suite.Require().Equal(models.SortRating, filter.Sort)
+			suite.Require().Equal(models.MatchAll, filter.Rules.Match)
+			suite.Require().Equal(smart.Rules.Conditions, filter.Rules.Conditions)
+			return []models.Song{{SongID: "song id"}}, nil
+		}).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodPost, models.NewPlaylist{Name: "top rated", Smart: &smart}),
+		auth.RoleViewer)
+	suite.Require().NoError(suite.handler.CreatePlaylist(suite.e.NewContext(req, httptest.NewRecorder())))
+
+	// the default limit and match are stored with the rules
+	suite.Require().NotNil(playlist.Smart)
+	suite.Require().Equal(100, playlist.Smart.Limit)
+	suite.Require().Equal(models.MatchAll, playlist.Smart.Rules.Match)
+
+	rec := httptest.NewRecorder()
+	c := suite.e.NewContext(withRole(suite.authRequest(http.MethodGet, nil), auth.RoleViewer), rec)
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+	suite.Require().NoError(suite.handler.GetPlaylist(c))
+
+	var res struct {
+		Playlist models.Playlist `json:"playlist"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Len(res.Playlist.Songs, 1)
+	suite.Require().Equal("song id", res.Playlist.Songs[0].Song.SongID)
+
+	// the songs follow the rules only
+	req = withRole(suite.authRequest(http.MethodPost, models.PlaylistPlacement{SongID: "song id"}), auth.RoleViewer)
+	c = suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+	suite.Require().Equal(utils.Conflict, utils.ErrorCode(suite.handler.AddPlaylistSong(c)))
+}
+
+func (suite *HTTPHandlersSuite) TestEditPlaylistSmart() {
+	suite.repo.EXPECT().
+		GetPlaylist(gomock.Any(), gomock.Eq("id")).
+		Return(models.Playlist{PlaylistID: "id", OwnerID: "user id", Visibility: models.PlaylistPrivate}, nil).
+		AnyTimes()
+
+	smart := &models.SmartPlaylist{Rules: models.SmartRules{Conditions: []models.SmartCondition{{MinPlays: 1}}}}
+	req := withRole(suite.authRequest(http.MethodPut, models.NewPlaylist{Name: "mix", Smart: smart}), auth.RoleViewer)
+	c := suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	err := suite.handler.EditPlaylist(c)
+	suite.Require().Equal(utils.BadRequest, utils.ErrorCode(err))
+}
+
+func (suite *HTTPHandlersSuite) TestPreviewSmartPlaylist() {
+	suite.repo.EXPECT().
+		GetSongs(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, filter models.SongFilter) ([]models.Song, error) {
+			suite.Require().Equal(10, filter.Lim)
+			suite.Require().Len(filter.Rules.Groups, 1)
+			return []models.Song{{SongID: "song id"}}, nil
+		}).
+		Times(1)
+
+	smart := models.SmartPlaylist{
+		Rules: models.SmartRules{
+			Match:  models.MatchAny,
+			Groups: []models.SmartRules{{Conditions: []models.SmartCondition{{AddedWithinDays: 30}}}},
+		},
+		Limit: 10,
+	}
+
+	rec := httptest.NewRecorder()
+	suite.Require().NoError(suite.handler.PreviewSmartPlaylist(
+		suite.e.NewContext(suite.authRequest(http.MethodPost, smart), rec)))
+
+	var res map[string][]models.Song
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal([]models.Song{{SongID: "song id"}}, res["songs"])
+}
+
+func (suite *HTTPHandlersSuite) TestPreviewSmartPlaylistInvalid() {
+	negative, deep := int64(-1), models.SmartRules{Conditions: []models.SmartCondition{{MinPlays: 1}}}
+	for range 4 {
+		deep = models.SmartRules{Groups: []models.SmartRules{deep}}
+	}
+
+	tests := []struct {
+		name  string
+		smart models.SmartPlaylist
+	}{
+		{
+			name:  "unknown match",
+			smart: models.SmartPlaylist{Rules: models.SmartRules{Match: "some"}},
+		},
+		{
+			name:  "unknown sort",
+			smart: models.SmartPlaylist{Sort: "random"},
+		},
+		{
+			name:  "limit too high",
+			smart: models.SmartPlaylist{Limit: 1000},
+		},
+		{
+			name: "empty condition",
+			smart: models.SmartPlaylist{Rules: models.SmartRules{
+				Conditions: []models.SmartCondition{{}},
+			}},
+		},
+		{
+			name: "empty nested group",
+			smart: models.SmartPlaylist{Rules: models.SmartRules{
+				Groups: []models.SmartRules{{Match: models.MatchAny}},
+			}},
+		},
+		{
+			name: "negative plays",
+			smart: models.SmartPlaylist{Rules: models.SmartRules{
+				Conditions: []models.SmartCondition{{MaxPlays: &negative}},
+			}},
+		},
+		{
+			name: "rating too high",
+			smart: models.SmartPlaylist{Rules: models.SmartRules{
+				Conditions: []models.SmartCondition{{MinRating: 6}},
+			}},
+		},
+		{
+			name:  "too deep",
+			smart: models.SmartPlaylist{Rules: deep},
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := suite.authRequest(http.MethodPost, tc.smart)
+
+			err := suite.handler.PreviewSmartPlaylist(suite.e.NewContext(req, httptest.NewRecorder()))
+			suite.Require().Equal(utils.BadRequest, utils.ErrorCode(err))
+		})
+	}
+}
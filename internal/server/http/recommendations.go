@@ -0,0 +1,113 @@
+package http
+
+import (
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+// @Summary GetSimilarSongs
+// @Description Get the songs most similar to a song by lyrics, artist and release era, the most similar first.
+// @Description The score of the songs is from 0 to 1
+// @Tags recommendations
+// @Produce json
+// @Param id path string true "Song ID"
+// @Param limit query int true "Limit of songs to return, at most 50"
+// @Success 200 {array} models.SimilarSong "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 404 {object} string "Not found"
+// @Failure 500 {object} string "Internal error"
+// @Failure 503 {object} string "Index is not built yet"
+// @Router /songs/{id}/similar [get]
+func (h *handler) GetSimilarSongs(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetSimilarSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil {
+		return utils.NewError("failed to parse limit", utils.BadRequest)
+	}
+
+	songs, err := h.srvc.GetSimilarSongs(c.Request().Context(), c.Param("id"), lim)
+	if err != nil {
+		return fmt.Errorf("failed to get similar songs: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetSimilarSongs request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"songs": songs})
+}
+
+// @Summary GetRecommendations
+// @Description Get the songs recommended to the authenticated user among the songs they neither played nor
+// @Description favorited. Songs played by the listeners of their songs go first with the reason "listeners", the
+// @Description popular songs follow with the reason "popular", so new users get the popular ones
+// @Tags recommendations
+// @Produce json
+// @Param Authorization header string true "Bearer token"
+// @Param limit query int true "Limit of songs to return, at most 50"
+// @Success 200 {array} models.Recommendation "Success"
+// @Failure 400 {object} string "Bad request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 500 {object} string "Internal error"
+// @Router /users/me/recommendations [get]
+func (h *handler) GetRecommendations(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received GetRecommendations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	lim, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil {
+		return utils.NewError("failed to parse limit", utils.BadRequest)
+	}
+
+	recommendations, err := h.srvc.GetRecommendations(c.Request().Context(), lim)
+	if err != nil {
+		return fmt.Errorf("failed to get recommendations: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed GetRecommendations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"recommendations": recommendations})
+}
+
+// @Summary TrainRecommendations
+// @Description Train the recommendations on the plays and favorites of all users now instead of waiting for
+// @Description the scheduled training
+// @Tags admin
+// @Produce json
+// @Success 200 {object} int "Number of song neighbors"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 500 {object} string "Internal error"
+// @Router /admin/recommendations/train [post]
+func (h *handler) TrainRecommendations(c echo.Context) error {
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("received TrainRecommendations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	neighbors, err := h.srvc.TrainRecommendations(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("failed to train recommendations: %w", err)
+	}
+
+	logger.ExtractLogger(c.Request().Context()).
+		Debug("passed TrainRecommendations request",
+			logger.WithArg("id", logger.ExtractIdentifier(c.Request().Context())),
+		)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{"neighbors": neighbors})
+}
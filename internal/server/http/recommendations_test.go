@@ -0,0 +1,284 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/golang/mock/gomock"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (suite *HTTPHandlersSuite) TestGetSimilarSongs() {
+	library := []models.Song{
+		{SongID: "id1", Group: "Muse", Song: "Starlight", Data: models.SongData{
+			ReleaseDate: time.Date(2006, 9, 4, 0, 0, 0, 0, time.UTC),
+			Text:        "Far away\\nThe ship is taking me far away\\nFar away from the memories",
+		}},
+		{SongID: "id2", Group: "Muse", Song: "Hoodoo", Data: models.SongData{
+			ReleaseDate: time.Date(2006, 7, 3, 0, 0, 0, 0, time.UTC),
+			Text:        "Far away from the memories of the people who care",
+		}},
+		{SongID: "id3", Group: "The Beatles", Song: "Yesterday", Data: models.SongData{
+			ReleaseDate: time.Date(1965, 8, 6, 0, 0, 0, 0, time.UTC),
+			Text:        "Yesterday all my troubles seemed so far away",
+		}},
+		{SongID: "id4", Group: "ABBA", Song: "Waterloo", Data: models.SongData{Text: "My my, at Waterloo Napoleon did surrender"}},
+	}
+
+	suite.repo.EXPECT().
+		StreamSongs(gomock.Any(), gomock.Eq(models.SongFilter{}), gomock.Any()).
+		DoAndReturn(func(_ any, _ models.SongFilter, fn func(models.Song) error) error {
+			for _, song := range library {
+				suite.Require().NoError(fn(song))
+			}
+			return nil
+		}).
+		Times(1)
+
+	byID := make(map[string]models.Song)
+	for _, song := range library {
+		byID[song.SongID] = song
+	}
+	// the results are fetched in one query
+	getSongsByID := func(_ any, songIDs []string) ([]models.Song, error) {
+		songs := make([]models.Song, 0, len(songIDs))
+		for _, songID := range songIDs {
+			songs = append(songs, byID[songID])
+		}
+		return songs, nil
+	}
+	suite.repo.EXPECT().
+		GetSongsByID(gomock.Any(), gomock.Any()).
+		DoAndReturn(getSongsByID).
+		Times(1)
+
+	// the index is built by the job, not by the requests
+	admin := withRole(suite.authRequest(http.MethodPost, nil), auth.RoleAdmin).Context()
+	indexed, err := suite.handler.srvc.RebuildSimilarityIndex(admin)
+	suite.Require().NoError(err)
+	suite.Require().Equal(len(library), indexed)
+
+	getSimilar := func() []models.SimilarSong {
+		req := withRole(suite.authRequest(http.MethodGet, nil), auth.RoleViewer)
+		req.URL.RawQuery = "limit=5"
+		rec := httptest.NewRecorder()
+		c := suite.e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("id1")
+
+		suite.Require().NoError(suite.handler.GetSimilarSongs(c))
+		suite.Equal(http.StatusOK, rec.Code)
+
+		var res struct {
+			Songs []models.SimilarSong `json:"songs"`
+		}
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+		return res.Songs
+	}
+
+	// the song of the same artist and era goes first, the one sharing nothing is left out
+	songs := getSimilar()
+	suite.Require().Len(songs, 2)
+	suite.Equal("id2", songs[0].Song.SongID)
+	suite.Equal("id3", songs[1].Song.SongID)
+	suite.Greater(songs[0].Score, songs[1].Score)
+	suite.Greater(songs[1].Score, 0.0)
+
+	// edited songs are indexed again without rebuilding the index
+	edited := library[3]
+	edited.Group, edited.Data.Text = "Muse", "The ship is taking me far away"
+
+	suite.repo.EXPECT().
+		EditSong(gomock.Any(), gomock.Eq(edited)).
+		Return(nil).
+		Times(1)
+	// the edited song is refreshed and the results are fetched in one query each
+	suite.repo.EXPECT().
+		GetSongsByID(gomock.Any(), gomock.Eq([]string{"id4"})).
+		Return([]models.Song{edited}, nil).
+		Times(1)
+	suite.repo.EXPECT().
+		GetSongsByID(gomock.Any(), gomock.Len(3)).
+		DoAndReturn(getSongsByID).
+		Times(1)
+
+	ctx := withRole(suite.authRequest(http.MethodPut, nil), auth.RoleEditor).Context()
+	suite.Require().NoError(suite.handler.srvc.EditSong(ctx, edited))
+	byID[edited.SongID] = edited
+
+	songs = getSimilar()
+	suite.Require().Len(songs, 3)
+	suite.ElementsMatch([]string{"id2", "id4"}, []string{songs[0].Song.SongID, songs[1].Song.SongID})
+	suite.Equal("id3", songs[2].Song.SongID)
+}
+
+func (suite *HTTPHandlersSuite) TestGetSimilarSongsInvalid() {
+	// songs are not similar to anything till the index is built
+	req := suite.authRequest(http.MethodGet, nil)
+	req.URL.RawQuery = "limit=10"
+	c := suite.e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("id")
+
+	err := suite.handler.GetSimilarSongs(c)
+	suite.Require().Error(err)
+	code, _ := utils.FromErrorToHTTP(req.Context(), err)
+	suite.Equal(http.StatusServiceUnavailable, code)
+
+	suite.repo.EXPECT().
+		StreamSongs(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil).
+		Times(1)
+
+	admin := withRole(suite.authRequest(http.MethodPost, nil), auth.RoleAdmin).Context()
+	_, err = suite.handler.srvc.RebuildSimilarityIndex(admin)
+	suite.Require().NoError(err)
+
+	suite.repo.EXPECT().
+		GetSong(gomock.Any(), gomock.Eq("missing")).
+		Return(models.Song{}, utils.NewError("song not found", utils.NotFound)).
+		Times(1)
+
+	tests := []struct {
+		name   string
+		songID string
+		query  string
+		code   int
+	}{
+		{name: "no limit", songID: "id", query: "", code: http.StatusBadRequest},
+		{name: "zero limit", songID: "id", query: "limit=0", code: http.StatusBadRequest},
+		{name: "too high limit", songID: "id", query: "limit=51", code: http.StatusBadRequest},
+		{name: "missing song", songID: "missing", query: "limit=10", code: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := suite.authRequest(http.MethodGet, nil)
+			req.URL.RawQuery = tc.query
+			c := suite.e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames("id")
+			c.SetParamValues(tc.songID)
+
+			err := suite.handler.GetSimilarSongs(c)
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
+
+func (suite *HTTPHandlersSuite) TestTrainRecommendations() {
+	interactions := []models.Interaction{
+		{UserID: "user1", SongID: "id1", Plays: 1},
+		{UserID: "user1", SongID: "id2", Plays: 1},
+		{UserID: "user2", SongID: "id2", Plays: 1},
+		{UserID: "user2", SongID: "id1", Plays: 1},
+		{UserID: "user3", SongID: "id1", Plays: 1},
+		{UserID: "user3", SongID: "id3", Plays: 1},
+	}
+
+	suite.repo.EXPECT().
+		StreamInteractions(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, fn func(models.Interaction) error) error {
+			for _, interaction := range interactions {
+				suite.Require().NoError(fn(interaction))
+			}
+			return nil
+		}).
+		Times(1)
+	// the third song has a single listener in common with the first one, so it is no neighbor
+	suite.repo.EXPECT().
+		ReplaceSongNeighbors(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ any, neighbors []models.SongNeighbor) error {
+			suite.Require().Len(neighbors, 2)
+			suite.Equal("id1", neighbors[0].SongID)
+			suite.Equal("id2", neighbors[0].NeighborID)
+			suite.Equal("id2", neighbors[1].SongID)
+			suite.Equal("id1", neighbors[1].NeighborID)
+			suite.InDelta(2/math.Sqrt(6), neighbors[0].Score, 1e-9)
+			suite.InDelta(2/math.Sqrt(6), neighbors[1].Score, 1e-9)
+			return nil
+		}).
+		Times(1)
+
+	rec := httptest.NewRecorder()
+	req := withRole(suite.authRequest(http.MethodPost, nil), auth.RoleAdmin)
+	suite.Require().NoError(suite.handler.TrainRecommendations(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string]int
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Equal(2, res["neighbors"])
+
+	req = withRole(suite.authRequest(http.MethodPost, nil), auth.RoleEditor)
+	err := suite.handler.TrainRecommendations(suite.e.NewContext(req, httptest.NewRecorder()))
+	suite.Require().Equal(utils.Forbidden, utils.ErrorCode(err))
+}
+
+func (suite *HTTPHandlersSuite) TestGetRecommendations() {
+	suite.repo.EXPECT().
+		GetRecommendations(gomock.Any(), gomock.Eq("user id"), gomock.Eq(5)).
+		Return([]models.Recommendation{
+			{Song: models.Song{SongID: "id1"}, Score: 0.5},
+			{Song: models.Song{SongID: "id2"}},
+		}, nil).
+		Times(1)
+
+	req := withRole(suite.authRequest(http.MethodGet, nil), auth.RoleViewer)
+	req.URL.RawQuery = "limit=5"
+	rec := httptest.NewRecorder()
+
+	suite.Require().NoError(suite.handler.GetRecommendations(suite.e.NewContext(req, rec)))
+	suite.Equal(http.StatusOK, rec.Code)
+
+	var res map[string][]models.Recommendation
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	suite.Require().Equal([]models.Recommendation{
+		{Song: models.Song{SongID: "id1"}, Score: 0.5, Reason: models.RecommendedByListeners},
+		{Song: models.Song{SongID: "id2"}, Reason: models.RecommendedByPopularity},
+	}, res["recommendations"])
+}
+
+func (suite *HTTPHandlersSuite) TestGetRecommendationsInvalid() {
+	tests := []struct {
+		name  string
+		query string
+		req   func(req *http.Request) *http.Request
+		code  int
+	}{
+		{
+			name:  "anonymous",
+			query: "limit=5",
+			req:   func(req *http.Request) *http.Request { return req },
+			code:  http.StatusUnauthorized,
+		},
+		{
+			name:  "no limit",
+			query: "",
+			req:   func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:  http.StatusBadRequest,
+		},
+		{
+			name:  "too high limit",
+			query: "limit=51",
+			req:   func(req *http.Request) *http.Request { return withRole(req, auth.RoleViewer) },
+			code:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			req := tc.req(suite.authRequest(http.MethodGet, nil))
+			req.URL.RawQuery = tc.query
+
+			err := suite.handler.GetRecommendations(suite.e.NewContext(req, httptest.NewRecorder()))
+			suite.Require().Error(err)
+			code, _ := utils.FromErrorToHTTP(req.Context(), err)
+			suite.Equal(tc.code, code)
+		})
+	}
+}
@@ -1,32 +1,130 @@
 package http
 
 import (
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/importer"
 	"github.com/alserok/music_lib/internal/server/http/middleware"
 	"github.com/labstack/echo/v4"
 	"github.com/swaggo/echo-swagger"
 	"net/http"
 )
 
-func setupRoutes(s *echo.Echo, h handler) {
+func setupRoutes(s *echo.Echo, h handler, opts Options) {
 	s.GET("/ping", func(c echo.Context) error {
 		_ = c.JSON(http.StatusOK, "pong")
 		return nil
 	})
 
 	v1 := s.Group("/v1")
-	v1.Use(middleware.WithRecovery(h.log), middleware.WithLogger(h.log), middleware.WithErrorHandler)
+	v1.Use(middleware.WithRecovery(h.log), middleware.WithLogger(h.log), middleware.WithReadYourWrites,
+		middleware.WithErrorHandler)
+	v1.Use(middleware.WithAPIKey(h.srvc.AuthenticateAPIKey))
+	if opts.JWT != nil {
+		v1.Use(middleware.WithJWT(opts.JWT))
+	}
+	v1.Use(middleware.WithUser(h.srvc.Authenticate))
 	v1.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	get := v1.Group("/get")
-	get.GET("/songs", h.GetSongs)
-	get.GET("/songs/:id", h.GetSongText)
+	// API keys and JWTs are limited by their scopes besides their role
+	read, export := middleware.WithScope(auth.ScopeRead), middleware.WithScope(auth.ScopeExport)
 
-	del := v1.Group("/del")
+	readLimit, writeLimit, authLimit := rateLimit(opts.RateLimits.Read), rateLimit(opts.RateLimits.Write),
+		rateLimit(opts.RateLimits.Auth)
+
+	get := v1.Group("/get", readLimit, read)
+	get.GET("/songs", h.GetSongs, middleware.WithHTTPCache(opts.Cache.Songs))
+	get.GET("/songs/:id", h.GetSongText, middleware.WithHTTPCache(opts.Cache.SongText))
+	get.GET("/song/:id", h.GetSong, middleware.WithHTTPCache(opts.Cache.Song))
+	get.GET("/song/:id/revisions", h.GetSongRevisions)
+	get.GET("/song/:id/snapshot", h.GetSongAsOf, middleware.WithHTTPCache(opts.Cache.SongSnapshot))
+
+	editor, admin := middleware.WithRole(auth.RoleEditor), middleware.WithRole(auth.RoleAdmin)
+
+	del := v1.Group("/del", writeLimit, admin)
 	del.DELETE("/:id", h.DeleteSong)
 
-	edit := v1.Group("/edit")
+	trash := v1.Group("/trash", writeLimit, editor)
+	trash.POST("/:id/restore", h.RestoreDeletedSong)
+
+	edit := v1.Group("/edit", writeLimit, editor)
 	edit.PUT("/", h.EditSong)
+	edit.POST("/:id/restore", h.RestoreSong)
 
-	create := v1.Group("/new")
+	create := v1.Group("/new", writeLimit, editor)
 	create.POST("/song", h.CreateSong)
+
+	bulk := v1.Group("/bulk", writeLimit)
+	bulk.POST("/songs", h.CreateSongs, editor)
+	bulk.PUT("/songs", h.EditSongs, editor)
+	bulk.DELETE("/songs", h.DeleteSongs, admin)
+
+	imp := v1.Group("/import", writeLimit, editor, middleware.WithBodyLimit(importer.MaxFileSize))
+	imp.POST("/songs", h.ImportSongs)
+
+	v1.GET("/export", h.ExportSongs, readLimit, export)
+
+	pls := v1.Group("/playlist", readLimit)
+	pls.GET("/export", h.ExportPlaylist, export)
+	pls.POST("/import", h.ImportPlaylist, read)
+
+	// playlists are managed by their members whatever their role, so API keys need the write scope for changes
+	write := middleware.WithScope(auth.ScopeWrite)
+
+	lists := v1.Group("/playlists")
+	lists.GET("", h.GetPlaylists, readLimit, read)
+	lists.GET("/public", h.GetPublicPlaylists, readLimit, read)
+	lists.GET("/:id", h.GetPlaylist, readLimit, read)
+	lists.POST("/preview", h.PreviewSmartPlaylist, readLimit, read)
+	lists.POST("", h.CreatePlaylist, writeLimit, write)
+	lists.PUT("/:id", h.EditPlaylist, writeLimit, write)
+	lists.DELETE("/:id", h.DeletePlaylist, writeLimit, write)
+	lists.POST("/:id/songs", h.AddPlaylistSong, writeLimit, write)
+	lists.PUT("/:id/songs/:entry", h.MovePlaylistSong, writeLimit, write)
+	lists.DELETE("/:id/songs/:entry", h.RemovePlaylistSong, writeLimit, write)
+	lists.PUT("/:id/collaborators/:name", h.AddPlaylistCollaborator, writeLimit, write)
+	lists.DELETE("/:id/collaborators/:name", h.RemovePlaylistCollaborator, writeLimit, write)
+
+	// favorites, ratings and plays belong to the user whatever their role, like playlists
+	songs := v1.Group("/songs")
+	songs.PUT("/:id/favorite", h.FavoriteSong, writeLimit, write)
+	songs.DELETE("/:id/favorite", h.UnfavoriteSong, writeLimit, write)
+	songs.PUT("/:id/rating", h.RateSong, writeLimit, write)
+	songs.DELETE("/:id/rating", h.UnrateSong, writeLimit, write)
+	songs.POST("/:id/plays", h.RecordPlay, writeLimit, write)
+	songs.GET("/:id/similar", h.GetSimilarSongs, readLimit, read)
+
+	me := v1.Group("/users/me", readLimit, read)
+	me.GET("/favorites", h.GetFavorites)
+	me.GET("/ratings", h.GetRatings)
+	me.GET("/plays", h.GetPlays)
+	me.GET("/recommendations", h.GetRecommendations)
+
+	authn := v1.Group("/auth", authLimit)
+	authn.POST("/register", h.Register)
+	authn.POST("/login", h.Login)
+	authn.POST("/logout", h.Logout)
+	authn.GET("/me", h.GetProfile)
+	authn.PUT("/me", h.EditProfile)
+	authn.PUT("/me/password", h.ChangePassword)
+	authn.POST("/keys", h.IssueAPIKey)
+	authn.GET("/keys", h.GetAPIKeys)
+	authn.DELETE("/keys/:id", h.RevokeAPIKey)
+
+	adm := v1.Group("/admin", writeLimit, admin)
+	adm.GET("/migrations", h.GetMigrations)
+	adm.POST("/migrations/up", h.MigrateUp)
+	adm.POST("/migrations/down", h.MigrateDown)
+	adm.PUT("/users/:name/role", h.SetUserRole)
+	adm.POST("/recommendations/train", h.TrainRecommendations)
+}
+
+// rateLimit returns the middleware limiting every client to the budget, each call has its own buckets
+func rateLimit(limit middleware.RateLimit) echo.MiddlewareFunc {
+	if !limit.Enabled() {
+		return func(next echo.HandlerFunc) echo.HandlerFunc {
+			return next
+		}
+	}
+
+	return middleware.WithRateLimit(middleware.NewRateLimiter(limit))
 }
@@ -4,24 +4,67 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
 	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
 	"github.com/alserok/music_lib/internal/service"
 	"github.com/labstack/echo/v4"
+	"net"
 	"net/http"
 	"os/signal"
 	"syscall"
 )
 
-func NewServer(srvc service.Service, log logger.Logger) *server {
+// Options configure the authentication, the limits and the caching of the server, the zero value disables them
+type Options struct {
+	// JWT verifies the bearer JWTs of other services
+	JWT *auth.JWTVerifier
+
+	RateLimits RateLimits
+	// TrustedProxies are the networks of the proxies which X-Forwarded-For headers tell the client addresses,
+	// without them the address of the connection is used
+	TrustedProxies []*net.IPNet
+
+	Cache CachePolicies
+}
+
+// CachePolicies are the Cache-Control policies of the read routes
+type CachePolicies struct {
+	// Songs is the policy of the song lists
+	Songs middleware.CachePolicy
+	// SongText is the policy of the song texts
+	SongText middleware.CachePolicy
+	// Song is the policy of the songs with their stats
+	Song middleware.CachePolicy
+	// SongSnapshot is the policy of the songs as of a revision or a time
+	SongSnapshot middleware.CachePolicy
+}
+
+// RateLimits are the budgets of every client in the groups of routes, a zero budget disables the limit
+type RateLimits struct {
+	// Read limits reading and exporting the library
+	Read middleware.RateLimit
+	// Write limits creating, editing and deleting songs and the admin routes
+	Write middleware.RateLimit
+	// Auth limits the account routes
+	Auth middleware.RateLimit
+}
+
+func NewServer(srvc service.Service, log logger.Logger, opts Options) *server {
+	serv := echo.New()
+	serv.IPExtractor = middleware.IPExtractor(opts.TrustedProxies)
+
 	return &server{
 		srvc: srvc,
-		serv: echo.New(),
+		opts: opts,
+		serv: serv,
 		log:  log,
 	}
 }
 
 type server struct {
 	srvc service.Service
+	opts Options
 
 	log logger.Logger
 
@@ -32,7 +75,7 @@ func (s server) MustServe(port string) {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	setupRoutes(s.serv, newHandler(s.srvc, s.log))
+	setupRoutes(s.serv, newHandler(s.srvc, s.log), s.opts)
 
 	go func() {
 		if err := s.serv.Start(fmt.Sprintf(":%s", port)); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/server/http"
+	"github.com/alserok/music_lib/internal/server/http/middleware"
 	"github.com/alserok/music_lib/internal/service"
 )
 
@@ -14,10 +15,25 @@ const (
 	HTTP = iota
 )
 
-func New(serverType uint, srvc service.Service, log logger.Logger) Server {
+// Options configure the authentication, the limits and the caching of the server
+type Options = http.Options
+
+// RateLimits are the budgets of every client in the groups of routes
+type RateLimits = http.RateLimits
+
+// CachePolicies are the Cache-Control policies of the read routes
+type CachePolicies = http.CachePolicies
+
+// CachePolicy describes the Cache-Control header of a route
+type CachePolicy = middleware.CachePolicy
+
+// RateLimit allows requests per period, they can be spent at once
+type RateLimit = middleware.RateLimit
+
+func New(serverType uint, srvc service.Service, log logger.Logger, opts Options) Server {
 	switch serverType {
 	case HTTP:
-		return http.NewServer(srvc, log)
+		return http.NewServer(srvc, log, opts)
 	default:
 		panic("invalid server type")
 	}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// maxPlayDuration is the longest play in seconds, longer ones are most likely players left running
+	maxPlayDuration = 24 * 60 * 60
+	// maxPlayClockSkew is how far in the future plays may start, clocks of players are not exact
+	maxPlayClockSkew = time.Minute
+)
+
+func (s *service) FavoriteSong(ctx context.Context, songID string) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received FavoriteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed FavoriteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	if err = s.repo.SetFavorite(ctx, user.UserID, songID, true); err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to set favorite: %w", err)
+	}
+
+	song, err := s.repo.GetSong(ctx, songID)
+	if err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to get song: %w", err)
+	}
+
+	return song, nil
+}
+
+func (s *service) UnfavoriteSong(ctx context.Context, songID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received UnfavoriteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed UnfavoriteSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.SetFavorite(ctx, user.UserID, songID, false); err != nil {
+		return fmt.Errorf("repo failed to set favorite: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RateSong(ctx context.Context, songID string, rating int) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Song{}, err
+	}
+
+	if rating < models.MinRating || rating > models.MaxRating {
+		return models.Song{}, utils.NewError(
+			fmt.Sprintf("rating must be from %d to %d", models.MinRating, models.MaxRating), utils.BadRequest)
+	}
+
+	if err = s.repo.RateSong(ctx, user.UserID, songID, rating); err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to rate song: %w", err)
+	}
+
+	song, err := s.repo.GetSong(ctx, songID)
+	if err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to get song: %w", err)
+	}
+
+	return song, nil
+}
+
+func (s *service) UnrateSong(ctx context.Context, songID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received UnrateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed UnrateSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.RateSong(ctx, user.UserID, songID, 0); err != nil {
+		return fmt.Errorf("repo failed to rate song: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) RecordPlay(ctx context.Context, songID string, newPlay models.NewPlay) (models.Play, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received RecordPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RecordPlay",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Play{}, err
+	}
+
+	if newPlay.Duration < 0 || newPlay.Duration > maxPlayDuration {
+		return models.Play{}, utils.NewError(
+			fmt.Sprintf("duration must be from 0 to %d seconds", maxPlayDuration), utils.BadRequest)
+	}
+
+	now := time.Now().UTC()
+	playedAt := now
+	if newPlay.PlayedAt != nil {
+		playedAt = newPlay.PlayedAt.UTC()
+	}
+	if playedAt.After(now.Add(maxPlayClockSkew)) {
+		return models.Play{}, utils.NewError("play must not start in the future", utils.BadRequest)
+	}
+
+	play := models.Play{
+		PlayID:   uuid.NewString(),
+		Song:     models.Song{SongID: songID},
+		PlayedAt: playedAt,
+		Duration: newPlay.Duration,
+	}
+
+	if err = s.repo.AddPlay(ctx, user.UserID, play); err != nil {
+		return models.Play{}, fmt.Errorf("repo failed to add play: %w", err)
+	}
+
+	if play.Song, err = s.repo.GetSong(ctx, songID); err != nil {
+		return models.Play{}, fmt.Errorf("repo failed to get song: %w", err)
+	}
+
+	return play, nil
+}
+
+func (s *service) GetFavorites(ctx context.Context, lim, off int) ([]models.Favorite, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetFavorites",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites, err := s.repo.GetFavorites(ctx, user.UserID, lim, off)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get favorites: %w", err)
+	}
+
+	return favorites, nil
+}
+
+func (s *service) GetRatings(ctx context.Context, lim, off int) ([]models.Rating, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetRatings",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings, err := s.repo.GetRatings(ctx, user.UserID, lim, off)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get ratings: %w", err)
+	}
+
+	return ratings, nil
+}
+
+func (s *service) GetPlays(ctx context.Context, lim, off int) ([]models.Play, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetPlays",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	plays, err := s.repo.GetPlays(ctx, user.UserID, lim, off)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get plays: %w", err)
+	}
+
+	return plays, nil
+}
+
+// validatePopularity checks the popularity sort and filters of the song filter
+func validatePopularity(filter models.SongFilter) error {
+	switch filter.Sort {
+	case "", models.SortPlays, models.SortFavorites, models.SortRating:
+	default:
+		return utils.NewError(fmt.Sprintf("sort must be one of %s, %s and %s", models.SortPlays, models.SortFavorites,
+			models.SortRating), utils.BadRequest)
+	}
+
+	if filter.MinPlays < 0 {
+		return utils.NewError("minimum plays must not be negative", utils.BadRequest)
+	}
+	// written negated, so NaN fails as well
+	if !(filter.MinRating >= 0 && filter.MinRating <= models.MaxRating) {
+		return utils.NewError(fmt.Sprintf("minimum rating must be from 0 to %d", models.MaxRating), utils.BadRequest)
+	}
+
+	return nil
+}
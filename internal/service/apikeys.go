@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+const maxAPIKeyNameLen = 64
+
+func (s *service) IssueAPIKey(ctx context.Context, newKey models.NewAPIKey) (models.IssuedAPIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received IssueAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed IssueAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := keyOwner(ctx)
+	if err != nil {
+		return models.IssuedAPIKey{}, err
+	}
+
+	name := strings.TrimSpace(newKey.Name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return models.IssuedAPIKey{}, utils.NewError(
+			fmt.Sprintf("name must be 1 to %d characters long", maxAPIKeyNameLen), utils.BadRequest)
+	}
+
+	scopes, err := normalizeScopes(newKey.Scopes)
+	if err != nil {
+		return models.IssuedAPIKey{}, err
+	}
+
+	// a key can not do more than its owner
+	if role := auth.ScopesRole(scopes); !auth.HasRole(user.Role, role) {
+		return models.IssuedAPIKey{}, utils.NewError("the scopes require the "+role+" role", utils.Forbidden)
+	}
+
+	secret, hash, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		return models.IssuedAPIKey{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	key := models.APIKey{
+		KeyID:   uuid.NewString(),
+		Name:    name,
+		Prefix:  prefix,
+		Scopes:  scopes,
+		OwnerID: user.UserID,
+	}
+
+	if err = s.repo.CreateAPIKey(ctx, key, hash); err != nil {
+		return models.IssuedAPIKey{}, fmt.Errorf("repo failed to create API key: %w", err)
+	}
+
+	if key, err = s.repo.GetAPIKey(ctx, key.KeyID); err != nil {
+		return models.IssuedAPIKey{}, fmt.Errorf("repo failed to get API key: %w", err)
+	}
+
+	return models.IssuedAPIKey{Key: secret, APIKey: key}, nil
+}
+
+func (s *service) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetAPIKeys",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := keyOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.repo.GetAPIKeys(ctx, user.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, keyID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RevokeAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := keyOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.repo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return fmt.Errorf("repo failed to get API key: %w", err)
+	}
+
+	// admins revoke the keys of anyone, others only know their own keys
+	if key.OwnerID != user.UserID && user.Role != auth.RoleAdmin {
+		return utils.NewError("API key not found", utils.NotFound)
+	}
+
+	if err = s.repo.RevokeAPIKey(ctx, key.KeyID); err != nil {
+		return fmt.Errorf("repo failed to revoke API key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) AuthenticateAPIKey(ctx context.Context, secret string) (models.User, models.APIKey, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received AuthenticateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed AuthenticateAPIKey",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if !auth.IsAPIKey(secret) {
+		return models.User{}, models.APIKey{}, utils.NewError("invalid or revoked API key", utils.Unauthorized)
+	}
+
+	key, err := s.repo.UseAPIKey(ctx, auth.HashToken(secret))
+	if err != nil {
+		if utils.IsNotFound(err) {
+			return models.User{}, models.APIKey{}, utils.NewError("invalid or revoked API key", utils.Unauthorized)
+		}
+		return models.User{}, models.APIKey{}, fmt.Errorf("repo failed to use API key: %w", err)
+	}
+
+	user, err := s.repo.GetUser(ctx, key.OwnerID)
+	if err != nil {
+		return models.User{}, models.APIKey{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	return user, key, nil
+}
+
+// keyOwner returns the user managing API keys, keys are managed with the sessions of users only, so a leaked key
+// can not issue keys outliving its revocation
+func keyOwner(ctx context.Context) (models.User, error) {
+	if _, ok := auth.ExtractAPIKey(ctx); ok {
+		return models.User{}, utils.NewError("API keys can not manage API keys", utils.Forbidden)
+	}
+
+	return currentUser(ctx)
+}
+
+// normalizeScopes checks the scopes and returns them without duplicates in a stable order
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, utils.NewError("at least one scope is required", utils.BadRequest)
+	}
+
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !auth.ValidScope(scope) {
+			return nil, utils.NewError("invalid scope: "+scope, utils.BadRequest)
+		}
+		normalized = append(normalized, scope)
+	}
+
+	slices.Sort(normalized)
+
+	return slices.Compact(normalized), nil
+}
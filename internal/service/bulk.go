@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"sync"
+)
+
+const (
+	maxBulkItems = 1000
+	// enrichWorkers limits concurrent requests to the song data API
+	enrichWorkers = 8
+)
+
+func (s *service) CreateSongs(ctx context.Context, songs []models.NewSong, atomic bool) ([]models.BulkResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed CreateSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	if err := validateBulkSize(len(songs)); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(songs))
+	for i, song := range songs {
+		if song.Group == "" || song.Song == "" {
+			results[i].Err = utils.NewError("group and song are required", utils.BadRequest)
+		}
+	}
+
+	songsData := s.enrichSongs(ctx, songs, results)
+
+	if atomic && abortOnFailure(results) {
+		return results, nil
+	}
+
+	created := make([]models.Song, 0, len(songs))
+	indexes := make([]int, 0, len(songs))
+	for i, song := range songs {
+		if results[i].Err != nil {
+			continue
+		}
+
+		results[i].SongID = uuid.NewString()
+		created = append(created, models.Song{SongID: results[i].SongID, Group: song.Group, Song: song.Song, Data: songsData[i]})
+		indexes = append(indexes, i)
+	}
+
+	if len(created) == 0 {
+		return results, nil
+	}
+
+	// failed songs are touched as well, the index skips the ones that are not found
+	defer func() {
+		for _, song := range created {
+			s.similar.touch(song.SongID)
+		}
+	}()
+
+	err := s.repo.CreateSongs(ctx, created)
+	if err == nil {
+		return results, nil
+	}
+
+	if atomic {
+		for _, i := range indexes {
+			results[i].SongID = ""
+			results[i].Err = fmt.Errorf("repo failed to create songs: %w", err)
+		}
+		return results, nil
+	}
+
+	for j, err := range s.createSongsOneByOne(ctx, created) {
+		if err != nil {
+			results[indexes[j]].SongID = ""
+			results[indexes[j]].Err = err
+		}
+	}
+
+	return results, nil
+}
+
+func (s *service) EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]models.BulkResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed EditSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	if err := validateBulkSize(len(songs)); err != nil {
+		return nil, err
+	}
+
+	errs, err := s.repo.EditSongs(ctx, songs, atomic)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to edit songs: %w", err)
+	}
+
+	results := make([]models.BulkResult, len(songs))
+	for i := range songs {
+		results[i].SongID = songs[i].SongID
+		results[i].Err = errs[i]
+		s.similar.touch(songs[i].SongID)
+	}
+
+	if atomic {
+		abortOnFailure(results)
+	}
+
+	return results, nil
+}
+
+func (s *service) DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]models.BulkResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed DeleteSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := validateBulkSize(len(songs)); err != nil {
+		return nil, err
+	}
+
+	errs, err := s.repo.DeleteSongs(ctx, songs, atomic)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to delete songs: %w", err)
+	}
+
+	results := make([]models.BulkResult, len(songs))
+	for i := range songs {
+		results[i].SongID = songs[i].SongID
+		results[i].Err = errs[i]
+		s.similar.touch(songs[i].SongID)
+	}
+
+	if atomic {
+		abortOnFailure(results)
+	}
+
+	return results, nil
+}
+
+// createSongsOneByOne is the fallback for a failed multi-row insert that finds out which songs are broken,
+// returns an error per song
+func (s *service) createSongsOneByOne(ctx context.Context, songs []models.Song) []error {
+	errs := make([]error, len(songs))
+	for i := range songs {
+		if err := s.repo.CreateSong(ctx, songs[i]); err != nil {
+			errs[i] = fmt.Errorf("repo failed to create song: %w", err)
+		}
+	}
+
+	return errs
+}
+
+// enrichSongs requests the song data for the songs without errors concurrently, identical group and song pairs
+// are requested once. Failed requests are reported to results
+func (s *service) enrichSongs(ctx context.Context, songs []models.NewSong, results []models.BulkResult) []models.SongData {
+	type response struct {
+		data models.SongData
+		err  error
+	}
+
+	unique := make(map[models.NewSong]*response, len(songs))
+	for i, song := range songs {
+		if results[i].Err == nil {
+			unique[song] = new(response)
+		}
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, enrichWorkers)
+	)
+	for song, res := range unique {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(song models.NewSong, res *response) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			res.data, res.err = s.songDataAPIClient.GetSongData(ctx, song.Group, song.Song)
+		}(song, res)
+	}
+	wg.Wait()
+
+	songsData := make([]models.SongData, len(songs))
+	for i, song := range songs {
+		if results[i].Err != nil {
+			continue
+		}
+
+		res := unique[song]
+		if res.err != nil {
+			results[i].Err = fmt.Errorf("client failed to get song data: %w", res.err)
+			continue
+		}
+		songsData[i] = res.data
+	}
+
+	return songsData
+}
+
+// abortOnFailure marks all successful results as not applied if any of the results failed, reports if it did
+func abortOnFailure(results []models.BulkResult) bool {
+	failed := false
+	for _, res := range results {
+		if res.Err != nil {
+			failed = true
+			break
+		}
+	}
+
+	if !failed {
+		return false
+	}
+
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = utils.NewError("not applied: another item of the batch failed", utils.FailedDependency)
+		}
+	}
+
+	return true
+}
+
+func validateBulkSize(n int) error {
+	if n == 0 || n > maxBulkItems {
+		return utils.NewError(fmt.Sprintf("number of items must be between 1 and %d", maxBulkItems), utils.BadRequest)
+	}
+	return nil
+}
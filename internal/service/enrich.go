@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/api"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"golang.org/x/time/rate"
+	"time"
+)
+
+// maxSongDataWait is how long a request waits for its turn to the song data API, the songs that would wait longer
+// fail without waiting
+const maxSongDataWait = 30 * time.Second
+
+func (s *service) EnrichSongs(ctx context.Context, songIDs []string) ([]models.BulkResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received EnrichSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed EnrichSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	if err := validateBulkSize(len(songIDs)); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(songIDs))
+	songs := make([]models.Song, 0, len(songIDs))
+	indexes := make([]int, 0, len(songIDs))
+	for i, songID := range songIDs {
+		results[i].SongID = songID
+
+		song, err := s.repo.GetSong(ctx, songID)
+		if err != nil {
+			results[i].Err = fmt.Errorf("repo failed to get song: %w", err)
+			continue
+		}
+
+		songs = append(songs, song)
+		indexes = append(indexes, i)
+	}
+
+	for j, res := range s.enrichStoredSongs(ctx, songs) {
+		results[indexes[j]] = res
+	}
+
+	return results, nil
+}
+
+func (s *service) EnrichPendingSongs(ctx context.Context) ([]models.BulkResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received EnrichPendingSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed EnrichPendingSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	var pending []models.Song
+	err := s.repo.StreamSongs(ctx, models.SongFilter{}, func(song models.Song) error {
+		if isPending(song.Data) {
+			pending = append(pending, song)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to stream songs: %w", err)
+	}
+
+	results := make([]models.BulkResult, 0, len(pending))
+	for start := 0; start < len(pending); start += maxBulkItems {
+		results = append(results, s.enrichStoredSongs(ctx, pending[start:min(start+maxBulkItems, len(pending))])...)
+	}
+
+	return results, nil
+}
+
+// enrichStoredSongs fills the missing fields of the songs with the song data and saves the changed songs,
+// returns a result per song
+func (s *service) enrichStoredSongs(ctx context.Context, songs []models.Song) []models.BulkResult {
+	newSongs := make([]models.NewSong, len(songs))
+	results := make([]models.BulkResult, len(songs))
+	for i, song := range songs {
+		newSongs[i] = models.NewSong{Group: song.Group, Song: song.Song}
+		results[i].SongID = song.SongID
+	}
+
+	songsData := s.enrichSongs(ctx, newSongs, results)
+
+	edited := make([]models.Song, 0, len(songs))
+	indexes := make([]int, 0, len(songs))
+	for i, song := range songs {
+		if results[i].Err != nil {
+			continue
+		}
+
+		data, changed := &song.Data, false
+		if data.ReleaseDate.IsZero() && !songsData[i].ReleaseDate.IsZero() {
+			data.ReleaseDate, changed = songsData[i].ReleaseDate, true
+		}
+		if data.Text == "" && songsData[i].Text != "" {
+			data.Text, changed = songsData[i].Text, true
+		}
+		if data.Link == "" && songsData[i].Link != "" {
+			data.Link, changed = songsData[i].Link, true
+		}
+
+		// songs the song data API knows nothing new about are left untouched
+		if !changed {
+			continue
+		}
+
+		edited = append(edited, song)
+		indexes = append(indexes, i)
+	}
+
+	if len(edited) == 0 {
+		return results
+	}
+
+	errs, err := s.repo.EditSongs(ctx, edited, false)
+	for j, i := range indexes {
+		s.similar.touch(edited[j].SongID)
+		switch {
+		case err != nil:
+			results[i].Err = fmt.Errorf("repo failed to edit songs: %w", err)
+		case errs[j] != nil:
+			results[i].Err = errs[j]
+		}
+	}
+
+	return results
+}
+
+// isPending reports if the song misses data the song data API may provide
+func isPending(data models.SongData) bool {
+	return data.ReleaseDate.IsZero() || data.Text == "" || data.Link == ""
+}
+
+// limitedSongDataClient waits for the limiter before every request to the song data API
+type limitedSongDataClient struct {
+	api.SongDataAPIClient
+
+	limiter *rate.Limiter
+}
+
+func (c *limitedSongDataClient) GetSongData(ctx context.Context, group string, song string) (models.SongData, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, maxSongDataWait)
+	defer cancel()
+
+	if err := c.limiter.Wait(waitCtx); err != nil {
+		return models.SongData{}, utils.NewError("song data API is busy, try again later", utils.TooManyRequests)
+	}
+
+	return c.SongDataAPIClient.GetSongData(ctx, group, song)
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"net/url"
+)
+
+func (s *service) ImportSongs(ctx context.Context, rows []models.ImportRow, enrich bool) ([]models.ImportResult, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received ImportSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed ImportSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ImportResult, len(rows))
+
+	// the first occurrence of a song is imported, the following ones are skipped
+	firstRows := make(map[models.NewSong]string, len(rows))
+	keys := make([]models.NewSong, 0, len(rows))
+	for i, row := range rows {
+		results[i].Line, results[i].Source = row.Line, row.Source
+
+		if row.Err == nil {
+			row.Err = validateImportedSong(row.Song)
+		}
+		if row.Err != nil {
+			results[i].Status, results[i].Err = models.ImportFailed, row.Err
+			continue
+		}
+
+		key := models.NewSong{Group: row.Song.Group, Song: row.Song.Song}
+		if first, ok := firstRows[key]; ok {
+			results[i].Status = models.ImportSkipped
+			results[i].Err = utils.NewError(fmt.Sprintf("duplicate of %s", first), utils.BadRequest)
+			continue
+		}
+
+		firstRows[key] = rowName(row)
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return results, nil
+	}
+
+	existing, err := s.repo.FindSongs(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to find songs: %w", err)
+	}
+
+	exists := make(map[models.NewSong]bool, len(existing))
+	for _, song := range existing {
+		exists[song] = true
+	}
+
+	pending := make([]int, 0, len(keys))
+	for i, row := range rows {
+		if results[i].Status != "" {
+			continue
+		}
+
+		if exists[models.NewSong{Group: row.Song.Group, Song: row.Song.Song}] {
+			results[i].Status = models.ImportSkipped
+			results[i].Err = utils.NewError("song already exists", utils.BadRequest)
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	songs := make([]models.Song, len(pending))
+	for j, i := range pending {
+		songs[j] = rows[i].Song
+		songs[j].SongID = uuid.NewString()
+	}
+
+	if enrich {
+		for j, err := range s.enrichImportedSongs(ctx, songs) {
+			if err != nil {
+				results[pending[j]].Status, results[pending[j]].Err = models.ImportFailed, err
+			}
+		}
+	}
+
+	for start := 0; start < len(pending); start += maxBulkItems {
+		end := min(start+maxBulkItems, len(pending))
+
+		batch := make([]models.Song, 0, end-start)
+		indexes := make([]int, 0, end-start)
+		for j := start; j < end; j++ {
+			if results[pending[j]].Status == "" {
+				batch = append(batch, songs[j])
+				indexes = append(indexes, pending[j])
+			}
+		}
+
+		if len(batch) == 0 {
+			continue
+		}
+
+		errs := make([]error, len(batch))
+		if err = s.repo.CreateSongs(ctx, batch); err != nil {
+			errs = s.createSongsOneByOne(ctx, batch)
+		}
+
+		for j, i := range indexes {
+			s.similar.touch(batch[j].SongID)
+			if errs[j] != nil {
+				results[i].Status, results[i].Err = models.ImportFailed, errs[j]
+				continue
+			}
+
+			results[i].Status, results[i].SongID = models.ImportCreated, batch[j].SongID
+		}
+	}
+
+	return results, nil
+}
+
+// enrichImportedSongs requests the song data and fills the fields that are missing in the import file,
+// returns an error per song
+func (s *service) enrichImportedSongs(ctx context.Context, songs []models.Song) []error {
+	newSongs := make([]models.NewSong, len(songs))
+	for i, song := range songs {
+		newSongs[i] = models.NewSong{Group: song.Group, Song: song.Song}
+	}
+
+	results := make([]models.BulkResult, len(songs))
+	songsData := s.enrichSongs(ctx, newSongs, results)
+
+	errs := make([]error, len(songs))
+	for i := range songs {
+		if results[i].Err != nil {
+			errs[i] = results[i].Err
+			continue
+		}
+
+		data := &songs[i].Data
+		if data.ReleaseDate.IsZero() {
+			data.ReleaseDate = songsData[i].ReleaseDate
+		}
+		if data.Text == "" {
+			data.Text = songsData[i].Text
+		}
+		if data.Link == "" {
+			data.Link = songsData[i].Link
+		}
+	}
+
+	return errs
+}
+
+func validateImportedSong(song models.Song) error {
+	if song.Group == "" || song.Song == "" {
+		return utils.NewError("group and song are required", utils.BadRequest)
+	}
+
+	if song.Data.Link != "" {
+		link, err := url.Parse(song.Data.Link)
+		if err != nil || link.Scheme == "" || (link.Host == "" && link.Scheme != "file") {
+			return utils.NewError(fmt.Sprintf("invalid link: %s", song.Data.Link), utils.BadRequest)
+		}
+	}
+
+	return nil
+}
+
+// rowName refers to the row in messages by its line or file
+func rowName(row models.ImportRow) string {
+	if row.Source == "" {
+		return fmt.Sprintf("line %d", row.Line)
+	}
+	if row.Line == 0 {
+		return row.Source
+	}
+	return fmt.Sprintf("%s:%d", row.Source, row.Line)
+}
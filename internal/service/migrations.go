@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+func (s *service) GetMigrations(ctx context.Context) ([]models.Migration, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetMigrations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetMigrations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	migrations, err := s.repo.GetMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get migrations: %w", err)
+	}
+
+	return migrations, nil
+}
+
+func (s *service) MigrateUp(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received MigrateUp",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed MigrateUp",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	if version != 0 {
+		if err := s.validateMigrationVersion(ctx, version); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.MigrateUp(ctx, version); err != nil {
+		return fmt.Errorf("repo failed to migrate up: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) MigrateDown(ctx context.Context, version int64) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received MigrateDown",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed MigrateDown",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	if version != 0 {
+		if err := s.validateMigrationVersion(ctx, version); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.MigrateDown(ctx, version); err != nil {
+		return fmt.Errorf("repo failed to migrate down: %w", err)
+	}
+
+	return nil
+}
+
+// validateMigrationVersion rejects versions that do not belong to any migration, goose would silently migrate
+// to the nearest one
+func (s *service) validateMigrationVersion(ctx context.Context, version int64) error {
+	migrations, err := s.repo.GetMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("repo failed to get migrations: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return nil
+		}
+	}
+
+	return utils.NewError(fmt.Sprintf("unknown migration version %d", version), utils.BadRequest)
+}
@@ -7,6 +7,24 @@ type Song struct {
 	Group  string   `json:"group" db:"group_name"`
 	Song   string   `json:"song"`
 	Data   SongData `json:"data"`
+
+	// Version is incremented on every edit, on edit/delete requests it holds the expected version (0 - any)
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	// DeletedAt is set for songs in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty" db:"deleted_at"`
+
+	// Stats are set when songs are read from the library, they are not part of the song versions
+	Stats *SongStats `json:"stats,omitempty" db:"-"`
+}
+
+// SongStats sums up the favorites, ratings and plays of a song by all users, AverageRating is 0 for songs
+// nobody rated
+type SongStats struct {
+	Favorites     int64   `json:"favorites"`
+	Ratings       int64   `json:"ratings"`
+	AverageRating float64 `json:"averageRating"`
+	Plays         int64   `json:"plays"`
 }
 
 type NewSong struct {
@@ -29,4 +47,393 @@ type SongFilter struct {
 	Link        string
 	Lim         int
 	Off         int
+
+	// IncludeDeleted adds songs from the trash to the result
+	IncludeDeleted bool
+
+	// MinPlays and MinRating leave out the less popular songs
+	MinPlays  int64
+	MinRating float64
+	// Sort orders the songs by popularity, the most popular first. The empty sort keeps the order of the library
+	Sort string
+
+	// Rules are the rules of a smart playlist the songs have to match besides the other fields
+	Rules *SmartRules
+}
+
+const (
+	SortPlays     = "plays"
+	SortFavorites = "favorites"
+	// SortRating orders songs by their average rating, songs rated by more users go first among equal ones
+	SortRating = "rating"
+)
+
+const (
+	RevisionCreate  = "create"
+	RevisionEdit    = "edit"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	// RevisionUndelete brings a song back from the trash
+	RevisionUndelete = "undelete"
+)
+
+// SongRevision is a single change of a song, Before is empty for created songs and After for deleted ones
+type SongRevision struct {
+	SongID       string       `json:"songID"`
+	Version      int          `json:"version"`
+	Action       string       `json:"action"`
+	Author       string       `json:"author"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	Before       *Song        `json:"before,omitempty"`
+	After        *Song        `json:"after,omitempty"`
+	RestoredFrom int          `json:"restoredFrom,omitempty"`
+	Changes      []SongChange `json:"changes"`
+}
+
+type SongChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// SongVersion identifies a song to delete, Version 0 matches any version
+type SongVersion struct {
+	SongID  string `json:"songID"`
+	Version int    `json:"version"`
+}
+
+// BulkResult is the outcome of a single item of a bulk request, results are in the order of the request items
+type BulkResult struct {
+	SongID string
+	Err    error
+}
+
+// BulkItemStatus is the response representation of BulkResult
+type BulkItemStatus struct {
+	Index  int    `json:"index"`
+	SongID string `json:"songID,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportRow is a song read from an import file, Err is set if the row could not be parsed.
+// Source is the file of the song if songs are imported from several files
+type ImportRow struct {
+	Line   int
+	Source string
+	Song   Song
+	Err    error
+}
+
+// ImportResult is the outcome of a single import row, Err holds the reason of skipped and failed rows
+type ImportResult struct {
+	Line   int
+	Source string
+	Status string
+	SongID string
+	Err    error
+}
+
+// ImportReport is the response representation of import results
+type ImportReport struct {
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Skipped int               `json:"skipped"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowStatus `json:"rows"`
+}
+
+type ImportRowStatus struct {
+	Line   int    `json:"line,omitempty"`
+	Source string `json:"source,omitempty"`
+	Status string `json:"status"`
+	SongID string `json:"songID,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PlaylistEntry is a track of a playlist file, SongID is set if the entry refers to a library song
+type PlaylistEntry struct {
+	SongID   string `json:"songID,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+const (
+	MatchByID    = "id"
+	MatchByLink  = "link"
+	MatchByTitle = "title"
+)
+
+// PlaylistMatch is the library song found for a playlist entry, Song is nil if the entry is unmatched.
+// Score is the similarity of artist and title for matches by title, 1 is an exact match
+type PlaylistMatch struct {
+	Index     int           `json:"index"`
+	Entry     PlaylistEntry `json:"entry"`
+	Song      *Song         `json:"song,omitempty"`
+	MatchedBy string        `json:"matchedBy,omitempty"`
+	Score     float64       `json:"score,omitempty"`
+}
+
+// Migration is the state of a database migration, AppliedAt is nil for pending ones
+type Migration struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+}
+
+// User is an account of the library, the password hash is never sent to clients
+type User struct {
+	UserID       string    `json:"userID" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	DisplayName  string    `json:"displayName" db:"display_name"`
+	Email        string    `json:"email" db:"email"`
+	Role         string    `json:"role" db:"role"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type NewUser struct {
+	Name        string `json:"name"`
+	Password    string `json:"password"`
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+}
+
+type Credentials struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type UserProfile struct {
+	DisplayName string `json:"displayName"`
+	Email       string `json:"email"`
+}
+
+type RoleChange struct {
+	Role string `json:"role"`
+}
+
+type PasswordChange struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// Session is a login of a user, requests are authenticated with its bearer token
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      User      `json:"user"`
+}
+
+// APIKey is a credential of a machine client, the key itself is only shown when it is issued
+type APIKey struct {
+	KeyID string `json:"keyID" db:"id"`
+	Name  string `json:"name" db:"name"`
+	// Prefix is the start of the key that tells keys apart in listings
+	Prefix string   `json:"prefix" db:"prefix"`
+	Scopes []string `json:"scopes" db:"-"`
+	// OwnerID is the user who issued the key
+	OwnerID    string     `json:"ownerID" db:"owner_id"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty" db:"revoked_at"`
+}
+
+type NewAPIKey struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type IssuedAPIKey struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"apiKey"`
+}
+
+const (
+	// PlaylistPrivate playlists are seen by their owner and collaborators only
+	PlaylistPrivate = "private"
+	// PlaylistUnlisted playlists are seen by anyone who knows their ID
+	PlaylistUnlisted = "unlisted"
+	// PlaylistPublic playlists are also listed to everyone
+	PlaylistPublic = "public"
+)
+
+// Playlist is a playlist of a user, collaborators edit its songs like the owner. Songs are set when a single
+// playlist is requested
+type Playlist struct {
+	PlaylistID    string                 `json:"playlistID" db:"id"`
+	OwnerID       string                 `json:"ownerID" db:"owner_id"`
+	Name          string                 `json:"name" db:"name"`
+	Description   string                 `json:"description" db:"description"`
+	Visibility    string                 `json:"visibility" db:"visibility"`
+	Collaborators []PlaylistCollaborator `json:"collaborators" db:"-"`
+	Songs         []PlaylistSong         `json:"songs,omitempty" db:"-"`
+	CreatedAt     time.Time              `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time              `json:"updatedAt" db:"updated_at"`
+
+	// Smart is set for smart playlists, their songs follow the rules instead of being added by users
+	Smart *SmartPlaylist `json:"smart,omitempty" db:"-"`
+}
+
+type PlaylistCollaborator struct {
+	UserID  string    `json:"userID" db:"user_id"`
+	Name    string    `json:"name" db:"name"`
+	AddedAt time.Time `json:"addedAt" db:"added_at"`
+}
+
+// PlaylistSong is an entry of a playlist, the same song can be added several times. Entries are ordered
+// by their positions, which are compared as strings. Entries of smart playlists hold the song only
+type PlaylistSong struct {
+	EntryID  string `json:"entryID"`
+	Position string `json:"position"`
+	Song     Song   `json:"song"`
+	// AddedBy is the ID of the user who added the song
+	AddedBy string    `json:"addedBy"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// NewPlaylist creates a playlist or replaces its details, the empty visibility is private. Smart creates a smart
+// playlist or replaces the rules of one, the rules are kept if it is not set
+type NewPlaylist struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Visibility  string         `json:"visibility"`
+	Smart       *SmartPlaylist `json:"smart,omitempty"`
+}
+
+// PlaylistPlacement places a song between the entries After and Before, both empty place it at the end
+type PlaylistPlacement struct {
+	SongID string `json:"songID,omitempty"`
+	// After and Before are entry IDs, one of them is enough
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+}
+
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// SmartPlaylist is the policy of a smart playlist: its songs are the songs matching the rules at the time of
+// the read, at most Limit of them in the order of Sort, which is one of the sorts of SongFilter
+type SmartPlaylist struct {
+	Rules SmartRules `json:"rules"`
+	Sort  string     `json:"sort,omitempty"`
+	Limit int        `json:"limit"`
+}
+
+// SmartRules is a group of conditions and nested groups. Songs match the all group if they match all its members
+// and the any group if they match any of them, empty groups match all songs
+type SmartRules struct {
+	Match      string           `json:"match"`
+	Conditions []SmartCondition `json:"conditions,omitempty"`
+	Groups     []SmartRules     `json:"groups,omitempty"`
+}
+
+// SmartCondition matches the songs matching all its set fields, the text fields match like the ones of SongFilter
+type SmartCondition struct {
+	Group string `json:"group,omitempty"`
+	Song  string `json:"song,omitempty"`
+	Text  string `json:"text,omitempty"`
+	Link  string `json:"link,omitempty"`
+	// ReleasedFrom and ReleasedTo bound the release date, both bounds are included
+	ReleasedFrom *time.Time `json:"releasedFrom,omitempty"`
+	ReleasedTo   *time.Time `json:"releasedTo,omitempty"`
+
+	MinPlays int64 `json:"minPlays,omitempty"`
+	// MaxPlays of 0 matches the songs nobody played
+	MaxPlays     *int64  `json:"maxPlays,omitempty"`
+	MinFavorites int64   `json:"minFavorites,omitempty"`
+	MinRating    float64 `json:"minRating,omitempty"`
+	// AddedWithinDays matches the songs added to the library in the last days
+	AddedWithinDays int `json:"addedWithinDays,omitempty"`
+}
+
+// PlaylistFilter selects the playlists a user owns or collaborates on if MemberID is set, the public playlists
+// otherwise
+type PlaylistFilter struct {
+	MemberID string
+	Lim      int
+	Off      int
+}
+
+// Favorite is a song a user marked as a favorite
+type Favorite struct {
+	Song        Song      `json:"song"`
+	FavoritedAt time.Time `json:"favoritedAt"`
+}
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// Rating is the rating of a song by a user from MinRating to MaxRating
+type Rating struct {
+	Song    Song      `json:"song"`
+	Rating  int       `json:"rating"`
+	RatedAt time.Time `json:"ratedAt"`
+}
+
+type NewRating struct {
+	Rating int `json:"rating"`
+}
+
+// Play is a single listening of a song by a user, Duration is the number of seconds listened
+type Play struct {
+	PlayID   string    `json:"playID"`
+	Song     Song      `json:"song"`
+	PlayedAt time.Time `json:"playedAt"`
+	Duration int       `json:"duration"`
+}
+
+// NewPlay records a play, the empty PlayedAt is the time of the request
+type NewPlay struct {
+	PlayedAt *time.Time `json:"playedAt,omitempty"`
+	Duration int        `json:"duration"`
+}
+
+// SimilarSong is a song with its similarity to another song from 0 to 1
+type SimilarSong struct {
+	Song  Song    `json:"song"`
+	Score float64 `json:"score"`
+}
+
+const (
+	// RecommendedByListeners are songs played or favorited by the listeners of the songs of the user,
+	// RecommendedByPopularity fill up the recommendations of users with little history
+	RecommendedByListeners  = "listeners"
+	RecommendedByPopularity = "popular"
+)
+
+// Recommendation is a song recommended to a user, songs of the listeners have a Score above 0
+type Recommendation struct {
+	Song   Song    `json:"song"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// Interaction sums up the plays and the favorite of a song by a user, LastAt is the time of the latest of them
+type Interaction struct {
+	UserID   string    `db:"user_id"`
+	SongID   string    `db:"song_id"`
+	Plays    int64     `db:"plays"`
+	Favorite bool      `db:"favorite"`
+	LastAt   time.Time `db:"last_at"`
+}
+
+// SongNeighbor is a song often listened to by the listeners of another song, Score is their cosine similarity
+type SongNeighbor struct {
+	SongID     string  `db:"song_id"`
+	NeighborID string  `db:"neighbor_id"`
+	Score      float64 `db:"score"`
 }
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// minMatchScore is the lowest artist and title similarity accepted as a match
+	minMatchScore = 0.8
+)
+
+var (
+	bracketsRegexp = regexp.MustCompile(`\([^)]*\)|\[[^\]]*]`)
+	featRegexp     = regexp.MustCompile(`\s(feat|ft|featuring)\.?\s.*$`)
+)
+
+func (s *service) MatchPlaylist(ctx context.Context, entries []models.PlaylistEntry) ([]models.PlaylistMatch, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received MatchPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed MatchPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	matches := make([]models.PlaylistMatch, len(entries))
+	byTitle := false
+	for i, entry := range entries {
+		matches[i] = models.PlaylistMatch{Index: i, Entry: entry}
+
+		if entry.SongID != "" {
+			song, err := s.repo.GetSong(ctx, entry.SongID)
+			if err == nil {
+				matches[i].Song, matches[i].MatchedBy, matches[i].Score = &song, models.MatchByID, 1
+				continue
+			}
+			if !utils.IsNotFound(err) {
+				return nil, fmt.Errorf("repo failed to get song: %w", err)
+			}
+		}
+
+		if entry.Location != "" {
+			songs, err := s.repo.GetSongs(ctx, models.SongFilter{Link: entry.Location, Lim: 1})
+			if err != nil {
+				return nil, fmt.Errorf("repo failed to get songs: %w", err)
+			}
+			if len(songs) > 0 {
+				matches[i].Song, matches[i].MatchedBy, matches[i].Score = &songs[0], models.MatchByLink, 1
+				continue
+			}
+		}
+
+		if entry.Title != "" {
+			byTitle = true
+		}
+	}
+
+	if !byTitle {
+		return matches, nil
+	}
+
+	index, err := s.newTitleIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		if matches[i].Song != nil || matches[i].Entry.Title == "" {
+			continue
+		}
+
+		songID, score, ok := index.match(matches[i].Entry.Artist, matches[i].Entry.Title)
+		if !ok {
+			continue
+		}
+
+		song, err := s.repo.GetSong(ctx, songID)
+		if err != nil {
+			return nil, fmt.Errorf("repo failed to get song: %w", err)
+		}
+		matches[i].Song, matches[i].MatchedBy, matches[i].Score = &song, models.MatchByTitle, score
+	}
+
+	return matches, nil
+}
+
+// titleIndex holds the normalized song titles grouped by normalized artists
+type titleIndex map[string][]indexedTitle
+
+type indexedTitle struct {
+	songID string
+	title  string
+}
+
+func (s *service) newTitleIndex(ctx context.Context) (titleIndex, error) {
+	index := make(titleIndex)
+
+	err := s.repo.StreamSongs(ctx, models.SongFilter{}, func(song models.Song) error {
+		artist := normalizeName(song.Group)
+		index[artist] = append(index[artist], indexedTitle{songID: song.SongID, title: normalizeName(song.Song)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to stream songs: %w", err)
+	}
+
+	return index, nil
+}
+
+// match finds the most similar song, an empty artist matches any artist
+func (t titleIndex) match(artist, title string) (string, float64, bool) {
+	artist, title = normalizeName(artist), normalizeName(title)
+
+	var (
+		bestID    string
+		bestScore float64
+	)
+	for name, titles := range t {
+		artistScore := 1.0
+		if artist != "" {
+			if artistScore = similarity(artist, name); artistScore < minMatchScore {
+				continue
+			}
+		}
+
+		for _, candidate := range titles {
+			titleScore := similarity(title, candidate.title)
+			if titleScore < minMatchScore {
+				continue
+			}
+
+			score := titleScore
+			if artist != "" {
+				score = (artistScore + titleScore) / 2
+			}
+
+			// ties are broken by the song ID to keep the result stable
+			if score > bestScore || (score == bestScore && candidate.songID < bestID) {
+				bestID, bestScore = candidate.songID, score
+			}
+		}
+	}
+
+	return bestID, bestScore, bestID != ""
+}
+
+// normalizeName drops case, diacritics, punctuation, bracketed remarks like "(Live)", featured artists and
+// the leading article, so "The Beatles" matches "beatles" and "Let It Be (Remastered)" matches "let it be"
+func normalizeName(s string) string {
+	s = strings.ToLower(s)
+	s, _, _ = transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	s = bracketsRegexp.ReplaceAllString(s, " ")
+	s = featRegexp.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "&", " and ")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, s)
+
+	words := strings.Fields(s)
+	if len(words) > 1 && words[0] == "the" {
+		words = words[1:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// similarity is 1 minus the Levenshtein distance relative to the longer string
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
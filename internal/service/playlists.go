@@ -0,0 +1,569 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/playlist"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+const (
+	maxPlaylistNameLen        = 100
+	maxPlaylistDescriptionLen = 1000
+
+	// maxPlacementAttempts bounds the retries of placing a song when concurrent edits take its position
+	maxPlacementAttempts = 3
+)
+
+func (s *service) CreatePlaylist(ctx context.Context, newPlaylist models.NewPlaylist) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed CreatePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	if err = validatePlaylist(&newPlaylist); err != nil {
+		return models.Playlist{}, err
+	}
+
+	pl := models.Playlist{
+		PlaylistID:  uuid.NewString(),
+		OwnerID:     user.UserID,
+		Name:        newPlaylist.Name,
+		Description: newPlaylist.Description,
+		Visibility:  newPlaylist.Visibility,
+		Smart:       newPlaylist.Smart,
+	}
+
+	if err = s.repo.CreatePlaylist(ctx, pl); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to create playlist: %w", err)
+	}
+
+	if pl, err = s.repo.GetPlaylist(ctx, pl.PlaylistID); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get playlist: %w", err)
+	}
+
+	return pl, nil
+}
+
+func (s *service) GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, err := s.visiblePlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	if pl.Smart != nil {
+		songs, err := s.smartSongs(ctx, *pl.Smart)
+		if err != nil {
+			return models.Playlist{}, err
+		}
+
+		pl.Songs = make([]models.PlaylistSong, 0, len(songs))
+		for _, song := range songs {
+			pl.Songs = append(pl.Songs, models.PlaylistSong{Song: song})
+		}
+
+		return pl, nil
+	}
+
+	if pl.Songs, err = s.repo.GetPlaylistSongs(ctx, pl.PlaylistID); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get playlist songs: %w", err)
+	}
+
+	return pl, nil
+}
+
+func (s *service) GetPlaylists(ctx context.Context, lim, off int) ([]models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	playlists, err := s.repo.GetPlaylists(ctx, models.PlaylistFilter{MemberID: user.UserID, Lim: lim, Off: off})
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get playlists: %w", err)
+	}
+
+	return playlists, nil
+}
+
+func (s *service) GetPublicPlaylists(ctx context.Context, lim, off int) ([]models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetPublicPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetPublicPlaylists",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	playlists, err := s.repo.GetPlaylists(ctx, models.PlaylistFilter{Lim: lim, Off: off})
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get playlists: %w", err)
+	}
+
+	return playlists, nil
+}
+
+func (s *service) EditPlaylist(ctx context.Context, playlistID string, details models.NewPlaylist) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed EditPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, err := s.ownedPlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	if err = validatePlaylist(&details); err != nil {
+		return models.Playlist{}, err
+	}
+
+	// the songs of regular playlists would be lost, so playlists keep their kind
+	if details.Smart != nil && pl.Smart == nil {
+		return models.Playlist{}, utils.NewError("regular playlists can not become smart", utils.BadRequest)
+	}
+
+	pl.Name, pl.Description, pl.Visibility = details.Name, details.Description, details.Visibility
+	if details.Smart != nil {
+		pl.Smart = details.Smart
+	}
+	if err = s.repo.EditPlaylist(ctx, pl); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to edit playlist: %w", err)
+	}
+
+	if pl, err = s.repo.GetPlaylist(ctx, pl.PlaylistID); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get playlist: %w", err)
+	}
+
+	return pl, nil
+}
+
+func (s *service) DeletePlaylist(ctx context.Context, playlistID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed DeletePlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, err := s.ownedPlaylist(ctx, playlistID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.DeletePlaylist(ctx, pl.PlaylistID); err != nil {
+		return fmt.Errorf("repo failed to delete playlist: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) AddPlaylistSong(ctx context.Context, playlistID string, placement models.PlaylistPlacement) (models.PlaylistSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed AddPlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, user, err := s.editableSongs(ctx, playlistID)
+	if err != nil {
+		return models.PlaylistSong{}, err
+	}
+
+	if placement.SongID == "" {
+		return models.PlaylistSong{}, utils.NewError("song ID is required", utils.BadRequest)
+	}
+
+	entry := models.PlaylistSong{
+		EntryID: uuid.NewString(),
+		Song:    models.Song{SongID: placement.SongID},
+		AddedBy: user.UserID,
+	}
+
+	err = s.placeSong(ctx, pl.PlaylistID, "", placement, func(position string) error {
+		entry.Position = position
+		return s.repo.AddPlaylistSong(ctx, pl.PlaylistID, entry)
+	})
+	if err != nil {
+		return models.PlaylistSong{}, fmt.Errorf("repo failed to add playlist song: %w", err)
+	}
+
+	return s.playlistSong(ctx, pl.PlaylistID, entry.EntryID)
+}
+
+func (s *service) MovePlaylistSong(ctx context.Context, playlistID string, entryID string, placement models.PlaylistPlacement) (models.PlaylistSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed MovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, _, err := s.editableSongs(ctx, playlistID)
+	if err != nil {
+		return models.PlaylistSong{}, err
+	}
+
+	if placement.After == entryID || placement.Before == entryID {
+		return models.PlaylistSong{}, utils.NewError("a song can not be placed next to itself", utils.BadRequest)
+	}
+
+	err = s.placeSong(ctx, pl.PlaylistID, entryID, placement, func(position string) error {
+		return s.repo.MovePlaylistSong(ctx, pl.PlaylistID, entryID, position)
+	})
+	if err != nil {
+		return models.PlaylistSong{}, fmt.Errorf("repo failed to move playlist song: %w", err)
+	}
+
+	return s.playlistSong(ctx, pl.PlaylistID, entryID)
+}
+
+func (s *service) RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RemovePlaylistSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, _, err := s.editableSongs(ctx, playlistID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.RemovePlaylistSong(ctx, pl.PlaylistID, entryID); err != nil {
+		return fmt.Errorf("repo failed to remove playlist song: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) AddPlaylistCollaborator(ctx context.Context, playlistID string, name string) (models.Playlist, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed AddPlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, err := s.ownedPlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	collaborator, err := s.repo.GetUserByName(ctx, normalizeUserName(name))
+	if err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	if collaborator.UserID == pl.OwnerID {
+		return models.Playlist{}, utils.NewError("the owner can not be a collaborator", utils.BadRequest)
+	}
+
+	if err = s.repo.AddPlaylistCollaborator(ctx, pl.PlaylistID, collaborator.UserID); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to add playlist collaborator: %w", err)
+	}
+
+	if pl, err = s.repo.GetPlaylist(ctx, pl.PlaylistID); err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get playlist: %w", err)
+	}
+
+	return pl, nil
+}
+
+func (s *service) RemovePlaylistCollaborator(ctx context.Context, playlistID string, name string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RemovePlaylistCollaborator",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	pl, user, err := s.editablePlaylist(ctx, playlistID)
+	if err != nil {
+		return err
+	}
+
+	name = normalizeUserName(name)
+	idx := slices.IndexFunc(pl.Collaborators, func(c models.PlaylistCollaborator) bool {
+		return c.Name == name
+	})
+	if idx < 0 {
+		return utils.NewError("collaborator not found", utils.NotFound)
+	}
+
+	// collaborators leave playlists on their own, only the owner removes others
+	collaborator := pl.Collaborators[idx]
+	if collaborator.UserID != user.UserID && !ownsPlaylist(ctx, user, pl) {
+		return utils.NewError("only the owner manages collaborators", utils.Forbidden)
+	}
+
+	if err = s.repo.RemovePlaylistCollaborator(ctx, pl.PlaylistID, collaborator.UserID); err != nil {
+		return fmt.Errorf("repo failed to remove playlist collaborator: %w", err)
+	}
+
+	return nil
+}
+
+// placeSong computes the position of the placement among the songs of the playlist except the moved entry and
+// calls place with it. A position taken by a concurrent change is computed again from the new songs
+func (s *service) placeSong(ctx context.Context, playlistID, entryID string, placement models.PlaylistPlacement,
+	place func(position string) error) error {
+	for attempt := 1; ; attempt++ {
+		songs, err := s.repo.GetPlaylistSongs(ctx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		songs = slices.DeleteFunc(songs, func(song models.PlaylistSong) bool {
+			return song.EntryID == entryID
+		})
+
+		before, after, err := placementBounds(songs, placement)
+		if err != nil {
+			return err
+		}
+
+		position, err := playlist.PositionBetween(before, after)
+		if err != nil {
+			return utils.NewError(err.Error(), utils.Internal)
+		}
+
+		if err = place(position); utils.ErrorCode(err) != utils.Conflict || attempt == maxPlacementAttempts {
+			return err
+		}
+	}
+}
+
+// placementBounds returns the positions the placement is between, empty bounds are open. After is enough to
+// place the song right after the entry and Before right before it, both have to be neighbours
+func placementBounds(songs []models.PlaylistSong, placement models.PlaylistPlacement) (string, string, error) {
+	find := func(entryID string) (int, error) {
+		idx := slices.IndexFunc(songs, func(song models.PlaylistSong) bool {
+			return song.EntryID == entryID
+		})
+		if idx < 0 {
+			return 0, utils.NewError("playlist entry not found: "+entryID, utils.BadRequest)
+		}
+		return idx, nil
+	}
+
+	switch {
+	case placement.After == "" && placement.Before == "":
+		if len(songs) == 0 {
+			return "", "", nil
+		}
+		return songs[len(songs)-1].Position, "", nil
+	case placement.Before == "":
+		idx, err := find(placement.After)
+		if err != nil {
+			return "", "", err
+		}
+		if idx == len(songs)-1 {
+			return songs[idx].Position, "", nil
+		}
+		return songs[idx].Position, songs[idx+1].Position, nil
+	case placement.After == "":
+		idx, err := find(placement.Before)
+		if err != nil {
+			return "", "", err
+		}
+		if idx == 0 {
+			return "", songs[idx].Position, nil
+		}
+		return songs[idx-1].Position, songs[idx].Position, nil
+	}
+
+	after, err := find(placement.After)
+	if err != nil {
+		return "", "", err
+	}
+	before, err := find(placement.Before)
+	if err != nil {
+		return "", "", err
+	}
+	if before != after+1 {
+		return "", "", utils.NewError("the entries after and before are not neighbours", utils.Conflict)
+	}
+
+	return songs[after].Position, songs[before].Position, nil
+}
+
+// playlistSong returns the entry of the playlist
+func (s *service) playlistSong(ctx context.Context, playlistID, entryID string) (models.PlaylistSong, error) {
+	songs, err := s.repo.GetPlaylistSongs(ctx, playlistID)
+	if err != nil {
+		return models.PlaylistSong{}, fmt.Errorf("repo failed to get playlist songs: %w", err)
+	}
+
+	idx := slices.IndexFunc(songs, func(song models.PlaylistSong) bool {
+		return song.EntryID == entryID
+	})
+	if idx < 0 {
+		return models.PlaylistSong{}, utils.NewError("playlist entry not found", utils.NotFound)
+	}
+
+	return songs[idx], nil
+}
+
+// visiblePlaylist returns the playlist if the request can see it, private playlists are seen by their members
+// and admins only. Other playlists are not found, so their IDs are not revealed
+func (s *service) visiblePlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	pl, err := s.repo.GetPlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, fmt.Errorf("repo failed to get playlist: %w", err)
+	}
+
+	if pl.Visibility != models.PlaylistPrivate {
+		return pl, nil
+	}
+
+	if user, ok := auth.ExtractUser(ctx); !ok || !editsPlaylist(ctx, user, pl) {
+		return models.Playlist{}, utils.NewError("playlist not found", utils.NotFound)
+	}
+
+	return pl, nil
+}
+
+// editablePlaylist returns the playlist and the user if the user edits its songs
+func (s *service) editablePlaylist(ctx context.Context, playlistID string) (models.Playlist, models.User, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Playlist{}, models.User{}, err
+	}
+
+	pl, err := s.visiblePlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, models.User{}, err
+	}
+
+	if !editsPlaylist(ctx, user, pl) {
+		return models.Playlist{}, models.User{}, utils.NewError("only the owner and collaborators edit the playlist",
+			utils.Forbidden)
+	}
+
+	return pl, user, nil
+}
+
+// editableSongs returns the playlist and the user like editablePlaylist if the songs of the playlist are added
+// by users, the songs of smart playlists follow their rules
+func (s *service) editableSongs(ctx context.Context, playlistID string) (models.Playlist, models.User, error) {
+	pl, user, err := s.editablePlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, models.User{}, err
+	}
+
+	if pl.Smart != nil {
+		return models.Playlist{}, models.User{}, utils.NewError("the songs of smart playlists follow their rules",
+			utils.Conflict)
+	}
+
+	return pl, user, nil
+}
+
+// ownedPlaylist returns the playlist if the user owns it
+func (s *service) ownedPlaylist(ctx context.Context, playlistID string) (models.Playlist, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	pl, err := s.visiblePlaylist(ctx, playlistID)
+	if err != nil {
+		return models.Playlist{}, err
+	}
+
+	if !ownsPlaylist(ctx, user, pl) {
+		return models.Playlist{}, utils.NewError("only the owner manages the playlist", utils.Forbidden)
+	}
+
+	return pl, nil
+}
+
+// ownsPlaylist reports whether the user manages the playlist, admins manage all playlists
+func ownsPlaylist(ctx context.Context, user models.User, pl models.Playlist) bool {
+	return pl.OwnerID == user.UserID || auth.ExtractRole(ctx) == auth.RoleAdmin
+}
+
+// editsPlaylist reports whether the user edits the songs of the playlist
+func editsPlaylist(ctx context.Context, user models.User, pl models.Playlist) bool {
+	return ownsPlaylist(ctx, user, pl) || slices.ContainsFunc(pl.Collaborators, func(c models.PlaylistCollaborator) bool {
+		return c.UserID == user.UserID
+	})
+}
+
+func validatePlaylist(pl *models.NewPlaylist) error {
+	pl.Name, pl.Description = strings.TrimSpace(pl.Name), strings.TrimSpace(pl.Description)
+	if pl.Name == "" || len(pl.Name) > maxPlaylistNameLen {
+		return utils.NewError(fmt.Sprintf("name must be 1 to %d characters long", maxPlaylistNameLen), utils.BadRequest)
+	}
+	if len(pl.Description) > maxPlaylistDescriptionLen {
+		return utils.NewError(fmt.Sprintf("description must be at most %d characters long", maxPlaylistDescriptionLen),
+			utils.BadRequest)
+	}
+
+	switch pl.Visibility {
+	case "":
+		pl.Visibility = models.PlaylistPrivate
+	case models.PlaylistPrivate, models.PlaylistUnlisted, models.PlaylistPublic:
+	default:
+		return utils.NewError("visibility must be private, unlisted or public", utils.BadRequest)
+	}
+
+	if pl.Smart != nil {
+		return validateSmartPlaylist(pl.Smart)
+	}
+
+	return nil
+}
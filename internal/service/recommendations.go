@@ -0,0 +1,200 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	maxRecommendations = 50
+
+	// maxUserInteractions are the latest songs of a user the model is trained on, the pairs of songs of a user grow
+	// with the square of their number
+	maxUserInteractions = 200
+	// maxSongNeighbors are kept for every song, the most similar ones
+	maxSongNeighbors = 20
+	// minCoListeners is the number of users two songs need in common to be neighbors, so a single user can not
+	// make songs recommended to everyone else
+	minCoListeners = 2
+	// favoriteWeight is what a favorite adds to the weight of the plays of a song
+	favoriteWeight = 1
+)
+
+func (s *service) GetRecommendations(ctx context.Context, lim int) ([]models.Recommendation, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if lim <= 0 || lim > maxRecommendations {
+		return nil, utils.NewError(fmt.Sprintf("limit must be from 1 to %d", maxRecommendations), utils.BadRequest)
+	}
+
+	recommendations, err := s.repo.GetRecommendations(ctx, user.UserID, lim)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get recommendations: %w", err)
+	}
+
+	for i := range recommendations {
+		if recommendations[i].Score > 0 {
+			recommendations[i].Reason = models.RecommendedByListeners
+		} else {
+			recommendations[i].Reason = models.RecommendedByPopularity
+		}
+	}
+
+	return recommendations, nil
+}
+
+func (s *service) TrainRecommendations(ctx context.Context) (int, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received TrainRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed TrainRecommendations",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return 0, err
+	}
+
+	model := newCooccurrence()
+
+	// the interactions come ordered by users, so the songs of one user are collected at a time
+	var (
+		userID string
+		songs  []weightedSong
+	)
+	err := s.repo.StreamInteractions(ctx, func(interaction models.Interaction) error {
+		if interaction.UserID != userID {
+			model.addUser(songs)
+			userID, songs = interaction.UserID, songs[:0]
+		}
+
+		if len(songs) < maxUserInteractions {
+			songs = append(songs, weightedSong{songID: interaction.SongID, weight: interactionWeight(interaction)})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repo failed to stream interactions: %w", err)
+	}
+	model.addUser(songs)
+
+	neighbors := model.neighbors()
+	if err = s.repo.ReplaceSongNeighbors(ctx, neighbors); err != nil {
+		return 0, fmt.Errorf("repo failed to replace song neighbors: %w", err)
+	}
+
+	return len(neighbors), nil
+}
+
+// interactionWeight grows slowly with the plays, so a song played on repeat does not outweigh the others
+func interactionWeight(interaction models.Interaction) float64 {
+	weight := math.Log1p(float64(interaction.Plays))
+	if interaction.Favorite {
+		weight += favoriteWeight
+	}
+
+	return weight
+}
+
+type weightedSong struct {
+	songID string
+	weight float64
+}
+
+// cooccurrence is the item-item model of the recommendations. Songs are vectors of the weights of their users
+// and the neighbors of a song are the songs with the highest cosine similarity of the vectors
+type cooccurrence struct {
+	// norms are the squared lengths of the song vectors
+	norms map[string]float64
+	// pairs by song IDs, the lower ID first
+	pairs map[[2]string]*songPair
+}
+
+type songPair struct {
+	// dot is the dot product of the song vectors, users are the number of users of both songs
+	dot   float64
+	users int
+}
+
+func newCooccurrence() *cooccurrence {
+	return &cooccurrence{
+		norms: make(map[string]float64),
+		pairs: make(map[[2]string]*songPair),
+	}
+}
+
+func (c *cooccurrence) addUser(songs []weightedSong) {
+	for i, a := range songs {
+		c.norms[a.songID] += a.weight * a.weight
+
+		for _, b := range songs[i+1:] {
+			key := [2]string{a.songID, b.songID}
+			if key[0] > key[1] {
+				key[0], key[1] = key[1], key[0]
+			}
+
+			pair, ok := c.pairs[key]
+			if !ok {
+				pair = &songPair{}
+				c.pairs[key] = pair
+			}
+			pair.dot += a.weight * b.weight
+			pair.users++
+		}
+	}
+}
+
+// neighbors returns the most similar songs of every song ordered by songs, the most similar first
+func (c *cooccurrence) neighbors() []models.SongNeighbor {
+	bySong := make(map[string][]models.SongNeighbor)
+	for key, pair := range c.pairs {
+		if pair.users < minCoListeners {
+			continue
+		}
+
+		score := pair.dot / math.Sqrt(c.norms[key[0]]*c.norms[key[1]])
+		if score <= 0 {
+			continue
+		}
+
+		bySong[key[0]] = append(bySong[key[0]], models.SongNeighbor{SongID: key[0], NeighborID: key[1], Score: score})
+		bySong[key[1]] = append(bySong[key[1]], models.SongNeighbor{SongID: key[1], NeighborID: key[0], Score: score})
+	}
+
+	neighbors := make([]models.SongNeighbor, 0)
+	for _, songNeighbors := range bySong {
+		slices.SortFunc(songNeighbors, func(a, b models.SongNeighbor) int {
+			return cmp.Or(cmp.Compare(b.Score, a.Score), strings.Compare(a.NeighborID, b.NeighborID))
+		})
+		neighbors = append(neighbors, songNeighbors[:min(maxSongNeighbors, len(songNeighbors))]...)
+	}
+
+	// the songs are ordered to keep the model stable
+	slices.SortStableFunc(neighbors, func(a, b models.SongNeighbor) int {
+		return strings.Compare(a.SongID, b.SongID)
+	})
+
+	return neighbors
+}
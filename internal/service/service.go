@@ -4,30 +4,153 @@ import (
 	"context"
 	"fmt"
 	"github.com/alserok/music_lib/internal/api"
+	"github.com/alserok/music_lib/internal/auth"
 	"github.com/alserok/music_lib/internal/db"
 	"github.com/alserok/music_lib/internal/logger"
 	"github.com/alserok/music_lib/internal/service/models"
 	"github.com/alserok/music_lib/internal/utils"
 	"github.com/google/uuid"
+	"golang.org/x/time/rate"
 	"strings"
+	"time"
 )
 
+// Service checks the role of the request: creating and editing songs requires the editor role, deleting them,
+// migrations and the roles of users require the admin role
 type Service interface {
 	CreateSong(ctx context.Context, song models.Song) error
 	EditSong(ctx context.Context, song models.Song) error
-	DeleteSong(ctx context.Context, songID string) error
-	GetSongText(ctx context.Context, songID string, lim, off int) (string, error)
+	DeleteSong(ctx context.Context, songID string, version int) error
+	GetSong(ctx context.Context, songID string) (models.Song, error)
+	GetSongText(ctx context.Context, songID string, lim, off int) (text string, updatedAt time.Time, err error)
 	GetSongs(ctx context.Context, filter models.SongFilter) ([]models.Song, error)
+	// ExportSongs calls fn for every song matching the filter, the zero limit matches all songs
+	ExportSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error
+
+	GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error)
+	// GetSongAsOf returns the song state of the revision, if revision is 0 the state at the given time is returned
+	GetSongAsOf(ctx context.Context, songID string, revision int, at time.Time) (models.Song, error)
+	RestoreSong(ctx context.Context, songID string, revision int, version int) error
+
+	RestoreDeletedSong(ctx context.Context, songID string, version int) error
+	PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error)
+
+	// CreateSongs, EditSongs and DeleteSongs return a result per item in the order of the input. If atomic is set
+	// either all items are applied or none of them, otherwise failed items are skipped
+	CreateSongs(ctx context.Context, songs []models.NewSong, atomic bool) ([]models.BulkResult, error)
+	EditSongs(ctx context.Context, songs []models.Song, atomic bool) ([]models.BulkResult, error)
+	DeleteSongs(ctx context.Context, songs []models.SongVersion, atomic bool) ([]models.BulkResult, error)
+
+	// ImportSongs creates the songs of the import rows that are valid and not in the library yet, returns a result
+	// per row. If enrich is set the fields missing in the rows are requested from the song data API
+	ImportSongs(ctx context.Context, rows []models.ImportRow, enrich bool) ([]models.ImportResult, error)
+
+	// EnrichSongs requests the song data of the songs and fills their missing release date, text and link,
+	// EnrichPendingSongs does it for all songs that miss any of them. A result is returned per song
+	EnrichSongs(ctx context.Context, songIDs []string) ([]models.BulkResult, error)
+	EnrichPendingSongs(ctx context.Context) ([]models.BulkResult, error)
+
+	GetMigrations(ctx context.Context) ([]models.Migration, error)
+	// MigrateUp applies the pending migrations up to and including the version, 0 applies all of them.
+	// MigrateDown rolls back the migrations newer than the version
+	MigrateUp(ctx context.Context, version int64) error
+	MigrateDown(ctx context.Context, version int64) error
+
+	// MatchPlaylist finds the library songs of playlist entries by song ID, link or similar artist and title,
+	// returns a match per entry
+	MatchPlaylist(ctx context.Context, entries []models.PlaylistEntry) ([]models.PlaylistMatch, error)
+
+	// Register creates the account and logs it in, Login starts a new session of the account
+	Register(ctx context.Context, user models.NewUser) (models.Session, error)
+	Login(ctx context.Context, credentials models.Credentials) (models.Session, error)
+	// Logout ends the session of the token
+	Logout(ctx context.Context, token string) error
+	// Authenticate returns the user of the session token, invalid and expired tokens are Unauthorized errors
+	Authenticate(ctx context.Context, token string) (models.User, error)
+
+	// GetProfile, EditProfile and ChangePassword work with the authenticated user of the context. Changing
+	// the password ends all sessions of the user and starts a new one
+	GetProfile(ctx context.Context) (models.User, error)
+	EditProfile(ctx context.Context, profile models.UserProfile) (models.User, error)
+	ChangePassword(ctx context.Context, change models.PasswordChange) (models.Session, error)
+	// SetUserRole grants the role to the user of the name
+	SetUserRole(ctx context.Context, name string, role string) (models.User, error)
+
+	// IssueAPIKey issues a key of the authenticated user, the key is returned only once. The scopes can not exceed
+	// the role of the user. GetAPIKeys and RevokeAPIKey manage the keys of the user, admins revoke any key
+	IssueAPIKey(ctx context.Context, key models.NewAPIKey) (models.IssuedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string) error
+	// AuthenticateAPIKey returns the owner of the key and the key, invalid and revoked keys are Unauthorized errors
+	AuthenticateAPIKey(ctx context.Context, key string) (models.User, models.APIKey, error)
+
+	// CreatePlaylist creates a playlist of the authenticated user. Private playlists are seen by their owner,
+	// collaborators and admins only, GetPlaylist returns the playlist with its songs
+	CreatePlaylist(ctx context.Context, playlist models.NewPlaylist) (models.Playlist, error)
+	GetPlaylist(ctx context.Context, playlistID string) (models.Playlist, error)
+	// GetPlaylists returns the playlists the authenticated user owns or collaborates on, GetPublicPlaylists
+	// the public ones, both recently updated first
+	GetPlaylists(ctx context.Context, lim, off int) ([]models.Playlist, error)
+	GetPublicPlaylists(ctx context.Context, lim, off int) ([]models.Playlist, error)
+	// EditPlaylist, DeletePlaylist and the collaborator methods require the owner of the playlist or an admin,
+	// collaborators can only remove themselves
+	EditPlaylist(ctx context.Context, playlistID string, playlist models.NewPlaylist) (models.Playlist, error)
+	DeletePlaylist(ctx context.Context, playlistID string) error
+	AddPlaylistCollaborator(ctx context.Context, playlistID string, name string) (models.Playlist, error)
+	RemovePlaylistCollaborator(ctx context.Context, playlistID string, name string) error
+	// AddPlaylistSong and MovePlaylistSong place the song by the placement, other songs keep their positions.
+	// The songs of a playlist are edited by its owner and collaborators, the ones of smart playlists follow their rules
+	// and are evaluated on every read
+	AddPlaylistSong(ctx context.Context, playlistID string, placement models.PlaylistPlacement) (models.PlaylistSong, error)
+	MovePlaylistSong(ctx context.Context, playlistID string, entryID string, placement models.PlaylistPlacement) (models.PlaylistSong, error)
+	RemovePlaylistSong(ctx context.Context, playlistID string, entryID string) error
+	// PreviewSmartPlaylist returns the songs a smart playlist with the policy would have now
+	PreviewSmartPlaylist(ctx context.Context, smart models.SmartPlaylist) ([]models.Song, error)
+
+	// FavoriteSong, RateSong and RecordPlay keep the personal data of the authenticated user and return the song
+	// with its updated stats. Removing a missing favorite or rating changes nothing
+	FavoriteSong(ctx context.Context, songID string) (models.Song, error)
+	UnfavoriteSong(ctx context.Context, songID string) error
+	RateSong(ctx context.Context, songID string, rating int) (models.Song, error)
+	UnrateSong(ctx context.Context, songID string) error
+	RecordPlay(ctx context.Context, songID string, play models.NewPlay) (models.Play, error)
+	// GetFavorites, GetRatings and GetPlays return the data of the authenticated user, the most recent first
+	GetFavorites(ctx context.Context, lim, off int) ([]models.Favorite, error)
+	GetRatings(ctx context.Context, lim, off int) ([]models.Rating, error)
+	GetPlays(ctx context.Context, lim, off int) ([]models.Play, error)
+
+	// GetSimilarSongs returns the songs most similar to the song by lyrics, artist and release era, the most
+	// similar first. The index of the library is built on first use and follows the songs changed by the service,
+	// RebuildSimilarityIndex builds it again to catch up with the changes of other instances and returns
+	// the number of indexed songs
+	GetSimilarSongs(ctx context.Context, songID string, lim int) ([]models.SimilarSong, error)
+	RebuildSimilarityIndex(ctx context.Context) (int, error)
+
+	// GetRecommendations returns the songs the authenticated user neither played nor favorited, the ones played by
+	// the listeners of the songs of the user first and the popular ones after them
+	GetRecommendations(ctx context.Context, lim int) ([]models.Recommendation, error)
+	// TrainRecommendations trains the neighbors of the songs on the plays and favorites of all users and replaces
+	// the stored ones, returns the number of neighbors
+	TrainRecommendations(ctx context.Context) (int, error)
 }
 
 type Clients struct {
 	SongDataAPIClient api.SongDataAPIClient
+	// SongDataAPILimiter limits the requests to the song data API of all clients together, a single bulk or import
+	// request enriches many songs. Nil does not limit them
+	SongDataAPILimiter *rate.Limiter
 }
 
 func New(repo db.Repository, cls *Clients) *service {
+	songDataAPIClient := cls.SongDataAPIClient
+	if cls.SongDataAPILimiter != nil {
+		songDataAPIClient = &limitedSongDataClient{SongDataAPIClient: songDataAPIClient, limiter: cls.SongDataAPILimiter}
+	}
+
 	return &service{
 		repo:              repo,
-		songDataAPIClient: cls.SongDataAPIClient,
+		songDataAPIClient: songDataAPIClient,
+		similar:           newSimilarIndex(),
 	}
 }
 
@@ -35,6 +158,8 @@ type service struct {
 	repo db.Repository
 
 	songDataAPIClient api.SongDataAPIClient
+
+	similar *similarIndex
 }
 
 func (s *service) CreateSong(ctx context.Context, song models.Song) error {
@@ -47,6 +172,10 @@ func (s *service) CreateSong(ctx context.Context, song models.Song) error {
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return err
+	}
+
 	songData, err := s.songDataAPIClient.GetSongData(ctx, song.Group, song.Song)
 	if err != nil {
 		return fmt.Errorf("client failed to get song data: %w", err)
@@ -58,6 +187,7 @@ func (s *service) CreateSong(ctx context.Context, song models.Song) error {
 	if err = s.repo.CreateSong(ctx, song); err != nil {
 		return fmt.Errorf("repo failed to create song: %w", err)
 	}
+	s.similar.touch(song.SongID)
 
 	return nil
 }
@@ -72,14 +202,19 @@ func (s *service) EditSong(ctx context.Context, song models.Song) error {
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return err
+	}
+
 	if err := s.repo.EditSong(ctx, song); err != nil {
 		return fmt.Errorf("repo failed to edit song: %w", err)
 	}
+	s.similar.touch(song.SongID)
 
 	return nil
 }
 
-func (s *service) DeleteSong(ctx context.Context, songID string) error {
+func (s *service) DeleteSong(ctx context.Context, songID string, version int) error {
 	logger.ExtractLogger(ctx).
 		Debug("service received DeleteSong",
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
@@ -89,14 +224,37 @@ func (s *service) DeleteSong(ctx context.Context, songID string) error {
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
-	if err := s.repo.DeleteSong(ctx, songID); err != nil {
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteSong(ctx, songID, version); err != nil {
 		return fmt.Errorf("repo failed to delete song: %w", err)
 	}
+	s.similar.touch(songID)
 
 	return nil
 }
 
-func (s *service) GetSongText(ctx context.Context, songID string, lim, off int) (string, error) {
+func (s *service) GetSong(ctx context.Context, songID string) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	song, err := s.repo.GetSong(ctx, songID)
+	if err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to get song: %w", err)
+	}
+
+	return song, nil
+}
+
+func (s *service) GetSongText(ctx context.Context, songID string, lim, off int) (string, time.Time, error) {
 	logger.ExtractLogger(ctx).
 		Debug("service received GetSongText",
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
@@ -106,23 +264,23 @@ func (s *service) GetSongText(ctx context.Context, songID string, lim, off int)
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
-	text, err := s.repo.GetSongText(ctx, songID)
+	text, updatedAt, err := s.repo.GetSongText(ctx, songID)
 	if err != nil {
-		return "", fmt.Errorf("repo failed to get song text: %w", err)
+		return "", time.Time{}, fmt.Errorf("repo failed to get song text: %w", err)
 	}
 
 	text = strings.ReplaceAll(text, "\\n", "\n")
 	couplets := strings.Split(text, "\n\n")
 
 	if off >= len(couplets) {
-		return "", utils.NewError(
+		return "", time.Time{}, utils.NewError(
 			fmt.Sprintf("invalid offset parameter: number of couplets: %d offset_index: %d", len(couplets), off), utils.BadRequest)
 	}
 
 	if off+lim > len(couplets) {
-		return strings.Join(couplets[off:], "\n\n"), nil
+		return strings.Join(couplets[off:], "\n\n"), updatedAt, nil
 	} else {
-		return strings.Join(couplets[off:lim+off], "\n\n"), nil
+		return strings.Join(couplets[off:lim+off], "\n\n"), updatedAt, nil
 	}
 }
 
@@ -136,6 +294,10 @@ func (s *service) GetSongs(ctx context.Context, filter models.SongFilter) ([]mod
 			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
 		)
 
+	if err := validatePopularity(filter); err != nil {
+		return nil, err
+	}
+
 	songs, err := s.repo.GetSongs(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("repo failed to get songs: %w", err)
@@ -143,3 +305,189 @@ func (s *service) GetSongs(ctx context.Context, filter models.SongFilter) ([]mod
 
 	return songs, nil
 }
+
+func (s *service) ExportSongs(ctx context.Context, filter models.SongFilter, fn func(song models.Song) error) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received ExportSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed ExportSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if filter.Lim < 0 || filter.Off < 0 {
+		return utils.NewError("limit and offset must not be negative", utils.BadRequest)
+	}
+
+	if err := s.repo.StreamSongs(ctx, filter, fn); err != nil {
+		return fmt.Errorf("repo failed to stream songs: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) GetSongRevisions(ctx context.Context, songID string, lim, off int) ([]models.SongRevision, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetSongRevisions",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	revisions, err := s.repo.GetSongRevisions(ctx, songID, lim, off)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get song revisions: %w", err)
+	}
+
+	for i := range revisions {
+		revisions[i].Changes = diffSongs(revisions[i].Before, revisions[i].After)
+	}
+
+	return revisions, nil
+}
+
+func (s *service) GetSongAsOf(ctx context.Context, songID string, revision int, at time.Time) (models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetSongAsOf",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetSongAsOf",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	var (
+		rev models.SongRevision
+		err error
+	)
+	if revision != 0 {
+		rev, err = s.repo.GetSongRevision(ctx, songID, revision)
+	} else {
+		rev, err = s.repo.GetSongRevisionAt(ctx, songID, at)
+	}
+	if err != nil {
+		return models.Song{}, fmt.Errorf("repo failed to get song revision: %w", err)
+	}
+
+	if rev.After == nil {
+		return models.Song{}, utils.NewError("song was deleted at this revision", utils.NotFound)
+	}
+
+	return *rev.After, nil
+}
+
+func (s *service) RestoreSong(ctx context.Context, songID string, revision int, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RestoreSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return err
+	}
+
+	if revision <= 0 {
+		return utils.NewError("invalid revision", utils.BadRequest)
+	}
+
+	if err := s.repo.RestoreSong(ctx, songID, revision, version); err != nil {
+		return fmt.Errorf("repo failed to restore song: %w", err)
+	}
+	s.similar.touch(songID)
+
+	return nil
+}
+
+// diffSongs lists the fields changed between two song states, a missing state has empty fields
+func diffSongs(before, after *models.Song) []models.SongChange {
+	var b, a models.Song
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"group", b.Group, a.Group},
+		{"song", b.Song, a.Song},
+		{"releaseDate", formatDate(b.Data.ReleaseDate), formatDate(a.Data.ReleaseDate)},
+		{"text", b.Data.Text, a.Data.Text},
+		{"link", b.Data.Link, a.Data.Link},
+	}
+
+	changes := make([]models.SongChange, 0, len(fields))
+	for _, field := range fields {
+		if field.before != field.after {
+			changes = append(changes, models.SongChange{Field: field.name, Before: field.before, After: field.after})
+		}
+	}
+
+	return changes
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (s *service) RestoreDeletedSong(ctx context.Context, songID string, version int) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RestoreDeletedSong",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.repo.RestoreDeletedSong(ctx, songID, version); err != nil {
+		return fmt.Errorf("repo failed to restore deleted song: %w", err)
+	}
+	s.similar.touch(songID)
+
+	return nil
+}
+
+func (s *service) PurgeDeletedSongs(ctx context.Context, retention time.Duration) (int64, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed PurgeDeletedSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return 0, err
+	}
+
+	// songs deleted a moment ago would be purged with no chance to restore them
+	if retention <= 0 {
+		return 0, utils.NewError("retention must be positive", utils.BadRequest)
+	}
+
+	purged, err := s.repo.PurgeDeletedSongs(ctx, retention)
+	if err != nil {
+		return 0, fmt.Errorf("repo failed to purge deleted songs: %w", err)
+	}
+
+	return purged, nil
+}
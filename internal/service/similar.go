@@ -0,0 +1,446 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const maxSimilarSongs = 50
+
+// the weights are the shares of the features in the similarity of songs with all features known. The library has
+// no genres, so the lyrics, the artist and the release era are all there is
+const (
+	lyricsWeight = 0.6
+	artistWeight = 0.25
+	eraWeight    = 0.15
+)
+
+// stopWords are too common in lyrics to tell songs apart
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {}, "by": {}, "do": {}, "for": {},
+	"from": {}, "he": {}, "her": {}, "him": {}, "his": {}, "i": {}, "if": {}, "in": {}, "is": {}, "it": {}, "its": {},
+	"me": {}, "my": {}, "no": {}, "not": {}, "of": {}, "oh": {}, "on": {}, "or": {}, "so": {}, "she": {}, "that": {},
+	"the": {}, "their": {}, "them": {}, "then": {}, "there": {}, "they": {}, "this": {}, "to": {}, "up": {}, "was": {},
+	"we": {}, "what": {}, "when": {}, "with": {}, "you": {}, "your": {},
+}
+
+func (s *service) GetSimilarSongs(ctx context.Context, songID string, lim int) ([]models.SimilarSong, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetSimilarSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetSimilarSongs",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if lim <= 0 || lim > maxSimilarSongs {
+		return nil, utils.NewError(fmt.Sprintf("limit must be from 1 to %d", maxSimilarSongs), utils.BadRequest)
+	}
+
+	// the index is built by the rebuild job, requests never wait for the whole library to be read
+	if !s.similar.built() {
+		return nil, utils.NewError("similar songs are not indexed yet, try again later", utils.Unavailable)
+	}
+
+	if err := s.refreshSimilarIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	// songs created by other instances or the command line are not in the index till it is rebuilt
+	if !s.similar.has(songID) {
+		song, err := s.repo.GetSong(ctx, songID)
+		if err != nil {
+			return nil, fmt.Errorf("repo failed to get song: %w", err)
+		}
+		s.similar.put(song)
+		songID = song.SongID
+	}
+
+	scores := s.similar.nearest(songID, lim)
+
+	songIDs := make([]string, len(scores))
+	for i, score := range scores {
+		songIDs[i] = score.songID
+	}
+	songs, err := s.repo.GetSongsByID(ctx, songIDs)
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get songs: %w", err)
+	}
+
+	byID := make(map[string]models.Song, len(songs))
+	for _, song := range songs {
+		byID[song.SongID] = song
+	}
+
+	similar := make([]models.SimilarSong, 0, len(scores))
+	for _, score := range scores {
+		// the song was deleted after the index was refreshed
+		song, ok := byID[score.songID]
+		if !ok {
+			continue
+		}
+
+		similar = append(similar, models.SimilarSong{Song: song, Score: score.score})
+	}
+
+	return similar, nil
+}
+
+func (s *service) RebuildSimilarityIndex(ctx context.Context) (int, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received RebuildSimilarityIndex",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed RebuildSimilarityIndex",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return 0, err
+	}
+
+	s.similar.buildMu.Lock()
+	defer s.similar.buildMu.Unlock()
+
+	return s.rebuildSimilarIndex(ctx)
+}
+
+// refreshSimilarIndex indexes again the songs changed since the last refresh. While the index is rebuilt the
+// requests are served by the old one, the rebuild indexes the changes anyway
+func (s *service) refreshSimilarIndex(ctx context.Context) error {
+	if !s.similar.buildMu.TryLock() {
+		return nil
+	}
+	defer s.similar.buildMu.Unlock()
+
+	pending := s.similar.takePending()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	songs, err := s.repo.GetSongsByID(ctx, pending)
+	if err != nil {
+		s.similar.touch(pending...)
+		return fmt.Errorf("repo failed to get songs: %w", err)
+	}
+
+	// the songs not found were deleted
+	found := make(map[string]struct{}, len(songs))
+	for _, song := range songs {
+		s.similar.put(song)
+		found[song.SongID] = struct{}{}
+	}
+	for _, songID := range pending {
+		if _, ok := found[songID]; !ok {
+			s.similar.remove(songID)
+		}
+	}
+
+	return nil
+}
+
+// rebuildSimilarIndex replaces the index with the one of the whole library, the build lock has to be held
+func (s *service) rebuildSimilarIndex(ctx context.Context) (int, error) {
+	// the songs changed while the library is streamed are refreshed once it is indexed
+	s.similar.track()
+
+	docs := make(map[string]*similarDoc)
+	err := s.repo.StreamSongs(ctx, models.SongFilter{}, func(song models.Song) error {
+		docs[song.SongID] = newSimilarDoc(song)
+		return nil
+	})
+	if err != nil {
+		s.similar.untrack()
+		return 0, fmt.Errorf("repo failed to stream songs: %w", err)
+	}
+
+	s.similar.replace(docs)
+
+	return len(docs), nil
+}
+
+// similarIndex holds the feature vectors of the library songs. The vectors are built from the lyric terms
+// weighted by TF-IDF, the artist and the release era and are compared by cosine similarity. Changed songs are
+// touched and weighed again on the next refresh by the IDF of the last build, which is slightly stale till the
+// next rebuild, so a change never weighs the whole library again
+type similarIndex struct {
+	// buildMu serializes the builds and refreshes, which read the repository without holding mu
+	buildMu sync.Mutex
+
+	mu   sync.Mutex
+	docs map[string]*similarDoc
+	// idf are the weights of the lyric terms in the library of the last build
+	idf idfWeights
+	// pending are the songs touched since the last refresh, they are tracked only once the index is being built
+	pending  map[string]struct{}
+	tracking bool
+}
+
+// idfWeights weigh the lyric terms by the number of songs with them
+type idfWeights struct {
+	// df is the number of songs with each lyric term
+	df map[string]int
+	// songs is the number of songs in the library
+	songs int
+}
+
+func (w idfWeights) weight(term string) float64 {
+	return math.Log((1+float64(w.songs))/(1+float64(w.df[term]))) + 1
+}
+
+type similarDoc struct {
+	// terms are the sublinear frequencies of the lyric terms
+	terms  map[string]float64
+	artist map[string]float64
+	era    map[string]float64
+	vector map[string]float64
+}
+
+type similarScore struct {
+	songID string
+	score  float64
+}
+
+func newSimilarIndex() *similarIndex {
+	return &similarIndex{pending: make(map[string]struct{})}
+}
+
+// touch marks the songs as changed
+func (idx *similarIndex) touch(songIDs ...string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if !idx.tracking {
+		return
+	}
+	for _, songID := range songIDs {
+		idx.pending[songID] = struct{}{}
+	}
+}
+
+func (idx *similarIndex) takePending() []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	songIDs := make([]string, 0, len(idx.pending))
+	for songID := range idx.pending {
+		songIDs = append(songIDs, songID)
+	}
+	clear(idx.pending)
+
+	return songIDs
+}
+
+func (idx *similarIndex) track() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.tracking = true
+	clear(idx.pending)
+}
+
+// untrack stops tracking after a failed build unless there is an earlier index to refresh
+func (idx *similarIndex) untrack() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.tracking = idx.docs != nil
+}
+
+func (idx *similarIndex) built() bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.docs != nil
+}
+
+func (idx *similarIndex) has(songID string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	_, ok := idx.docs[songID]
+	return ok
+}
+
+// replace weighs the docs without holding mu, so requests are served by the old index while the new one is built
+func (idx *similarIndex) replace(docs map[string]*similarDoc) {
+	idf := idfWeights{df: make(map[string]int), songs: len(docs)}
+	for _, doc := range docs {
+		for term := range doc.terms {
+			idf.df[term]++
+		}
+	}
+	for _, doc := range docs {
+		doc.vector = weigh(doc, idf)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.docs, idx.idf = docs, idf
+}
+
+func (idx *similarIndex) put(song models.Song) {
+	doc := newSimilarDoc(song)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.docs == nil {
+		return
+	}
+	doc.vector = weigh(doc, idx.idf)
+	idx.docs[song.SongID] = doc
+}
+
+func (idx *similarIndex) remove(songID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	delete(idx.docs, songID)
+}
+
+// nearest returns the lim songs most similar to the song, the most similar first. Songs sharing no feature
+// with it are left out
+func (idx *similarIndex) nearest(songID string, lim int) []similarScore {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	target, ok := idx.docs[songID]
+	if !ok {
+		return nil
+	}
+
+	scores := make([]similarScore, 0)
+	for id, doc := range idx.docs {
+		if id == songID {
+			continue
+		}
+
+		if score := dot(target.vector, doc.vector); score > 0 {
+			scores = append(scores, similarScore{songID: id, score: min(score, 1)})
+		}
+	}
+
+	// ties are broken by the song ID to keep the result stable
+	slices.SortFunc(scores, func(a, b similarScore) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.songID, b.songID)
+	})
+
+	return scores[:min(lim, len(scores))]
+}
+
+// weigh builds the unit vector of the song. Each feature is scaled to the square root of its weight, so for songs
+// with all features the cosine similarity is the weighted mean of the similarities of the features
+func weigh(doc *similarDoc, idf idfWeights) map[string]float64 {
+	vector := make(map[string]float64, len(doc.terms)+len(doc.artist)+len(doc.era))
+
+	lyrics := make(map[string]float64, len(doc.terms))
+	for term, tf := range doc.terms {
+		lyrics[term] = tf * idf.weight(term)
+	}
+	addScaled(vector, lyrics, lyricsWeight)
+
+	addScaled(vector, doc.artist, artistWeight)
+	addScaled(vector, doc.era, eraWeight)
+
+	if length := math.Sqrt(dot(vector, vector)); length > 0 {
+		for key := range vector {
+			vector[key] /= length
+		}
+	}
+
+	return vector
+}
+
+// addScaled adds the values to the vector scaled to the length of the square root of the weight
+func addScaled(vector, values map[string]float64, weight float64) {
+	length := math.Sqrt(dot(values, values))
+	if length == 0 {
+		return
+	}
+
+	for key, value := range values {
+		vector[key] = value / length * math.Sqrt(weight)
+	}
+}
+
+func dot(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	var sum float64
+	for key, value := range a {
+		sum += value * b[key]
+	}
+
+	return sum
+}
+
+// newSimilarDoc extracts the features of the song. The era is the decade of the release and its half, so songs
+// of the same half of a decade are more similar than the ones of the same decade
+func newSimilarDoc(song models.Song) *similarDoc {
+	counts := make(map[string]int)
+	for _, term := range lyricTerms(song.Data.Text) {
+		counts[term]++
+	}
+
+	terms := make(map[string]float64, len(counts))
+	for term, count := range counts {
+		terms[term] = 1 + math.Log(float64(count))
+	}
+
+	// the features are keyed apart from the lyric terms, which are letters and digits only
+	doc := &similarDoc{terms: terms, artist: make(map[string]float64), era: make(map[string]float64)}
+	if artist := normalizeName(song.Group); artist != "" {
+		doc.artist["artist:"+artist] = 1
+	}
+	if !song.Data.ReleaseDate.IsZero() {
+		year := song.Data.ReleaseDate.Year()
+		doc.era["era:"+strconv.Itoa(year/10*10)+"s"] = 1
+		doc.era["era:"+strconv.Itoa(year/5*5)+"-"+strconv.Itoa(year/5*5+4)] = 1
+	}
+
+	return doc
+}
+
+// lyricTerms splits the lyrics into lower case words without diacritics, stop words and single letters.
+// Stored lyrics may keep line breaks escaped
+func lyricTerms(text string) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, "\\n", "\n"))
+	text, _, _ = transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), text)
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if _, ok := stopWords[word]; ok || len([]rune(word)) < 2 {
+			continue
+		}
+		terms = append(terms, word)
+	}
+
+	return terms
+}
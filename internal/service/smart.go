@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+)
+
+const (
+	defaultSmartPlaylistLimit = 100
+	maxSmartPlaylistLimit     = 500
+
+	// maxSmartRulesDepth and maxSmartConditions bound the size of the queries the rules are evaluated with
+	maxSmartRulesDepth = 4
+	maxSmartConditions = 50
+	// maxAddedWithinDays is about a hundred years
+	maxAddedWithinDays = 36500
+)
+
+func (s *service) PreviewSmartPlaylist(ctx context.Context, smart models.SmartPlaylist) ([]models.Song, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received PreviewSmartPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed PreviewSmartPlaylist",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := validateSmartPlaylist(&smart); err != nil {
+		return nil, err
+	}
+
+	return s.smartSongs(ctx, smart)
+}
+
+// smartSongs evaluates the rules of the smart playlist against the library
+func (s *service) smartSongs(ctx context.Context, smart models.SmartPlaylist) ([]models.Song, error) {
+	songs, err := s.repo.GetSongs(ctx, models.SongFilter{
+		Lim:   smart.Limit,
+		Sort:  smart.Sort,
+		Rules: &smart.Rules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repo failed to get songs: %w", err)
+	}
+
+	return songs, nil
+}
+
+// validateSmartPlaylist checks the policy of the smart playlist, the zero limit and the empty matches are
+// replaced with the defaults
+func validateSmartPlaylist(smart *models.SmartPlaylist) error {
+	if err := validatePopularity(models.SongFilter{Sort: smart.Sort}); err != nil {
+		return err
+	}
+
+	switch {
+	case smart.Limit == 0:
+		smart.Limit = defaultSmartPlaylistLimit
+	case smart.Limit < 0 || smart.Limit > maxSmartPlaylistLimit:
+		return utils.NewError(fmt.Sprintf("limit must be from 1 to %d", maxSmartPlaylistLimit), utils.BadRequest)
+	}
+
+	conditions := 0
+	return validateSmartRules(&smart.Rules, 1, &conditions)
+}
+
+func validateSmartRules(rules *models.SmartRules, depth int, conditions *int) error {
+	if depth > maxSmartRulesDepth {
+		return utils.NewError(fmt.Sprintf("rules must be nested at most %d levels deep", maxSmartRulesDepth),
+			utils.BadRequest)
+	}
+
+	switch rules.Match {
+	case "":
+		rules.Match = models.MatchAll
+	case models.MatchAll, models.MatchAny:
+	default:
+		return utils.NewError(fmt.Sprintf("match must be %s or %s", models.MatchAll, models.MatchAny), utils.BadRequest)
+	}
+
+	// only the top group may be empty to select the whole library, nested ones are mistakes
+	if depth > 1 && len(rules.Conditions) == 0 && len(rules.Groups) == 0 {
+		return utils.NewError("nested groups must not be empty", utils.BadRequest)
+	}
+
+	*conditions += len(rules.Conditions)
+	if *conditions > maxSmartConditions {
+		return utils.NewError(fmt.Sprintf("rules must have at most %d conditions", maxSmartConditions), utils.BadRequest)
+	}
+
+	for _, condition := range rules.Conditions {
+		if err := validateSmartCondition(condition); err != nil {
+			return err
+		}
+	}
+	for i := range rules.Groups {
+		if err := validateSmartRules(&rules.Groups[i], depth+1, conditions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateSmartCondition(condition models.SmartCondition) error {
+	if condition == (models.SmartCondition{}) {
+		return utils.NewError("conditions must set at least one field", utils.BadRequest)
+	}
+
+	if condition.ReleasedFrom != nil && condition.ReleasedTo != nil && condition.ReleasedFrom.After(*condition.ReleasedTo) {
+		return utils.NewError("releasedFrom must not be after releasedTo", utils.BadRequest)
+	}
+
+	if condition.MinPlays < 0 || condition.MinFavorites < 0 {
+		return utils.NewError("minimum plays and favorites must not be negative", utils.BadRequest)
+	}
+	if condition.MaxPlays != nil && *condition.MaxPlays < condition.MinPlays {
+		return utils.NewError("maximum plays must not be less than the minimum plays", utils.BadRequest)
+	}
+	// written negated, so NaN fails as well
+	if !(condition.MinRating >= 0 && condition.MinRating <= models.MaxRating) {
+		return utils.NewError(fmt.Sprintf("minimum rating must be from 0 to %d", models.MaxRating), utils.BadRequest)
+	}
+
+	if condition.AddedWithinDays < 0 || condition.AddedWithinDays > maxAddedWithinDays {
+		return utils.NewError(fmt.Sprintf("addedWithinDays must be from 0 to %d", maxAddedWithinDays), utils.BadRequest)
+	}
+
+	return nil
+}
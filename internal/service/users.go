@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/alserok/music_lib/internal/auth"
+	"github.com/alserok/music_lib/internal/logger"
+	"github.com/alserok/music_lib/internal/service/models"
+	"github.com/alserok/music_lib/internal/utils"
+	"github.com/google/uuid"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionTTL is how long a login stays valid
+	sessionTTL = 30 * 24 * time.Hour
+
+	minPasswordLen = 8
+	// maxPasswordLen bounds the work of hashing a password sent by a client
+	maxPasswordLen = 256
+)
+
+// userNameRe are the names users log in with, they are compared in lower case
+var userNameRe = regexp.MustCompile(`^[a-z0-9_.-]{3,64}$`)
+
+// dummyPasswordHash is verified for unknown names, so logins of missing and existing users take the same time
+var dummyPasswordHash, _ = auth.HashPassword("dummy password")
+
+func (s *service) Register(ctx context.Context, newUser models.NewUser) (models.Session, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received Register",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed Register",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	name := normalizeUserName(newUser.Name)
+	if !userNameRe.MatchString(name) {
+		return models.Session{}, utils.NewError(
+			"name must be 3 to 64 letters, digits, dots, dashes or underscores", utils.BadRequest)
+	}
+
+	profile := models.UserProfile{DisplayName: newUser.DisplayName, Email: newUser.Email}
+	if err := validateProfile(&profile); err != nil {
+		return models.Session{}, err
+	}
+
+	hash, err := hashPassword(newUser.Password)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	user := models.User{
+		UserID:       uuid.NewString(),
+		Name:         name,
+		DisplayName:  profile.DisplayName,
+		Email:        profile.Email,
+		Role:         auth.RoleViewer,
+		PasswordHash: hash,
+	}
+	if user.DisplayName == "" {
+		user.DisplayName = strings.TrimSpace(newUser.Name)
+	}
+
+	if err = s.repo.CreateUser(ctx, user); err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to create user: %w", err)
+	}
+
+	return s.startSession(ctx, user.UserID)
+}
+
+func (s *service) Login(ctx context.Context, credentials models.Credentials) (models.Session, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received Login",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed Login",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if len(credentials.Password) > maxPasswordLen {
+		return models.Session{}, utils.NewError("invalid name or password", utils.Unauthorized)
+	}
+
+	user, err := s.repo.GetUserByName(ctx, normalizeUserName(credentials.Name))
+	if err != nil && !utils.IsNotFound(err) {
+		return models.Session{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	hash := user.PasswordHash
+	if err != nil {
+		hash = dummyPasswordHash
+	}
+
+	ok, verifyErr := auth.VerifyPassword(credentials.Password, hash)
+	if verifyErr != nil {
+		return models.Session{}, utils.NewError("failed to verify password: "+verifyErr.Error(), utils.Internal)
+	}
+
+	if err != nil || !ok {
+		return models.Session{}, utils.NewError("invalid name or password", utils.Unauthorized)
+	}
+
+	return s.startSession(ctx, user.UserID)
+}
+
+func (s *service) Logout(ctx context.Context, token string) error {
+	logger.ExtractLogger(ctx).
+		Debug("service received Logout",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed Logout",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := s.repo.DeleteSession(ctx, auth.HashToken(token)); err != nil {
+		return fmt.Errorf("repo failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *service) Authenticate(ctx context.Context, token string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received Authenticate",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed Authenticate",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	user, err := s.repo.GetSessionUser(ctx, auth.HashToken(token))
+	if err != nil {
+		if utils.IsNotFound(err) {
+			return models.User{}, utils.NewError("invalid or expired token", utils.Unauthorized)
+		}
+		return models.User{}, fmt.Errorf("repo failed to get session user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *service) GetProfile(ctx context.Context) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received GetProfile",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed GetProfile",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	current, err := currentUser(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.repo.GetUser(ctx, current.UserID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *service) EditProfile(ctx context.Context, profile models.UserProfile) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received EditProfile",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed EditProfile",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	current, err := currentUser(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err = validateProfile(&profile); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.repo.GetUser(ctx, current.UserID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	user.DisplayName, user.Email = profile.DisplayName, profile.Email
+	if user.DisplayName == "" {
+		user.DisplayName = user.Name
+	}
+
+	if err = s.repo.EditUser(ctx, user); err != nil {
+		return models.User{}, fmt.Errorf("repo failed to edit user: %w", err)
+	}
+
+	if user, err = s.repo.GetUser(ctx, user.UserID); err != nil {
+		return models.User{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *service) ChangePassword(ctx context.Context, change models.PasswordChange) (models.Session, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received ChangePassword",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed ChangePassword",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	current, err := currentUser(ctx)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	user, err := s.repo.GetUser(ctx, current.UserID)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	ok, err := auth.VerifyPassword(change.OldPassword, user.PasswordHash)
+	if err != nil {
+		return models.Session{}, utils.NewError("failed to verify password: "+err.Error(), utils.Internal)
+	}
+	if !ok {
+		return models.Session{}, utils.NewError("invalid password", utils.Unauthorized)
+	}
+
+	if user.PasswordHash, err = hashPassword(change.NewPassword); err != nil {
+		return models.Session{}, err
+	}
+
+	if err = s.repo.EditUser(ctx, user); err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to edit user: %w", err)
+	}
+
+	// sessions started with the old password may have been stolen with it
+	if err = s.repo.DeleteUserSessions(ctx, user.UserID); err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to delete user sessions: %w", err)
+	}
+
+	return s.startSession(ctx, user.UserID)
+}
+
+func (s *service) SetUserRole(ctx context.Context, name string, role string) (models.User, error) {
+	logger.ExtractLogger(ctx).
+		Debug("service received SetUserRole",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+	defer logger.ExtractLogger(ctx).
+		Debug("service passed SetUserRole",
+			logger.WithArg("id", logger.ExtractIdentifier(ctx)),
+		)
+
+	if err := auth.RequireRole(ctx, auth.RoleAdmin); err != nil {
+		return models.User{}, err
+	}
+
+	if !auth.ValidRole(role) {
+		return models.User{}, utils.NewError("invalid role: "+role, utils.BadRequest)
+	}
+
+	user, err := s.repo.GetUserByName(ctx, normalizeUserName(name))
+	if err != nil {
+		return models.User{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	user.Role = role
+	if err = s.repo.EditUser(ctx, user); err != nil {
+		return models.User{}, fmt.Errorf("repo failed to edit user: %w", err)
+	}
+
+	if user, err = s.repo.GetUser(ctx, user.UserID); err != nil {
+		return models.User{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (s *service) startSession(ctx context.Context, userID string) (models.Session, error) {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return models.Session{}, utils.NewError(err.Error(), utils.Internal)
+	}
+
+	expiresAt, err := s.repo.CreateSession(ctx, hash, userID, sessionTTL)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to create session: %w", err)
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("repo failed to get user: %w", err)
+	}
+
+	return models.Session{Token: token, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// currentUser returns the authenticated user of the context
+func currentUser(ctx context.Context) (models.User, error) {
+	user, ok := auth.ExtractUser(ctx)
+	if !ok {
+		return models.User{}, utils.NewError("authentication required", utils.Unauthorized)
+	}
+
+	return user, nil
+}
+
+func normalizeUserName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return "", utils.NewError(
+			fmt.Sprintf("password must be %d to %d characters long", minPasswordLen, maxPasswordLen), utils.BadRequest)
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return "", utils.NewError(err.Error(), utils.Internal)
+	}
+
+	return hash, nil
+}
+
+func validateProfile(profile *models.UserProfile) error {
+	profile.DisplayName, profile.Email = strings.TrimSpace(profile.DisplayName), strings.TrimSpace(profile.Email)
+
+	if len(profile.DisplayName) > 255 {
+		return utils.NewError("display name must be at most 255 characters long", utils.BadRequest)
+	}
+
+	if profile.Email != "" {
+		addr, err := mail.ParseAddress(profile.Email)
+		if err != nil || addr.Address != profile.Email || len(profile.Email) > 255 {
+			return utils.NewError("invalid email", utils.BadRequest)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package tags
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	id3FlagUnsync         = 0x80
+	id3FlagExtendedHeader = 0x40
+)
+
+// id3Frames maps the frame IDs of ID3v2.2 to the ones of ID3v2.3 and ID3v2.4
+var id3Frames = map[string]string{
+	"TT2": "TIT2",
+	"TP1": "TPE1",
+	"TP2": "TPE2",
+	"TAL": "TALB",
+	"TRK": "TRCK",
+	"TYE": "TYER",
+	"ULT": "USLT",
+}
+
+func readID3v2(r io.Reader) (Tags, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Tags{}, err
+	}
+
+	version, flags := header[3], header[5]
+	if version < 2 || version > 4 {
+		return Tags{}, fmt.Errorf("unsupported ID3v2.%d", version)
+	}
+
+	body, err := readBlock(r, int64(syncsafe(header[6:10])))
+	if err != nil {
+		return Tags{}, err
+	}
+
+	// before ID3v2.4 the unsynchronisation is applied to the whole tag
+	if flags&id3FlagUnsync != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+
+	if flags&id3FlagExtendedHeader != 0 {
+		if version == 2 {
+			return Tags{}, errors.New("compressed ID3v2.2 tags are not supported")
+		}
+		if len(body) < 4 {
+			return Tags{}, errors.New("invalid ID3v2 extended header")
+		}
+
+		size := int(binary.BigEndian.Uint32(body))
+		if version == 3 {
+			size += 4
+		} else {
+			size = syncsafe(body[:4])
+		}
+		if size > len(body) {
+			return Tags{}, errors.New("invalid ID3v2 extended header")
+		}
+		body = body[size:]
+	}
+
+	var (
+		tags        Tags
+		albumArtist string
+	)
+	for len(body) > 0 {
+		id, data, rest, err := nextID3Frame(body, version)
+		if err != nil {
+			return Tags{}, err
+		}
+		if id == "" {
+			break
+		}
+		body = rest
+
+		switch id {
+		case "TIT2":
+			tags.Title = id3Text(data)
+		case "TPE1":
+			tags.Artist = id3Text(data)
+		case "TPE2":
+			albumArtist = id3Text(data)
+		case "TALB":
+			tags.Album = id3Text(data)
+		case "TRCK":
+			tags.setTrack(id3Text(data))
+		case "TYER", "TDRC":
+			if tags.Year == 0 || id == "TDRC" {
+				tags.setDate(id3Text(data))
+			}
+		case "USLT":
+			if tags.Lyrics == "" {
+				tags.Lyrics = id3Lyrics(data)
+			}
+		}
+	}
+
+	if tags.Artist == "" {
+		tags.Artist = albumArtist
+	}
+
+	return tags, nil
+}
+
+// nextID3Frame returns the frame ID and the decoded frame data, an empty ID marks the padding
+func nextID3Frame(body []byte, version byte) (string, []byte, []byte, error) {
+	headerSize := 10
+	if version == 2 {
+		headerSize = 6
+	}
+	if len(body) < headerSize || body[0] == 0 {
+		return "", nil, nil, nil
+	}
+
+	var (
+		id    string
+		size  int
+		flags byte
+	)
+	switch version {
+	case 2:
+		id = string(body[:3])
+		if mapped, ok := id3Frames[id]; ok {
+			id = mapped
+		}
+		size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+	case 3:
+		id = string(body[:4])
+		size = int(binary.BigEndian.Uint32(body[4:8]))
+		flags = body[9]
+	default:
+		id = string(body[:4])
+		size = syncsafe(body[4:8])
+		flags = body[9]
+	}
+
+	if size < 0 || headerSize+size > len(body) {
+		return "", nil, nil, errors.New("invalid ID3v2 frame size")
+	}
+	data, rest := body[headerSize:headerSize+size], body[headerSize+size:]
+
+	data, err := decodeID3Frame(data, version, flags)
+	if err != nil {
+		// broken frames are skipped, the other frames are still useful
+		return id + "?", nil, rest, nil
+	}
+
+	return id, data, rest, nil
+}
+
+// decodeID3Frame removes the grouping, data length, unsynchronisation and compression of the frame data
+func decodeID3Frame(data []byte, version byte, flags byte) ([]byte, error) {
+	var compressed, encrypted bool
+
+	switch version {
+	case 3:
+		compressed, encrypted = flags&0x80 != 0, flags&0x40 != 0
+		if compressed {
+			// decompressed size
+			data = skip(data, 4)
+		}
+		if encrypted {
+			data = skip(data, 1)
+		}
+		if flags&0x20 != 0 {
+			data = skip(data, 1)
+		}
+	case 4:
+		compressed, encrypted = flags&0x08 != 0, flags&0x04 != 0
+		if flags&0x40 != 0 {
+			data = skip(data, 1)
+		}
+		if encrypted {
+			data = skip(data, 1)
+		}
+		if flags&0x01 != 0 {
+			data = skip(data, 4)
+		}
+		if flags&0x02 != 0 {
+			data = removeUnsync(data)
+		}
+	}
+
+	if encrypted {
+		return nil, errors.New("encrypted frame")
+	}
+
+	if compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = zr.Close()
+		}()
+
+		return io.ReadAll(io.LimitReader(zr, maxTagSize))
+	}
+
+	return data, nil
+}
+
+// id3Text decodes the first value of a text frame
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	text, _ := id3String(data[1:], data[0])
+	return strings.TrimSpace(text)
+}
+
+// id3Lyrics decodes the USLT frame: encoding, language, content descriptor and the lyrics
+func id3Lyrics(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+
+	enc := data[0]
+	_, rest := id3String(data[4:], enc)
+	// the lyrics are not required to be terminated but often are
+	lyrics, _ := id3String(rest, enc)
+
+	return strings.TrimSpace(strings.ReplaceAll(lyrics, "\r\n", "\n"))
+}
+
+// id3String decodes a NUL terminated string and returns the data after the terminator
+func id3String(data []byte, enc byte) (string, []byte) {
+	end, width := len(data), 1
+	if enc == 1 || enc == 2 {
+		width = 2
+	}
+
+	for i := 0; i+width <= len(data); i += width {
+		if data[i] == 0 && (width == 1 || data[i+1] == 0) {
+			end = i
+			break
+		}
+	}
+
+	text, _ := decodeID3String(data[:end], enc)
+	return text, skip(data, end+width)
+}
+
+// decodeID3String decodes ISO-8859-1 (0), UTF-16 with BOM (1), UTF-16BE (2) and UTF-8 (3) strings
+func decodeID3String(data []byte, enc byte) (string, error) {
+	switch enc {
+	case 0:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		if enc == 1 && len(data) >= 2 {
+			switch {
+			case data[0] == 0xFF && data[1] == 0xFE:
+				order, data = binary.LittleEndian, data[2:]
+			case data[0] == 0xFE && data[1] == 0xFF:
+				data = data[2:]
+			}
+		}
+
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	case 3:
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("unknown text encoding %d", enc)
+	}
+}
+
+// removeUnsync drops the 0x00 bytes the unsynchronisation scheme inserts after every 0xFF
+func removeUnsync(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		out = append(out, data[i])
+		if data[i] == 0xFF && i+1 < len(data) && data[i+1] == 0x00 {
+			i++
+		}
+	}
+	return out
+}
+
+// readID3v1 reads the fixed size tag at the end of MP3 files without ID3v2 tags
+func readID3v1(r io.ReadSeeker) (Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return Tags{}, ErrUnsupported
+	}
+
+	tag := make([]byte, 128)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return Tags{}, err
+	}
+	if string(tag[:3]) != "TAG" {
+		return Tags{}, ErrUnsupported
+	}
+
+	field := func(b []byte) string {
+		text, _ := id3String(b, 0)
+		return strings.TrimSpace(text)
+	}
+
+	tags := Tags{
+		Title:  field(tag[3:33]),
+		Artist: field(tag[33:63]),
+		Album:  field(tag[63:93]),
+	}
+	tags.setDate(field(tag[93:97]))
+
+	// ID3v1.1 keeps the track number in the last byte of the comment
+	if tag[125] == 0 && tag[126] != 0 {
+		tags.Track = int(tag[126])
+	}
+
+	return tags, nil
+}
+
+// syncsafe decodes the 28 bit integers of ID3v2 that have the high bit of every byte cleared
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+func skip(data []byte, n int) []byte {
+	if n >= len(data) {
+		return nil
+	}
+	return data[n:]
+}
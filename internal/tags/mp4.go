@@ -0,0 +1,174 @@
+package tags
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// mp4Path leads to the iTunes metadata list
+var mp4Path = []string{"moov", "udta", "meta", "ilst"}
+
+func readMP4(r io.ReadSeeker) (Tags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Tags{}, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+
+	for _, name := range mp4Path {
+		size, found, err := findMP4Box(r, name, end)
+		if err != nil {
+			return Tags{}, err
+		}
+		if !found {
+			return Tags{}, nil
+		}
+		end = size
+
+		if name == "meta" {
+			if err = skipMetaHeader(r); err != nil {
+				return Tags{}, err
+			}
+		}
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Tags{}, err
+	}
+
+	ilst, err := readBlock(r, end-pos)
+	if err != nil {
+		return Tags{}, err
+	}
+
+	return parseMP4Items(ilst)
+}
+
+// findMP4Box seeks to the payload of the named box among the boxes before end and returns the payload end
+func findMP4Box(r io.ReadSeeker, name string, end int64) (int64, bool, error) {
+	header := make([]byte, 8)
+	for {
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false, err
+		}
+		if pos+8 > end {
+			return 0, false, nil
+		}
+
+		if _, err = io.ReadFull(r, header); err != nil {
+			return 0, false, err
+		}
+
+		size, headerSize := int64(binary.BigEndian.Uint32(header)), int64(8)
+		switch size {
+		case 0:
+			// the box lasts till the end of its parent
+			size = end - pos
+		case 1:
+			large := make([]byte, 8)
+			if _, err = io.ReadFull(r, large); err != nil {
+				return 0, false, err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+
+		if size < headerSize || pos+size > end {
+			return 0, false, errors.New("invalid mp4 box size")
+		}
+
+		if string(header[4:8]) == name {
+			return pos + size, true, nil
+		}
+
+		if _, err = r.Seek(pos+size, io.SeekStart); err != nil {
+			return 0, false, err
+		}
+	}
+}
+
+// skipMetaHeader skips the version and flags of the meta box, QuickTime files write meta without them
+func skipMetaHeader(r io.ReadSeeker) error {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	offset := int64(-8)
+	if string(header[4:8]) != "hdlr" {
+		offset = -4
+	}
+
+	_, err := r.Seek(offset, io.SeekCurrent)
+	return err
+}
+
+// parseMP4Items reads the items of the ilst box, every item holds its value in a data box
+func parseMP4Items(ilst []byte) (Tags, error) {
+	var (
+		tags        Tags
+		albumArtist string
+	)
+
+	for len(ilst) >= 8 {
+		size := int(binary.BigEndian.Uint32(ilst))
+		if size < 8 || size > len(ilst) {
+			return Tags{}, errors.New("invalid mp4 item size")
+		}
+		name, item := string(ilst[4:8]), ilst[8:size]
+		ilst = ilst[size:]
+
+		value, ok := mp4Data(item)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "\xa9nam":
+			tags.Title = strings.TrimSpace(string(value))
+		case "\xa9ART":
+			tags.Artist = strings.TrimSpace(string(value))
+		case "aART":
+			albumArtist = strings.TrimSpace(string(value))
+		case "\xa9alb":
+			tags.Album = strings.TrimSpace(string(value))
+		case "\xa9day":
+			tags.setDate(string(value))
+		case "\xa9lyr":
+			tags.Lyrics = strings.TrimSpace(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(value)))
+		case "trkn":
+			// reserved, track and total as 16 bit integers
+			if len(value) >= 4 {
+				tags.Track = int(binary.BigEndian.Uint16(value[2:4]))
+			}
+		}
+	}
+
+	if tags.Artist == "" {
+		tags.Artist = albumArtist
+	}
+
+	return tags, nil
+}
+
+// mp4Data returns the value of the first data box, skipping its type and locale
+func mp4Data(item []byte) ([]byte, bool) {
+	for len(item) >= 8 {
+		size := int(binary.BigEndian.Uint32(item))
+		if size < 8 || size > len(item) {
+			return nil, false
+		}
+
+		if string(item[4:8]) == "data" && size >= 16 {
+			return item[16:size], true
+		}
+		item = item[size:]
+	}
+
+	return nil, false
+}
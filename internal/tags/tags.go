@@ -0,0 +1,104 @@
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tags are the song metadata embedded into an audio file
+type Tags struct {
+	Artist string
+	Title  string
+	Album  string
+	Track  int
+	Year   int
+	// Date is the full release date if the file has it, otherwise the first day of Year
+	Date time.Time
+	// Lyrics are the unsynchronised lyrics
+	Lyrics string
+}
+
+var ErrUnsupported = errors.New("unsupported audio format")
+
+const (
+	// maxTagSize limits the metadata read into memory, embedded cover art makes tags large
+	maxTagSize = 64 << 20
+)
+
+// Read detects the container by its signature and reads the tags of ID3v2 and ID3v1 (MP3), FLAC, Ogg Vorbis,
+// Opus and MP4 (M4A) files
+func Read(r io.ReadSeeker) (Tags, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Tags{}, err
+	}
+	header = header[:n]
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return readID3v2(r)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return readFLAC(r)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return readOgg(r)
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return readMP4(r)
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync
+		return readID3v1(r)
+	default:
+		return Tags{}, ErrUnsupported
+	}
+}
+
+// setDate parses dates like "2009", "2009-09-07" or "2009-09-07T12:00:00Z"
+func (t *Tags) setDate(s string) {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 {
+		return
+	}
+
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return
+	}
+
+	t.Year = year
+	t.Date = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if len(s) >= 10 {
+		if date, err := time.Parse(time.DateOnly, s[:10]); err == nil {
+			t.Date = date
+		}
+	}
+}
+
+// setTrack parses track numbers like "3" or "3/12"
+func (t *Tags) setTrack(s string) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "/")
+	if track, err := strconv.Atoi(s); err == nil {
+		t.Track = track
+	}
+}
+
+// readBlock reads n bytes refusing sizes over maxTagSize
+func readBlock(r io.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > maxTagSize {
+		return nil, errors.New("tag is too large")
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,299 @@
+package tags
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestTagsSuite(t *testing.T) {
+	suite.Run(t, new(TagsSuite))
+}
+
+type TagsSuite struct {
+	suite.Suite
+}
+
+func (suite *TagsSuite) TestID3v23() {
+	lyrics := append([]byte{1}, []byte("eng")...)
+	lyrics = append(lyrics, utf16String("")...)
+	lyrics = append(lyrics, utf16String("Ooh baby\r\ndon't you know")...)
+
+	body := bytes.Join([][]byte{
+		id3Frame(3, "TIT2", append([]byte{1}, utf16String("Supermassive Black Hole")...), 0),
+		id3Frame(3, "TPE2", append([]byte{0}, "Album Artist"...), 0),
+		id3Frame(3, "TPE1", append([]byte{0}, "Muse"...), 0),
+		id3Frame(3, "TALB", append([]byte{0}, "Black Holes and Revelations"...), 0),
+		id3Frame(3, "TRCK", append([]byte{0}, "3/12"...), 0),
+		id3Frame(3, "TYER", append([]byte{0}, "2006"...), 0),
+		id3Frame(3, "USLT", lyrics, 0),
+		id3Frame(3, "TCOM", append([]byte{0}, "compressed"...), 0x80),
+		make([]byte, 32),
+	}, nil)
+
+	tags, err := Read(bytes.NewReader(append(id3Header(3, body), mpegFrame()...)))
+	suite.Require().NoError(err)
+	suite.Equal(Tags{
+		Artist: "Muse",
+		Title:  "Supermassive Black Hole",
+		Album:  "Black Holes and Revelations",
+		Track:  3,
+		Year:   2006,
+		Date:   time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Lyrics: "Ooh baby\ndon't you know",
+	}, tags)
+}
+
+func (suite *TagsSuite) TestID3v24() {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	_, _ = zw.Write(append([]byte{3}, "Björk"...))
+	_ = zw.Close()
+
+	// data length indicator and zlib compression
+	artist := append(binary.BigEndian.AppendUint32(nil, 6), compressed.Bytes()...)
+
+	body := bytes.Join([][]byte{
+		id3Frame(4, "TIT2", append([]byte{3}, "Jóga"...), 0),
+		id3Frame(4, "TPE1", artist, 0x09),
+		id3Frame(4, "TDRC", append([]byte{3}, "1997-09-22"...), 0),
+		id3Frame(4, "TRCK", append([]byte{3}, "2"...), 0),
+	}, nil)
+
+	tags, err := Read(bytes.NewReader(id3Header(4, body)))
+	suite.Require().NoError(err)
+	suite.Equal(Tags{
+		Artist: "Björk",
+		Title:  "Jóga",
+		Track:  2,
+		Year:   1997,
+		Date:   time.Date(1997, time.September, 22, 0, 0, 0, 0, time.UTC),
+	}, tags)
+}
+
+func (suite *TagsSuite) TestID3v22() {
+	frame := func(id, text string) []byte {
+		data := append([]byte{0}, text...)
+		return append([]byte{id[0], id[1], id[2], 0, 0, byte(len(data))}, data...)
+	}
+
+	body := bytes.Join([][]byte{frame("TT2", "Creep"), frame("TP1", "Radiohead"), frame("TYE", "1992")}, nil)
+
+	tags, err := Read(bytes.NewReader(id3Header(2, body)))
+	suite.Require().NoError(err)
+	suite.Equal("Creep", tags.Title)
+	suite.Equal("Radiohead", tags.Artist)
+	suite.Equal(1992, tags.Year)
+}
+
+func (suite *TagsSuite) TestID3v1() {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:], "Paranoid Android")
+	copy(tag[33:], "Radiohead")
+	copy(tag[63:], "OK Computer")
+	copy(tag[93:], "1997")
+	tag[126] = 2
+
+	tags, err := Read(bytes.NewReader(append(mpegFrame(), tag...)))
+	suite.Require().NoError(err)
+	suite.Equal(Tags{
+		Artist: "Radiohead",
+		Title:  "Paranoid Android",
+		Album:  "OK Computer",
+		Track:  2,
+		Year:   1997,
+		Date:   time.Date(1997, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}, tags)
+}
+
+func (suite *TagsSuite) TestFLAC() {
+	comments := vorbisComments("ARTIST=Muse", "title=Uprising", "ALBUM=The Resistance", "TRACKNUMBER=1", "DATE=2009-09-07", "LYRICS=Paranoia is in bloom")
+
+	var file []byte
+	file = append(file, "fLaC"...)
+	// STREAMINFO
+	file = append(file, 0x00, 0, 0, 34)
+	file = append(file, make([]byte, 34)...)
+	// VORBIS_COMMENT, the last block
+	file = append(file, 0x84, byte(len(comments)>>16), byte(len(comments)>>8), byte(len(comments)))
+	file = append(file, comments...)
+
+	tags, err := Read(bytes.NewReader(file))
+	suite.Require().NoError(err)
+	suite.Equal(Tags{
+		Artist: "Muse",
+		Title:  "Uprising",
+		Album:  "The Resistance",
+		Track:  1,
+		Year:   2009,
+		Date:   time.Date(2009, time.September, 7, 0, 0, 0, 0, time.UTC),
+		Lyrics: "Paranoia is in bloom",
+	}, tags)
+}
+
+func (suite *TagsSuite) TestOgg() {
+	tests := []struct {
+		name   string
+		header []byte
+		prefix string
+	}{
+		{name: "vorbis", header: append([]byte("\x01vorbis"), make([]byte, 23)...), prefix: "\x03vorbis"},
+		{name: "opus", header: append([]byte("OpusHead"), make([]byte, 11)...), prefix: "OpusTags"},
+	}
+
+	for _, tc := range tests {
+		suite.Run(tc.name, func() {
+			// the padding makes the comment packet span two pages
+			padding := "DESCRIPTION=" + string(bytes.Repeat([]byte("x"), 70000))
+			comments := append([]byte(tc.prefix), vorbisComments("ALBUMARTIST=Daft Punk", "TITLE=One More Time", "YEAR=2000", padding)...)
+
+			var file []byte
+			file = append(file, oggPage(1, tc.header)...)
+			file = append(file, oggPage(2, []byte("other stream"))...)
+			file = append(file, oggPages(1, comments)...)
+
+			tags, err := Read(bytes.NewReader(file))
+			suite.Require().NoError(err)
+			suite.Equal("Daft Punk", tags.Artist)
+			suite.Equal("One More Time", tags.Title)
+			suite.Equal(2000, tags.Year)
+		})
+	}
+}
+
+func (suite *TagsSuite) TestMP4() {
+	data := func(value []byte) []byte {
+		return mp4Box("data", append(make([]byte, 8), value...))
+	}
+
+	ilst := mp4Box("ilst", bytes.Join([][]byte{
+		mp4Box("\xa9nam", data([]byte("Hey Ya!"))),
+		mp4Box("\xa9ART", data([]byte("OutKast"))),
+		mp4Box("\xa9alb", data([]byte("Speakerboxxx/The Love Below"))),
+		mp4Box("\xa9day", data([]byte("2003-08-25T07:00:00Z"))),
+		mp4Box("trkn", data([]byte{0, 0, 0, 9, 0, 21, 0, 0})),
+		mp4Box("\xa9lyr", data([]byte("One, two, three, uh\rMy baby don't mess around"))),
+	}, nil))
+
+	meta := mp4Box("meta", append(append(make([]byte, 4), mp4Box("hdlr", make([]byte, 25))...), ilst...))
+	moov := mp4Box("moov", append(mp4Box("mvhd", make([]byte, 100)), mp4Box("udta", meta)...))
+
+	file := append(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), mp4Box("mdat", make([]byte, 1000))...)
+	file = append(file, moov...)
+
+	tags, err := Read(bytes.NewReader(file))
+	suite.Require().NoError(err)
+	suite.Equal(Tags{
+		Artist: "OutKast",
+		Title:  "Hey Ya!",
+		Album:  "Speakerboxxx/The Love Below",
+		Track:  9,
+		Year:   2003,
+		Date:   time.Date(2003, time.August, 25, 0, 0, 0, 0, time.UTC),
+		Lyrics: "One, two, three, uh\nMy baby don't mess around",
+	}, tags)
+}
+
+func (suite *TagsSuite) TestUnsupported() {
+	_, err := Read(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE")))
+	suite.Require().ErrorIs(err, ErrUnsupported)
+
+	_, err = Read(bytes.NewReader(nil))
+	suite.Require().ErrorIs(err, ErrUnsupported)
+}
+
+func (suite *TagsSuite) TestBrokenTag() {
+	// the frame size exceeds the tag
+	body := []byte("TIT2\x00\x00\x01\x00\x00\x00title")
+
+	_, err := Read(bytes.NewReader(id3Header(3, body)))
+	suite.Require().Error(err)
+}
+
+func id3Header(version byte, body []byte) []byte {
+	header := []byte{'I', 'D', '3', version, 0, 0}
+	return append(append(header, syncsafeBytes(len(body))...), body...)
+}
+
+func id3Frame(version byte, id string, data []byte, flags byte) []byte {
+	frame := []byte(id)
+	if version == 4 {
+		frame = append(frame, syncsafeBytes(len(data))...)
+	} else {
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+	}
+	return append(append(frame, 0, flags), data...)
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// utf16String encodes a NUL terminated little endian UTF-16 string with BOM
+func utf16String(s string) []byte {
+	out := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = binary.LittleEndian.AppendUint16(out, u)
+	}
+	return append(out, 0, 0)
+}
+
+func mpegFrame() []byte {
+	return append([]byte{0xFF, 0xFB, 0x90, 0x64}, make([]byte, 413)...)
+}
+
+func vorbisComments(comments ...string) []byte {
+	vendor := "test"
+
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	out = append(out, vendor...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(comments)))
+	for _, comment := range comments {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(comment)))
+		out = append(out, comment...)
+	}
+	return out
+}
+
+// oggPages splits the packet into pages of at most 255 segments
+func oggPages(serial uint32, packet []byte) []byte {
+	var out []byte
+	for {
+		n := min(len(packet), 255*255)
+		if n < 255*255 {
+			return append(out, oggPage(serial, packet)...)
+		}
+		out = append(out, oggPage(serial, packet[:n])...)
+		packet = packet[n:]
+	}
+}
+
+// oggPage writes the data as a single page, data shorter than 255*255 bytes ends the packet
+func oggPage(serial uint32, data []byte) []byte {
+	var lacing []byte
+	n := len(data)
+	for n >= 255 && len(lacing) < 255 {
+		lacing = append(lacing, 255)
+		n -= 255
+	}
+	if len(lacing) < 255 {
+		lacing = append(lacing, byte(n))
+	}
+
+	page := append([]byte("OggS"), make([]byte, 10)...)
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = append(page, make([]byte, 8)...)
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	return append(page, data...)
+}
+
+func mp4Box(name string, payload []byte) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+8))
+	return append(append(box, name...), payload...)
+}
@@ -0,0 +1,192 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	flacBlockVorbisComment = 4
+
+	// oggMaxPages limits the pages read while looking for the comment header
+	oggMaxPages = 1024
+)
+
+func readFLAC(r io.Reader) (Tags, error) {
+	if _, err := readBlock(r, 4); err != nil {
+		return Tags{}, err
+	}
+
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return Tags{}, err
+		}
+
+		last, blockType := header[0]&0x80 != 0, header[0]&0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType == flacBlockVorbisComment {
+			block, err := readBlock(r, size)
+			if err != nil {
+				return Tags{}, err
+			}
+			return parseVorbisComments(block)
+		}
+
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return Tags{}, err
+		}
+
+		if last {
+			return Tags{}, nil
+		}
+	}
+}
+
+// readOgg reads the comment header which is the second packet of Vorbis and Opus streams
+func readOgg(r io.Reader) (Tags, error) {
+	var (
+		serial  uint32
+		packet  []byte
+		packets int
+	)
+
+	header := make([]byte, 27)
+	for page := 0; page < oggMaxPages; page++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return Tags{}, err
+		}
+		if string(header[:4]) != "OggS" {
+			return Tags{}, errors.New("invalid ogg page")
+		}
+
+		// pages of other multiplexed streams are skipped
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		if page == 0 {
+			serial = pageSerial
+		}
+
+		lacing, err := readBlock(r, int64(header[26]))
+		if err != nil {
+			return Tags{}, err
+		}
+
+		for _, size := range lacing {
+			segment, err := readBlock(r, int64(size))
+			if err != nil {
+				return Tags{}, err
+			}
+			if pageSerial != serial {
+				continue
+			}
+
+			if packets == 1 {
+				if len(packet)+len(segment) > maxTagSize {
+					return Tags{}, errors.New("tag is too large")
+				}
+				packet = append(packet, segment...)
+			}
+
+			// a segment shorter than 255 bytes ends the packet
+			if size < 255 {
+				if packets == 1 {
+					return parseOggComments(packet)
+				}
+				packets++
+			}
+		}
+	}
+
+	return Tags{}, errors.New("ogg comment header not found")
+}
+
+func parseOggComments(packet []byte) (Tags, error) {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		return parseVorbisComments(packet[7:])
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		return parseVorbisComments(packet[8:])
+	default:
+		return Tags{}, ErrUnsupported
+	}
+}
+
+// parseVorbisComments reads the little endian vendor string and the list of KEY=value comments
+func parseVorbisComments(data []byte) (Tags, error) {
+	next := func() (string, error) {
+		if len(data) < 4 {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		s := string(data[4 : 4+size])
+		data = data[4+size:]
+		return s, nil
+	}
+
+	if _, err := next(); err != nil {
+		return Tags{}, err
+	}
+
+	if len(data) < 4 {
+		return Tags{}, io.ErrUnexpectedEOF
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	var (
+		tags        Tags
+		albumArtist string
+	)
+	for i := uint32(0); i < count; i++ {
+		comment, err := next()
+		if err != nil {
+			return Tags{}, err
+		}
+
+		key, val, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+
+		switch strings.ToUpper(key) {
+		case "ARTIST":
+			if tags.Artist == "" {
+				tags.Artist = val
+			}
+		case "ALBUMARTIST":
+			albumArtist = val
+		case "TITLE":
+			if tags.Title == "" {
+				tags.Title = val
+			}
+		case "ALBUM":
+			tags.Album = val
+		case "TRACKNUMBER":
+			tags.setTrack(val)
+		case "DATE", "YEAR":
+			if tags.Year == 0 {
+				tags.setDate(val)
+			}
+		case "LYRICS", "UNSYNCEDLYRICS":
+			if tags.Lyrics == "" {
+				tags.Lyrics = strings.ReplaceAll(val, "\r\n", "\n")
+			}
+		}
+	}
+
+	if tags.Artist == "" {
+		tags.Artist = albumArtist
+	}
+
+	return tags, nil
+}
@@ -20,6 +20,21 @@ const (
 	Internal = iota
 	BadRequest
 	NotFound
+	PreconditionFailed
+	// FailedDependency marks bulk items that were not applied because another item failed
+	FailedDependency
+	// Unauthorized marks requests without valid credentials
+	Unauthorized
+	// Conflict marks resources that already exist, like a taken user name
+	Conflict
+	// Forbidden marks requests of users without the rights for them
+	Forbidden
+	// TooManyRequests marks requests of clients that ran out of their rate limit
+	TooManyRequests
+	// TooLarge marks requests which bodies are over the limit of the route
+	TooLarge
+	// Unavailable marks requests that can not be served yet, like the ones waiting for an index to be built
+	Unavailable
 )
 
 func NewError(msg string, code int) error {
@@ -29,6 +44,21 @@ func NewError(msg string, code int) error {
 	}
 }
 
+// IsNotFound reports whether any error in the chain has the NotFound code
+func IsNotFound(in error) bool {
+	var e *err
+	return errors.As(in, &e) && e.code == NotFound
+}
+
+// ErrorCode returns the code of the first error in the chain that has one, errors without a code are Internal
+func ErrorCode(in error) int {
+	var e *err
+	if errors.As(in, &e) {
+		return e.code
+	}
+	return Internal
+}
+
 func FromErrorToHTTP(ctx context.Context, in error) (int, string) {
 	l := logger.ExtractLogger(ctx)
 
@@ -46,6 +76,22 @@ func FromErrorToHTTP(ctx context.Context, in error) (int, string) {
 		return http.StatusBadRequest, e.msg
 	case NotFound:
 		return http.StatusNotFound, e.msg
+	case PreconditionFailed:
+		return http.StatusPreconditionFailed, e.msg
+	case FailedDependency:
+		return http.StatusFailedDependency, e.msg
+	case Unauthorized:
+		return http.StatusUnauthorized, e.msg
+	case Conflict:
+		return http.StatusConflict, e.msg
+	case Forbidden:
+		return http.StatusForbidden, e.msg
+	case TooManyRequests:
+		return http.StatusTooManyRequests, e.msg
+	case TooLarge:
+		return http.StatusRequestEntityTooLarge, e.msg
+	case Unavailable:
+		return http.StatusServiceUnavailable, e.msg
 	default:
 		l.Error("unknown error code", logger.WithArg("code", e.code))
 		return http.StatusInternalServerError, "internal server error"